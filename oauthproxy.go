@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"embed"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
@@ -22,21 +24,30 @@ import (
 	ipapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/ip"
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	optionsutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options/util"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/pagewriter"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/app/redirect"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authentication/basic"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authorization"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/cookies"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/device"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/extauthz"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/header"
 	proxyhttp "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/http"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/identitytoken"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/version"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/ip"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/middleware"
+	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
 	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions"
+	sessionadmin "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/admin"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/upstream"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
 )
@@ -46,14 +57,19 @@ const (
 	schemeHTTPS     = "https"
 	applicationJSON = "application/json"
 
-	robotsPath        = "/robots.txt"
-	signInPath        = "/sign_in"
-	signOutPath       = "/sign_out"
-	oauthStartPath    = "/start"
-	oauthCallbackPath = "/callback"
-	authOnlyPath      = "/auth"
-	userInfoPath      = "/userinfo"
-	staticPathPrefix  = "/static/"
+	robotsPath            = "/robots.txt"
+	signInPath            = "/sign_in"
+	signOutPath           = "/sign_out"
+	signOutCallbackPath   = "/sign_out/callback"
+	oauthStartPath        = "/start"
+	oauthCallbackPath     = "/callback"
+	backChannelLogoutPath = "/backchannel-logout"
+	authOnlyPath          = "/auth"
+	userInfoPath          = "/userinfo"
+	jwksPath              = "/jwks.json"
+	deviceCodePath        = "/device/code"
+	deviceTokenPath       = "/device/token"
+	staticPathPrefix      = "/static/"
 )
 
 var (
@@ -63,6 +79,11 @@ var (
 	// ErrAccessDenied means the user should receive a 401 Unauthorized response
 	ErrAccessDenied = errors.New("access denied")
 
+	// ErrForbidden means the authenticated user may not access the request
+	// but the session should be kept, the user should receive a 403 Forbidden
+	// response
+	ErrForbidden = errors.New("forbidden")
+
 	//go:embed static/*
 	staticFiles embed.FS
 )
@@ -76,6 +97,8 @@ type allowedRoute struct {
 
 type apiRoute struct {
 	pathRegex *regexp.Regexp
+	// tokenRequirements is nil when the route has no token requirements
+	tokenRequirements authorization.Authorizer
 }
 
 // OAuthProxy is the main authentication proxy
@@ -91,7 +114,11 @@ type OAuthProxy struct {
 	relativeRedirectURL  bool
 	whitelistDomains     []string
 	provider             providers.Provider
+	providers            *providerSet
 	sessionStore         sessionsapi.SessionStore
+	sessionRevoker       sessionsapi.SessionRevoker
+	sessionRefresher     *middleware.BackgroundRefresher
+	identityTokenSigner  *identitytoken.Signer
 	ProxyPrefix          string
 	basicAuthValidator   basic.Validator
 	basicAuthGroups      []string
@@ -108,11 +135,21 @@ type OAuthProxy struct {
 	preAuthChain      alice.Chain
 	pageWriter        pagewriter.Writer
 	server            proxyhttp.Server
-	upstreamProxy     http.Handler
+	upstreamProxy     upstream.Proxy
 	serveMux          *mux.Router
 	redirectValidator redirect.Validator
 	appDirector       redirect.AppDirector
 
+	authorizer           authorization.Authorizer
+	upstreamAuthorizers  map[string]authorization.Authorizer
+	tokenRequirements    map[string]authorization.Authorizer // by upstream ID
+	authorizationWebhook *authorization.Webhook
+
+	tokenExchanger *providers.TokenExchanger
+	tokenExchanges map[string]*options.TokenExchange // by upstream ID
+
+	deviceHandler *device.Handler
+
 	encodeState bool
 }
 
@@ -133,10 +170,11 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		}
 	}
 
-	provider, err := providers.NewProvider(opts.Providers[0])
+	providerSet, err := newProviderSet(opts.Providers)
 	if err != nil {
 		return nil, fmt.Errorf("error initialising provider: %v", err)
 	}
+	provider := providerSet.defaultProvider
 
 	pageWriter, err := pagewriter.NewWriter(pagewriter.Opts{
 		TemplatesPath:    opts.Templates.Path,
@@ -146,6 +184,7 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		Version:          version.VERSION,
 		Debug:            opts.Templates.Debug,
 		ProviderName:     buildProviderName(provider, opts.Providers[0].Name),
+		Providers:        buildSignInProviders(opts.Providers, providerSet),
 		SignInMessage:    buildSignInMessage(opts),
 		DisplayLoginForm: basicAuthValidator != nil && opts.Templates.DisplayLoginForm,
 	})
@@ -158,8 +197,29 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		return nil, fmt.Errorf("error initialising upstream proxy: %v", err)
 	}
 
+	authorizer, err := authorization.NewAuthorizer(&opts.Authorization)
+	if err != nil {
+		return nil, fmt.Errorf("error initialising authorization: %v", err)
+	}
+
+	upstreamAuthorizers, err := buildUpstreamAuthorizers(opts.UpstreamServers)
+	if err != nil {
+		return nil, fmt.Errorf("error initialising upstream authorization: %v", err)
+	}
+
+	var authorizationWebhook *authorization.Webhook
+	if opts.AuthorizationWebhook != nil {
+		authorizationWebhook, err = authorization.NewWebhook(opts.AuthorizationWebhook, opts.GetRealClientIPParser())
+		if err != nil {
+			return nil, fmt.Errorf("error initialising authorization webhook: %v", err)
+		}
+		logger.Printf("Authorization webhook configured: %s", opts.AuthorizationWebhook.URL)
+	}
+
 	if opts.SkipJwtBearerTokens {
-		logger.Printf("Skipping JWT tokens from configured OIDC issuer: %q", opts.Providers[0].OIDCConfig.IssuerURL)
+		for _, providerOpts := range opts.Providers {
+			logger.Printf("Skipping JWT tokens from configured OIDC issuer: %q", providerOpts.OIDCConfig.IssuerURL)
+		}
 		for _, issuer := range opts.ExtraJwtIssuers {
 			logger.Printf("Skipping JWT tokens from extra JWT issuer: %q", issuer)
 		}
@@ -169,7 +229,10 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		redirectURL.Path = fmt.Sprintf("%s/callback", opts.ProxyPrefix)
 	}
 
-	logger.Printf("OAuthProxy configured for %s Client ID: %s", provider.Data().ProviderName, opts.Providers[0].ClientID)
+	for _, providerOpts := range opts.Providers {
+		prov, _ := providerSet.get(providerOpts.ID)
+		logger.Printf("OAuthProxy configured for %s Client ID: %s (provider ID: %s)", prov.Data().ProviderName, providerOpts.ClientID, providerOpts.ID)
+	}
 	refresh := "disabled"
 	if opts.Cookie.Refresh != time.Duration(0) {
 		refresh = fmt.Sprintf("after %s", opts.Cookie.Refresh)
@@ -200,8 +263,41 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 	if err != nil {
 		return nil, fmt.Errorf("could not build pre-auth chain: %v", err)
 	}
-	sessionChain := buildSessionChain(opts, provider, sessionStore, basicAuthValidator)
-	headersChain, err := buildHeadersChain(opts)
+	// Session stores that cannot remove sessions without the session cookie
+	// record revoked sessions in a revocation list that is checked whenever
+	// a session is loaded.
+	var revocationList *sessions.RevocationList
+	sessionRevoker, ok := sessionStore.(sessionsapi.SessionRevoker)
+	if !ok {
+		revocationList = sessions.NewRevocationList(opts.Cookie.Expire)
+		sessionRevoker = revocationList
+	}
+
+	// Sessions in persistent session stores can be refreshed in the
+	// background, the cookie store holds the refreshed session in the cookie
+	var sessionRefresher *middleware.BackgroundRefresher
+	if opts.Session.BackgroundRefreshInterval > 0 && opts.Session.Type != options.CookieSessionStoreType {
+		sessionRefresher = middleware.NewBackgroundRefresher(opts.Cookie.Name, opts.Session.BackgroundRefreshInterval, opts.Session.IdleTimeout)
+	}
+
+	var identityTokenSigner *identitytoken.Signer
+	if opts.IdentityToken != nil {
+		identityTokenSigner, err = identitytoken.NewSigner(opts.IdentityToken)
+		if err != nil {
+			return nil, fmt.Errorf("could not create identity token signer: %v", err)
+		}
+	}
+
+	var deviceHandler *device.Handler
+	if opts.DeviceAuthorization != nil {
+		deviceHandler, err = buildDeviceHandler(opts, sessionStore)
+		if err != nil {
+			return nil, fmt.Errorf("could not create device authorization handler: %v", err)
+		}
+	}
+
+	sessionChain := buildSessionChain(opts, providerSet, sessionStore, revocationList, sessionRefresher, basicAuthValidator)
+	headersChain, err := buildHeadersChain(opts, identityTokenSigner)
 	if err != nil {
 		return nil, fmt.Errorf("could not build headers chain: %v", err)
 	}
@@ -220,7 +316,11 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 
 		ProxyPrefix:          opts.ProxyPrefix,
 		provider:             provider,
+		providers:            providerSet,
 		sessionStore:         sessionStore,
+		sessionRevoker:       sessionRevoker,
+		sessionRefresher:     sessionRefresher,
+		identityTokenSigner:  identityTokenSigner,
 		redirectURL:          redirectURL,
 		relativeRedirectURL:  opts.RelativeRedirectURL,
 		apiRoutes:            apiRoutes,
@@ -234,16 +334,23 @@ func NewOAuthProxy(opts *options.Options, validator func(string) bool) (*OAuthPr
 		allowQuerySemicolons: opts.AllowQuerySemicolons,
 		trustedIPs:           trustedIPs,
 
-		basicAuthValidator: basicAuthValidator,
-		basicAuthGroups:    opts.HtpasswdUserGroups,
-		sessionChain:       sessionChain,
-		headersChain:       headersChain,
-		preAuthChain:       preAuthChain,
-		pageWriter:         pageWriter,
-		upstreamProxy:      upstreamProxy,
-		redirectValidator:  redirectValidator,
-		appDirector:        appDirector,
-		encodeState:        opts.EncodeState,
+		basicAuthValidator:   basicAuthValidator,
+		basicAuthGroups:      opts.HtpasswdUserGroups,
+		sessionChain:         sessionChain,
+		headersChain:         headersChain,
+		preAuthChain:         preAuthChain,
+		pageWriter:           pageWriter,
+		upstreamProxy:        upstreamProxy,
+		authorizer:           authorizer,
+		upstreamAuthorizers:  upstreamAuthorizers,
+		tokenRequirements:    buildUpstreamTokenRequirements(opts.UpstreamServers),
+		authorizationWebhook: authorizationWebhook,
+		tokenExchanger:       providers.NewTokenExchanger(),
+		tokenExchanges:       buildUpstreamTokenExchanges(opts.UpstreamServers),
+		deviceHandler:        deviceHandler,
+		redirectValidator:    redirectValidator,
+		appDirector:          appDirector,
+		encodeState:          opts.EncodeState,
 	}
 	p.buildServeMux(opts.ProxyPrefix)
 
@@ -271,7 +378,23 @@ func (p *OAuthProxy) Start() error {
 		cancel() // cancel the context
 	}()
 
-	return p.server.Start(ctx)
+	if p.sessionRefresher != nil {
+		go p.sessionRefresher.Run(ctx)
+	}
+	if p.identityTokenSigner != nil {
+		go p.identityTokenSigner.Run(ctx)
+	}
+
+	err := p.server.Start(ctx)
+
+	// Give the session store the chance to release its resources, or save
+	// its sessions, once the server has stopped
+	if closer, ok := p.sessionStore.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil {
+			logger.Errorf("error closing the session store: %v", closeErr)
+		}
+	}
+	return err
 }
 
 func (p *OAuthProxy) setupServer(opts *options.Options) error {
@@ -302,10 +425,109 @@ func (p *OAuthProxy) setupServer(opts *options.Options) error {
 		return fmt.Errorf("could not build metrics server: %v", err)
 	}
 
-	p.server = proxyhttp.NewServerGroup(appServer, metricsServer)
+	servers := []proxyhttp.Server{appServer, metricsServer}
+
+	if isServerEnabled(opts.ExtAuthzServer) {
+		extAuthzServer, err := proxyhttp.NewGRPCServer(proxyhttp.GRPCOpts{
+			Register:          extauthz.Register(p.buildExtAuthzHandler(opts)),
+			BindAddress:       opts.ExtAuthzServer.BindAddress,
+			SecureBindAddress: opts.ExtAuthzServer.SecureBindAddress,
+			TLS:               opts.ExtAuthzServer.TLS,
+		})
+		if err != nil {
+			return fmt.Errorf("could not build ext_authz server: %v", err)
+		}
+		servers = append(servers, extAuthzServer)
+	}
+
+	if opts.SessionAdmin != nil && isServerEnabled(opts.SessionAdmin.Server) {
+		sessionAdminServer, err := p.buildSessionAdminServer(opts.SessionAdmin)
+		if err != nil {
+			return fmt.Errorf("could not build session admin server: %v", err)
+		}
+		servers = append(servers, sessionAdminServer)
+	}
+
+	p.server = proxyhttp.NewServerGroup(servers...)
 	return nil
 }
 
+// buildExtAuthzHandler builds the handler for requests received by the
+// ext_authz server. The requests do not pass through the serve mux, so the
+// request scope is created here before the session is loaded.
+func (p *OAuthProxy) buildExtAuthzHandler(opts *options.Options) http.Handler {
+	return alice.New(
+		middleware.NewScope(opts.ReverseProxy, opts.Logging.RequestIDHeader),
+		middleware.NewClientIP(opts.GetRealClientIPParser()),
+		middleware.NewRequestLogger(),
+	).Extend(p.sessionChain).ThenFunc(p.ExtAuthz)
+}
+
+// buildSessionAdminServer builds the server for the session admin API.
+// The session store must be able to find the sessions of a user.
+func (p *OAuthProxy) buildSessionAdminServer(opts *options.SessionAdmin) (proxyhttp.Server, error) {
+	administrator, ok := p.sessionStore.(sessionsapi.SessionAdministrator)
+	if !ok {
+		return nil, errors.New("the session store does not support session administration")
+	}
+	token, err := optionsutil.GetSecretValue(opts.Token)
+	if err != nil {
+		return nil, fmt.Errorf("could not load token: %v", err)
+	}
+
+	return proxyhttp.NewServer(proxyhttp.Opts{
+		Handler:           sessionadmin.NewHandler(administrator, string(token), p.provider.Data().ID),
+		BindAddress:       opts.Server.BindAddress,
+		SecureBindAddress: opts.Server.SecureBindAddress,
+		TLS:               opts.Server.TLS,
+	})
+}
+
+// buildDeviceHandler builds the handler of the device authorization grant.
+// Pending requests are kept in the persistent session store, which also
+// issues the bearer tokens of approved requests.
+func buildDeviceHandler(opts *options.Options, sessionStore sessionsapi.SessionStore) (*device.Handler, error) {
+	manager, ok := sessionStore.(*persistence.Manager)
+	if !ok {
+		return nil, errors.New("the session store does not support the device authorization grant")
+	}
+	maxPending := opts.DeviceAuthorization.MaxPendingRequests
+	if maxPending == 0 {
+		maxPending = options.DefaultDeviceMaxPendingRequests
+	}
+	store, err := device.NewStore(manager.Store, opts.Cookie.Name, opts.Cookie.Secret, maxPending)
+	if err != nil {
+		return nil, err
+	}
+
+	codeLifetime := options.DefaultDeviceCodeLifetime
+	if opts.DeviceAuthorization.CodeLifetime != nil {
+		codeLifetime = opts.DeviceAuthorization.CodeLifetime.Duration()
+	}
+	pollInterval := options.DefaultDevicePollInterval
+	if opts.DeviceAuthorization.PollInterval != nil {
+		pollInterval = opts.DeviceAuthorization.PollInterval.Duration()
+	}
+
+	return device.NewHandler(device.Opts{
+		Store:         store,
+		Issuer:        manager,
+		ClientIDs:     opts.DeviceAuthorization.ClientIDs,
+		ProxyPrefix:   opts.ProxyPrefix,
+		ForceHTTPS:    opts.Cookie.Secure,
+		CodeLifetime:  codeLifetime,
+		PollInterval:  pollInterval,
+		TokenLifetime: opts.Cookie.Expire,
+		CSRFSecret:    opts.Cookie.Secret,
+	}), nil
+}
+
+// isServerEnabled returns true when either of the server bind addresses is set.
+func isServerEnabled(server options.Server) bool {
+	enabled := func(addr string) bool { return addr != "" && addr != "-" }
+	return enabled(server.BindAddress) || enabled(server.SecureBindAddress)
+}
+
 func (p *OAuthProxy) buildServeMux(proxyPrefix string) {
 	// Use the encoded path here so we can have the option to pass it on in the upstream mux.
 	// Otherwise something like /%2F/ would be redirected to / here already.
@@ -337,6 +559,8 @@ func (p *OAuthProxy) buildProxySubrouter(s *mux.Router) {
 	s.Path(signInPath).HandlerFunc(p.SignIn)
 	s.Path(oauthStartPath).HandlerFunc(p.OAuthStart)
 	s.Path(oauthCallbackPath).HandlerFunc(p.OAuthCallback)
+	s.Path(backChannelLogoutPath).HandlerFunc(p.BackChannelLogout)
+	s.Path(signOutCallbackPath).HandlerFunc(p.SignOutCallback)
 
 	// Static file paths
 	s.PathPrefix(staticPathPrefix).Handler(http.StripPrefix(p.ProxyPrefix, http.FileServer(http.FS(staticFiles))))
@@ -344,13 +568,29 @@ func (p *OAuthProxy) buildProxySubrouter(s *mux.Router) {
 	// The userinfo and logout endpoints needs to load sessions before handling the request
 	s.Path(userInfoPath).Handler(p.sessionChain.ThenFunc(p.UserInfo))
 	s.Path(signOutPath).Handler(p.sessionChain.ThenFunc(p.SignOut))
+
+	// Upstreams verify the identity tokens with the published keys
+	if p.identityTokenSigner != nil {
+		s.Path(jwksPath).HandlerFunc(p.identityTokenSigner.ServeJWKS)
+	}
+
+	// Clients of the device authorization grant have no session yet, users
+	// approve their requests with their session on the verification page
+	if p.deviceHandler != nil {
+		s.Path(deviceCodePath).HandlerFunc(p.deviceHandler.ServeCode)
+		s.Path(deviceTokenPath).HandlerFunc(p.deviceHandler.ServeToken)
+		s.Path(device.VerificationPath).Handler(p.sessionChain.ThenFunc(p.DeviceVerification))
+	}
 }
 
 // buildPreAuthChain constructs a chain that should process every request before
 // the OAuth2 Proxy authentication logic kicks in.
 // For example forcing HTTPS or health checks.
 func buildPreAuthChain(opts *options.Options, sessionStore sessionsapi.SessionStore) (alice.Chain, error) {
-	chain := alice.New(middleware.NewScope(opts.ReverseProxy, opts.Logging.RequestIDHeader))
+	chain := alice.New(
+		middleware.NewScope(opts.ReverseProxy, opts.Logging.RequestIDHeader),
+		middleware.NewClientIP(opts.GetRealClientIPParser()),
+	)
 
 	if opts.ForceHTTPS {
 		_, httpsPort, err := net.SplitHostPort(opts.Server.SecureBindAddress)
@@ -389,12 +629,18 @@ func buildPreAuthChain(opts *options.Options, sessionStore sessionsapi.SessionSt
 	return chain, nil
 }
 
-func buildSessionChain(opts *options.Options, provider providers.Provider, sessionStore sessionsapi.SessionStore, validator basic.Validator) alice.Chain {
+func buildSessionChain(opts *options.Options, providerSet *providerSet, sessionStore sessionsapi.SessionStore, revocationList *sessions.RevocationList, refresher *middleware.BackgroundRefresher, validator basic.Validator) alice.Chain {
 	chain := alice.New()
 
 	if opts.SkipJwtBearerTokens {
-		sessionLoaders := []middlewareapi.TokenToSessionFunc{
-			provider.CreateSessionFromToken,
+		sessionLoaders := []middlewareapi.TokenToSessionFunc{}
+		introspectionLoaders := []middlewareapi.TokenToSessionFunc{}
+		for _, provider := range providerSet.providers {
+			sessionLoaders = append(sessionLoaders, createSessionFromToken(provider))
+			if provider.Data().IntrospectBearerTokens {
+				introspectionLoaders = append(introspectionLoaders,
+					providers.NewTokenIntrospector(provider.Data()).CreateSessionFromToken)
+			}
 		}
 
 		for _, verifier := range opts.GetJWTBearerVerifiers() {
@@ -402,30 +648,46 @@ func buildSessionChain(opts *options.Options, provider providers.Provider, sessi
 				middlewareapi.CreateTokenToSessionFunc(verifier.Verify))
 		}
 
-		chain = chain.Append(middleware.NewJwtSessionLoader(sessionLoaders))
+		chain = chain.Append(middleware.NewJwtSessionLoaderWithIntrospection(sessionLoaders, introspectionLoaders))
 	}
 
 	if validator != nil {
 		chain = chain.Append(middleware.NewBasicAuthSessionLoader(validator, opts.HtpasswdUserGroups, opts.LegacyPreferEmailToUser))
 	}
 
-	chain = chain.Append(middleware.NewStoredSessionLoader(&middleware.StoredSessionLoaderOptions{
-		SessionStore:    sessionStore,
-		RefreshPeriod:   opts.Cookie.Refresh,
-		RefreshSession:  provider.RefreshSession,
-		ValidateSession: provider.ValidateSession,
-	}))
+	loaderOpts := &middleware.StoredSessionLoaderOptions{
+		SessionStore:        sessionStore,
+		RefreshPeriod:       opts.Cookie.Refresh,
+		RefreshSession:      providerSet.refreshSession,
+		ValidateSession:     providerSet.validateSession,
+		IdleTimeout:         opts.Session.IdleTimeout,
+		MaxLifetime:         opts.Session.MaxLifetime,
+		ActivityInterval:    opts.Session.ActivityInterval,
+		RefreshSkew:         opts.Session.RefreshSkew,
+		BackgroundRefresher: refresher,
+	}
+	if revocationList != nil {
+		loaderOpts.IsRevoked = revocationList.IsRevoked
+	}
+	chain = chain.Append(middleware.NewStoredSessionLoader(loaderOpts))
 
 	return chain
 }
 
-func buildHeadersChain(opts *options.Options) (alice.Chain, error) {
-	requestInjector, err := middleware.NewRequestHeaderInjector(opts.InjectRequestHeaders)
+func buildHeadersChain(opts *options.Options, identityTokenSigner *identitytoken.Signer) (alice.Chain, error) {
+	// Avoid passing a typed nil, headers sourced from the identity token
+	// require a signer
+	var signer header.TokenSigner
+	if identityTokenSigner != nil {
+		signer = identityTokenSigner
+	}
+
+	requestInjector, err := middleware.NewRequestHeaderInjector(opts.InjectRequestHeaders, signer)
 	if err != nil {
 		return alice.Chain{}, fmt.Errorf("error constructing request header injector: %v", err)
 	}
 
-	responseInjector, err := middleware.NewResponseHeaderInjector(opts.InjectResponseHeaders)
+	responseInjector, err := middleware.NewResponseHeaderInjector(opts.InjectResponseHeaders, signer)
 	if err != nil {
 		return alice.Chain{}, fmt.Errorf("error constructing request header injector: %v", err)
 	}
@@ -458,6 +720,100 @@ func buildProviderName(p providers.Provider, override string) string {
 	return p.Data().ProviderName
 }
 
+// buildSignInProviders builds the list of providers to offer on the sign-in
+// page. When only a single provider is configured, no list is needed as the
+// sign-in page falls back to the default provider name.
+func buildSignInProviders(providerOpts options.Providers, providerSet *providerSet) []pagewriter.SignInProvider {
+	if len(providerOpts) < 2 {
+		return nil
+	}
+
+	signInProviders := make([]pagewriter.SignInProvider, 0, len(providerOpts))
+	for _, opts := range providerOpts {
+		provider, _ := providerSet.get(opts.ID)
+		signInProviders = append(signInProviders, pagewriter.SignInProvider{
+			ID:   opts.ID,
+			Name: buildProviderName(provider, opts.Name),
+		})
+	}
+	return signInProviders
+}
+
+// providerSet holds all of the configured providers.
+// The first configured provider is the default and is used whenever a request
+// or session does not identify a provider.
+type providerSet struct {
+	defaultProvider providers.Provider
+	providers       []providers.Provider
+	byID            map[string]providers.Provider
+}
+
+// newProviderSet initialises a provider for each of the provider options given
+func newProviderSet(providerOpts options.Providers) (*providerSet, error) {
+	if len(providerOpts) == 0 {
+		return nil, errors.New("at least one provider has to be defined")
+	}
+
+	set := &providerSet{
+		providers: make([]providers.Provider, 0, len(providerOpts)),
+		byID:      make(map[string]providers.Provider, len(providerOpts)),
+	}
+	for _, opts := range providerOpts {
+		provider, err := providers.NewProvider(opts)
+		if err != nil {
+			return nil, fmt.Errorf("provider %q: %v", opts.ID, err)
+		}
+		set.providers = append(set.providers, provider)
+		set.byID[opts.ID] = provider
+	}
+	set.defaultProvider = set.providers[0]
+
+	return set, nil
+}
+
+// get returns the provider with the given ID.
+// An empty ID returns the default provider.
+func (s *providerSet) get(id string) (providers.Provider, bool) {
+	if id == "" {
+		return s.defaultProvider, true
+	}
+	provider, ok := s.byID[id]
+	return provider, ok
+}
+
+// refreshSession refreshes the session with the provider that issued it
+func (s *providerSet) refreshSession(ctx context.Context, session *sessionsapi.SessionState) (bool, error) {
+	provider, ok := s.get(session.ProviderID)
+	if !ok {
+		return false, fmt.Errorf("unknown provider %q", session.ProviderID)
+	}
+	return provider.RefreshSession(ctx, session)
+}
+
+// validateSession validates the session with the provider that issued it.
+// Sessions issued by a provider that is no longer configured are invalid.
+func (s *providerSet) validateSession(ctx context.Context, session *sessionsapi.SessionState) bool {
+	provider, ok := s.get(session.ProviderID)
+	if !ok {
+		logger.Errorf("Session was issued by unknown provider %q", session.ProviderID)
+		return false
+	}
+	return provider.ValidateSession(ctx, session)
+}
+
+// createSessionFromToken wraps the provider's bearer token session loader
+// so that the resulting session is attributed to the provider.
+func createSessionFromToken(provider providers.Provider) middlewareapi.TokenToSessionFunc {
+	return func(ctx context.Context, token string) (*sessionsapi.SessionState, error) {
+		session, err := provider.CreateSessionFromToken(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		session.ProviderID = provider.Data().ID
+		return session, nil
+	}
+}
+
 // buildRoutesAllowlist builds an []allowedRoute  list from either the legacy
 // SkipAuthRegex option (paths only support) or newer SkipAuthRoutes option
 // (method=path support)
@@ -507,9 +863,63 @@ func buildRoutesAllowlist(opts *options.Options) ([]allowedRoute, error) {
 	return routes, nil
 }
 
-// buildAPIRoutes builds an []apiRoute from ApiRoutes option
+// buildUpstreamAuthorizers builds an Authorizer for each upstream that
+// defines authorization rules, keyed by the upstream ID.
+func buildUpstreamAuthorizers(upstreams options.UpstreamConfig) (map[string]authorization.Authorizer, error) {
+	authorizers := make(map[string]authorization.Authorizer)
+
+	for _, u := range upstreams.Upstreams {
+		if u.Authorization == nil {
+			continue
+		}
+
+		authorizer, err := authorization.NewAuthorizer(u.Authorization)
+		if err != nil {
+			return nil, fmt.Errorf("upstream %q: %v", u.ID, err)
+		}
+		logger.Printf("Authorization rules configured for upstream: %s", u.ID)
+		authorizers[u.ID] = authorizer
+	}
+
+	return authorizers, nil
+}
+
+// buildUpstreamTokenRequirements builds the token requirements of each
+// upstream that has them, keyed by upstream ID
+func buildUpstreamTokenRequirements(upstreams options.UpstreamConfig) map[string]authorization.Authorizer {
+	requirements := make(map[string]authorization.Authorizer)
+
+	for _, u := range upstreams.Upstreams {
+		if u.TokenRequirements == nil {
+			continue
+		}
+		logger.Printf("Token requirements configured for upstream: %s", u.ID)
+		requirements[u.ID] = authorization.NewTokenRequirementsAuthorizer(u.TokenRequirements)
+	}
+
+	return requirements
+}
+
+// buildUpstreamTokenExchanges builds the token exchanges of the upstreams,
+// keyed by upstream ID
+func buildUpstreamTokenExchanges(upstreams options.UpstreamConfig) map[string]*options.TokenExchange {
+	exchanges := make(map[string]*options.TokenExchange)
+
+	for _, u := range upstreams.Upstreams {
+		if u.TokenExchange == nil {
+			continue
+		}
+		logger.Printf("Token exchange configured for upstream: %s", u.ID)
+		exchanges[u.ID] = u.TokenExchange
+	}
+
+	return exchanges
+}
+
+// buildAPIRoutes builds an []apiRoute from the ApiRoutes and APIRouteConfig
+// options
 func buildAPIRoutes(opts *options.Options) ([]apiRoute, error) {
-	routes := make([]apiRoute, 0, len(opts.APIRoutes))
+	routes := make([]apiRoute, 0, len(opts.APIRoutes)+len(opts.APIRouteConfig))
 
 	for _, path := range opts.APIRoutes {
 		compiledRegex, err := regexp.Compile(path)
@@ -522,6 +932,21 @@ func buildAPIRoutes(opts *options.Options) ([]apiRoute, error) {
 		})
 	}
 
+	for _, route := range opts.APIRouteConfig {
+		compiledRegex, err := regexp.Compile(route.Path)
+		if err != nil {
+			return nil, err
+		}
+		r := apiRoute{pathRegex: compiledRegex}
+		if route.TokenRequirements != nil {
+			logger.Printf("API route - Path: %s | Scopes: %v | Audiences: %v", route.Path, route.TokenRequirements.Scopes, route.TokenRequirements.Audiences)
+			r.tokenRequirements = authorization.NewTokenRequirementsAuthorizer(route.TokenRequirements)
+		} else {
+			logger.Printf("API route - Path: %s", route.Path)
+		}
+		routes = append(routes, r)
+	}
+
 	return routes, nil
 }
 
@@ -536,8 +961,28 @@ func (p *OAuthProxy) LoadCookiedSession(req *http.Request) (*sessionsapi.Session
 	return p.sessionStore.Load(req)
 }
 
+// getProvider returns the provider with the given ID.
+// An empty ID refers to the default provider.
+func (p *OAuthProxy) getProvider(id string) (providers.Provider, error) {
+	if id == "" || id == p.provider.Data().ID {
+		return p.provider, nil
+	}
+	if provider, ok := p.providers.get(id); ok {
+		return provider, nil
+	}
+	return nil, fmt.Errorf("unknown provider %q", id)
+}
+
+// getSessionProvider returns the provider that issued the session
+func (p *OAuthProxy) getSessionProvider(s *sessionsapi.SessionState) (providers.Provider, error) {
+	return p.getProvider(s.ProviderID)
+}
+
 // SaveSession creates a new session cookie value and sets this on the response
 func (p *OAuthProxy) SaveSession(rw http.ResponseWriter, req *http.Request, s *sessionsapi.SessionState) error {
+	if s.IssuedAt == nil {
+		s.IssuedAtNow()
+	}
 	return p.sessionStore.Save(rw, req, s)
 }
 
@@ -718,15 +1163,17 @@ func (p *OAuthProxy) UserInfo(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	userInfo := struct {
-		User              string   `json:"user"`
-		Email             string   `json:"email"`
-		Groups            []string `json:"groups,omitempty"`
-		PreferredUsername string   `json:"preferredUsername,omitempty"`
+		User              string              `json:"user"`
+		Email             string              `json:"email"`
+		Groups            []string            `json:"groups,omitempty"`
+		PreferredUsername string              `json:"preferredUsername,omitempty"`
+		Claims            map[string][]string `json:"claims,omitempty"`
 	}{
 		User:              session.User,
 		Email:             session.Email,
 		Groups:            session.Groups,
 		PreferredUsername: session.PreferredUsername,
+		Claims:            session.Claims,
 	}
 
 	if err := json.NewEncoder(rw).Encode(userInfo); err != nil {
@@ -752,9 +1199,103 @@ func (p *OAuthProxy) SignOut(rw http.ResponseWriter, req *http.Request) {
 
 	p.backendLogout(rw, req)
 
+	if endSessionURL := p.rpInitiatedLogoutURL(rw, req, redirect); endSessionURL != "" {
+		http.Redirect(rw, req, endSessionURL, http.StatusFound)
+		return
+	}
+
 	http.Redirect(rw, req, redirect, http.StatusFound)
 }
 
+// rpInitiatedLogoutURL returns the provider's end session URL when the
+// session's provider has RP-initiated logout enabled. The post logout
+// redirect is stored in a signed cookie with the state so that the browser
+// can be sent on to it once the provider returns it to the sign out callback.
+// An empty string is returned when the provider session should not be ended.
+func (p *OAuthProxy) rpInitiatedLogoutURL(rw http.ResponseWriter, req *http.Request, redirect string) string {
+	session := middlewareapi.GetRequestScope(req).Session
+	if session == nil {
+		return ""
+	}
+
+	provider, err := p.getSessionProvider(session)
+	if err != nil {
+		logger.Errorf("error getting provider during RP-initiated logout: %v", err)
+		return ""
+	}
+	providerData := provider.Data()
+	if !providerData.RPInitiatedLogout || providerData.EndSessionURL == nil || providerData.EndSessionURL.Host == "" {
+		return ""
+	}
+
+	nonce, err := encryption.Nonce(32)
+	if err != nil {
+		logger.Errorf("error generating RP-initiated logout state: %v", err)
+		return ""
+	}
+	state := base64.RawURLEncoding.EncodeToString(nonce)
+
+	cookieName := p.signOutCookieName()
+	value, err := encryption.SignedValue(p.CookieOptions.Secret, cookieName, []byte(state+"|"+redirect), time.Now())
+	if err != nil {
+		logger.Errorf("error signing RP-initiated logout cookie: %v", err)
+		return ""
+	}
+	http.SetCookie(rw, cookies.MakeCookieFromOptions(req, cookieName, value, p.CookieOptions, p.CookieOptions.CSRFExpire))
+
+	// Providers require an absolute post logout redirect URI, even when the
+	// OAuth redirect URL is relative
+	callbackURL := p.getAbsoluteURL(req, *p.redirectURL)
+	callbackURL.Path = p.ProxyPrefix + signOutCallbackPath
+	callbackURL.RawQuery = ""
+
+	endSessionURL := *providerData.EndSessionURL
+	params := endSessionURL.Query()
+	if session.IDToken != "" {
+		params.Set("id_token_hint", session.IDToken)
+	}
+	params.Set("post_logout_redirect_uri", callbackURL.String())
+	params.Set("client_id", providerData.ClientID)
+	params.Set("state", state)
+	endSessionURL.RawQuery = params.Encode()
+	return endSessionURL.String()
+}
+
+// SignOutCallback handles the return of the browser from the provider's end
+// session endpoint and redirects it to the redirect requested at sign out.
+func (p *OAuthProxy) SignOutCallback(rw http.ResponseWriter, req *http.Request) {
+	cookieName := p.signOutCookieName()
+	cookie, err := req.Cookie(cookieName)
+	if err != nil {
+		logger.Errorf("Error loading sign out cookie: %v", err)
+		p.ErrorPage(rw, req, http.StatusForbidden, "sign out state not found")
+		return
+	}
+	http.SetCookie(rw, cookies.MakeCookieFromOptions(req, cookieName, "", p.CookieOptions, time.Hour*-1))
+
+	value, _, _, ok := encryption.ValidateWithSeeds(cookie, p.CookieOptions.Secrets(), p.CookieOptions.CSRFExpire)
+	if !ok {
+		p.ErrorPage(rw, req, http.StatusForbidden, "invalid sign out state")
+		return
+	}
+	state, redirect, ok := strings.Cut(string(value), "|")
+	if !ok || subtle.ConstantTimeCompare([]byte(state), []byte(req.URL.Query().Get("state"))) != 1 {
+		p.ErrorPage(rw, req, http.StatusForbidden, "invalid sign out state")
+		return
+	}
+
+	if !p.redirectValidator.IsValidRedirect(redirect) {
+		redirect = "/"
+	}
+	http.Redirect(rw, req, redirect, http.StatusFound)
+}
+
+// signOutCookieName returns the name of the cookie holding the state of an
+// RP-initiated logout
+func (p *OAuthProxy) signOutCookieName() string {
+	return p.CookieOptions.Name + "_logout"
+}
+
 func (p *OAuthProxy) backendLogout(rw http.ResponseWriter, req *http.Request) {
 	session, err := p.getAuthenticatedSession(rw, req)
 	if err != nil {
@@ -766,7 +1307,13 @@ func (p *OAuthProxy) backendLogout(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	providerData := p.provider.Data()
+	provider, err := p.getSessionProvider(session)
+	if err != nil {
+		logger.Errorf("error getting provider during backend logout: %v", err)
+		return
+	}
+
+	providerData := provider.Data()
 	if providerData.BackendLogoutURL == "" {
 		return
 	}
@@ -786,6 +1333,70 @@ func (p *OAuthProxy) backendLogout(rw http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// BackChannelLogout handles OpenID Connect Back-Channel Logout requests.
+// The provider posts a Logout Token identifying the sessions to be ended,
+// which are then removed from the session store or, when the session store
+// cannot remove them, revoked the next time they are loaded.
+func (p *OAuthProxy) BackChannelLogout(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		p.backChannelLogoutError(rw, http.StatusMethodNotAllowed, "invalid_request", "back-channel logout requests must use POST")
+		return
+	}
+
+	provider, err := p.getProvider(req.URL.Query().Get("provider"))
+	if err != nil {
+		p.backChannelLogoutError(rw, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	verifier := provider.Data().Verifier
+	if verifier == nil {
+		p.backChannelLogoutError(rw, http.StatusBadRequest, "invalid_request", "provider does not support back-channel logout")
+		return
+	}
+
+	if err := req.ParseForm(); err != nil {
+		p.backChannelLogoutError(rw, http.StatusBadRequest, "invalid_request", "could not parse request body")
+		return
+	}
+	rawLogoutToken := req.PostForm.Get("logout_token")
+	if rawLogoutToken == "" {
+		p.backChannelLogoutError(rw, http.StatusBadRequest, "invalid_request", "missing logout_token")
+		return
+	}
+
+	logoutToken, err := internaloidc.VerifyLogoutToken(req.Context(), verifier, rawLogoutToken)
+	if err != nil {
+		logger.Errorf("Error verifying back-channel logout token: %v", err)
+		p.backChannelLogoutError(rw, http.StatusBadRequest, "invalid_request", "invalid logout_token")
+		return
+	}
+
+	providerID := provider.Data().ID
+	if err := p.sessionRevoker.RevokeSessions(req.Context(), providerID, logoutToken.Subject, logoutToken.SessionID); err != nil {
+		logger.Errorf("Error revoking sessions during back-channel logout: %v", err)
+		p.backChannelLogoutError(rw, http.StatusBadRequest, "logout_failed", "sessions could not be revoked")
+		return
+	}
+
+	logger.Printf("Back-channel logout from provider %q - Subject: %s; SessionID: %s", providerID, logoutToken.Subject, logoutToken.SessionID)
+	rw.WriteHeader(http.StatusOK)
+}
+
+// backChannelLogoutError writes an error response to a back-channel logout
+// request in the format of an OAuth 2.0 error response.
+func (p *OAuthProxy) backChannelLogoutError(rw http.ResponseWriter, code int, errorCode, description string) {
+	rw.Header().Set("Content-Type", applicationJSON)
+	rw.WriteHeader(code)
+	err := json.NewEncoder(rw).Encode(map[string]string{
+		"error":             errorCode,
+		"error_description": description,
+	})
+	if err != nil {
+		logger.Errorf("Error encoding back-channel logout error: %v", err)
+	}
+}
+
 // OAuthStart starts the OAuth2 authentication flow
 func (p *OAuthProxy) OAuthStart(rw http.ResponseWriter, req *http.Request) {
 	// start the flow permitting login URL query parameters to be overridden from the request URL
@@ -793,15 +1404,19 @@ func (p *OAuthProxy) OAuthStart(rw http.ResponseWriter, req *http.Request) {
 }
 
 func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, overrides url.Values) {
-	extraParams := p.provider.Data().LoginURLParams(overrides)
 	prepareNoCache(rw)
 
-	var (
-		err                                              error
-		codeChallenge, codeVerifier, codeChallengeMethod string
-	)
-	if p.provider.Data().CodeChallengeMethod != "" {
-		codeChallengeMethod = p.provider.Data().CodeChallengeMethod
+	provider, err := p.getProvider(overrides.Get("provider"))
+	if err != nil {
+		logger.Errorf("Error selecting provider: %v", err)
+		p.ErrorPage(rw, req, http.StatusBadRequest, err.Error())
+		return
+	}
+	extraParams := provider.Data().LoginURLParams(overrides)
+
+	var codeChallenge, codeVerifier, codeChallengeMethod string
+	if provider.Data().CodeChallengeMethod != "" {
+		codeChallengeMethod = provider.Data().CodeChallengeMethod
 		codeVerifier, err = encryption.GenerateCodeVerifierString(96)
 		if err != nil {
 			logger.Errorf("Unable to build random ASCII string for code verifier: %v", err)
@@ -809,7 +1424,7 @@ func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, ove
 			return
 		}
 
-		codeChallenge, err = encryption.GenerateCodeChallenge(p.provider.Data().CodeChallengeMethod, codeVerifier)
+		codeChallenge, err = encryption.GenerateCodeChallenge(provider.Data().CodeChallengeMethod, codeVerifier)
 		if err != nil {
 			logger.Errorf("Error creating code challenge: %v", err)
 			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
@@ -820,7 +1435,7 @@ func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, ove
 		extraParams.Add("code_challenge_method", codeChallengeMethod)
 	}
 
-	csrf, err := cookies.NewCSRF(p.CookieOptions, codeVerifier)
+	csrf, err := cookies.NewCSRF(p.CookieOptions, codeVerifier, provider.Data().ID)
 	if err != nil {
 		logger.Errorf("Error creating CSRF nonce: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
@@ -835,13 +1450,22 @@ func (p *OAuthProxy) doOAuthStart(rw http.ResponseWriter, req *http.Request, ove
 	}
 
 	callbackRedirect := p.getOAuthRedirectURI(req)
-	loginURL := p.provider.GetLoginURL(
+	loginURL := provider.GetLoginURL(
 		callbackRedirect,
 		encodeState(csrf.HashOAuthState(), appRedirect, p.encodeState),
 		csrf.HashOIDCNonce(),
 		extraParams,
 	)
 
+	// Providers that require pushed authorization requests receive the
+	// parameters of the login URL from the proxy instead of the browser
+	loginURL, err = provider.Data().PushAuthorizationRequest(req.Context(), loginURL)
+	if err != nil {
+		logger.Errorf("Error pushing authorization request: %v", err)
+		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	if _, err := csrf.SetCookie(rw, req); err != nil {
 		logger.Errorf("Error setting CSRF cookie: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
@@ -892,14 +1516,22 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	session, err := p.redeemCode(req, csrf.GetCodeVerifier())
+	provider, err := p.getProvider(csrf.GetProviderID())
+	if err != nil {
+		logger.Errorf("Error selecting provider during OAuth2 callback: %v", err)
+		p.ErrorPage(rw, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	session, err := p.redeemCode(req, provider, csrf.GetCodeVerifier())
 	if err != nil {
 		logger.Errorf("Error redeeming code during OAuth2 callback: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
 		return
 	}
+	session.ProviderID = provider.Data().ID
 
-	err = p.enrichSessionState(req.Context(), session)
+	err = p.enrichSessionState(req.Context(), provider, session)
 	if err != nil {
 		logger.Errorf("Error creating session during OAuth2 callback: %v", err)
 		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
@@ -915,7 +1547,7 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	csrf.SetSessionNonce(session)
-	if !p.provider.ValidateSession(req.Context(), session) {
+	if !provider.ValidateSession(req.Context(), session) {
 		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Session validation failed: %s", session)
 		p.ErrorPage(rw, req, http.StatusForbidden, "Session validation failed")
 		return
@@ -926,13 +1558,18 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	// set cookie, or deny
-	authorized, err := p.provider.Authorize(req.Context(), session)
+	authorized, err := provider.Authorize(req.Context(), session)
 	if err != nil {
 		logger.Errorf("Error with authorization: %v", err)
 	}
 	if p.Validator(session.Email) && authorized {
 		logger.PrintAuthf(session.Email, req, logger.AuthSuccess, "Authenticated via OAuth2: %s", session)
 		err := p.SaveSession(rw, req, session)
+		if errors.Is(err, sessionsapi.ErrSessionLimitExceeded) {
+			logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Session rejected: %v", err)
+			p.ErrorPage(rw, req, http.StatusForbidden, "Too many active sessions, sign out of another session and try again")
+			return
+		}
 		if err != nil {
 			logger.Errorf("Error saving session state for %s: %v", remoteAddr, err)
 			p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
@@ -945,14 +1582,14 @@ func (p *OAuthProxy) OAuthCallback(rw http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func (p *OAuthProxy) redeemCode(req *http.Request, codeVerifier string) (*sessionsapi.SessionState, error) {
+func (p *OAuthProxy) redeemCode(req *http.Request, provider providers.Provider, codeVerifier string) (*sessionsapi.SessionState, error) {
 	code := req.Form.Get("code")
 	if code == "" {
 		return nil, providers.ErrMissingCode
 	}
 
 	redirectURI := p.getOAuthRedirectURI(req)
-	s, err := p.provider.Redeem(req.Context(), redirectURI, code, codeVerifier)
+	s, err := provider.Redeem(req.Context(), redirectURI, code, codeVerifier)
 	if err != nil {
 		return nil, err
 	}
@@ -968,24 +1605,29 @@ func (p *OAuthProxy) redeemCode(req *http.Request, codeVerifier string) (*sessio
 	return s, nil
 }
 
-func (p *OAuthProxy) enrichSessionState(ctx context.Context, s *sessionsapi.SessionState) error {
+func (p *OAuthProxy) enrichSessionState(ctx context.Context, provider providers.Provider, s *sessionsapi.SessionState) error {
 	var err error
 	if s.Email == "" {
 		// TODO(@NickMeves): Remove once all provider are updated to implement EnrichSession
 		// nolint:staticcheck
-		s.Email, err = p.provider.GetEmailAddress(ctx, s)
+		s.Email, err = provider.GetEmailAddress(ctx, s)
 		if err != nil && !errors.Is(err, providers.ErrNotImplemented) {
 			return err
 		}
 	}
 
-	return p.provider.EnrichSession(ctx, s)
+	return provider.EnrichSession(ctx, s)
 }
 
 // AuthOnly checks whether the user is currently logged in (both authentication
 // and optional authorization).
 func (p *OAuthProxy) AuthOnly(rw http.ResponseWriter, req *http.Request) {
+	markAuthRequest(req)
 	session, err := p.getAuthenticatedSession(rw, req)
+	if err == ErrForbidden {
+		http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
@@ -998,22 +1640,124 @@ func (p *OAuthProxy) AuthOnly(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if err := p.checkAPIRouteTokenRequirements(req, session); err != nil {
+		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Insufficient token scope: %v", err)
+		p.errorInsufficientScope(rw, err)
+		return
+	}
+
 	// we are authenticated
 	p.addHeadersForProxying(rw, session)
+	addAuthorizationHeaders(req, rw.Header())
 	p.headersChain.Then(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
 		rw.WriteHeader(http.StatusAccepted)
 	})).ServeHTTP(rw, req)
 }
 
+// DeviceVerification serves the page on which users approve the requests of
+// the device authorization grant. Users that are not signed in are redirected
+// to sign in first.
+func (p *OAuthProxy) DeviceVerification(rw http.ResponseWriter, req *http.Request) {
+	session, err := p.getAuthenticatedSession(rw, req)
+	switch {
+	case err == ErrNeedsLogin:
+		signInURL := fmt.Sprintf("%s?rd=%s", p.SignInPath, url.QueryEscape(req.URL.String()))
+		http.Redirect(rw, req, signInURL, http.StatusFound)
+		return
+	case err == ErrAccessDenied, err == ErrForbidden:
+		p.ErrorPage(rw, req, http.StatusForbidden, "The session failed authorization checks")
+		return
+	case err != nil:
+		logger.Errorf("Unexpected internal error: %v", err)
+		p.ErrorPage(rw, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	p.deviceHandler.ServeVerification(rw, req, session)
+}
+
+// ExtAuthz checks requests received by the Envoy external authorization
+// server. It applies the same checks as AuthOnly, but the configured request
+// headers are injected into the request so that they are forwarded to the
+// upstream. Unauthenticated browser requests are redirected to sign in.
+// The request is built from the host and path Envoy routes, so it is not
+// marked as an auth request: its forwarded headers come from the client.
+func (p *OAuthProxy) ExtAuthz(rw http.ResponseWriter, req *http.Request) {
+	session, err := p.getAuthenticatedSession(rw, req)
+	switch {
+	case err == ErrNeedsLogin && !p.forceJSONErrors && !isAjax(req) && !p.isAPIPath(req):
+		signInURL := fmt.Sprintf("%s?rd=%s", p.SignInPath, url.QueryEscape(req.URL.String()))
+		http.Redirect(rw, req, signInURL, http.StatusFound)
+		return
+	case err == ErrForbidden, err == nil && !authOnlyAuthorize(req, session):
+		http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	case err != nil:
+		http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if err := p.checkAPIRouteTokenRequirements(req, session); err != nil {
+		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Insufficient token scope: %v", err)
+		p.errorInsufficientScope(rw, err)
+		return
+	}
+
+	// we are authenticated
+	addAuthorizationHeaders(req, req.Header)
+	p.headersChain.Then(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rw, req)
+}
+
+// markAuthRequest records in the request scope that the request asks whether
+// another request may be proxied, so that authorization rules apply to the
+// forwarded request.
+func markAuthRequest(req *http.Request) {
+	if scope := middlewareapi.GetRequestScope(req); scope != nil {
+		scope.AuthRequest = true
+	}
+}
+
 // Proxy proxies the user request if the user is authenticated else it prompts
 // them to authenticate
 func (p *OAuthProxy) Proxy(rw http.ResponseWriter, req *http.Request) {
 	session, err := p.getAuthenticatedSession(rw, req)
 	switch err {
 	case nil:
-		// we are authenticated
+		// check the bearer token carries the scopes required by the route
+		if err := p.checkTokenRequirements(req, session); err != nil {
+			logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Insufficient token scope: %v", err)
+			p.errorInsufficientScope(rw, err)
+			return
+		}
+
+		// we are authenticated, check the session may access this upstream
+		if upstreamID, err := p.authorizeUpstream(req, session); err != nil {
+			logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Unauthorized for upstream %q: %v", upstreamID, err)
+			if p.forceJSONErrors {
+				p.errorJSON(rw, http.StatusForbidden)
+			} else {
+				p.ErrorPage(rw, req, http.StatusForbidden, "The session failed authorization checks for this upstream")
+			}
+			return
+		}
+
+		// exchange the token of the session for a token issued for the upstream
+		handler, err := p.exchangeUpstreamToken(req, session)
+		if err != nil {
+			logger.Errorf("Error exchanging token for upstream: %v", err)
+			if p.forceJSONErrors {
+				p.errorJSON(rw, http.StatusBadGateway)
+			} else {
+				p.ErrorPage(rw, req, http.StatusBadGateway, "Could not obtain a token for this upstream")
+			}
+			return
+		}
+
 		p.addHeadersForProxying(rw, session)
-		p.headersChain.Then(p.upstreamProxy).ServeHTTP(rw, req)
+		addAuthorizationHeaders(req, req.Header)
+		p.headersChain.Then(handler).ServeHTTP(rw, req)
 	case ErrNeedsLogin:
 		// we need to send the user to a login screen
 		if p.forceJSONErrors || isAjax(req) || p.isAPIPath(req) {
@@ -1033,7 +1777,7 @@ func (p *OAuthProxy) Proxy(rw http.ResponseWriter, req *http.Request) {
 			p.SignInPage(rw, req, http.StatusForbidden)
 		}
 
-	case ErrAccessDenied:
+	case ErrAccessDenied, ErrForbidden:
 		if p.forceJSONErrors {
 			p.errorJSON(rw, http.StatusForbidden)
 		} else {
@@ -1073,13 +1817,22 @@ func prepareNoCacheMiddleware(next http.Handler) http.Handler {
 // redirect clients to once authenticated.
 // This is usually the OAuthProxy callback URL.
 func (p *OAuthProxy) getOAuthRedirectURI(req *http.Request) string {
-	// if `p.redirectURL` already has a host, return it
-	if p.relativeRedirectURL || p.redirectURL.Host != "" {
+	if p.relativeRedirectURL {
 		return p.redirectURL.String()
 	}
+	rd := p.getAbsoluteURL(req, *p.redirectURL)
+	return rd.String()
+}
+
+// getAbsoluteURL returns the URL with the scheme and host of the request when
+// it has no host of its own.
+func (p *OAuthProxy) getAbsoluteURL(req *http.Request, rd url.URL) *url.URL {
+	// if the URL already has a host, return it
+	if rd.Host != "" {
+		return &rd
+	}
 
 	// Otherwise figure out the scheme + host from the request
-	rd := *p.redirectURL
 	rd.Host = requestutil.GetRequestHost(req)
 	rd.Scheme = requestutil.GetRequestProto(req)
 
@@ -1093,13 +1846,14 @@ func (p *OAuthProxy) getOAuthRedirectURI(req *http.Request) string {
 	if p.CookieOptions.Secure {
 		rd.Scheme = schemeHTTPS
 	}
-	return rd.String()
+	return &rd
 }
 
 // getAuthenticatedSession checks whether a user is authenticated and returns a session object and nil error if so
 // Returns:
 // - `nil, ErrNeedsLogin` if user needs to login.
 // - `nil, ErrAccessDenied` if the authenticated user is not authorized
+// - `nil, ErrForbidden` if the authenticated user fails the authorization rules for this request
 // Set-Cookie headers may be set on the response as a side-effect of calling this method.
 func (p *OAuthProxy) getAuthenticatedSession(rw http.ResponseWriter, req *http.Request) (*sessionsapi.SessionState, error) {
 	session := middlewareapi.GetRequestScope(req).Session
@@ -1114,9 +1868,16 @@ func (p *OAuthProxy) getAuthenticatedSession(rw http.ResponseWriter, req *http.R
 	}
 
 	invalidEmail := session.Email != "" && !p.Validator(session.Email)
-	authorized, err := p.provider.Authorize(req.Context(), session)
+
+	var authorized bool
+	provider, err := p.getSessionProvider(session)
 	if err != nil {
 		logger.Errorf("Error with authorization: %v", err)
+	} else {
+		authorized, err = provider.Authorize(req.Context(), session)
+		if err != nil {
+			logger.Errorf("Error with authorization: %v", err)
+		}
 	}
 
 	if invalidEmail || !authorized {
@@ -1134,9 +1895,135 @@ func (p *OAuthProxy) getAuthenticatedSession(rw http.ResponseWriter, req *http.R
 		return nil, ErrAccessDenied
 	}
 
+	// Authorization rules may depend on the request, so the session is kept
+	// when they are not satisfied.
+	if err := p.authorizer.Authorize(req, session); err != nil {
+		logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Unauthorized by authorization rules: %v", err)
+		return nil, ErrForbidden
+	}
+
+	if p.authorizationWebhook != nil {
+		decision := p.authorizationWebhook.Check(req, session)
+		if !decision.Allow {
+			logger.PrintAuthf(session.Email, req, logger.AuthFailure, "Unauthorized by authorization webhook: %s", decision.Reason)
+			return nil, ErrForbidden
+		}
+
+		scope := middlewareapi.GetRequestScope(req)
+		scope.AuthorizationHeaders = make(http.Header, len(decision.Headers))
+		for name, value := range decision.Headers {
+			scope.AuthorizationHeaders.Set(name, value)
+		}
+	}
+
 	return session, nil
 }
 
+// authorizeUpstream checks the session against the authorization rules of the
+// upstream that will serve the request.
+// Requests that are allowed without authentication are not checked.
+// The ID of the matched upstream is returned to aid logging.
+func (p *OAuthProxy) authorizeUpstream(req *http.Request, session *sessionsapi.SessionState) (string, error) {
+	if session == nil || p.IsAllowedRequest(req) {
+		return "", nil
+	}
+
+	upstreamID, ok := p.upstreamProxy.MatchUpstream(req)
+	if !ok {
+		return "", nil
+	}
+
+	authorizer, ok := p.upstreamAuthorizers[upstreamID]
+	if !ok {
+		return upstreamID, nil
+	}
+
+	return upstreamID, authorizer.Authorize(req, session)
+}
+
+// exchangeUpstreamToken exchanges the token of the session for a token issued
+// for the upstream that will serve the request, when the upstream configures
+// a token exchange.
+// The returned handler proxies the request with the exchanged token as the
+// Authorization header, replacing any header set by the headers chain.
+func (p *OAuthProxy) exchangeUpstreamToken(req *http.Request, session *sessionsapi.SessionState) (http.Handler, error) {
+	if session == nil || p.IsAllowedRequest(req) {
+		return p.upstreamProxy, nil
+	}
+
+	upstreamID, ok := p.upstreamProxy.MatchUpstream(req)
+	if !ok {
+		return p.upstreamProxy, nil
+	}
+	exchange, ok := p.tokenExchanges[upstreamID]
+	if !ok {
+		return p.upstreamProxy, nil
+	}
+
+	provider, err := p.getSessionProvider(session)
+	if err != nil {
+		return nil, err
+	}
+	token, err := p.tokenExchanger.ExchangeToken(req.Context(), provider.Data(), session, exchange)
+	if err != nil {
+		return nil, fmt.Errorf("upstream %q: %v", upstreamID, err)
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+		p.upstreamProxy.ServeHTTP(rw, req)
+	}), nil
+}
+
+// checkTokenRequirements checks the session against the token requirements
+// of the API routes and the upstream matching the request.
+// Only sessions loaded from a bearer token are checked: sessions started by
+// signing in carry no scopes or audiences.
+func (p *OAuthProxy) checkTokenRequirements(req *http.Request, session *sessionsapi.SessionState) error {
+	if err := p.checkAPIRouteTokenRequirements(req, session); err != nil {
+		return err
+	}
+	if session == nil || !loadedFromBearerToken(req) || p.IsAllowedRequest(req) {
+		return nil
+	}
+
+	upstreamID, ok := p.upstreamProxy.MatchUpstream(req)
+	if !ok {
+		return nil
+	}
+	requirements, ok := p.tokenRequirements[upstreamID]
+	if !ok {
+		return nil
+	}
+	return requirements.Authorize(req, session)
+}
+
+// checkAPIRouteTokenRequirements checks the session against the token
+// requirements of every API route matching the request, when it was loaded
+// from a bearer token.
+func (p *OAuthProxy) checkAPIRouteTokenRequirements(req *http.Request, session *sessionsapi.SessionState) error {
+	if session == nil || !loadedFromBearerToken(req) || p.IsAllowedRequest(req) {
+		return nil
+	}
+
+	for _, route := range p.apiRoutes {
+		if route.tokenRequirements == nil || !route.pathRegex.MatchString(requestutil.GetRequestURI(req)) {
+			continue
+		}
+		if err := route.tokenRequirements.Authorize(req, session); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadedFromBearerToken returns true if the session of the request was loaded
+// from a bearer token
+func loadedFromBearerToken(req *http.Request) bool {
+	scope := middlewareapi.GetRequestScope(req)
+	return scope != nil && scope.BearerToken
+}
+
 // authOnlyAuthorize handles special authorization logic that is only done
 // on the AuthOnly endpoint for use with Nginx subrequest architectures.
 func authOnlyAuthorize(req *http.Request, s *sessionsapi.SessionState) bool {
@@ -1277,6 +2164,18 @@ func (p *OAuthProxy) addHeadersForProxying(rw http.ResponseWriter, session *sess
 	}
 }
 
+// addAuthorizationHeaders copies the headers returned by the authorization
+// webhook for the request into the given headers.
+func addAuthorizationHeaders(req *http.Request, header http.Header) {
+	scope := middlewareapi.GetRequestScope(req)
+	if scope == nil {
+		return
+	}
+	for name, values := range scope.AuthorizationHeaders {
+		header[name] = values
+	}
+}
+
 // isAjax checks if a request is an ajax request
 func isAjax(req *http.Request) bool {
 	acceptValues := req.Header.Values("Accept")
@@ -1306,6 +2205,16 @@ func (p *OAuthProxy) errorJSON(rw http.ResponseWriter, code int) {
 	rw.Write([]byte("{}"))
 }
 
+// errorInsufficientScope rejects a request whose bearer token does not carry
+// the scopes or audiences required by the route, with an RFC 6750 error
+func (p *OAuthProxy) errorInsufficientScope(rw http.ResponseWriter, err error) {
+	var insufficientScope *authorization.InsufficientScopeError
+	if errors.As(err, &insufficientScope) {
+		rw.Header().Set("WWW-Authenticate", insufficientScope.WWWAuthenticate())
+	}
+	p.errorJSON(rw, http.StatusForbidden)
+}
+
 // LoggingCSRFCookiesInOAuthCallback Log all CSRF cookies found in HTTP request OAuth callback,
 // which were successfully parsed
 func LoggingCSRFCookiesInOAuthCallback(req *http.Request, cookieName string) {
@@ -4,8 +4,10 @@ import (
 	"context"
 	"crypto"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -15,18 +17,27 @@ import (
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/go-jose/go-jose/v3"
 	"github.com/mbland/hmacauth"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/cookies"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/device"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/extauthz"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
 	sessionscookie "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/cookie"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	sessionstests "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/upstream"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/validation"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 const (
@@ -114,7 +125,7 @@ func Test_redeemCode(t *testing.T) {
 	}
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
-	_, err = proxy.redeemCode(req, "")
+	_, err = proxy.redeemCode(req, proxy.provider, "")
 	assert.Equal(t, providers.ErrMissingCode, err)
 }
 
@@ -166,7 +177,7 @@ func Test_enrichSession(t *testing.T) {
 			}
 			proxy.provider = NewTestProvider(&url.URL{Host: "www.example.com"}, providerEmail)
 
-			err = proxy.enrichSessionState(context.Background(), tc.session)
+			err = proxy.enrichSessionState(context.Background(), proxy.provider, tc.session)
 			assert.NoError(t, err)
 			assert.Equal(t, tc.expectedUser, tc.session.User)
 			assert.Equal(t, tc.expectedEmail, tc.session.Email)
@@ -404,7 +415,7 @@ func (patTest *PassAccessTokenTest) Close() {
 func (patTest *PassAccessTokenTest) getCallbackEndpoint() (httpCode int, cookie string) {
 	rw := httptest.NewRecorder()
 
-	csrf, err := cookies.NewCSRF(patTest.proxy.CookieOptions, "")
+	csrf, err := cookies.NewCSRF(patTest.proxy.CookieOptions, "", "")
 	if err != nil {
 		panic(err)
 	}
@@ -797,6 +808,135 @@ func TestSignInPageSkipProviderDirect(t *testing.T) {
 	}
 }
 
+func TestOAuthStartPushedAuthorizationRequest(t *testing.T) {
+	var pushed url.Values
+	parServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_ = req.ParseForm()
+		if req.PostForm.Get("login_hint") == "rejected" {
+			rw.WriteHeader(http.StatusBadRequest)
+			_, _ = rw.Write([]byte(`{"error":"invalid_request"}`))
+			return
+		}
+		pushed = req.PostForm
+		rw.WriteHeader(http.StatusCreated)
+		_, _ = rw.Write([]byte(`{"request_uri":"urn:ietf:params:oauth:request_uri:abc123","expires_in":60}`))
+	}))
+	defer parServer.Close()
+
+	opts := baseTestOptions()
+	opts.Providers[0].CodeChallengeMethod = "S256"
+	anyValue := ".*"
+	opts.Providers[0].LoginURLParameters = []options.LoginURLParameter{{Name: "login_hint", Allow: []options.URLParameterRule{{Pattern: &anyValue}}}}
+	opts.Providers[0].OIDCConfig.PushedAuthorizationRequests = true
+	opts.Providers[0].OIDCConfig.PushedAuthorizationRequestURL = parServer.URL
+	err := validation.Validate(opts)
+	require.NoError(t, err)
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	t.Run("redirects with the request_uri of the pushed parameters", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/start?rd=%2Fapp", nil))
+
+		assert.Equal(t, http.StatusFound, rw.Code)
+		location, err := url.Parse(rw.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, url.Values{
+			"client_id":   {clientID},
+			"request_uri": {"urn:ietf:params:oauth:request_uri:abc123"},
+		}, location.Query())
+
+		assert.Equal(t, clientID, pushed.Get("client_id"))
+		assert.NotEmpty(t, pushed.Get("state"))
+		assert.NotEmpty(t, pushed.Get("code_challenge"))
+		assert.Equal(t, "S256", pushed.Get("code_challenge_method"))
+		assert.Equal(t, "https://example.com/oauth2/callback", pushed.Get("redirect_uri"))
+	})
+
+	t.Run("shows the error page when the request is rejected", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/oauth2/start?login_hint=rejected", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rw.Code)
+		assert.Empty(t, rw.Result().Cookies())
+	})
+}
+
+func TestMultipleProviders(t *testing.T) {
+	opts := baseTestOptions()
+	opts.Providers = append(opts.Providers, options.Provider{
+		ID:           "github",
+		Type:         options.GitHubProvider,
+		Name:         "GitHub",
+		ClientID:     "github-client-id",
+		ClientSecret: "github-client-secret",
+		LoginURL:     "https://github.example.com/login/oauth/authorize",
+	})
+	err := validation.Validate(opts)
+	require.NoError(t, err)
+
+	proxy, err := NewOAuthProxy(opts, func(string) bool { return true })
+	require.NoError(t, err)
+
+	t.Run("sign in page lists every provider", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/oauth2/sign_in", nil)
+		proxy.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Contains(t, rw.Body.String(), `<input type="hidden" name="provider" value="providerID">`)
+		assert.Contains(t, rw.Body.String(), `<input type="hidden" name="provider" value="github">`)
+		assert.Contains(t, rw.Body.String(), "Sign in with GitHub")
+	})
+
+	t.Run("start selects the requested provider", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/oauth2/start?provider=github", nil)
+		proxy.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusFound, rw.Code)
+		location, err := url.Parse(rw.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "github.example.com", location.Host)
+		assert.Equal(t, "github-client-id", location.Query().Get("client_id"))
+
+		csrfReq := httptest.NewRequest(http.MethodGet, "/oauth2/callback", nil)
+		for _, c := range rw.Result().Cookies() {
+			csrfReq.AddCookie(c)
+		}
+		csrf, err := cookies.LoadCSRFCookie(csrfReq, cookies.GenerateCookieName(&opts.Cookie, ""), &opts.Cookie)
+		require.NoError(t, err)
+		assert.Equal(t, "github", csrf.GetProviderID())
+	})
+
+	t.Run("start rejects an unknown provider", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/oauth2/start?provider=unknown", nil)
+		proxy.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+
+	t.Run("sessions from an unknown provider are denied", func(t *testing.T) {
+		session := &sessions.SessionState{Email: "john.doe@example.com", ProviderID: "unknown"}
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		require.NoError(t, proxy.SaveSession(rw, req, session))
+
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, c := range rw.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		rw = httptest.NewRecorder()
+		proxy.ServeHTTP(rw, req)
+
+		assert.Equal(t, http.StatusForbidden, rw.Code)
+		assert.NotContains(t, rw.Body.String(), "Sign in with")
+	})
+}
+
 type ProcessCookieTest struct {
 	opts         *options.Options
 	proxy        *OAuthProxy
@@ -898,6 +1038,8 @@ func TestLoadCookiedSession(t *testing.T) {
 	assert.Equal(t, startSession.Email, session.Email)
 	assert.Equal(t, "", session.User)
 	assert.Equal(t, startSession.AccessToken, session.AccessToken)
+	assert.NotNil(t, session.IssuedAt)
+	assert.NotNil(t, session.LastActivity)
 }
 
 func TestProcessCookieNoCookieError(t *testing.T) {
@@ -1031,6 +1173,18 @@ func TestUserInfoEndpointAccepted(t *testing.T) {
 			},
 			expectedResponse: "{\"user\":\"john.doe\",\"email\":\"john.doe@example.com\",\"groups\":[\"example\",\"groups\"],\"preferredUsername\":\"john\"}\n",
 		},
+		{
+			name: "With claims",
+			session: &sessions.SessionState{
+				User:        "john.doe",
+				Email:       "john.doe@example.com",
+				AccessToken: "my_access_token",
+				Claims: map[string][]string{
+					"department": {"engineering"},
+				},
+			},
+			expectedResponse: "{\"user\":\"john.doe\",\"email\":\"john.doe@example.com\",\"claims\":{\"department\":[\"engineering\"]}}\n",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -1230,6 +1384,84 @@ func TestAuthOnlyEndpointSetXAuthRequestHeaders(t *testing.T) {
 	assert.Equal(t, "oauth_user@example.com", pcTest.rw.Header().Get("X-Auth-Request-Email"))
 }
 
+func TestAuthOnlyEndpointSetIdentityTokenHeader(t *testing.T) {
+	var pcTest ProcessCookieTest
+
+	pcTest.opts = baseTestOptions()
+	pcTest.opts.IdentityToken = &options.IdentityToken{
+		Issuer:    "https://proxy.example.com",
+		Audiences: []string{"upstream"},
+	}
+	pcTest.opts.InjectResponseHeaders = []options.Header{
+		{
+			Name: "Authorization",
+			Values: []options.HeaderValue{
+				{
+					ClaimSource: &options.ClaimSource{
+						Claim:  "identity_token",
+						Prefix: "Bearer ",
+					},
+				},
+			},
+		},
+	}
+	err := validation.Validate(pcTest.opts)
+	assert.NoError(t, err)
+
+	pcTest.proxy, err = NewOAuthProxy(pcTest.opts, func(email string) bool {
+		return pcTest.validateUser
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcTest.proxy.provider = &TestProvider{
+		ProviderData: &providers.ProviderData{},
+		ValidToken:   true,
+	}
+
+	pcTest.validateUser = true
+
+	pcTest.rw = httptest.NewRecorder()
+	pcTest.req, _ = http.NewRequest("GET",
+		pcTest.opts.ProxyPrefix+authOnlyPath, nil)
+
+	created := time.Now()
+	startSession := &sessions.SessionState{
+		User: "oauth_user", Email: "oauth_user@example.com", AccessToken: "oauth_token", CreatedAt: &created}
+	err = pcTest.SaveSession(startSession)
+	assert.NoError(t, err)
+
+	pcTest.proxy.ServeHTTP(pcTest.rw, pcTest.req)
+	assert.Equal(t, http.StatusAccepted, pcTest.rw.Code)
+	authorization := pcTest.rw.Header().Get("Authorization")
+	require.True(t, strings.HasPrefix(authorization, "Bearer "))
+
+	// The token is verified by the keys published by the proxy
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", pcTest.opts.ProxyPrefix+jwksPath, nil)
+	pcTest.proxy.ServeHTTP(rw, req)
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	jwks := jose.JSONWebKeySet{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &jwks))
+	// The generated key of the next rotation is published as well
+	require.Len(t, jwks.Keys, 2)
+
+	signed, err := jose.ParseSigned(strings.TrimPrefix(authorization, "Bearer "))
+	require.NoError(t, err)
+	keys := jwks.Key(signed.Signatures[0].Header.KeyID)
+	require.Len(t, keys, 1)
+	payload, err := signed.Verify(keys[0].Key)
+	require.NoError(t, err)
+
+	claims := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(payload, &claims))
+	assert.Equal(t, "https://proxy.example.com", claims["iss"])
+	assert.Equal(t, "upstream", claims["aud"])
+	assert.Equal(t, "oauth_user", claims["sub"])
+	assert.Equal(t, "oauth_user@example.com", claims["email"])
+}
+
 func TestAuthOnlyEndpointSetBasicAuthTrueRequestHeaders(t *testing.T) {
 	var pcTest ProcessCookieTest
 
@@ -2995,162 +3227,673 @@ func TestProxyAllowedGroups(t *testing.T) {
 	}
 }
 
-func TestAuthOnlyAllowedGroups(t *testing.T) {
-	testCases := []struct {
-		name               string
-		allowedGroups      []string
-		groups             []string
-		querystring        string
-		expectedStatusCode int
+func TestProxyUpstreamAuthorization(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		groups       []string
+		expectedCode int
 	}{
-		{
-			name:               "NoAllowedGroups",
-			allowedGroups:      []string{},
-			groups:             []string{},
-			querystring:        "",
-			expectedStatusCode: http.StatusAccepted,
-		},
-		{
-			name:               "NoAllowedGroupsUserHasGroups",
-			allowedGroups:      []string{},
-			groups:             []string{"a", "b"},
-			querystring:        "",
-			expectedStatusCode: http.StatusAccepted,
-		},
-		{
-			name:               "UserInAllowedGroup",
-			allowedGroups:      []string{"a"},
-			groups:             []string{"a", "b"},
-			querystring:        "",
-			expectedStatusCode: http.StatusAccepted,
-		},
-		{
-			name:               "UserNotInAllowedGroup",
-			allowedGroups:      []string{"a"},
-			groups:             []string{"c"},
-			querystring:        "",
-			expectedStatusCode: http.StatusUnauthorized,
-		},
-		{
-			name:               "UserInQuerystringGroup",
-			allowedGroups:      []string{"a", "b"},
-			groups:             []string{"a", "c"},
-			querystring:        "?allowed_groups=a",
-			expectedStatusCode: http.StatusAccepted,
-		},
-		{
-			name:               "UserInMultiParamQuerystringGroup",
-			allowedGroups:      []string{"a", "b"},
-			groups:             []string{"b"},
-			querystring:        "?allowed_groups=a&allowed_groups=b,d",
-			expectedStatusCode: http.StatusAccepted,
-		},
-		{
-			name:               "UserInOnlyQuerystringGroup",
-			allowedGroups:      []string{},
-			groups:             []string{"a", "c"},
-			querystring:        "?allowed_groups=a,b",
-			expectedStatusCode: http.StatusAccepted,
-		},
-		{
-			name:               "UserInDelimitedQuerystringGroup",
-			allowedGroups:      []string{"a", "b", "c"},
-			groups:             []string{"c"},
-			querystring:        "?allowed_groups=a,c",
-			expectedStatusCode: http.StatusAccepted,
-		},
-		{
-			name:               "UserNotInQuerystringGroup",
-			allowedGroups:      []string{},
-			groups:             []string{"c"},
-			querystring:        "?allowed_groups=a,b",
-			expectedStatusCode: http.StatusForbidden,
-		},
-		{
-			name:               "UserInConfigGroupNotInQuerystringGroup",
-			allowedGroups:      []string{"a", "b", "c"},
-			groups:             []string{"c"},
-			querystring:        "?allowed_groups=a,b",
-			expectedStatusCode: http.StatusForbidden,
-		},
-		{
-			name:               "UserInQuerystringGroupNotInConfigGroup",
-			allowedGroups:      []string{"a", "b"},
-			groups:             []string{"c"},
-			querystring:        "?allowed_groups=b,c",
-			expectedStatusCode: http.StatusUnauthorized,
-		},
+		{"UnrestrictedUpstream", "/", []string{"devs"}, http.StatusOK},
+		{"UserInAllowedGroup", "/admin/", []string{"admins"}, http.StatusOK},
+		{"UserNotInAllowedGroup", "/admin/", []string{"devs"}, http.StatusForbidden},
 	}
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			emailAddress := "test"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
 			created := time.Now()
 
 			session := &sessions.SessionState{
-				Groups:      tc.groups,
-				Email:       emailAddress,
+				Groups:      tt.groups,
+				Email:       "test",
 				AccessToken: "oauth_token",
 				CreatedAt:   &created,
 			}
 
-			test, err := NewAuthOnlyEndpointTest(tc.querystring, func(opts *options.Options) {
-				opts.Providers[0].AllowedGroups = tc.allowedGroups
+			upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(200)
+			}))
+			t.Cleanup(upstreamServer.Close)
+
+			test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+				opts.UpstreamServers = options.UpstreamConfig{
+					Upstreams: []options.Upstream{
+						{
+							ID:   "root",
+							Path: "/",
+							URI:  upstreamServer.URL,
+						},
+						{
+							ID:   "admin",
+							Path: "/admin/",
+							URI:  upstreamServer.URL,
+							Authorization: &options.Authorization{
+								AllowedGroups: []string{"admins"},
+							},
+						},
+					},
+				}
 			})
 			if err != nil {
 				t.Fatal(err)
 			}
 
+			test.req, _ = http.NewRequest("GET", tt.path, nil)
 			err = test.SaveSession(session)
 			assert.NoError(t, err)
-
 			test.proxy.ServeHTTP(test.rw, test.req)
 
-			assert.Equal(t, tc.expectedStatusCode, test.rw.Code)
+			assert.Equal(t, tt.expectedCode, test.rw.Code)
 		})
 	}
 }
 
-func TestAuthOnlyAllowedGroupsWithSkipMethods(t *testing.T) {
-	testCases := []struct {
-		name               string
-		groups             []string
-		method             string
-		ip                 string
-		withSession        bool
-		expectedStatusCode int
+func TestAuthorizationPolicies(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		groups       []string
+		expectedCode int
 	}{
-		{
-			name:               "UserWithGroupSkipAuthPreflight",
-			groups:             []string{"a", "c"},
-			method:             "OPTIONS",
-			ip:                 "1.2.3.5:43670",
-			withSession:        true,
-			expectedStatusCode: http.StatusAccepted,
-		},
-		{
-			name:               "UserWithGroupTrustedIp",
-			groups:             []string{"a", "c"},
-			method:             "GET",
-			ip:                 "1.2.3.4:43670",
-			withSession:        true,
-			expectedStatusCode: http.StatusAccepted,
-		},
-		{
-			name:               "UserWithoutGroupSkipAuthPreflight",
-			groups:             []string{"c"},
-			method:             "OPTIONS",
-			ip:                 "1.2.3.5:43670",
-			withSession:        true,
-			expectedStatusCode: http.StatusForbidden,
-		},
-		{
-			name:               "UserWithoutGroupTrustedIp",
-			groups:             []string{"c"},
-			method:             "GET",
-			ip:                 "1.2.3.4:43670",
-			withSession:        true,
-			expectedStatusCode: http.StatusForbidden,
+		{"ProxyAllowed", "GET", "/", []string{"sre"}, http.StatusOK},
+		{"ProxyDeniedByGroup", "GET", "/", []string{"devs"}, http.StatusForbidden},
+		{"ProxyDeniedByMethod", "DELETE", "/", []string{"sre"}, http.StatusForbidden},
+		{"AuthOnlyAllowed", "GET", "/oauth2/auth", []string{"sre"}, http.StatusAccepted},
+		{"AuthOnlyDenied", "GET", "/oauth2/auth", []string{"devs"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created := time.Now()
+
+			session := &sessions.SessionState{
+				Groups:      tt.groups,
+				Email:       "test",
+				AccessToken: "oauth_token",
+				CreatedAt:   &created,
+			}
+
+			upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(200)
+			}))
+			t.Cleanup(upstreamServer.Close)
+
+			test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+				opts.UpstreamServers = options.UpstreamConfig{
+					Upstreams: []options.Upstream{
+						{
+							ID:   "root",
+							Path: "/",
+							URI:  upstreamServer.URL,
+						},
+					},
+				}
+				opts.Authorization = options.Authorization{
+					Policies: []options.Policy{
+						{Name: "sre", Expression: `"sre" in session.groups && request.method != "DELETE"`},
+					},
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			test.req, _ = http.NewRequest(tt.method, tt.path, nil)
+			err = test.SaveSession(session)
+			assert.NoError(t, err)
+
+			rw := httptest.NewRecorder()
+			test.proxy.ServeHTTP(rw, test.req)
+
+			assert.Equal(t, tt.expectedCode, rw.Code)
+			// The session must not be cleared when a policy denies the request
+			assert.Empty(t, rw.Header().Values("Set-Cookie"))
+		})
+	}
+}
+
+func TestAuthorizationWebhook(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		groups         []string
+		expectedCode   int
+		expectedTenant string
+	}{
+		{"ProxyAllowed", "/", []string{"sre"}, http.StatusOK, "a"},
+		{"ProxyDenied", "/", []string{"devs"}, http.StatusForbidden, ""},
+		{"AuthOnlyAllowed", "/oauth2/auth", []string{"sre"}, http.StatusAccepted, "a"},
+		{"AuthOnlyDenied", "/oauth2/auth", []string{"devs"}, http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created := time.Now()
+
+			session := &sessions.SessionState{
+				Groups:      tt.groups,
+				Email:       "test",
+				AccessToken: "oauth_token",
+				CreatedAt:   &created,
+			}
+
+			webhookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				doc := struct {
+					Groups []string `json:"groups"`
+				}{}
+				if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				if len(doc.Groups) > 0 && doc.Groups[0] == "sre" {
+					_, _ = w.Write([]byte(`{"allow": true, "headers": {"X-Tenant": "a"}}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"allow": false, "reason": "not sre"}`))
+			}))
+			t.Cleanup(webhookServer.Close)
+
+			upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Upstream-Tenant", r.Header.Get("X-Tenant"))
+				w.WriteHeader(200)
+			}))
+			t.Cleanup(upstreamServer.Close)
+
+			test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+				opts.UpstreamServers = options.UpstreamConfig{
+					Upstreams: []options.Upstream{
+						{
+							ID:   "root",
+							Path: "/",
+							URI:  upstreamServer.URL,
+						},
+					},
+				}
+				opts.AuthorizationWebhook = &options.AuthorizationWebhook{
+					URL: webhookServer.URL,
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			test.req, _ = http.NewRequest("GET", tt.path, nil)
+			err = test.SaveSession(session)
+			assert.NoError(t, err)
+
+			rw := httptest.NewRecorder()
+			test.proxy.ServeHTTP(rw, test.req)
+
+			assert.Equal(t, tt.expectedCode, rw.Code)
+			if tt.path == "/" {
+				assert.Equal(t, tt.expectedTenant, rw.Header().Get("Upstream-Tenant"))
+			} else {
+				assert.Equal(t, tt.expectedTenant, rw.Header().Get("X-Tenant"))
+			}
+		})
+	}
+}
+
+func TestTokenRequirements(t *testing.T) {
+	tests := []struct {
+		name                    string
+		path                    string
+		forwardedURI            string
+		scopes                  []string
+		audiences               []string
+		cookieSession           bool
+		expectedCode            int
+		expectedWWWAuthenticate string
+	}{
+		{"UnrestrictedRoute", "/", "", nil, nil, false, http.StatusOK, ""},
+		{"APIRouteScopeGranted", "/api/orders", "", []string{"orders:read"}, nil, false, http.StatusOK, ""},
+		{"APIRouteScopeMissing", "/api/orders", "", []string{"openid"}, nil, false, http.StatusForbidden,
+			`Bearer error="insufficient_scope", error_description="the token is missing the required scope orders:read", scope="orders:read"`},
+		{"UpstreamAudienceAccepted", "/admin/", "", nil, []string{"https://admin.example.com"}, false, http.StatusOK, ""},
+		{"UpstreamAudienceRejected", "/admin/", "", nil, []string{"https://api.example.com"}, false, http.StatusForbidden,
+			`Bearer error="insufficient_scope", error_description="the token was not issued for an accepted audience"`},
+		{"AuthOnlyScopeGranted", "/oauth2/auth", "/api/orders", []string{"orders:read"}, nil, false, http.StatusAccepted, ""},
+		{"AuthOnlyScopeMissing", "/oauth2/auth", "/api/orders", nil, nil, false, http.StatusForbidden,
+			`Bearer error="insufficient_scope", error_description="the token is missing the required scope orders:read", scope="orders:read"`},
+		{"CookieSessionAPIRoute", "/api/orders", "", nil, nil, true, http.StatusOK, ""},
+		{"CookieSessionUpstream", "/admin/", "", nil, nil, true, http.StatusOK, ""},
+		{"CookieSessionAuthOnly", "/oauth2/auth", "/api/orders", nil, nil, true, http.StatusAccepted, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(200)
+			}))
+			t.Cleanup(upstreamServer.Close)
+
+			verifier := internaloidc.NewVerifier(
+				oidc.NewVerifier("https://issuer.example.com", NoOpKeySet{},
+					&oidc.Config{ClientID: "https://test.myapp.com", SkipExpiryCheck: true, SkipClientIDCheck: true}),
+				internaloidc.IDTokenVerificationOptions{
+					AudienceClaims: []string{"aud"},
+					ClientID:       "https://test.myapp.com",
+					ExtraAudiences: []string{"https://admin.example.com", "https://api.example.com"},
+				})
+
+			test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+				opts.UpstreamServers = options.UpstreamConfig{
+					Upstreams: []options.Upstream{
+						{
+							ID:   "root",
+							Path: "/",
+							URI:  upstreamServer.URL,
+						},
+						{
+							ID:   "admin",
+							Path: "/admin/",
+							URI:  upstreamServer.URL,
+							TokenRequirements: &options.TokenRequirements{
+								Audiences: []string{"https://admin.example.com"},
+							},
+						},
+					},
+				}
+				opts.ReverseProxy = true
+				opts.APIRouteConfig = []options.APIRoute{
+					{
+						Path: "^/api/",
+						TokenRequirements: &options.TokenRequirements{
+							Scopes: []string{"orders:read"},
+						},
+					},
+				}
+				opts.SkipJwtBearerTokens = true
+				opts.SetJWTBearerVerifiers([]internaloidc.IDTokenVerifier{verifier})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			test.req, _ = http.NewRequest("GET", tt.path, nil)
+			if tt.forwardedURI != "" {
+				test.req.Header.Set("X-Forwarded-Uri", tt.forwardedURI)
+			}
+			if tt.cookieSession {
+				// Sessions started by signing in have no scopes or audiences
+				created := time.Now()
+				err = test.SaveSession(&sessions.SessionState{Email: "test", AccessToken: "oauth_token", CreatedAt: &created})
+				assert.NoError(t, err)
+			} else {
+				claims := map[string]interface{}{
+					"iss":   "https://issuer.example.com",
+					"aud":   append([]string{"https://test.myapp.com"}, tt.audiences...),
+					"sub":   "1234567890",
+					"email": "test@example.com",
+					"exp":   time.Now().Add(time.Hour).Unix(),
+					"scope": strings.Join(tt.scopes, " "),
+				}
+				test.req.Header.Set("Authorization", "Bearer "+unsignedJWT(t, claims))
+			}
+
+			rw := httptest.NewRecorder()
+			test.proxy.ServeHTTP(rw, test.req)
+
+			assert.Equal(t, tt.expectedCode, rw.Code)
+			assert.Equal(t, tt.expectedWWWAuthenticate, rw.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+// unsignedJWT encodes the claims as a JWT with a signature that the
+// NoOpKeySet does not check
+func unsignedJWT(t *testing.T, claims map[string]interface{}) string {
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte("signature"))
+}
+
+func TestUpstreamTokenExchange(t *testing.T) {
+	tests := []struct {
+		name                  string
+		path                  string
+		accessToken           string
+		expectedCode          int
+		expectedAuthorization string
+	}{
+		{"UpstreamWithoutTokenExchange", "/", "oauth_token", http.StatusOK, ""},
+		{"UpstreamWithTokenExchange", "/orders/", "oauth_token", http.StatusOK, "Bearer orders-api:oauth_token"},
+		{"TokenExchangeFailed", "/orders/", "revoked_token", http.StatusBadGateway, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created := time.Now()
+
+			session := &sessions.SessionState{
+				Email:       "test",
+				AccessToken: tt.accessToken,
+				CreatedAt:   &created,
+			}
+
+			tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.FormValue("subject_token") == "revoked_token" {
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = w.Write([]byte(`{"error": "invalid_grant"}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"access_token": "` + r.FormValue("audience") + ":" + r.FormValue("subject_token") + `", "token_type": "Bearer", "expires_in": 300}`))
+			}))
+			t.Cleanup(tokenServer.Close)
+
+			upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Upstream-Authorization", r.Header.Get("Authorization"))
+				w.WriteHeader(200)
+			}))
+			t.Cleanup(upstreamServer.Close)
+
+			test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+				opts.UpstreamServers = options.UpstreamConfig{
+					Upstreams: []options.Upstream{
+						{
+							ID:   "root",
+							Path: "/",
+							URI:  upstreamServer.URL,
+						},
+						{
+							ID:   "orders",
+							Path: "/orders/",
+							URI:  upstreamServer.URL,
+							TokenExchange: &options.TokenExchange{
+								Audience: "orders-api",
+							},
+						},
+					},
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.proxy.provider.Data().RedeemURL, _ = url.Parse(tokenServer.URL)
+
+			test.req, _ = http.NewRequest("GET", tt.path, nil)
+			err = test.SaveSession(session)
+			assert.NoError(t, err)
+
+			rw := httptest.NewRecorder()
+			test.proxy.ServeHTTP(rw, test.req)
+
+			assert.Equal(t, tt.expectedCode, rw.Code)
+			assert.Equal(t, tt.expectedAuthorization, rw.Header().Get("Upstream-Authorization"))
+		})
+	}
+}
+
+func TestExtAuthz(t *testing.T) {
+	tests := []struct {
+		name             string
+		session          bool
+		header           http.Header
+		expectedCode     int
+		expectedLocation string
+	}{
+		{"Authenticated", true, nil, http.StatusOK, ""},
+		{"BrowserNeedsLogin", false, nil, http.StatusFound, "/oauth2/sign_in?rd=https%3A%2F%2Fapp.example.com%2Ffoo%3Fbar%3Dbaz"},
+		{"AjaxNeedsLogin", false, http.Header{"Accept": []string{applicationJSON}}, http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test, err := NewProcessCookieTestWithDefaults()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			test.req, _ = http.NewRequest("GET", "https://app.example.com/foo?bar=baz", nil)
+			for name, values := range tt.header {
+				test.req.Header[name] = values
+			}
+			if tt.session {
+				created := time.Now()
+				err = test.SaveSession(&sessions.SessionState{
+					Email:       "john@example.com",
+					AccessToken: "oauth_token",
+					CreatedAt:   &created,
+				})
+				assert.NoError(t, err)
+			}
+
+			rw := httptest.NewRecorder()
+			test.proxy.buildExtAuthzHandler(test.opts).ServeHTTP(rw, test.req)
+
+			assert.Equal(t, tt.expectedCode, rw.Code)
+			assert.Equal(t, tt.expectedLocation, rw.Header().Get("Location"))
+		})
+	}
+}
+
+func TestExtAuthzIgnoresForwardedTarget(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		expectedCode codes.Code
+	}{
+		{"Allowed", "/public/page", codes.OK},
+		{"SpoofedForwardedURI", "/admin", codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+				opts.ReverseProxy = true
+				opts.Authorization = options.Authorization{
+					Policies: []options.Policy{
+						{Name: "public", Expression: `request.host == "app.example.com" && request.path.startsWith("/public/")`},
+					},
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			test.req, _ = http.NewRequest("GET", "https://app.example.com/", nil)
+			created := time.Now()
+			err = test.SaveSession(&sessions.SessionState{
+				Email:       "john@example.com",
+				AccessToken: "oauth_token",
+				CreatedAt:   &created,
+			})
+			require.NoError(t, err)
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			server := grpc.NewServer()
+			extauthz.Register(test.proxy.buildExtAuthzHandler(test.opts))(server)
+			go func() { _ = server.Serve(listener) }()
+			t.Cleanup(server.Stop)
+
+			conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = conn.Close() })
+
+			resp, err := authv3.NewAuthorizationClient(conn).Check(context.Background(), &authv3.CheckRequest{
+				Attributes: &authv3.AttributeContext{
+					Request: &authv3.AttributeContext_Request{
+						Http: &authv3.AttributeContext_HttpRequest{
+							Method: "GET",
+							Scheme: "https",
+							Host:   "app.example.com",
+							Path:   tt.path,
+							Headers: map[string]string{
+								"cookie":             test.req.Header.Get("Cookie"),
+								"x-forwarded-host":   "app.example.com",
+								"x-forwarded-uri":    "/public/page",
+								"x-forwarded-method": "GET",
+							},
+						},
+					},
+				},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, int32(tt.expectedCode), resp.GetStatus().GetCode())
+		})
+	}
+}
+
+func TestAuthOnlyAllowedGroups(t *testing.T) {
+	testCases := []struct {
+		name               string
+		allowedGroups      []string
+		groups             []string
+		querystring        string
+		expectedStatusCode int
+	}{
+		{
+			name:               "NoAllowedGroups",
+			allowedGroups:      []string{},
+			groups:             []string{},
+			querystring:        "",
+			expectedStatusCode: http.StatusAccepted,
+		},
+		{
+			name:               "NoAllowedGroupsUserHasGroups",
+			allowedGroups:      []string{},
+			groups:             []string{"a", "b"},
+			querystring:        "",
+			expectedStatusCode: http.StatusAccepted,
+		},
+		{
+			name:               "UserInAllowedGroup",
+			allowedGroups:      []string{"a"},
+			groups:             []string{"a", "b"},
+			querystring:        "",
+			expectedStatusCode: http.StatusAccepted,
+		},
+		{
+			name:               "UserNotInAllowedGroup",
+			allowedGroups:      []string{"a"},
+			groups:             []string{"c"},
+			querystring:        "",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "UserInQuerystringGroup",
+			allowedGroups:      []string{"a", "b"},
+			groups:             []string{"a", "c"},
+			querystring:        "?allowed_groups=a",
+			expectedStatusCode: http.StatusAccepted,
+		},
+		{
+			name:               "UserInMultiParamQuerystringGroup",
+			allowedGroups:      []string{"a", "b"},
+			groups:             []string{"b"},
+			querystring:        "?allowed_groups=a&allowed_groups=b,d",
+			expectedStatusCode: http.StatusAccepted,
+		},
+		{
+			name:               "UserInOnlyQuerystringGroup",
+			allowedGroups:      []string{},
+			groups:             []string{"a", "c"},
+			querystring:        "?allowed_groups=a,b",
+			expectedStatusCode: http.StatusAccepted,
+		},
+		{
+			name:               "UserInDelimitedQuerystringGroup",
+			allowedGroups:      []string{"a", "b", "c"},
+			groups:             []string{"c"},
+			querystring:        "?allowed_groups=a,c",
+			expectedStatusCode: http.StatusAccepted,
+		},
+		{
+			name:               "UserNotInQuerystringGroup",
+			allowedGroups:      []string{},
+			groups:             []string{"c"},
+			querystring:        "?allowed_groups=a,b",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "UserInConfigGroupNotInQuerystringGroup",
+			allowedGroups:      []string{"a", "b", "c"},
+			groups:             []string{"c"},
+			querystring:        "?allowed_groups=a,b",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "UserInQuerystringGroupNotInConfigGroup",
+			allowedGroups:      []string{"a", "b"},
+			groups:             []string{"c"},
+			querystring:        "?allowed_groups=b,c",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			emailAddress := "test"
+			created := time.Now()
+
+			session := &sessions.SessionState{
+				Groups:      tc.groups,
+				Email:       emailAddress,
+				AccessToken: "oauth_token",
+				CreatedAt:   &created,
+			}
+
+			test, err := NewAuthOnlyEndpointTest(tc.querystring, func(opts *options.Options) {
+				opts.Providers[0].AllowedGroups = tc.allowedGroups
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = test.SaveSession(session)
+			assert.NoError(t, err)
+
+			test.proxy.ServeHTTP(test.rw, test.req)
+
+			assert.Equal(t, tc.expectedStatusCode, test.rw.Code)
+		})
+	}
+}
+
+func TestAuthOnlyAllowedGroupsWithSkipMethods(t *testing.T) {
+	testCases := []struct {
+		name               string
+		groups             []string
+		method             string
+		ip                 string
+		withSession        bool
+		expectedStatusCode int
+	}{
+		{
+			name:               "UserWithGroupSkipAuthPreflight",
+			groups:             []string{"a", "c"},
+			method:             "OPTIONS",
+			ip:                 "1.2.3.5:43670",
+			withSession:        true,
+			expectedStatusCode: http.StatusAccepted,
+		},
+		{
+			name:               "UserWithGroupTrustedIp",
+			groups:             []string{"a", "c"},
+			method:             "GET",
+			ip:                 "1.2.3.4:43670",
+			withSession:        true,
+			expectedStatusCode: http.StatusAccepted,
+		},
+		{
+			name:               "UserWithoutGroupSkipAuthPreflight",
+			groups:             []string{"c"},
+			method:             "OPTIONS",
+			ip:                 "1.2.3.5:43670",
+			withSession:        true,
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "UserWithoutGroupTrustedIp",
+			groups:             []string{"c"},
+			method:             "GET",
+			ip:                 "1.2.3.4:43670",
+			withSession:        true,
+			expectedStatusCode: http.StatusForbidden,
 		},
 		{
 			name:               "UserWithoutSessionSkipAuthPreflight",
@@ -3467,3 +4210,387 @@ func TestGetOAuthRedirectURI(t *testing.T) {
 		})
 	}
 }
+
+func TestBackChannelLogout(t *testing.T) {
+	newLogoutToken := func(claims map[string]interface{}) string {
+		claims["iss"] = "https://issuer.example.com"
+		claims["aud"] = "https://test.myapp.com"
+		payload, err := json.Marshal(claims)
+		assert.NoError(t, err)
+		return "eyJhbGciOiJSUzI1NiIsInR5cCI6ImxvZ291dCtqd3QifQ." +
+			base64.RawURLEncoding.EncodeToString(payload) + ".c2lnbmF0dXJl"
+	}
+	events := map[string]interface{}{internaloidc.BackChannelLogoutEvent: map[string]interface{}{}}
+
+	tests := []struct {
+		name              string
+		method            string
+		logoutToken       string
+		expectedCode      int
+		expectedAuthCode  int
+		expectedErrorCode string
+	}{
+		{
+			name:             "SessionIDMatches",
+			method:           http.MethodPost,
+			logoutToken:      newLogoutToken(map[string]interface{}{"sid": "session-1", "events": events}),
+			expectedCode:     http.StatusOK,
+			expectedAuthCode: http.StatusUnauthorized,
+		},
+		{
+			name:             "SubjectMatches",
+			method:           http.MethodPost,
+			logoutToken:      newLogoutToken(map[string]interface{}{"sub": "1234567890", "events": events}),
+			expectedCode:     http.StatusOK,
+			expectedAuthCode: http.StatusUnauthorized,
+		},
+		{
+			name:             "OtherSession",
+			method:           http.MethodPost,
+			logoutToken:      newLogoutToken(map[string]interface{}{"sub": "1234567890", "sid": "session-2", "events": events}),
+			expectedCode:     http.StatusOK,
+			expectedAuthCode: http.StatusAccepted,
+		},
+		{
+			name:              "MissingEvent",
+			method:            http.MethodPost,
+			logoutToken:       newLogoutToken(map[string]interface{}{"sid": "session-1"}),
+			expectedCode:      http.StatusBadRequest,
+			expectedAuthCode:  http.StatusAccepted,
+			expectedErrorCode: "invalid_request",
+		},
+		{
+			name:              "Nonce",
+			method:            http.MethodPost,
+			logoutToken:       newLogoutToken(map[string]interface{}{"sid": "session-1", "events": events, "nonce": "abc"}),
+			expectedCode:      http.StatusBadRequest,
+			expectedAuthCode:  http.StatusAccepted,
+			expectedErrorCode: "invalid_request",
+		},
+		{
+			name:              "MissingLogoutToken",
+			method:            http.MethodPost,
+			expectedCode:      http.StatusBadRequest,
+			expectedAuthCode:  http.StatusAccepted,
+			expectedErrorCode: "invalid_request",
+		},
+		{
+			name:              "Get",
+			method:            http.MethodGet,
+			logoutToken:       newLogoutToken(map[string]interface{}{"sid": "session-1", "events": events}),
+			expectedCode:      http.StatusMethodNotAllowed,
+			expectedAuthCode:  http.StatusAccepted,
+			expectedErrorCode: "invalid_request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test, err := NewProcessCookieTestWithDefaults()
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.proxy.provider.Data().Verifier = internaloidc.NewVerifier(
+				oidc.NewVerifier("https://issuer.example.com", NoOpKeySet{},
+					&oidc.Config{ClientID: "https://test.myapp.com", SkipExpiryCheck: true}),
+				internaloidc.IDTokenVerificationOptions{
+					AudienceClaims: []string{"aud"},
+					ClientID:       "https://test.myapp.com",
+				},
+			)
+
+			created := time.Now().Add(-time.Minute)
+			test.req, _ = http.NewRequest(http.MethodGet, "/oauth2/auth", nil)
+			err = test.SaveSession(&sessions.SessionState{
+				Email:       "john@example.com",
+				AccessToken: "oauth_token",
+				Subject:     "1234567890",
+				SessionID:   "session-1",
+				CreatedAt:   &created,
+			})
+			assert.NoError(t, err)
+
+			form := url.Values{}
+			if tt.logoutToken != "" {
+				form.Set("logout_token", tt.logoutToken)
+			}
+			logoutReq := httptest.NewRequest(tt.method, "/oauth2/backchannel-logout", strings.NewReader(form.Encode()))
+			logoutReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rw := httptest.NewRecorder()
+			test.proxy.ServeHTTP(rw, logoutReq)
+
+			assert.Equal(t, tt.expectedCode, rw.Code)
+			if tt.expectedErrorCode != "" {
+				var body map[string]string
+				assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+				assert.Equal(t, tt.expectedErrorCode, body["error"])
+			}
+
+			rw = httptest.NewRecorder()
+			test.proxy.ServeHTTP(rw, test.req)
+			assert.Equal(t, tt.expectedAuthCode, rw.Code)
+		})
+	}
+}
+
+func TestRPInitiatedLogout(t *testing.T) {
+	tests := []struct {
+		name             string
+		state            string
+		expectedCode     int
+		expectedRedirect string
+	}{
+		{
+			name:             "ValidState",
+			expectedCode:     http.StatusFound,
+			expectedRedirect: "/app",
+		},
+		{
+			name:         "InvalidState",
+			state:        "invalid",
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test, err := NewProcessCookieTestWithDefaults()
+			if err != nil {
+				t.Fatal(err)
+			}
+			providerData := test.proxy.provider.Data()
+			providerData.ClientID = "client-id"
+			providerData.RPInitiatedLogout = true
+			providerData.EndSessionURL, _ = url.Parse("https://issuer.example.com/logout?ui_locales=en")
+
+			test.req, _ = http.NewRequest(http.MethodGet, "http://example.com/oauth2/sign_out?rd=%2Fapp", nil)
+			err = test.SaveSession(&sessions.SessionState{
+				Email:       "john@example.com",
+				AccessToken: "oauth_token",
+				IDToken:     "id_token",
+			})
+			assert.NoError(t, err)
+
+			rw := httptest.NewRecorder()
+			test.proxy.ServeHTTP(rw, test.req)
+			assert.Equal(t, http.StatusFound, rw.Code)
+
+			location, err := url.Parse(rw.Header().Get("Location"))
+			assert.NoError(t, err)
+			assert.Equal(t, "issuer.example.com", location.Host)
+			assert.Equal(t, "/logout", location.Path)
+			params := location.Query()
+			assert.Equal(t, "en", params.Get("ui_locales"))
+			assert.Equal(t, "id_token", params.Get("id_token_hint"))
+			assert.Equal(t, "client-id", params.Get("client_id"))
+			assert.Equal(t, "https://example.com/oauth2/sign_out/callback", params.Get("post_logout_redirect_uri"))
+			assert.NotEmpty(t, params.Get("state"))
+
+			state := params.Get("state")
+			if tt.state != "" {
+				state = tt.state
+			}
+			callbackReq := httptest.NewRequest(http.MethodGet, "/oauth2/sign_out/callback?state="+url.QueryEscape(state), nil)
+			for _, c := range rw.Result().Cookies() {
+				if c.Name == test.opts.Cookie.Name+"_logout" {
+					callbackReq.AddCookie(c)
+				}
+			}
+			rw = httptest.NewRecorder()
+			test.proxy.ServeHTTP(rw, callbackReq)
+			assert.Equal(t, tt.expectedCode, rw.Code)
+			if tt.expectedRedirect != "" {
+				assert.Equal(t, tt.expectedRedirect, rw.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestRPInitiatedLogoutRelativeRedirectURL(t *testing.T) {
+	test, err := NewProcessCookieTestWithDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.proxy.relativeRedirectURL = true
+	providerData := test.proxy.provider.Data()
+	providerData.RPInitiatedLogout = true
+	providerData.EndSessionURL, _ = url.Parse("https://issuer.example.com/logout")
+
+	test.req, _ = http.NewRequest(http.MethodGet, "http://example.com/oauth2/sign_out?rd=%2Fapp", nil)
+	err = test.SaveSession(&sessions.SessionState{
+		Email:       "john@example.com",
+		AccessToken: "oauth_token",
+	})
+	assert.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	test.proxy.ServeHTTP(rw, test.req)
+	assert.Equal(t, http.StatusFound, rw.Code)
+
+	location, err := url.Parse(rw.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/oauth2/sign_out/callback", location.Query().Get("post_logout_redirect_uri"))
+}
+
+func TestRPInitiatedLogoutDisabled(t *testing.T) {
+	test, err := NewProcessCookieTestWithDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.proxy.provider.Data().EndSessionURL, _ = url.Parse("https://issuer.example.com/logout")
+
+	test.req, _ = http.NewRequest(http.MethodGet, "/oauth2/sign_out?rd=%2Fapp", nil)
+	err = test.SaveSession(&sessions.SessionState{
+		Email:       "john@example.com",
+		AccessToken: "oauth_token",
+	})
+	assert.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	test.proxy.ServeHTTP(rw, test.req)
+	assert.Equal(t, http.StatusFound, rw.Code)
+	assert.Equal(t, "/app", rw.Header().Get("Location"))
+}
+
+func TestBuildSessionAdminServer(t *testing.T) {
+	test, err := NewProcessCookieTestWithDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminOpts := &options.SessionAdmin{
+		Server: options.Server{BindAddress: "127.0.0.1:0"},
+		Token:  &options.SecretSource{Value: []byte("token")},
+	}
+
+	_, err = test.proxy.buildSessionAdminServer(adminOpts)
+	assert.EqualError(t, err, "the session store does not support session administration")
+
+	test.proxy.sessionStore = persistence.NewManager(sessionstests.NewMockStore(), &test.opts.Cookie)
+	server, err := test.proxy.buildSessionAdminServer(adminOpts)
+	assert.NoError(t, err)
+	assert.NotNil(t, server)
+}
+
+func TestDeviceAuthorization(t *testing.T) {
+	test, err := NewProcessCookieTestWithOptionsModifiers(func(opts *options.Options) {
+		opts.Session.Type = options.MemorySessionStoreType
+		opts.DeviceAuthorization = &options.DeviceAuthorization{ClientIDs: []string{"cli"}}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	postForm := func(path string, form url.Values, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		rw := httptest.NewRecorder()
+		test.proxy.ServeHTTP(rw, req)
+		return rw
+	}
+
+	// Only the configured clients may request device codes
+	rw := postForm("/oauth2/device/code", url.Values{"client_id": {"other"}}, nil)
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+
+	// The client requests a device code
+	rw = postForm("/oauth2/device/code", url.Values{"client_id": {"cli"}}, nil)
+	require.Equal(t, http.StatusOK, rw.Code)
+	var code struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURIComplete string `json:"verification_uri_complete"`
+	}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &code))
+	assert.Equal(t, "https://example.com/oauth2/device?user_code="+code.UserCode, code.VerificationURIComplete)
+
+	// Users that are not signed in sign in first
+	verificationPath := "/oauth2/device?user_code=" + code.UserCode
+	rw = httptest.NewRecorder()
+	test.proxy.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, verificationPath, nil))
+	assert.Equal(t, http.StatusFound, rw.Code)
+	assert.Equal(t, "/oauth2/sign_in?rd="+url.QueryEscape(verificationPath), rw.Header().Get("Location"))
+
+	// The signed in user approves the request
+	created := time.Now()
+	test.req = httptest.NewRequest(http.MethodGet, verificationPath, nil)
+	require.NoError(t, test.SaveSession(&sessions.SessionState{Email: "john.doe@example.com", AccessToken: "my_access_token", CreatedAt: &created}))
+	rw = httptest.NewRecorder()
+	test.proxy.ServeHTTP(rw, test.req)
+	require.Equal(t, http.StatusOK, rw.Code)
+	match := regexp.MustCompile(`name="csrf_token" value="([^"]+)"`).FindStringSubmatch(rw.Body.String())
+	require.Len(t, match, 2)
+
+	rw = postForm("/oauth2/device", url.Values{"user_code": {code.UserCode}, "csrf_token": {match[1]}, "action": {"approve"}}, test.req.Cookies())
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	// The client receives a bearer token that loads a session of the user
+	rw = postForm("/oauth2/device/token", url.Values{"grant_type": {device.GrantType}, "device_code": {code.DeviceCode}, "client_id": {"cli"}}, nil)
+	require.Equal(t, http.StatusOK, rw.Code)
+	var token struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+	}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &token))
+	assert.Equal(t, "Bearer", token.TokenType)
+
+	req := httptest.NewRequest(http.MethodGet, "/oauth2/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	rw = httptest.NewRecorder()
+	test.proxy.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), `"email":"john.doe@example.com"`)
+}
+
+func TestBuildDeviceHandler(t *testing.T) {
+	opts := baseTestOptions()
+	err := validation.Validate(opts)
+	require.NoError(t, err)
+	opts.DeviceAuthorization = &options.DeviceAuthorization{ClientIDs: []string{"cli"}}
+
+	sessionStore, err := sessionscookie.NewCookieSessionStore(&opts.Session, &opts.Cookie)
+	require.NoError(t, err)
+	_, err = buildDeviceHandler(opts, sessionStore)
+	assert.EqualError(t, err, "the session store does not support the device authorization grant")
+
+	_, err = buildDeviceHandler(opts, persistence.NewManager(sessionstests.NewMockStore(), &opts.Cookie))
+	assert.NoError(t, err)
+}
+
+func TestOAuthCallbackSessionLimit(t *testing.T) {
+	tests := []struct {
+		name               string
+		reject             bool
+		expectedSecondCode int
+	}{
+		{"EvictOldest", false, http.StatusFound},
+		{"Reject", true, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patTest, err := NewPassAccessTokenTest(PassAccessTokenTestOptions{ValidToken: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(patTest.Close)
+
+			manager := persistence.NewManager(sessionstests.NewMockStore(), &patTest.opts.Cookie)
+			manager.MaxConcurrent = 1
+			manager.RejectOverLimit = tt.reject
+			patTest.proxy.sessionStore = manager
+
+			code, _ := patTest.getCallbackEndpoint()
+			assert.Equal(t, http.StatusFound, code)
+
+			code, _ = patTest.getCallbackEndpoint()
+			assert.Equal(t, tt.expectedSecondCode, code)
+
+			infos, err := manager.ListSessions(context.Background(), patTest.proxy.provider.Data().ID, "michael.bland@gsa.gov")
+			assert.NoError(t, err)
+			assert.Len(t, infos, 1)
+		})
+	}
+}
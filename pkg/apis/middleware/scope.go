@@ -25,9 +25,18 @@ type RequestScope struct {
 	// Otherwise a random UUID is set.
 	RequestID string
 
+	// ClientIP is the IP address of the client that made the request.
+	// When running behind a reverse proxy this is the real client IP.
+	ClientIP string
+
 	// Session details the authenticated users information (if it exists).
 	Session *sessions.SessionState
 
+	// BearerToken indicates that the session was loaded from a bearer token in
+	// the Authorization header, rather than from the session cookie. Token
+	// requirements only apply to such sessions.
+	BearerToken bool
+
 	// SaveSession indicates whether the session storage should attempt to save
 	// the session or not.
 	SaveSession bool
@@ -39,8 +48,18 @@ type RequestScope struct {
 	// it was loaded or not.
 	SessionRevalidated bool
 
+	// AuthRequest indicates that the request asks whether another request may
+	// be proxied, as for the auth endpoint, rather than being proxied itself.
+	// Authorization then applies to the request described by the
+	// `X-Forwarded-*` headers.
+	AuthRequest bool
+
 	// Upstream tracks which upstream was used for this request
 	Upstream string
+
+	// AuthorizationHeaders holds headers returned by the authorization webhook
+	// that should be passed on with the request.
+	AuthorizationHeaders http.Header
 }
 
 // GetRequestScope returns the current request scope from the given request
@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
@@ -25,6 +26,7 @@ func CreateTokenToSessionFunc(verify VerifyFunc) TokenToSessionFunc {
 			Verified          *bool    `json:"email_verified"`
 			PreferredUsername string   `json:"preferred_username"`
 			Groups            []string `json:"groups"`
+			ScopeClaims
 		}
 
 		idToken, err := verify(ctx, token)
@@ -53,8 +55,41 @@ func CreateTokenToSessionFunc(verify VerifyFunc) TokenToSessionFunc {
 			IDToken:           token,
 			RefreshToken:      "",
 			ExpiresOn:         &idToken.Expiry,
+			Scopes:            claims.Scopes(),
+			Audiences:         idToken.Audience,
 		}
 
 		return newSession, nil
 	}
 }
+
+// ScopeClaims are the claims that hold the scopes granted to an access token.
+// The `scope` claim is a space separated string (RFC 9068), while some
+// providers issue an `scp` claim, which may also be a list.
+type ScopeClaims struct {
+	Scope interface{} `json:"scope"`
+	Scp   interface{} `json:"scp"`
+}
+
+// Scopes returns the scopes of the first scope claim that is present
+func (c ScopeClaims) Scopes() []string {
+	for _, claim := range []interface{}{c.Scope, c.Scp} {
+		switch value := claim.(type) {
+		case string:
+			if scopes := strings.Fields(value); len(scopes) > 0 {
+				return scopes
+			}
+		case []interface{}:
+			scopes := []string{}
+			for _, v := range value {
+				if scope, ok := v.(string); ok && scope != "" {
+					scopes = append(scopes, scope)
+				}
+			}
+			if len(scopes) > 0 {
+				return scopes
+			}
+		}
+	}
+	return nil
+}
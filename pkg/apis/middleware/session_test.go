@@ -0,0 +1,25 @@
+package middleware_test
+
+import (
+	"encoding/json"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Session Suite", func() {
+	DescribeTable("ScopeClaims",
+		func(payload string, expected []string) {
+			var claims middleware.ScopeClaims
+			Expect(json.Unmarshal([]byte(payload), &claims)).To(Succeed())
+			Expect(claims.Scopes()).To(Equal(expected))
+		},
+		Entry("with no scope claims", `{"sub": "123"}`, nil),
+		Entry("with a scope string", `{"scope": "openid  orders:read"}`, []string{"openid", "orders:read"}),
+		Entry("with an scp list", `{"scp": ["orders:read", "orders:write"]}`, []string{"orders:read", "orders:write"}),
+		Entry("with an scp string", `{"scp": "orders:read"}`, []string{"orders:read"}),
+		Entry("with an empty scope claim", `{"scope": "", "scp": ["orders:read"]}`, []string{"orders:read"}),
+		Entry("with both scope claims", `{"scope": "openid", "scp": ["orders:read"]}`, []string{"openid"}),
+	)
+})
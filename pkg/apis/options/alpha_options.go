@@ -41,8 +41,45 @@ type AlphaOptions struct {
 	// To use the secure server you must configure a TLS certificate and key.
 	MetricsServer Server `json:"metricsServer,omitempty"`
 
+	// ExtAuthzServer is used to configure the gRPC server implementing the
+	// Envoy external authorization API (`envoy.service.auth.v3.Authorization`).
+	// The server applies the same checks as the `/oauth2/auth` endpoint.
+	// The server is disabled unless a BindAddress or SecureBindAddress is set.
+	ExtAuthzServer Server `json:"extAuthzServer,omitempty"`
+
+	// SessionAdmin is used to configure the session admin API.
+	// The API lists and revokes the sessions of a user and requires a
+	// persistent session store.
+	SessionAdmin *SessionAdmin `json:"sessionAdmin,omitempty"`
+
 	// Providers is used to configure multiple providers.
 	Providers Providers `json:"providers,omitempty"`
+
+	// Authorization is used to configure rules that every authenticated
+	// request must satisfy, in addition to any rules configured on the
+	// upstream serving the request.
+	Authorization Authorization `json:"authorization,omitempty"`
+
+	// AuthorizationWebhook is used to configure an external authorization
+	// service that is consulted after the session has been authorized.
+	AuthorizationWebhook *AuthorizationWebhook `json:"authorizationWebhook,omitempty"`
+
+	// IdentityToken is used to configure a JWT signed by the proxy that
+	// asserts the identity of the user to upstreams.
+	// Headers source the token from a ClaimSource with the claim
+	// `identity_token`.
+	IdentityToken *IdentityToken `json:"identityToken,omitempty"`
+
+	// APIRoutes is used to configure API routes, optionally with the scopes
+	// and audiences bearer tokens must carry to access them.
+	// They are in addition to the routes given with `--api-route`.
+	APIRoutes []APIRoute `json:"apiRoutes,omitempty"`
+
+	// DeviceAuthorization is used to configure the OAuth 2.0 Device
+	// Authorization Grant, which signs in command line tools and other
+	// clients that cannot open a browser.
+	// It requires a persistent session store.
+	DeviceAuthorization *DeviceAuthorization `json:"deviceAuthorization,omitempty"`
 }
 
 // MergeInto replaces alpha options in the Options struct with the values
@@ -53,7 +90,14 @@ func (a *AlphaOptions) MergeInto(opts *Options) {
 	opts.InjectResponseHeaders = a.InjectResponseHeaders
 	opts.Server = a.Server
 	opts.MetricsServer = a.MetricsServer
+	opts.ExtAuthzServer = a.ExtAuthzServer
+	opts.SessionAdmin = a.SessionAdmin
 	opts.Providers = a.Providers
+	opts.Authorization = a.Authorization
+	opts.AuthorizationWebhook = a.AuthorizationWebhook
+	opts.IdentityToken = a.IdentityToken
+	opts.APIRouteConfig = a.APIRoutes
+	opts.DeviceAuthorization = a.DeviceAuthorization
 }
 
 // ExtractFrom populates the fields in the AlphaOptions with the values from
@@ -64,5 +108,12 @@ func (a *AlphaOptions) ExtractFrom(opts *Options) {
 	a.InjectResponseHeaders = opts.InjectResponseHeaders
 	a.Server = opts.Server
 	a.MetricsServer = opts.MetricsServer
+	a.ExtAuthzServer = opts.ExtAuthzServer
+	a.SessionAdmin = opts.SessionAdmin
 	a.Providers = opts.Providers
+	a.Authorization = opts.Authorization
+	a.AuthorizationWebhook = opts.AuthorizationWebhook
+	a.IdentityToken = opts.IdentityToken
+	a.APIRoutes = opts.APIRouteConfig
+	a.DeviceAuthorization = opts.DeviceAuthorization
 }
@@ -0,0 +1,118 @@
+package options
+
+// Authorization defines the rules an authenticated session must satisfy to be
+// granted access.
+// All of the configured rules must be satisfied for access to be granted.
+type Authorization struct {
+	// AllowedGroups restricts access to sessions that are a member of at
+	// least one of the listed groups.
+	AllowedGroups []string `json:"allowedGroups,omitempty"`
+
+	// AllowedEmails restricts access to sessions with one of the listed
+	// email addresses.
+	AllowedEmails []string `json:"allowedEmails,omitempty"`
+
+	// AllowedEmailDomains restricts access to sessions with an email address
+	// in one of the listed domains.
+	// Prefix a domain with `.` or `*.` to also allow its subdomains.
+	AllowedEmailDomains []string `json:"allowedEmailDomains,omitempty"`
+
+	// RequiredClaims restricts access to sessions that hold each of the
+	// listed claims with one of the listed values.
+	RequiredClaims []RequiredClaim `json:"requiredClaims,omitempty"`
+
+	// Policies restricts access to sessions and requests for which each of the
+	// listed policy expressions evaluates to true.
+	Policies []Policy `json:"policies,omitempty"`
+}
+
+// RequiredClaim defines a claim that must be present in the session with one
+// of the given values.
+type RequiredClaim struct {
+	// Claim is the name of the claim in the session that should be checked.
+	// Available claims are the same as for the ClaimSource of a header.
+	Claim string `json:"claim,omitempty"`
+
+	// Values is the list of accepted values for the claim.
+	// At least one of the values of the claim must match one of these.
+	Values []string `json:"values,omitempty"`
+}
+
+// Policy defines a CEL expression that must evaluate to true for access to be
+// granted.
+type Policy struct {
+	// Name identifies the policy in the auth log when it denies a request.
+	// Defaults to the expression.
+	Name string `json:"name,omitempty"`
+
+	// Expression is a CEL expression evaluated against the session and the
+	// request.
+	// The `session` variable exposes the `user`, `email`, `groups`,
+	// `preferred_username` and `provider_id` fields, and `session.claim("name")`
+	// returns the values of any claim available to headers.
+	// The `request` variable exposes the `method`, `host`, `path`, `headers`
+	// and `query` of the request. Header names are lower case.
+	// Selecting a field the session does not have fails validation.
+	// Eg: `"sre" in session.groups && request.method != "DELETE"`
+	Expression string `json:"expression,omitempty"`
+}
+
+// TokenRequirements defines the scopes and audiences the bearer token of a
+// request must carry.
+// Sessions that were not loaded from a bearer token carry neither, so they
+// never satisfy the requirements.
+type TokenRequirements struct {
+	// Scopes lists the scopes that must all be granted to the token, read
+	// from its `scope` or `scp` claim.
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Audiences restricts access to tokens issued for at least one of the
+	// listed audiences.
+	Audiences []string `json:"audiences,omitempty"`
+}
+
+// APIRoute defines a route that serves an API.
+// Unauthenticated requests to API routes receive a 401 Unauthorized response
+// instead of being redirected to sign in.
+type APIRoute struct {
+	// Path is a regular expression matched against the request path, in the
+	// same way as the `--api-route` flag.
+	Path string `json:"path,omitempty"`
+
+	// TokenRequirements are the scopes and audiences the bearer token of
+	// requests to the route must carry.
+	// Requests that do not satisfy them receive a 403 Forbidden response with
+	// an RFC 6750 `insufficient_scope` error.
+	TokenRequirements *TokenRequirements `json:"tokenRequirements,omitempty"`
+}
+
+// AuthorizationWebhook configures an external service that is asked whether
+// an authenticated session may access a request.
+type AuthorizationWebhook struct {
+	// URL is the endpoint of the authorization service.
+	// A JSON document describing the session and the request is POSTed to
+	// the URL. The service should respond with a 200 status and a JSON body
+	// of the form `{"allow": true, "reason": "...", "headers": {"X-Foo": "bar"}}`.
+	// Headers in the response are passed to the upstream with the request, or
+	// set on the response of the `/oauth2/auth` endpoint.
+	URL string `json:"url,omitempty"`
+
+	// Claims lists additional claims from the session to include in the
+	// document sent to the authorization service.
+	Claims []string `json:"claims,omitempty"`
+
+	// Timeout is the maximum duration to wait for the authorization service
+	// to respond.
+	// Defaults to 5 seconds.
+	Timeout *Duration `json:"timeout,omitempty"`
+
+	// CacheTTL is the duration for which a decision is cached for the same
+	// session and route (method, host and path).
+	// Decisions are not cached when unset or zero.
+	CacheTTL *Duration `json:"cacheTTL,omitempty"`
+
+	// FailOpen allows requests when the authorization service cannot be
+	// reached or returns an invalid response.
+	// By default such requests are denied.
+	FailOpen bool `json:"failOpen,omitempty"`
+}
@@ -8,17 +8,25 @@ import (
 
 // Cookie contains configuration options relating to Cookie configuration
 type Cookie struct {
-	Name           string        `flag:"cookie-name" cfg:"cookie_name"`
-	Secret         string        `flag:"cookie-secret" cfg:"cookie_secret"`
-	Domains        []string      `flag:"cookie-domain" cfg:"cookie_domains"`
-	Path           string        `flag:"cookie-path" cfg:"cookie_path"`
-	Expire         time.Duration `flag:"cookie-expire" cfg:"cookie_expire"`
-	Refresh        time.Duration `flag:"cookie-refresh" cfg:"cookie_refresh"`
-	Secure         bool          `flag:"cookie-secure" cfg:"cookie_secure"`
-	HTTPOnly       bool          `flag:"cookie-httponly" cfg:"cookie_httponly"`
-	SameSite       string        `flag:"cookie-samesite" cfg:"cookie_samesite"`
-	CSRFPerRequest bool          `flag:"cookie-csrf-per-request" cfg:"cookie_csrf_per_request"`
-	CSRFExpire     time.Duration `flag:"cookie-csrf-expire" cfg:"cookie_csrf_expire"`
+	Name            string        `flag:"cookie-name" cfg:"cookie_name"`
+	Secret          string        `flag:"cookie-secret" cfg:"cookie_secret"`
+	PreviousSecrets []string      `flag:"cookie-previous-secret" cfg:"cookie_previous_secrets"`
+	Domains         []string      `flag:"cookie-domain" cfg:"cookie_domains"`
+	Path            string        `flag:"cookie-path" cfg:"cookie_path"`
+	Expire          time.Duration `flag:"cookie-expire" cfg:"cookie_expire"`
+	Refresh         time.Duration `flag:"cookie-refresh" cfg:"cookie_refresh"`
+	Secure          bool          `flag:"cookie-secure" cfg:"cookie_secure"`
+	HTTPOnly        bool          `flag:"cookie-httponly" cfg:"cookie_httponly"`
+	SameSite        string        `flag:"cookie-samesite" cfg:"cookie_samesite"`
+	CSRFPerRequest  bool          `flag:"cookie-csrf-per-request" cfg:"cookie_csrf_per_request"`
+	CSRFExpire      time.Duration `flag:"cookie-csrf-expire" cfg:"cookie_csrf_expire"`
+}
+
+// Secrets returns the cookie secrets in order of preference.
+// The first secret encrypts and signs cookies, all of them are tried when
+// decrypting and validating cookies.
+func (c *Cookie) Secrets() []string {
+	return append([]string{c.Secret}, c.PreviousSecrets...)
 }
 
 func cookieFlagSet() *pflag.FlagSet {
@@ -26,6 +34,7 @@ func cookieFlagSet() *pflag.FlagSet {
 
 	flagSet.String("cookie-name", "_oauth2_proxy", "the name of the cookie that the oauth_proxy creates")
 	flagSet.String("cookie-secret", "", "the seed string for secure cookies (optionally base64 encoded)")
+	flagSet.StringSlice("cookie-previous-secret", []string{}, "previous cookie secrets that are still accepted when validating cookies, to rotate the cookie secret without signing out users (may be given multiple times)")
 	flagSet.StringSlice("cookie-domain", []string{}, "Optional cookie domains to force cookies to (ie: `.yourcompany.com`). The longest domain matching the request's host will be used (or the shortest cookie domain if there is no match).")
 	flagSet.String("cookie-path", "/", "an optional cookie path to force cookies to (ie: /poc/)*")
 	flagSet.Duration("cookie-expire", time.Duration(168)*time.Hour, "expire timeframe for cookie")
@@ -0,0 +1,44 @@
+package options
+
+import "time"
+
+const (
+	// DefaultDeviceCodeLifetime is the default lifetime of device codes
+	DefaultDeviceCodeLifetime = 10 * time.Minute
+
+	// DefaultDevicePollInterval is the default interval between token requests
+	DefaultDevicePollInterval = 5 * time.Second
+
+	// DefaultDeviceMaxPendingRequests is the default number of device codes
+	// each proxy instance keeps pending at once
+	DefaultDeviceMaxPendingRequests = 1000
+)
+
+// DeviceAuthorization configures the OAuth 2.0 Device Authorization Grant
+// (RFC 8628), which signs in clients that cannot open a browser, such as
+// command line tools.
+// The client requests a code at `/oauth2/device/code`, the user approves it
+// in a browser at `/oauth2/device`, and the client then receives a bearer
+// token for a new session from `/oauth2/device/token`.
+// Pending codes and the issued sessions are kept in the session store, which
+// must be a persistent store.
+type DeviceAuthorization struct {
+	// ClientIDs are the client IDs of the clients allowed to request device
+	// codes. Requests with other client IDs are rejected.
+	ClientIDs []string `json:"clientIDs,omitempty"`
+
+	// MaxPendingRequests is the maximum number of device codes each proxy
+	// instance keeps pending at once. Further requests are rejected until
+	// pending codes are redeemed or expire.
+	// Defaults to 1000.
+	MaxPendingRequests int `json:"maxPendingRequests,omitempty"`
+
+	// CodeLifetime is how long a device code may be approved and redeemed.
+	// Defaults to 10 minutes.
+	CodeLifetime *Duration `json:"codeLifetime,omitempty"`
+
+	// PollInterval is the minimum time clients must wait between requests
+	// to the token endpoint.
+	// Defaults to 5 seconds.
+	PollInterval *Duration `json:"pollInterval,omitempty"`
+}
@@ -31,7 +31,11 @@ type HeaderValue struct {
 type ClaimSource struct {
 	// Claim is the name of the claim in the session that the value should be
 	// loaded from. Available claims: `access_token` `id_token` `created_at`
-	// `expires_on` `refresh_token` `email` `user` `groups` `preferred_username`.
+	// `expires_on` `refresh_token` `email` `user` `groups` `preferred_username`,
+	// `scope`, and the extra claims of the provider. The `scope` claim holds the
+	// scopes granted to the bearer token of the session.
+	// The claim `identity_token` loads an identity token signed by the proxy
+	// for the session, see IdentityToken.
 	Claim string `json:"claim,omitempty"`
 
 	// Prefix is an optional prefix that will be prepended to the value of the
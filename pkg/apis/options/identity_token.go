@@ -0,0 +1,58 @@
+package options
+
+// IdentityToken configures a JWT signed by the proxy that asserts the
+// identity of the authenticated user to upstreams.
+// The token is injected into a header by a ClaimSource with the claim
+// `identity_token`, and upstreams verify it with the keys published at
+// `/oauth2/jwks.json`.
+type IdentityToken struct {
+	// Issuer is the `iss` claim of the tokens.
+	Issuer string `json:"issuer,omitempty"`
+
+	// Audiences is the `aud` claim of the tokens.
+	Audiences []string `json:"audiences,omitempty"`
+
+	// Lifetime is how long a token is valid after it was issued.
+	// A token is issued for every request.
+	// Defaults to 5 minutes.
+	Lifetime *Duration `json:"lifetime,omitempty"`
+
+	// Claims lists the claims from the session to include in the tokens.
+	// The `sub` claim is always the user of the session, and the registered
+	// claims `iss` `sub` `aud` `exp` `nbf` `iat` and `jti` cannot be included.
+	// Defaults to `email`, `groups` and `preferred_username`.
+	Claims []string `json:"claims,omitempty"`
+
+	// SigningKeys are the keys the tokens are signed with.
+	// The first key signs the tokens, the others are only published, so that
+	// tokens signed before a key rotation can still be verified. A replaced key
+	// should be kept for at least the lifetime of the tokens.
+	// When no signing keys are configured, the proxy generates its own keys.
+	SigningKeys []SigningKey `json:"signingKeys,omitempty"`
+
+	// KeyRotationInterval is how often the proxy replaces the key it
+	// generated. The replaced key is published for the KeyGracePeriod, and
+	// the key that replaces it is published one interval before it is used,
+	// so the interval should be longer than upstreams cache the JWKS.
+	// Generated keys are only published by the instance that generated them,
+	// so they are only suitable when upstreams verify tokens with the JWKS of
+	// the instance that issued them, for example with a single instance.
+	// Defaults to 24 hours.
+	KeyRotationInterval *Duration `json:"keyRotationInterval,omitempty"`
+
+	// KeyGracePeriod is how long a replaced generated key is still published.
+	// Defaults to the lifetime of the tokens.
+	KeyGracePeriod *Duration `json:"keyGracePeriod,omitempty"`
+}
+
+// SigningKey is a private key used to sign identity tokens.
+type SigningKey struct {
+	// ID is the key ID, published as `kid`.
+	// Defaults to the RFC 7638 thumbprint of the key.
+	ID string `json:"id,omitempty"`
+
+	// Key is the PEM encoded RSA or EC private key.
+	// RSA keys sign with RS256, EC keys with ES256, ES384 or ES512 depending
+	// on the curve.
+	Key *SecretSource `json:"key,omitempty"`
+}
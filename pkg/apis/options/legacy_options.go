@@ -520,9 +520,16 @@ type LegacyProvider struct {
 	OIDCJwksURL                        string   `flag:"oidc-jwks-url" cfg:"oidc_jwks_url"`
 	OIDCEmailClaim                     string   `flag:"oidc-email-claim" cfg:"oidc_email_claim"`
 	OIDCGroupsClaim                    string   `flag:"oidc-groups-claim" cfg:"oidc_groups_claim"`
+	OIDCExtraClaims                    []string `flag:"oidc-extra-claim" cfg:"oidc_extra_claims"`
 	OIDCAudienceClaims                 []string `flag:"oidc-audience-claim" cfg:"oidc_audience_claims"`
 	OIDCExtraAudiences                 []string `flag:"oidc-extra-audience" cfg:"oidc_extra_audiences"`
 	OIDCPublicKeyFiles                 []string `flag:"oidc-public-key-file" cfg:"oidc_public_key_files"`
+	OIDCEndSessionURL                  string   `flag:"oidc-end-session-url" cfg:"oidc_end_session_url"`
+	OIDCRPInitiatedLogout              bool     `flag:"oidc-rp-initiated-logout" cfg:"oidc_rp_initiated_logout"`
+	OIDCIntrospectionURL               string   `flag:"oidc-introspection-url" cfg:"oidc_introspection_url"`
+	OIDCIntrospectBearerTokens         bool     `flag:"oidc-introspect-bearer-tokens" cfg:"oidc_introspect_bearer_tokens"`
+	OIDCPushedAuthRequestURL           string   `flag:"oidc-pushed-authorization-request-url" cfg:"oidc_pushed_authorization_request_url"`
+	OIDCPushedAuthRequests             bool     `flag:"oidc-pushed-authorization-requests" cfg:"oidc_pushed_authorization_requests"`
 	LoginURL                           string   `flag:"login-url" cfg:"login_url"`
 	RedeemURL                          string   `flag:"redeem-url" cfg:"redeem_url"`
 	ProfileURL                         string   `flag:"profile-url" cfg:"profile_url"`
@@ -537,6 +544,8 @@ type LegacyProvider struct {
 	AllowedRoles                       []string `flag:"allowed-role" cfg:"allowed_roles"`
 	BackendLogoutURL                   string   `flag:"backend-logout-url" cfg:"backend_logout_url"`
 
+	OIDCIntrospectionCacheTTL time.Duration `flag:"oidc-introspection-cache-ttl" cfg:"oidc_introspection_cache_ttl"`
+
 	AcrValues  string `flag:"acr-values" cfg:"acr_values"`
 	JWTKey     string `flag:"jwt-key" cfg:"jwt_key"`
 	JWTKeyFile string `flag:"jwt-key-file" cfg:"jwt_key_file"`
@@ -580,9 +589,17 @@ func legacyProviderFlagSet() *pflag.FlagSet {
 	flagSet.String("oidc-jwks-url", "", "OpenID Connect JWKS URL (ie: https://www.googleapis.com/oauth2/v3/certs)")
 	flagSet.String("oidc-groups-claim", OIDCGroupsClaim, "which OIDC claim contains the user groups")
 	flagSet.String("oidc-email-claim", OIDCEmailClaim, "which OIDC claim contains the user's email")
+	flagSet.StringSlice("oidc-extra-claim", []string{}, "additional OIDC claim, or JSON path to a nested claim, to store in the session (may be given multiple times)")
 	flagSet.StringSlice("oidc-audience-claim", OIDCAudienceClaims, "which OIDC claims are used as audience to verify against client id")
 	flagSet.StringSlice("oidc-extra-audience", []string{}, "additional audiences allowed to pass audience verification")
 	flagSet.StringSlice("oidc-public-key-file", []string{}, "path to public key file in PEM format to use for verifying JWT tokens (may be given multiple times)")
+	flagSet.String("oidc-end-session-url", "", "OpenID Connect end session endpoint used for RP-initiated logout, when OIDC discovery is skipped")
+	flagSet.Bool("oidc-rp-initiated-logout", false, "redirect users to the OpenID Connect end session endpoint when they sign out")
+	flagSet.String("oidc-introspection-url", "", "OAuth 2.0 token introspection endpoint used to validate opaque bearer tokens, when OIDC discovery is skipped")
+	flagSet.Bool("oidc-introspect-bearer-tokens", false, "validate bearer tokens that cannot be verified locally with the token introspection endpoint (requires --skip-jwt-bearer-tokens)")
+	flagSet.Duration("oidc-introspection-cache-ttl", 0, "maximum time to cache token introspection results (default 1m)")
+	flagSet.String("oidc-pushed-authorization-request-url", "", "OAuth 2.0 pushed authorization request endpoint, when OIDC discovery is skipped")
+	flagSet.Bool("oidc-pushed-authorization-requests", false, "send the parameters of authorization requests to the pushed authorization request endpoint instead of the login URL")
 	flagSet.String("login-url", "", "Authentication endpoint")
 	flagSet.String("redeem-url", "", "Token redemption endpoint")
 	flagSet.String("profile-url", "", "Profile access endpoint")
@@ -697,9 +714,20 @@ func (l *LegacyProvider) convert() (Providers, error) {
 		UserIDClaim:                    l.UserIDClaim,
 		EmailClaim:                     l.OIDCEmailClaim,
 		GroupsClaim:                    l.OIDCGroupsClaim,
+		ExtraClaims:                    l.OIDCExtraClaims,
 		AudienceClaims:                 l.OIDCAudienceClaims,
 		ExtraAudiences:                 l.OIDCExtraAudiences,
 		PublicKeyFiles:                 l.OIDCPublicKeyFiles,
+		EndSessionURL:                  l.OIDCEndSessionURL,
+		RPInitiatedLogout:              l.OIDCRPInitiatedLogout,
+		IntrospectionURL:               l.OIDCIntrospectionURL,
+		IntrospectBearerTokens:         l.OIDCIntrospectBearerTokens,
+		PushedAuthorizationRequestURL:  l.OIDCPushedAuthRequestURL,
+		PushedAuthorizationRequests:    l.OIDCPushedAuthRequests,
+	}
+	if l.OIDCIntrospectionCacheTTL != 0 {
+		ttl := Duration(l.OIDCIntrospectionCacheTTL)
+		provider.OIDCConfig.IntrospectionCacheTTL = &ttl
 	}
 
 	// Support for legacy configuration option
@@ -3,6 +3,7 @@ package options
 import (
 	"crypto"
 	"net/url"
+	"time"
 
 	ipapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/ip"
 	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
@@ -47,11 +48,23 @@ type Options struct {
 	InjectRequestHeaders  []Header `cfg:",internal"`
 	InjectResponseHeaders []Header `cfg:",internal"`
 
-	Server        Server `cfg:",internal"`
-	MetricsServer Server `cfg:",internal"`
+	Server         Server `cfg:",internal"`
+	MetricsServer  Server `cfg:",internal"`
+	ExtAuthzServer Server `cfg:",internal"`
+
+	SessionAdmin *SessionAdmin `cfg:",internal"`
 
 	Providers Providers `cfg:",internal"`
 
+	Authorization        Authorization         `cfg:",internal"`
+	AuthorizationWebhook *AuthorizationWebhook `cfg:",internal"`
+
+	IdentityToken *IdentityToken `cfg:",internal"`
+
+	APIRouteConfig []APIRoute `cfg:",internal"`
+
+	DeviceAuthorization *DeviceAuthorization `cfg:",internal"`
+
 	APIRoutes             []string `flag:"api-route" cfg:"api_routes"`
 	SkipAuthRegex         []string `flag:"skip-auth-regex" cfg:"skip_auth_regex"`
 	SkipAuthRoutes        []string `flag:"skip-auth-route" cfg:"skip_auth_routes"`
@@ -143,6 +156,13 @@ func NewFlagSet() *pflag.FlagSet {
 	flagSet.String("ping-user-agent", "", "special User-Agent that will be used for basic health checks")
 	flagSet.String("ready-path", "/ready", "the ready endpoint that can be used for deep health checks")
 	flagSet.String("session-store-type", "cookie", "the session storage provider to use")
+	flagSet.Int("session-max-concurrent", 0, "maximum number of concurrent sessions per user; 0 is unlimited (persistent session stores only)")
+	flagSet.String("session-limit-policy", EvictOldestSessionLimitPolicy, "what to do when a login exceeds the concurrent session limit: \"evict-oldest\" or \"reject\"")
+	flagSet.Duration("session-idle-timeout", time.Duration(0), "clear sessions that have not been used for this duration; 0 to disable")
+	flagSet.Duration("session-max-lifetime", time.Duration(0), "clear sessions this long after sign in, regardless of refreshes; 0 to disable")
+	flagSet.Duration("session-activity-interval", time.Minute, "how often the last activity of a session is saved when session-idle-timeout is set")
+	flagSet.Duration("session-refresh-skew", time.Duration(0), "refresh sessions when their access token expires within this duration; 0 to disable")
+	flagSet.Duration("session-background-refresh-interval", time.Duration(0), "how often sessions used by this instance are refreshed in the background (persistent session stores only); 0 to disable")
 	flagSet.Bool("session-cookie-minimal", false, "strip OAuth tokens from cookie session stores if they aren't needed (cookie session store only)")
 	flagSet.String("redis-connection-url", "", "URL of redis server for redis session storage (eg: redis://[USER[:PASSWORD]@]HOST[:PORT])")
 	flagSet.String("redis-username", "", "Redis username. Applicable for Redis configurations where ACL has been configured. Will override any username set in `--redis-connection-url`")
@@ -156,6 +176,25 @@ func NewFlagSet() *pflag.FlagSet {
 	flagSet.Bool("redis-use-cluster", false, "Connect to redis cluster. Must set --redis-cluster-connection-urls to use this feature")
 	flagSet.StringSlice("redis-cluster-connection-urls", []string{}, "List of Redis cluster connection URLs (eg redis://[USER[:PASSWORD]@]HOST[:PORT]). Used in conjunction with --redis-use-cluster")
 	flagSet.Int("redis-connection-idle-timeout", 0, "Redis connection idle timeout seconds, if Redis timeout option is non-zero, the --redis-connection-idle-timeout must be less then Redis timeout option")
+	flagSet.String("redis-key-prefix", "", "prefix of the keys the redis session store writes, to share a redis server between several proxies")
+	flagSet.Bool("redis-client-cache", false, "cache sessions in memory, using redis server assisted client side caching to invalidate them (not supported with redis cluster)")
+	flagSet.Duration("redis-client-cache-ttl", time.Minute, "maximum duration a session is cached in memory when redis-client-cache is enabled")
+	flagSet.Int("redis-client-cache-size", 10000, "maximum number of sessions cached in memory when redis-client-cache is enabled")
+	flagSet.String("sql-driver", "", "database of the sql session store: \"postgres\", \"mysql\" or \"sqlite\"")
+	flagSet.String("sql-connection-url", "", "connection string of the sql session store database, in the format of the database driver")
+	flagSet.String("sql-table-prefix", "oauth2_proxy_", "prefix of the tables created by the sql session store")
+	flagSet.Duration("sql-reap-interval", 5*time.Minute, "how often expired sessions are removed from the sql session store")
+	flagSet.Int("memory-store-capacity", 100000, "maximum number of entries in the memory session store, two per session; the least recently used entries are removed when it is full. 0 is unlimited")
+	flagSet.String("memory-store-snapshot-path", "", "file the memory session store saves its sessions to on shutdown and restores them from on start")
+	flagSet.StringSlice("etcd-endpoints", []string{}, "list of etcd endpoints for etcd session storage (eg https://HOST:2379)")
+	flagSet.String("etcd-username", "", "etcd username, for clusters with authentication enabled")
+	flagSet.String("etcd-password", "", "etcd password, for clusters with authentication enabled")
+	flagSet.String("etcd-ca-path", "", "etcd custom CA path")
+	flagSet.String("etcd-cert-path", "", "path to the client certificate for etcd, for clusters that authenticate clients with TLS certificates")
+	flagSet.String("etcd-key-path", "", "path to the private key of the etcd client certificate")
+	flagSet.Bool("etcd-insecure-skip-tls-verify", false, "Use insecure TLS connection to etcd")
+	flagSet.String("etcd-key-prefix", "/oauth2-proxy/", "prefix of the keys the etcd session store writes")
+	flagSet.Duration("etcd-dial-timeout", 5*time.Second, "timeout for establishing a connection to etcd")
 	flagSet.String("signature-key", "", "GAP-Signature request signature key (algorithm:secretkey)")
 	flagSet.Bool("gcp-healthchecks", false, "Enable GCP/GKE healthcheck endpoints")
 
@@ -50,6 +50,8 @@ type Provider struct {
 
 	// ID should be a unique identifier for the provider.
 	// This value is required for all providers.
+	// When multiple providers are configured, the ID is used to select the
+	// provider with the `provider` query parameter of the `/oauth2/start` endpoint.
 	ID string `json:"id,omitempty"`
 	// Type is the OAuth provider
 	// must be set from the supported providers group,
@@ -245,6 +247,43 @@ type OIDCOptions struct {
 	// PublicKeyFiles is a list of paths pointing to public key files in PEM format to use
 	// for verifying JWT tokens
 	PublicKeyFiles []string `json:"publicKeyFiles,omitempty"`
+	// EndSessionURL is the OpenID Connect end session endpoint used for RP-Initiated Logout.
+	// When discovery is enabled, the discovered endpoint takes precedence.
+	// eg: https://keycloak.example.com/realms/example/protocol/openid-connect/logout
+	EndSessionURL string `json:"endSessionURL,omitempty"`
+	// RPInitiatedLogout redirects users to the provider's end session endpoint
+	// when they sign out, so that they are also logged out of the provider.
+	// The provider returns users to `/oauth2/sign_out/callback`, which must be
+	// registered as a post logout redirect URI with the provider.
+	// default set to 'false'
+	RPInitiatedLogout bool `json:"rpInitiatedLogout,omitempty"`
+	// IntrospectionURL is the OAuth 2.0 Token Introspection (RFC 7662) endpoint
+	// used to validate opaque bearer tokens.
+	// When discovery is enabled, the discovered endpoint takes precedence.
+	// eg: https://keycloak.example.com/realms/example/protocol/openid-connect/token/introspect
+	IntrospectionURL string `json:"introspectionURL,omitempty"`
+	// IntrospectBearerTokens validates bearer tokens that are not JWTs, and JWTs
+	// that cannot be verified locally, with the introspection endpoint.
+	// Tokens must be issued for the client ID or one of the ExtraAudiences.
+	// Requires SkipJwtBearerTokens to be enabled.
+	// default set to 'false'
+	IntrospectBearerTokens bool `json:"introspectBearerTokens,omitempty"`
+	// IntrospectionCacheTTL is the maximum time introspection results are
+	// cached for. Active tokens are never cached beyond their expiry.
+	// default set to '1m'
+	IntrospectionCacheTTL *Duration `json:"introspectionCacheTTL,omitempty"`
+	// PushedAuthorizationRequestURL is the OAuth 2.0 Pushed Authorization
+	// Request (RFC 9126) endpoint.
+	// When discovery is enabled, the discovered endpoint takes precedence.
+	// eg: https://keycloak.example.com/realms/example/protocol/openid-connect/ext/par/request
+	PushedAuthorizationRequestURL string `json:"pushedAuthorizationRequestURL,omitempty"`
+	// PushedAuthorizationRequests sends the parameters of the authorization
+	// request to the pushed authorization request endpoint, and redirects users
+	// to the login URL with only the `client_id` and the returned `request_uri`.
+	// Logins fall back to the full login URL when the provider has no such
+	// endpoint.
+	// default set to 'false'
+	PushedAuthorizationRequests bool `json:"pushedAuthorizationRequests,omitempty"`
 	// EmailClaim indicates which claim contains the user email,
 	// default set to 'email'
 	EmailClaim string `json:"emailClaim,omitempty"`
@@ -254,6 +293,10 @@ type OIDCOptions struct {
 	// UserIDClaim indicates which claim contains the user ID
 	// default set to 'email'
 	UserIDClaim string `json:"userIDClaim,omitempty"`
+	// ExtraClaims is a list of additional claims to store in the session, so
+	// that they can be used by claim based headers and the userinfo endpoint.
+	// Nested claims can be selected with a JSON path, eg: `org.department`
+	ExtraClaims []string `json:"extraClaims,omitempty"`
 	// AudienceClaim allows to define any claim that is verified against the client id
 	// By default `aud` claim is used for verification.
 	AudienceClaims []string `json:"audienceClaims,omitempty"`
@@ -0,0 +1,15 @@
+package options
+
+// SessionAdmin configures the session admin API, which allows sessions held
+// by a persistent session store to be listed and revoked.
+type SessionAdmin struct {
+	// Server is used to configure the HTTP(S) server for the session admin
+	// API. The API is served on its own listener so that it is not exposed
+	// alongside the proxy.
+	// The API is disabled unless a BindAddress or SecureBindAddress is set.
+	Server Server `json:"server,omitempty"`
+
+	// Token is the bearer token that requests to the session admin API must
+	// present in the Authorization header.
+	Token *SecretSource `json:"token,omitempty"`
+}
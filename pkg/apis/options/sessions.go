@@ -1,10 +1,24 @@
 package options
 
+import "time"
+
 // SessionOptions contains configuration options for the SessionStore providers.
 type SessionOptions struct {
-	Type   string             `flag:"session-store-type" cfg:"session_store_type"`
-	Cookie CookieStoreOptions `cfg:",squash"`
-	Redis  RedisStoreOptions  `cfg:",squash"`
+	Type          string             `flag:"session-store-type" cfg:"session_store_type"`
+	MaxConcurrent int                `flag:"session-max-concurrent" cfg:"session_max_concurrent"`
+	LimitPolicy   string             `flag:"session-limit-policy" cfg:"session_limit_policy"`
+	Cookie        CookieStoreOptions `cfg:",squash"`
+	Redis         RedisStoreOptions  `cfg:",squash"`
+	SQL           SQLStoreOptions    `cfg:",squash"`
+	Memory        MemoryStoreOptions `cfg:",squash"`
+	Etcd          EtcdStoreOptions   `cfg:",squash"`
+
+	IdleTimeout      time.Duration `flag:"session-idle-timeout" cfg:"session_idle_timeout"`
+	MaxLifetime      time.Duration `flag:"session-max-lifetime" cfg:"session_max_lifetime"`
+	ActivityInterval time.Duration `flag:"session-activity-interval" cfg:"session_activity_interval"`
+
+	RefreshSkew               time.Duration `flag:"session-refresh-skew" cfg:"session_refresh_skew"`
+	BackgroundRefreshInterval time.Duration `flag:"session-background-refresh-interval" cfg:"session_background_refresh_interval"`
 }
 
 // CookieSessionStoreType is used to indicate the CookieSessionStore should be
@@ -15,6 +29,27 @@ var CookieSessionStoreType = "cookie"
 // used for storing sessions.
 var RedisSessionStoreType = "redis"
 
+// SQLSessionStoreType is used to indicate the SQLSessionStore should be
+// used for storing sessions.
+var SQLSessionStoreType = "sql"
+
+// MemorySessionStoreType is used to indicate the MemorySessionStore should be
+// used for storing sessions.
+var MemorySessionStoreType = "memory"
+
+// EtcdSessionStoreType is used to indicate the EtcdSessionStore should be
+// used for storing sessions.
+var EtcdSessionStoreType = "etcd"
+
+// EvictOldestSessionLimitPolicy is used to indicate that the oldest session of
+// a user should be removed when a new session exceeds the concurrent session
+// limit.
+var EvictOldestSessionLimitPolicy = "evict-oldest"
+
+// RejectSessionLimitPolicy is used to indicate that a new session should be
+// rejected when it exceeds the concurrent session limit.
+var RejectSessionLimitPolicy = "reject"
+
 // CookieStoreOptions contains configuration options for the CookieSessionStore.
 type CookieStoreOptions struct {
 	Minimal bool `flag:"session-cookie-minimal" cfg:"session_cookie_minimal"`
@@ -34,13 +69,71 @@ type RedisStoreOptions struct {
 	CAPath                 string   `flag:"redis-ca-path" cfg:"redis_ca_path"`
 	InsecureSkipTLSVerify  bool     `flag:"redis-insecure-skip-tls-verify" cfg:"redis_insecure_skip_tls_verify"`
 	IdleTimeout            int      `flag:"redis-connection-idle-timeout" cfg:"redis_connection_idle_timeout"`
+	KeyPrefix              string   `flag:"redis-key-prefix" cfg:"redis_key_prefix"`
+
+	ClientCache     bool          `flag:"redis-client-cache" cfg:"redis_client_cache"`
+	ClientCacheTTL  time.Duration `flag:"redis-client-cache-ttl" cfg:"redis_client_cache_ttl"`
+	ClientCacheSize int           `flag:"redis-client-cache-size" cfg:"redis_client_cache_size"`
+}
+
+// SQLStoreOptions contains configuration options for the SQLSessionStore.
+type SQLStoreOptions struct {
+	Driver        string        `flag:"sql-driver" cfg:"sql_driver"`
+	ConnectionURL string        `flag:"sql-connection-url" cfg:"sql_connection_url"`
+	TablePrefix   string        `flag:"sql-table-prefix" cfg:"sql_table_prefix"`
+	ReapInterval  time.Duration `flag:"sql-reap-interval" cfg:"sql_reap_interval"`
+}
+
+// PostgresSQLDriver, MySQLDriver and SQLiteDriver are the databases supported
+// by the SQLSessionStore.
+var (
+	PostgresSQLDriver = "postgres"
+	MySQLDriver       = "mysql"
+	SQLiteDriver      = "sqlite"
+)
+
+// MemoryStoreOptions contains configuration options for the
+// MemorySessionStore.
+type MemoryStoreOptions struct {
+	Capacity     int    `flag:"memory-store-capacity" cfg:"memory_store_capacity"`
+	SnapshotPath string `flag:"memory-store-snapshot-path" cfg:"memory_store_snapshot_path"`
+}
+
+// EtcdStoreOptions contains configuration options for the EtcdSessionStore.
+type EtcdStoreOptions struct {
+	Endpoints             []string      `flag:"etcd-endpoints" cfg:"etcd_endpoints"`
+	Username              string        `flag:"etcd-username" cfg:"etcd_username"`
+	Password              string        `flag:"etcd-password" cfg:"etcd_password"`
+	CAPath                string        `flag:"etcd-ca-path" cfg:"etcd_ca_path"`
+	CertPath              string        `flag:"etcd-cert-path" cfg:"etcd_cert_path"`
+	KeyPath               string        `flag:"etcd-key-path" cfg:"etcd_key_path"`
+	InsecureSkipTLSVerify bool          `flag:"etcd-insecure-skip-tls-verify" cfg:"etcd_insecure_skip_tls_verify"`
+	KeyPrefix             string        `flag:"etcd-key-prefix" cfg:"etcd_key_prefix"`
+	DialTimeout           time.Duration `flag:"etcd-dial-timeout" cfg:"etcd_dial_timeout"`
 }
 
 func sessionOptionsDefaults() SessionOptions {
 	return SessionOptions{
-		Type: CookieSessionStoreType,
+		Type:             CookieSessionStoreType,
+		LimitPolicy:      EvictOldestSessionLimitPolicy,
+		ActivityInterval: time.Minute,
 		Cookie: CookieStoreOptions{
 			Minimal: false,
 		},
+		Redis: RedisStoreOptions{
+			ClientCacheTTL:  time.Minute,
+			ClientCacheSize: 10000,
+		},
+		SQL: SQLStoreOptions{
+			TablePrefix:  "oauth2_proxy_",
+			ReapInterval: 5 * time.Minute,
+		},
+		Memory: MemoryStoreOptions{
+			Capacity: 100000,
+		},
+		Etcd: EtcdStoreOptions{
+			KeyPrefix:   "/oauth2-proxy/",
+			DialTimeout: 5 * time.Second,
+		},
 	}
 }
@@ -93,4 +93,93 @@ type Upstream struct {
 	// Timeout is the maximum duration the server will wait for a response from the upstream server.
 	// Defaults to 30 seconds.
 	Timeout *Duration `json:"timeout,omitempty"`
+
+	// Authorization defines additional rules an authenticated session must
+	// satisfy before requests are proxied to this upstream.
+	// Requests that do not satisfy the rules receive a 403 Forbidden response.
+	Authorization *Authorization `json:"authorization,omitempty"`
+
+	// TokenRequirements are the scopes and audiences the bearer token of
+	// requests proxied to this upstream must carry.
+	// Requests that do not satisfy them receive a 403 Forbidden response with
+	// an RFC 6750 `insufficient_scope` error.
+	TokenRequirements *TokenRequirements `json:"tokenRequirements,omitempty"`
+
+	// MessageSignature signs the requests proxied to this upstream with an
+	// RFC 9421 HTTP Message Signature, so that the upstream can verify they
+	// were sent by the proxy.
+	// The `github.com/oauth2-proxy/oauth2-proxy/v7/pkg/httpsig` package
+	// verifies the signatures in Go services.
+	MessageSignature *MessageSignature `json:"messageSignature,omitempty"`
+
+	// TokenExchange exchanges the token of the session for a token issued
+	// for this upstream, with OAuth 2.0 Token Exchange (RFC 8693) at the
+	// token endpoint of the provider that issued the session.
+	// The exchanged token replaces the Authorization header of the requests
+	// proxied to this upstream.
+	TokenExchange *TokenExchange `json:"tokenExchange,omitempty"`
+}
+
+// MessageSignature configures the RFC 9421 HTTP Message Signature of the
+// requests proxied to an upstream.
+type MessageSignature struct {
+	// KeyID is the `keyid` parameter of the signatures, which identifies the
+	// key to the upstream.
+	// This value is required.
+	KeyID string `json:"keyID,omitempty"`
+
+	// Algorithm is the signature algorithm, either `hmac-sha256` or
+	// `ed25519`.
+	// Defaults to `hmac-sha256`.
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// Key is the shared secret for `hmac-sha256`, or the PEM encoded PKCS #8
+	// private key for `ed25519`.
+	Key *SecretSource `json:"key,omitempty"`
+
+	// Components are the components of the requests covered by the
+	// signatures: derived components such as `@method`, `@target-uri`,
+	// `@authority` and `@path`, and lowercase header names.
+	// Covering `content-digest` makes the proxy add a SHA-256 Content-Digest
+	// of the request body, which requires the whole body to be read into
+	// memory before the request is sent, so request bodies are no longer
+	// streamed to the upstream.
+	// Headers missing from a request are not covered by its signature.
+	// Defaults to `@method`, `@target-uri`, `authorization`,
+	// `x-forwarded-user`, `x-forwarded-email`, `x-forwarded-preferred-username`
+	// and `x-forwarded-groups`.
+	Components []string `json:"components,omitempty"`
+
+	// Label identifies the signature in the Signature-Input and Signature
+	// headers.
+	// Defaults to `sig1`.
+	Label string `json:"label,omitempty"`
+
+	// Tag is the optional `tag` parameter of the signatures, identifying the
+	// application the signatures are intended for.
+	Tag string `json:"tag,omitempty"`
+
+	// Lifetime sets the `expires` parameter of the signatures.
+	// Signatures do not expire when unset.
+	Lifetime *Duration `json:"lifetime,omitempty"`
+}
+
+// TokenExchange configures the token requested for an upstream with OAuth 2.0
+// Token Exchange (RFC 8693).
+type TokenExchange struct {
+	// Audience is the logical name of the upstream the token is requested
+	// for, sent as the `audience` parameter.
+	Audience string `json:"audience,omitempty"`
+
+	// Resource is the URI of the upstream the token is requested for, sent
+	// as the `resource` parameter.
+	Resource string `json:"resource,omitempty"`
+
+	// Scopes are the scopes requested for the token.
+	Scopes []string `json:"scopes,omitempty"`
+
+	// SubjectTokenType selects the token of the session that is exchanged,
+	// either `access_token` or `id_token`.
+	// Defaults to `access_token`.
+	SubjectTokenType string `json:"subjectTokenType,omitempty"`
 }
@@ -15,6 +15,64 @@ type SessionStore interface {
 	VerifyConnection(ctx context.Context) error
 }
 
+// SessionRevoker is implemented by session stores that can remove sessions
+// without the session cookie, for example when the provider requests a
+// back-channel logout.
+type SessionRevoker interface {
+	// RevokeSessions removes all sessions issued by the provider that have the
+	// given provider session ID, or the given subject when sessionID is empty.
+	RevokeSessions(ctx context.Context, providerID, subject, sessionID string) error
+}
+
+// TokenIssuer is implemented by session stores that can issue bearer tokens
+// for sessions, for clients that present a token in the Authorization header
+// instead of a session cookie.
+type TokenIssuer interface {
+	// IssueToken saves the session as a new session and returns a bearer
+	// token that loads it. The token expires with the session.
+	IssueToken(req *http.Request, s *SessionState) (string, error)
+}
+
+// SessionAdministrator is implemented by session stores that can find the
+// sessions of a user, so that administrators can inspect and revoke them.
+type SessionAdministrator interface {
+	// ListSessions returns the sessions of the user of the provider with the
+	// given user name or email address.
+	ListSessions(ctx context.Context, providerID, user string) ([]SessionInfo, error)
+	// RevokeSession removes the session with the given ID.
+	// ErrSessionNotFound is returned when no such session exists.
+	RevokeSession(ctx context.Context, id string) error
+	// RevokeUserSessions removes all sessions of the user of the provider
+	// with the given user name or email address.
+	RevokeUserSessions(ctx context.Context, providerID, user string) error
+}
+
+// SessionInfo describes a stored session without any of its tokens.
+type SessionInfo struct {
+	ID         string     `json:"id"`
+	ProviderID string     `json:"providerID,omitempty"`
+	User       string     `json:"user,omitempty"`
+	Email      string     `json:"email,omitempty"`
+	Subject    string     `json:"subject,omitempty"`
+	SessionID  string     `json:"sessionID,omitempty"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty"`
+	IssuedAt   *time.Time `json:"issuedAt,omitempty"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	IP         string     `json:"ip,omitempty"`
+	UserAgent  string     `json:"userAgent,omitempty"`
+}
+
+// IssueTime returns when the user signed in. Sessions recorded without an
+// issue time are considered issued when they were created.
+func (i *SessionInfo) IssueTime() *time.Time {
+	if i.IssuedAt != nil {
+		return i.IssuedAt
+	}
+	return i.CreatedAt
+}
+
+var ErrSessionNotFound = errors.New("session not found")
+var ErrSessionLimitExceeded = errors.New("concurrent session limit exceeded")
 var ErrLockNotObtained = errors.New("lock: not obtained")
 var ErrNotLocked = errors.New("tried to release not existing lock")
 
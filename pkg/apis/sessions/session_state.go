@@ -7,6 +7,7 @@ import (
 	"io"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
 	"github.com/pierrec/lz4/v4"
@@ -18,6 +19,13 @@ type SessionState struct {
 	CreatedAt *time.Time `msgpack:"ca,omitempty"`
 	ExpiresOn *time.Time `msgpack:"eo,omitempty"`
 
+	// IssuedAt is the time the user signed in. Unlike CreatedAt, it is not
+	// reset when the session is refreshed.
+	// LastActivity is the start of the most recent period in which the
+	// session was used.
+	IssuedAt     *time.Time `msgpack:"ia,omitempty"`
+	LastActivity *time.Time `msgpack:"la,omitempty"`
+
 	AccessToken  string `msgpack:"at,omitempty"`
 	IDToken      string `msgpack:"it,omitempty"`
 	RefreshToken string `msgpack:"rt,omitempty"`
@@ -29,6 +37,26 @@ type SessionState struct {
 	Groups            []string `msgpack:"g,omitempty"`
 	PreferredUsername string   `msgpack:"pu,omitempty"`
 
+	// ProviderID identifies the provider that issued the session.
+	// An empty value refers to the default (first configured) provider.
+	ProviderID string `msgpack:"pi,omitempty"`
+
+	// Subject and SessionID are the `sub` and `sid` claims of the ID Token.
+	// They identify the session to the provider, for example when the
+	// provider requests a back-channel logout.
+	Subject   string `msgpack:"sub,omitempty"`
+	SessionID string `msgpack:"sid,omitempty"`
+
+	// Claims holds the values of the additional claims configured to be
+	// stored in the session, by claim name or JSON path.
+	Claims map[string][]string `msgpack:"cl,omitempty"`
+
+	// Scopes and Audiences are the scopes granted to, and the audiences of,
+	// the bearer token the session was loaded from. API routes and upstreams
+	// can require them.
+	Scopes    []string `msgpack:"sc,omitempty"`
+	Audiences []string `msgpack:"au,omitempty"`
+
 	// Internal helpers, not serialized
 	Clock clock.Clock `msgpack:"-"`
 	Lock  Lock        `msgpack:"-"`
@@ -91,6 +119,94 @@ func (s *SessionState) IsExpired() bool {
 	return false
 }
 
+// AccessTokenExpiresOn returns when the access token expires. This is
+// ExpiresOn if set, otherwise the `exp` claim of a JWT access token.
+// The JWT is not verified, the expiry is only used to refresh in time.
+func (s *SessionState) AccessTokenExpiresOn() *time.Time {
+	if s.ExpiresOn != nil && !s.ExpiresOn.IsZero() {
+		return s.ExpiresOn
+	}
+	if s.AccessToken == "" {
+		return nil
+	}
+
+	token, _, err := jwt.NewParser().ParseUnverified(s.AccessToken, jwt.MapClaims{})
+	if err != nil {
+		return nil
+	}
+	exp, err := token.Claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return nil
+	}
+	return &exp.Time
+}
+
+// AccessTokenExpiresWithin checks whether the access token expires within
+// the duration. Access tokens without a known expiry never do.
+func (s *SessionState) AccessTokenExpiresWithin(d time.Duration) bool {
+	expiresOn := s.AccessTokenExpiresOn()
+	if expiresOn == nil {
+		return false
+	}
+	return expiresOn.Before(s.Clock.Now().Add(d))
+}
+
+// IssuedAtNow sets a SessionState's IssuedAt and LastActivity to now
+func (s *SessionState) IssuedAtNow() {
+	now := s.Clock.Now()
+	s.IssuedAt = &now
+	s.LastActivity = &now
+}
+
+// IsIdle checks whether the session has not been used for longer than the
+// timeout. Sessions without recorded activity are considered last used when
+// they were created.
+func (s *SessionState) IsIdle(timeout time.Duration) bool {
+	lastActivity := s.LastActivity
+	if lastActivity == nil {
+		lastActivity = s.CreatedAt
+	}
+	if timeout <= 0 || lastActivity == nil || lastActivity.IsZero() {
+		return false
+	}
+	return s.Clock.Now().Sub(*lastActivity) > timeout
+}
+
+// IssueTime returns when the user signed in. Unlike CreatedAt, it does not
+// change when the session is refreshed. Sessions without an issue time are
+// considered issued when they were created.
+func (s *SessionState) IssueTime() *time.Time {
+	if s.IssuedAt != nil {
+		return s.IssuedAt
+	}
+	return s.CreatedAt
+}
+
+// IsPastLifetime checks whether the session was issued longer than the
+// maximum lifetime ago.
+func (s *SessionState) IsPastLifetime(maxLifetime time.Duration) bool {
+	issuedAt := s.IssueTime()
+	if maxLifetime <= 0 || issuedAt == nil || issuedAt.IsZero() {
+		return false
+	}
+	return s.Clock.Now().Sub(*issuedAt) > maxLifetime
+}
+
+// TouchActivity records activity on the session.
+// LastActivity is truncated to the interval so that it only changes once per
+// interval. It reports whether LastActivity changed.
+func (s *SessionState) TouchActivity(interval time.Duration) bool {
+	now := s.Clock.Now()
+	if interval > 0 {
+		now = now.Truncate(interval)
+	}
+	if s.LastActivity != nil && s.LastActivity.Equal(now) {
+		return false
+	}
+	s.LastActivity = &now
+	return true
+}
+
 // Age returns the age of a session
 func (s *SessionState) Age() time.Duration {
 	if s.CreatedAt != nil && !s.CreatedAt.IsZero() {
@@ -120,6 +236,12 @@ func (s *SessionState) String() string {
 	if len(s.Groups) > 0 {
 		o += fmt.Sprintf(" groups:%v", s.Groups)
 	}
+	if s.ProviderID != "" {
+		o += fmt.Sprintf(" provider:%s", s.ProviderID)
+	}
+	if len(s.Scopes) > 0 {
+		o += fmt.Sprintf(" scopes:%v", s.Scopes)
+	}
 	return o + "}"
 }
 
@@ -148,8 +270,18 @@ func (s *SessionState) GetClaim(claim string) []string {
 		return groups
 	case "preferred_username":
 		return []string{s.PreferredUsername}
+	case "scope":
+		// The scopes of bearer tokens are kept in Scopes rather than claims
+		if len(s.Scopes) > 0 {
+			scopes := make([]string, len(s.Scopes))
+			copy(scopes, s.Scopes)
+			return scopes
+		}
+		fallthrough
 	default:
-		return []string{}
+		values := make([]string, len(s.Claims[claim]))
+		copy(values, s.Claims[claim])
+		return values
 	}
 }
 
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
 	. "github.com/onsi/gomega"
 	"github.com/stretchr/testify/assert"
@@ -147,6 +148,26 @@ func TestIsExpired(t *testing.T) {
 	assert.Equal(t, false, s.IsExpired())
 }
 
+func TestAccessTokenExpiresWithin(t *testing.T) {
+	s := &SessionState{ExpiresOn: timePtr(time.Now().Add(time.Minute))}
+	assert.Equal(t, true, s.AccessTokenExpiresWithin(2*time.Minute))
+	assert.Equal(t, false, s.AccessTokenExpiresWithin(30*time.Second))
+
+	// Falls back to the exp claim of a JWT access token
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	s = &SessionState{AccessToken: token}
+	assert.Equal(t, true, s.AccessTokenExpiresWithin(2*time.Minute))
+	assert.Equal(t, false, s.AccessTokenExpiresWithin(30*time.Second))
+
+	// Opaque access tokens have no known expiry
+	s = &SessionState{AccessToken: "opaque"}
+	assert.Nil(t, s.AccessTokenExpiresOn())
+	assert.Equal(t, false, s.AccessTokenExpiresWithin(time.Hour))
+}
+
 func TestAge(t *testing.T) {
 	ss := &SessionState{}
 
@@ -158,6 +179,91 @@ func TestAge(t *testing.T) {
 	assert.Equal(t, time.Hour, ss.Age().Round(time.Minute))
 }
 
+func TestIsIdle(t *testing.T) {
+	s := &SessionState{LastActivity: timePtr(time.Now().Add(-31 * time.Minute))}
+	assert.Equal(t, true, s.IsIdle(30*time.Minute))
+	assert.Equal(t, false, s.IsIdle(time.Hour))
+	assert.Equal(t, false, s.IsIdle(0))
+
+	// Falls back to CreatedAt without recorded activity
+	s = &SessionState{CreatedAt: timePtr(time.Now().Add(-31 * time.Minute))}
+	assert.Equal(t, true, s.IsIdle(30*time.Minute))
+
+	s = &SessionState{}
+	assert.Equal(t, false, s.IsIdle(30*time.Minute))
+}
+
+func TestIssueTime(t *testing.T) {
+	issuedAt := time.Now().Add(-time.Hour)
+	createdAt := time.Now()
+
+	s := &SessionState{IssuedAt: &issuedAt, CreatedAt: &createdAt}
+	assert.Equal(t, &issuedAt, s.IssueTime())
+
+	s = &SessionState{CreatedAt: &createdAt}
+	assert.Equal(t, &createdAt, s.IssueTime())
+
+	s = &SessionState{}
+	assert.Nil(t, s.IssueTime())
+}
+
+func TestIsPastLifetime(t *testing.T) {
+	s := &SessionState{
+		IssuedAt:  timePtr(time.Now().Add(-13 * time.Hour)),
+		CreatedAt: timePtr(time.Now()),
+	}
+	assert.Equal(t, true, s.IsPastLifetime(12*time.Hour))
+	assert.Equal(t, false, s.IsPastLifetime(24*time.Hour))
+	assert.Equal(t, false, s.IsPastLifetime(0))
+
+	// Falls back to CreatedAt without an issue time
+	s = &SessionState{CreatedAt: timePtr(time.Now().Add(-13 * time.Hour))}
+	assert.Equal(t, true, s.IsPastLifetime(12*time.Hour))
+
+	s = &SessionState{}
+	assert.Equal(t, false, s.IsPastLifetime(12*time.Hour))
+}
+
+func TestTouchActivity(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC)
+	ss := &SessionState{}
+	ss.Clock.Set(now)
+
+	g.Expect(ss.TouchActivity(time.Minute)).To(BeTrue())
+	g.Expect(*ss.LastActivity).To(Equal(now.Truncate(time.Minute)))
+
+	// Activity within the same interval does not change the session
+	g.Expect(ss.Clock.Add(20 * time.Second)).To(Succeed())
+	g.Expect(ss.TouchActivity(time.Minute)).To(BeFalse())
+
+	g.Expect(ss.Clock.Add(20 * time.Second)).To(Succeed())
+	g.Expect(ss.TouchActivity(time.Minute)).To(BeTrue())
+	g.Expect(*ss.LastActivity).To(Equal(now.Add(time.Minute).Truncate(time.Minute)))
+}
+
+func TestGetClaim(t *testing.T) {
+	ss := &SessionState{
+		Email: "user@example.com",
+		Claims: map[string][]string{
+			"department":  {"engineering"},
+			"org.tenants": {"tenant-a", "tenant-b"},
+		},
+	}
+	assert.Equal(t, []string{"user@example.com"}, ss.GetClaim("email"))
+	assert.Equal(t, []string{"engineering"}, ss.GetClaim("department"))
+	assert.Equal(t, []string{"tenant-a", "tenant-b"}, ss.GetClaim("org.tenants"))
+	assert.Equal(t, []string{}, ss.GetClaim("missing"))
+	assert.Equal(t, []string{}, ss.GetClaim("scope"))
+
+	ss.Scopes = []string{"openid", "api:read"}
+	assert.Equal(t, []string{"openid", "api:read"}, ss.GetClaim("scope"))
+
+	// The claims of the session cannot be changed through the returned values
+	ss.GetClaim("department")[0] = "sales"
+	assert.Equal(t, []string{"engineering"}, ss.Claims["department"])
+}
+
 // TestEncodeAndDecodeSessionState encodes & decodes various session states
 // and confirms the operation is 1:1
 func TestEncodeAndDecodeSessionState(t *testing.T) {
@@ -225,6 +331,30 @@ func TestEncodeAndDecodeSessionState(t *testing.T) {
 			Nonce:             []byte("abcdef1234567890abcdef1234567890"),
 			Groups:            []string{"group-a", "group-b"},
 		},
+		"With claims": {
+			Email:        "username@example.com",
+			User:         "username",
+			AccessToken:  "AccessToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			IDToken:      "IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			CreatedAt:    &created,
+			ExpiresOn:    &expires,
+			RefreshToken: "RefreshToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			Claims: map[string][]string{
+				"department":  {"engineering"},
+				"org.tenants": {"tenant-a", "tenant-b"},
+			},
+		},
+		"With activity": {
+			Email:        "username@example.com",
+			User:         "username",
+			AccessToken:  "AccessToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			IDToken:      "IDToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+			CreatedAt:    &created,
+			ExpiresOn:    &expires,
+			IssuedAt:     &created,
+			LastActivity: &created,
+			RefreshToken: "RefreshToken.12349871293847fdsaihf9238h4f91h8fr.1349f831y98fd7",
+		},
 	}
 
 	for _, secretSize := range []int{16, 24, 32} {
@@ -270,25 +400,30 @@ func TestEncodeAndDecodeSessionState(t *testing.T) {
 }
 
 func compareSessionStates(t *testing.T, expected *SessionState, actual *SessionState) {
-	if expected.CreatedAt != nil {
-		assert.NotNil(t, actual.CreatedAt)
-		assert.Equal(t, true, expected.CreatedAt.Equal(*actual.CreatedAt))
-	} else {
-		assert.Nil(t, actual.CreatedAt)
-	}
-	if expected.ExpiresOn != nil {
-		assert.NotNil(t, actual.ExpiresOn)
-		assert.Equal(t, true, expected.ExpiresOn.Equal(*actual.ExpiresOn))
-	} else {
-		assert.Nil(t, actual.ExpiresOn)
-	}
+	compareTimes(t, expected.CreatedAt, actual.CreatedAt)
+	compareTimes(t, expected.ExpiresOn, actual.ExpiresOn)
+	compareTimes(t, expected.IssuedAt, actual.IssuedAt)
+	compareTimes(t, expected.LastActivity, actual.LastActivity)
 
 	// Compare sessions without *time.Time fields
 	exp := *expected
 	exp.CreatedAt = nil
 	exp.ExpiresOn = nil
+	exp.IssuedAt = nil
+	exp.LastActivity = nil
 	act := *actual
 	act.CreatedAt = nil
 	act.ExpiresOn = nil
+	act.IssuedAt = nil
+	act.LastActivity = nil
 	assert.Equal(t, exp, act)
 }
+
+func compareTimes(t *testing.T, expected *time.Time, actual *time.Time) {
+	if expected != nil {
+		assert.NotNil(t, actual)
+		assert.Equal(t, true, expected.Equal(*actual))
+	} else {
+		assert.Nil(t, actual)
+	}
+}
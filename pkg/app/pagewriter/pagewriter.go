@@ -49,6 +49,10 @@ type Opts struct {
 	// ProviderName is the name of the provider that should be displayed on the login button.
 	ProviderName string
 
+	// Providers is the list of providers a user may choose from on the sign-in page.
+	// If empty, a single login button for ProviderName is displayed.
+	Providers []SignInProvider
+
 	// SignInMessage is the messge displayed above the login button.
 	SignInMessage string
 
@@ -84,6 +88,7 @@ func NewWriter(opts Opts) (Writer, error) {
 		errorPageWriter:  errorPage,
 		proxyPrefix:      opts.ProxyPrefix,
 		providerName:     opts.ProviderName,
+		providers:        opts.Providers,
 		signInMessage:    opts.SignInMessage,
 		footer:           opts.Footer,
 		version:          opts.Version,
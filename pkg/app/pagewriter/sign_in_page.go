@@ -20,6 +20,15 @@ import (
 //go:embed default_logo.svg
 var defaultLogoData string
 
+// SignInProvider describes a provider that a user can choose to sign in with.
+type SignInProvider struct {
+	// ID is the provider ID passed to the OAuth start endpoint.
+	ID string
+
+	// Name is the name of the provider displayed on the login button.
+	Name string
+}
+
 // signInPageWriter is used to render sign-in pages.
 type signInPageWriter struct {
 	// Template is the sign-in page HTML template.
@@ -34,6 +43,9 @@ type signInPageWriter struct {
 	// ProviderName is the name of the provider that should be displayed on the login button.
 	providerName string
 
+	// Providers is the list of providers to render a login button for.
+	providers []SignInProvider
+
 	// SignInMessage is the messge displayed above the login button.
 	signInMessage string
 
@@ -55,8 +67,14 @@ type signInPageWriter struct {
 // WriteSignInPage writes the sign-in page to the given response writer.
 // It uses the redirectURL to be able to set the final destination for the user post login.
 func (s *signInPageWriter) WriteSignInPage(rw http.ResponseWriter, req *http.Request, redirectURL string, statusCode int) {
+	providers := s.providers
+	if len(providers) == 0 {
+		providers = []SignInProvider{{Name: s.providerName}}
+	}
+
 	t := struct {
 		ProviderName  string
+		Providers     []SignInProvider
 		SignInMessage template.HTML
 		StatusCode    int
 		CustomLogin   bool
@@ -67,6 +85,7 @@ func (s *signInPageWriter) WriteSignInPage(rw http.ResponseWriter, req *http.Req
 		LogoData      template.HTML
 	}{
 		ProviderName:  s.providerName,
+		Providers:     providers,
 		SignInMessage: template.HTML(s.signInMessage), // #nosec G203 -- We allow unescaped template.HTML since it is user configured options
 		StatusCode:    statusCode,
 		CustomLogin:   s.displayLoginForm,
@@ -60,6 +60,36 @@ var _ = Describe("SignIn Page", func() {
 				Expect(string(body)).To(Equal("/prefix/ My Provider Sign In Here Custom Footer Text v0.0.0-test /redirect true Logo Data"))
 			})
 
+			It("Writes a single provider from the provider name when no providers are set", func() {
+				tmpl, err := template.New("").Parse("{{range .Providers}}[{{.ID}}:{{.Name}}]{{end}}")
+				Expect(err).ToNot(HaveOccurred())
+				signInPage.template = tmpl
+
+				recorder := httptest.NewRecorder()
+				signInPage.WriteSignInPage(recorder, request, "/redirect", http.StatusOK)
+
+				body, err := io.ReadAll(recorder.Result().Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("[:My Provider]"))
+			})
+
+			It("Writes each of the configured providers", func() {
+				tmpl, err := template.New("").Parse("{{range .Providers}}[{{.ID}}:{{.Name}}]{{end}}")
+				Expect(err).ToNot(HaveOccurred())
+				signInPage.template = tmpl
+				signInPage.providers = []SignInProvider{
+					{ID: "entra", Name: "Entra ID"},
+					{ID: "github", Name: "GitHub"},
+				}
+
+				recorder := httptest.NewRecorder()
+				signInPage.WriteSignInPage(recorder, request, "/redirect", http.StatusOK)
+
+				body, err := io.ReadAll(recorder.Result().Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("[entra:Entra ID][github:GitHub]"))
+			})
+
 			It("Writes an error if the template can't be rendered", func() {
 				// Overwrite the template with something bad
 				tmpl, err := template.New("").Parse("{{.Unknown}}")
@@ -43,6 +43,7 @@ var _ = Describe("Templates", func() {
 				// For default sign_in template
 				SignInMessage string
 				ProviderName  string
+				Providers     []SignInProvider
 				CustomLogin   bool
 				LogoData      string
 
@@ -61,6 +62,7 @@ var _ = Describe("Templates", func() {
 
 				SignInMessage: "<sign-in-message>",
 				ProviderName:  "<provider-name>",
+				Providers:     []SignInProvider{{Name: "<provider-name>"}},
 				CustomLogin:   false,
 				LogoData:      "<logo>",
 
@@ -0,0 +1,147 @@
+package authorization
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/util"
+)
+
+// Authorizer decides whether an authenticated session may access a request.
+type Authorizer interface {
+	// Authorize returns nil if the session is allowed to access the request.
+	// Otherwise the returned error describes why access was denied.
+	Authorize(req *http.Request, session *sessionsapi.SessionState) error
+}
+
+// rule is a single authorization constraint.
+// It returns an error describing the reason for denial when the constraint
+// is not satisfied.
+type rule func(req *http.Request, session *sessionsapi.SessionState) error
+
+// authorizer grants access only when all of its rules are satisfied.
+type authorizer struct {
+	rules []rule
+}
+
+// NewAuthorizer constructs an Authorizer from the authorization options.
+// When no options are given, the Authorizer allows all sessions.
+func NewAuthorizer(opts *options.Authorization) (Authorizer, error) {
+	a := &authorizer{}
+	if opts == nil {
+		return a, nil
+	}
+
+	if len(opts.AllowedGroups) > 0 {
+		a.rules = append(a.rules, allowedGroupsRule(opts.AllowedGroups))
+	}
+	if len(opts.AllowedEmails) > 0 {
+		a.rules = append(a.rules, allowedEmailsRule(opts.AllowedEmails))
+	}
+	if len(opts.AllowedEmailDomains) > 0 {
+		a.rules = append(a.rules, allowedEmailDomainsRule(opts.AllowedEmailDomains))
+	}
+	for _, claim := range opts.RequiredClaims {
+		if claim.Claim == "" {
+			return nil, errors.New("required claim has empty name")
+		}
+		a.rules = append(a.rules, requiredClaimRule(claim))
+	}
+	for _, policy := range opts.Policies {
+		r, err := policyRule(policy)
+		if err != nil {
+			return nil, err
+		}
+		a.rules = append(a.rules, r)
+	}
+
+	return a, nil
+}
+
+// Authorize checks the session against each of the rules in turn.
+// The first rule that is not satisfied determines the reason for denial.
+func (a *authorizer) Authorize(req *http.Request, session *sessionsapi.SessionState) error {
+	if session == nil {
+		if len(a.rules) == 0 {
+			return nil
+		}
+		return errors.New("no session")
+	}
+
+	for _, r := range a.rules {
+		if err := r(req, session); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allowedGroupsRule requires the session to be a member of at least one of
+// the allowed groups.
+func allowedGroupsRule(groups []string) rule {
+	allowed := make(map[string]struct{}, len(groups))
+	for _, group := range groups {
+		allowed[group] = struct{}{}
+	}
+
+	return func(_ *http.Request, session *sessionsapi.SessionState) error {
+		for _, group := range session.Groups {
+			if _, ok := allowed[group]; ok {
+				return nil
+			}
+		}
+		return errors.New("session is not a member of any allowed group")
+	}
+}
+
+// allowedEmailsRule requires the session email to be one of the allowed emails.
+func allowedEmailsRule(emails []string) rule {
+	return func(_ *http.Request, session *sessionsapi.SessionState) error {
+		for _, email := range emails {
+			if session.Email != "" && strings.EqualFold(email, session.Email) {
+				return nil
+			}
+		}
+		return fmt.Errorf("email %q is not allowed", session.Email)
+	}
+}
+
+// allowedEmailDomainsRule requires the session email to be within one of the
+// allowed domains.
+// Domains follow the same rules as the whitelist domains, so a leading `.` or
+// `*.` allows subdomains.
+func allowedEmailDomainsRule(domains []string) rule {
+	return func(_ *http.Request, session *sessionsapi.SessionState) error {
+		splitEmail := strings.Split(session.Email, "@")
+		if len(splitEmail) == 2 {
+			endpoint := &url.URL{Host: strings.ToLower(splitEmail[1])}
+			if util.IsEndpointAllowed(endpoint, domains) {
+				return nil
+			}
+		}
+		return fmt.Errorf("email %q is not in an allowed domain", session.Email)
+	}
+}
+
+// requiredClaimRule requires the session claim to have at least one of the
+// accepted values.
+func requiredClaimRule(claim options.RequiredClaim) rule {
+	accepted := make(map[string]struct{}, len(claim.Values))
+	for _, value := range claim.Values {
+		accepted[value] = struct{}{}
+	}
+
+	return func(_ *http.Request, session *sessionsapi.SessionState) error {
+		for _, value := range session.GetClaim(claim.Claim) {
+			if _, ok := accepted[value]; ok {
+				return nil
+			}
+		}
+		return fmt.Errorf("claim %q does not have a required value", claim.Claim)
+	}
+}
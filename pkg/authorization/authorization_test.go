@@ -0,0 +1,111 @@
+package authorization
+
+import (
+	"net/http/httptest"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Authorizer", func() {
+	type authorizeTableInput struct {
+		options     *options.Authorization
+		session     *sessionsapi.SessionState
+		expectedErr string
+	}
+
+	session := &sessionsapi.SessionState{
+		User:   "john",
+		Email:  "john@example.com",
+		Groups: []string{"devs", "ops"},
+	}
+
+	DescribeTable("Authorize",
+		func(in authorizeTableInput) {
+			authorizer, err := NewAuthorizer(in.options)
+			Expect(err).ToNot(HaveOccurred())
+
+			req := httptest.NewRequest("GET", "/", nil)
+			err = authorizer.Authorize(req, in.session)
+			if in.expectedErr != "" {
+				Expect(err).To(MatchError(in.expectedErr))
+			} else {
+				Expect(err).ToNot(HaveOccurred())
+			}
+		},
+		Entry("with no options", authorizeTableInput{
+			options: nil,
+			session: session,
+		}),
+		Entry("with no options and no session", authorizeTableInput{
+			options: nil,
+			session: nil,
+		}),
+		Entry("with rules and no session", authorizeTableInput{
+			options:     &options.Authorization{AllowedGroups: []string{"devs"}},
+			session:     nil,
+			expectedErr: "no session",
+		}),
+		Entry("with a matching group", authorizeTableInput{
+			options: &options.Authorization{AllowedGroups: []string{"admins", "ops"}},
+			session: session,
+		}),
+		Entry("without a matching group", authorizeTableInput{
+			options:     &options.Authorization{AllowedGroups: []string{"admins"}},
+			session:     session,
+			expectedErr: "session is not a member of any allowed group",
+		}),
+		Entry("with a matching email", authorizeTableInput{
+			options: &options.Authorization{AllowedEmails: []string{"John@Example.com"}},
+			session: session,
+		}),
+		Entry("without a matching email", authorizeTableInput{
+			options:     &options.Authorization{AllowedEmails: []string{"jane@example.com"}},
+			session:     session,
+			expectedErr: "email \"john@example.com\" is not allowed",
+		}),
+		Entry("with a matching email domain", authorizeTableInput{
+			options: &options.Authorization{AllowedEmailDomains: []string{"example.com"}},
+			session: session,
+		}),
+		Entry("with a matching wildcard email domain", authorizeTableInput{
+			options: &options.Authorization{AllowedEmailDomains: []string{".example.com"}},
+			session: &sessionsapi.SessionState{Email: "john@corp.example.com"},
+		}),
+		Entry("without a matching email domain", authorizeTableInput{
+			options:     &options.Authorization{AllowedEmailDomains: []string{"example.org"}},
+			session:     session,
+			expectedErr: "email \"john@example.com\" is not in an allowed domain",
+		}),
+		Entry("with a matching required claim", authorizeTableInput{
+			options: &options.Authorization{
+				RequiredClaims: []options.RequiredClaim{{Claim: "user", Values: []string{"john"}}},
+			},
+			session: session,
+		}),
+		Entry("without a matching required claim", authorizeTableInput{
+			options: &options.Authorization{
+				RequiredClaims: []options.RequiredClaim{{Claim: "groups", Values: []string{"admins"}}},
+			},
+			session:     session,
+			expectedErr: "claim \"groups\" does not have a required value",
+		}),
+		Entry("when one of several rules is not satisfied", authorizeTableInput{
+			options: &options.Authorization{
+				AllowedGroups:       []string{"devs"},
+				AllowedEmailDomains: []string{"example.org"},
+			},
+			session:     session,
+			expectedErr: "email \"john@example.com\" is not in an allowed domain",
+		}),
+	)
+
+	It("rejects a required claim with an empty name", func() {
+		_, err := NewAuthorizer(&options.Authorization{
+			RequiredClaims: []options.RequiredClaim{{Values: []string{"foo"}}},
+		})
+		Expect(err).To(MatchError("required claim has empty name"))
+	})
+})
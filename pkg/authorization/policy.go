@@ -0,0 +1,281 @@
+package authorization
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
+)
+
+// policyEnv returns the CEL environment in which policy expressions are
+// compiled. The environment is shared by all policies.
+//
+// Expressions have access to two variables:
+//   - `session`: the session being authorized. Fields `user`, `email`,
+//     `groups`, `preferred_username` and `provider_id` may be selected, and
+//     `session.claim("name")` returns the values of any claim the session can
+//     provide, including the extra claims stored in the session.
+//   - `request`: the request being authorized, with fields `method`, `host`,
+//     `path`, `headers` and `query`. Header names are lower case.
+var policyEnv = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.CustomTypeProvider(sessionTypeProvider{Provider: types.NewEmptyRegistry()}),
+		cel.Variable("session", sessionType),
+		cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Function("claim",
+			cel.MemberOverload("session_claim_string",
+				[]*cel.Type{sessionType, cel.StringType},
+				cel.ListType(cel.StringType),
+				cel.BinaryBinding(sessionClaim),
+			),
+		),
+	)
+})
+
+// ValidatePolicy checks that the expression compiles to a boolean result.
+func ValidatePolicy(expression string) error {
+	_, err := compilePolicy(expression)
+	return err
+}
+
+// compilePolicy compiles the expression into a program ready for evaluation.
+func compilePolicy(expression string) (cel.Program, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, errors.New("expression is empty")
+	}
+
+	env, err := policyEnv()
+	if err != nil {
+		return nil, fmt.Errorf("could not create policy environment: %v", err)
+	}
+
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if !ast.OutputType().IsExactType(cel.BoolType) && !ast.OutputType().IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("expression must evaluate to a bool, not %s", ast.OutputType())
+	}
+
+	return env.Program(ast)
+}
+
+// policyRule builds a rule that grants access only when the compiled
+// expression of the policy evaluates to true.
+func policyRule(policy options.Policy) (rule, error) {
+	name := policy.Name
+	if name == "" {
+		name = policy.Expression
+	}
+
+	program, err := compilePolicy(policy.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("could not compile policy %q: %v", name, err)
+	}
+
+	return func(req *http.Request, session *sessionsapi.SessionState) error {
+		out, _, err := program.ContextEval(req.Context(), map[string]any{
+			"session": sessionValue{session: session},
+			"request": requestAttributes(req),
+		})
+		if err != nil {
+			return fmt.Errorf("policy %q could not be evaluated: %v", name, err)
+		}
+		if allowed, ok := out.Value().(bool); !ok || !allowed {
+			return fmt.Errorf("denied by policy %q", name)
+		}
+		return nil
+	}, nil
+}
+
+// requestAttributes extracts the attributes of the request that are exposed
+// to policy expressions.
+func requestAttributes(req *http.Request) map[string]any {
+	method, host, path, query := requestTarget(req)
+
+	headers := make(map[string]string, len(req.Header))
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+
+	params := make(map[string]string, len(query))
+	for name, values := range query {
+		params[name] = strings.Join(values, ",")
+	}
+
+	return map[string]any{
+		"method":  method,
+		"host":    host,
+		"path":    path,
+		"headers": headers,
+		"query":   params,
+	}
+}
+
+// requestTarget returns the method, host, path and query of the request.
+// Requests that ask whether another request may be proxied, such as those of
+// the auth endpoint, describe the original method, host and URI in their
+// forwarded headers. Other requests are authorized for the method, host and
+// path they are proxied with.
+func requestTarget(req *http.Request) (string, string, string, url.Values) {
+	path := req.URL.Path
+	query := req.URL.Query()
+	if scope := middlewareapi.GetRequestScope(req); scope == nil || !scope.AuthRequest {
+		return req.Method, req.Host, path, query
+	}
+	if u, err := url.ParseRequestURI(requestutil.GetRequestURI(req)); err == nil {
+		path = u.Path
+		query = u.Query()
+	}
+	return requestutil.GetRequestMethod(req), requestutil.GetRequestHost(req), path, query
+}
+
+// sessionTypeName is the name of the CEL type of the session variable.
+const sessionTypeName = "oauth2_proxy.Session"
+
+// sessionType is the CEL type of the session variable.
+var sessionType = cel.ObjectType(sessionTypeName)
+
+// sessionFields are the fields of the session that policies may select.
+// Declaring them lets expressions that refer to other fields fail to compile.
+var sessionFields = map[string]*types.FieldType{
+	"user":               sessionField(cel.StringType, func(s *sessionsapi.SessionState) any { return s.User }),
+	"email":              sessionField(cel.StringType, func(s *sessionsapi.SessionState) any { return s.Email }),
+	"groups":             sessionField(cel.ListType(cel.StringType), func(s *sessionsapi.SessionState) any { return s.Groups }),
+	"preferred_username": sessionField(cel.StringType, func(s *sessionsapi.SessionState) any { return s.PreferredUsername }),
+	"provider_id":        sessionField(cel.StringType, func(s *sessionsapi.SessionState) any { return s.ProviderID }),
+}
+
+// sessionField declares a field of the session type that is read with get.
+func sessionField(t *cel.Type, get func(*sessionsapi.SessionState) any) *types.FieldType {
+	return &types.FieldType{
+		Type:  t,
+		IsSet: func(any) bool { return true },
+		GetFrom: func(target any) (any, error) {
+			session, ok := target.(*sessionsapi.SessionState)
+			if !ok || session == nil {
+				return nil, errors.New("no session")
+			}
+			value := get(session)
+			if groups, ok := value.([]string); ok && groups == nil {
+				return []string{}, nil
+			}
+			return value, nil
+		},
+	}
+}
+
+// sessionTypeProvider declares the session type in addition to the types of
+// the registry it wraps.
+type sessionTypeProvider struct {
+	types.Provider
+}
+
+// FindStructType implements types.Provider
+func (p sessionTypeProvider) FindStructType(typeName string) (*types.Type, bool) {
+	if typeName == sessionTypeName {
+		return types.NewTypeTypeWithParam(sessionType), true
+	}
+	return p.Provider.FindStructType(typeName)
+}
+
+// FindStructFieldNames implements types.Provider
+func (p sessionTypeProvider) FindStructFieldNames(typeName string) ([]string, bool) {
+	if typeName == sessionTypeName {
+		names := make([]string, 0, len(sessionFields))
+		for name := range sessionFields {
+			names = append(names, name)
+		}
+		return names, true
+	}
+	return p.Provider.FindStructFieldNames(typeName)
+}
+
+// FindStructFieldType implements types.Provider
+func (p sessionTypeProvider) FindStructFieldType(typeName, fieldName string) (*types.FieldType, bool) {
+	if typeName == sessionTypeName {
+		field, ok := sessionFields[fieldName]
+		return field, ok
+	}
+	return p.Provider.FindStructFieldType(typeName, fieldName)
+}
+
+// sessionValue adapts a SessionState into a CEL value.
+// Fields are resolved on demand so that only the parts of the session a
+// policy refers to are converted.
+type sessionValue struct {
+	session *sessionsapi.SessionState
+}
+
+// ConvertToNative implements ref.Val
+func (v sessionValue) ConvertToNative(typeDesc reflect.Type) (any, error) {
+	if reflect.TypeOf(v.session).AssignableTo(typeDesc) {
+		return v.session, nil
+	}
+	return nil, fmt.Errorf("type conversion error from session to '%v'", typeDesc)
+}
+
+// ConvertToType implements ref.Val
+func (v sessionValue) ConvertToType(typeVal ref.Type) ref.Val {
+	if typeVal == types.TypeType {
+		return sessionType
+	}
+	return types.NewErr("type conversion error from session to '%v'", typeVal)
+}
+
+// Equal implements ref.Val
+func (v sessionValue) Equal(other ref.Val) ref.Val {
+	o, ok := other.(sessionValue)
+	return types.Bool(ok && o.session == v.session)
+}
+
+// Type implements ref.Val
+func (v sessionValue) Type() ref.Type {
+	return sessionType
+}
+
+// Value implements ref.Val
+func (v sessionValue) Value() any {
+	return v.session
+}
+
+// Get implements traits.Indexer to allow fields of the session to be selected.
+func (v sessionValue) Get(index ref.Val) ref.Val {
+	name, ok := index.(types.String)
+	if !ok {
+		return types.NewErr("no such session field: %v", index)
+	}
+	field, ok := sessionFields[string(name)]
+	if !ok {
+		return types.NewErr("no such session field: %s", name)
+	}
+	value, err := field.GetFrom(v.session)
+	if err != nil {
+		return types.WrapErr(err)
+	}
+	return types.DefaultTypeAdapter.NativeToValue(value)
+}
+
+// sessionClaim implements `session.claim("name")` using SessionState.GetClaim.
+func sessionClaim(lhs, rhs ref.Val) ref.Val {
+	v, ok := lhs.(sessionValue)
+	if !ok {
+		return types.NewErr("claim() may only be called on the session")
+	}
+	claim, ok := rhs.(types.String)
+	if !ok {
+		return types.NewErr("claim name must be a string")
+	}
+	return types.NewStringList(types.DefaultTypeAdapter, v.session.GetClaim(string(claim)))
+}
@@ -0,0 +1,154 @@
+package authorization
+
+import (
+	"net/http/httptest"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Policies", func() {
+	type policyTableInput struct {
+		expression  string
+		method      string
+		target      string
+		headers     map[string]string
+		proxied     bool
+		authRequest bool
+		expectedErr string
+	}
+
+	session := &sessionsapi.SessionState{
+		User:              "john",
+		Email:             "john@corp.com",
+		Groups:            []string{"sre", "devs"},
+		PreferredUsername: "Johnny",
+		ProviderID:        "corp",
+		Claims: map[string][]string{
+			"org.department": {"engineering"},
+		},
+	}
+
+	DescribeTable("Authorize",
+		func(in policyTableInput) {
+			authorizer, err := NewAuthorizer(&options.Authorization{
+				Policies: []options.Policy{{Name: "test", Expression: in.expression}},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			method := in.method
+			if method == "" {
+				method = "GET"
+			}
+			req := httptest.NewRequest(method, in.target, nil)
+			for name, value := range in.headers {
+				req.Header.Set(name, value)
+			}
+			req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{
+				ReverseProxy: in.proxied,
+				AuthRequest:  in.authRequest,
+			})
+
+			err = authorizer.Authorize(req, session)
+			if in.expectedErr != "" {
+				Expect(err).To(MatchError(ContainSubstring(in.expectedErr)))
+			} else {
+				Expect(err).ToNot(HaveOccurred())
+			}
+		},
+		Entry("with a group and method policy that is satisfied", policyTableInput{
+			expression: `"sre" in session.groups && request.method != "DELETE"`,
+			target:     "/",
+		}),
+		Entry("with a group and method policy that is not satisfied", policyTableInput{
+			expression:  `"sre" in session.groups && request.method != "DELETE"`,
+			method:      "DELETE",
+			target:      "/",
+			expectedErr: `denied by policy "test"`,
+		}),
+		Entry("with an email and path policy that is satisfied", policyTableInput{
+			expression: `session.email.endsWith("@corp.com") && request.path.startsWith("/admin")`,
+			target:     "/admin/users",
+		}),
+		Entry("with an email and path policy that is not satisfied", policyTableInput{
+			expression:  `session.email.endsWith("@corp.com") && request.path.startsWith("/admin")`,
+			target:      "/users",
+			expectedErr: `denied by policy "test"`,
+		}),
+		Entry("with a claim policy", policyTableInput{
+			expression: `session.claim("preferred_username") == ["Johnny"]`,
+			target:     "/",
+		}),
+		Entry("with an extra claim policy", policyTableInput{
+			expression: `"engineering" in session.claim("org.department")`,
+			target:     "/",
+		}),
+		Entry("with a header and query policy", policyTableInput{
+			expression: `request.headers["x-tenant"] == "a" && request.query["debug"] == "true"`,
+			target:     "/?debug=true",
+			headers:    map[string]string{"X-Tenant": "a"},
+		}),
+		Entry("with a forwarded request", policyTableInput{
+			expression: `request.path == "/forwarded" && request.host == "app.corp.com"`,
+			target:     "/oauth2/auth",
+			headers: map[string]string{
+				"X-Forwarded-Uri":  "/forwarded?a=b",
+				"X-Forwarded-Host": "app.corp.com",
+			},
+			proxied:     true,
+			authRequest: true,
+		}),
+		Entry("with forwarded headers on a proxied request", policyTableInput{
+			expression: `request.path == "/forwarded" || request.host == "app.corp.com"`,
+			target:     "http://upstream.corp.com/admin",
+			headers: map[string]string{
+				"X-Forwarded-Uri":  "/forwarded?a=b",
+				"X-Forwarded-Host": "app.corp.com",
+			},
+			proxied:     true,
+			expectedErr: `denied by policy "test"`,
+		}),
+		Entry("with the forwarded method of an auth request", policyTableInput{
+			expression: `request.method != "DELETE"`,
+			target:     "/oauth2/auth",
+			headers: map[string]string{
+				"X-Forwarded-Method": "DELETE",
+			},
+			proxied:     true,
+			authRequest: true,
+			expectedErr: `denied by policy "test"`,
+		}),
+		Entry("with a forwarded method on a proxied request", policyTableInput{
+			expression: `request.method != "DELETE"`,
+			target:     "http://upstream.corp.com/admin",
+			headers: map[string]string{
+				"X-Forwarded-Method": "DELETE",
+			},
+			proxied: true,
+		}),
+		Entry("with the provider ID", policyTableInput{
+			expression: `session.provider_id == "corp" && session.user == "john"`,
+			target:     "/",
+		}),
+	)
+
+	DescribeTable("ValidatePolicy",
+		func(expression string, expectedErr string) {
+			err := ValidatePolicy(expression)
+			if expectedErr != "" {
+				Expect(err).To(MatchError(ContainSubstring(expectedErr)))
+			} else {
+				Expect(err).ToNot(HaveOccurred())
+			}
+		},
+		Entry("with a valid expression", `"sre" in session.groups`, ""),
+		Entry("with an empty expression", "  ", "expression is empty"),
+		Entry("with a syntax error", `session.email ==`, "Syntax error"),
+		Entry("with an unknown variable", `user.email == "foo"`, "undeclared reference to 'user'"),
+		Entry("with an unknown session field", `session.unknown == "foo"`, "undefined field 'unknown'"),
+		Entry("with a non boolean result", `session.claim("email")`, "expression must evaluate to a bool, not list(string)"),
+	)
+})
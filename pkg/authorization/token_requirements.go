@@ -0,0 +1,102 @@
+package authorization
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+)
+
+// InsufficientScopeError is returned when the bearer token of a request does
+// not carry the scopes or audiences required for the request.
+// It is reported to the client with an RFC 6750 `insufficient_scope` error.
+type InsufficientScopeError struct {
+	// Scopes are the scopes required for the request
+	Scopes []string
+
+	// Description explains which requirement was not satisfied
+	Description string
+}
+
+// Error returns the description of the error
+func (e *InsufficientScopeError) Error() string {
+	return e.Description
+}
+
+// WWWAuthenticate returns the value of the WWW-Authenticate header of the
+// response, as described in RFC 6750 section 3.
+func (e *InsufficientScopeError) WWWAuthenticate() string {
+	// Neither the descriptions nor the scopes, which are validated, contain
+	// characters that must be escaped in a quoted string
+	value := `Bearer error="insufficient_scope", error_description="` + e.Description + `"`
+	if len(e.Scopes) > 0 {
+		value += `, scope="` + strings.Join(e.Scopes, " ") + `"`
+	}
+	return value
+}
+
+// tokenRequirements requires the session to carry the scopes and audiences
+// of the bearer token it was loaded from.
+type tokenRequirements struct {
+	scopes    []string
+	audiences map[string]struct{}
+}
+
+// NewTokenRequirementsAuthorizer constructs an Authorizer that returns an
+// InsufficientScopeError for sessions that do not satisfy the requirements.
+// When no requirements are given, the Authorizer allows all sessions.
+func NewTokenRequirementsAuthorizer(opts *options.TokenRequirements) Authorizer {
+	t := &tokenRequirements{}
+	if opts == nil {
+		return t
+	}
+
+	t.scopes = opts.Scopes
+	if len(opts.Audiences) > 0 {
+		t.audiences = make(map[string]struct{}, len(opts.Audiences))
+		for _, audience := range opts.Audiences {
+			t.audiences[audience] = struct{}{}
+		}
+	}
+	return t
+}
+
+// Authorize checks that the session has been granted every required scope,
+// and that it was issued for one of the required audiences.
+func (t *tokenRequirements) Authorize(_ *http.Request, session *sessionsapi.SessionState) error {
+	if len(t.scopes) == 0 && len(t.audiences) == 0 {
+		return nil
+	}
+
+	var scopes, audiences []string
+	if session != nil {
+		scopes, audiences = session.Scopes, session.Audiences
+	}
+
+	granted := make(map[string]struct{}, len(scopes))
+	for _, scope := range scopes {
+		granted[scope] = struct{}{}
+	}
+	for _, scope := range t.scopes {
+		if _, ok := granted[scope]; !ok {
+			return &InsufficientScopeError{
+				Scopes:      t.scopes,
+				Description: "the token is missing the required scope " + scope,
+			}
+		}
+	}
+
+	if len(t.audiences) == 0 {
+		return nil
+	}
+	for _, audience := range audiences {
+		if _, ok := t.audiences[audience]; ok {
+			return nil
+		}
+	}
+	return &InsufficientScopeError{
+		Scopes:      t.scopes,
+		Description: "the token was not issued for an accepted audience",
+	}
+}
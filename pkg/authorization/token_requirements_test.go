@@ -0,0 +1,92 @@
+package authorization
+
+import (
+	"net/http/httptest"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Token requirements", func() {
+	type tokenRequirementsTableInput struct {
+		options                 *options.TokenRequirements
+		session                 *sessionsapi.SessionState
+		expectedErr             string
+		expectedWWWAuthenticate string
+	}
+
+	session := &sessionsapi.SessionState{
+		User:      "client",
+		Scopes:    []string{"openid", "orders:read", "orders:write"},
+		Audiences: []string{"https://api.example.com", "https://other.example.com"},
+	}
+
+	DescribeTable("Authorize",
+		func(in tokenRequirementsTableInput) {
+			authorizer := NewTokenRequirementsAuthorizer(in.options)
+
+			req := httptest.NewRequest("GET", "/", nil)
+			err := authorizer.Authorize(req, in.session)
+			if in.expectedErr == "" {
+				Expect(err).ToNot(HaveOccurred())
+				return
+			}
+
+			Expect(err).To(MatchError(in.expectedErr))
+			insufficientScope, ok := err.(*InsufficientScopeError)
+			Expect(ok).To(BeTrue())
+			Expect(insufficientScope.WWWAuthenticate()).To(Equal(in.expectedWWWAuthenticate))
+		},
+		Entry("with no requirements", tokenRequirementsTableInput{
+			options: nil,
+			session: &sessionsapi.SessionState{},
+		}),
+		Entry("with granted scopes", tokenRequirementsTableInput{
+			options: &options.TokenRequirements{
+				Scopes: []string{"orders:read", "orders:write"},
+			},
+			session: session,
+		}),
+		Entry("with a missing scope", tokenRequirementsTableInput{
+			options: &options.TokenRequirements{
+				Scopes: []string{"orders:read", "orders:delete"},
+			},
+			session:                 session,
+			expectedErr:             "the token is missing the required scope orders:delete",
+			expectedWWWAuthenticate: `Bearer error="insufficient_scope", error_description="the token is missing the required scope orders:delete", scope="orders:read orders:delete"`,
+		}),
+		Entry("with an accepted audience", tokenRequirementsTableInput{
+			options: &options.TokenRequirements{
+				Scopes:    []string{"orders:read"},
+				Audiences: []string{"https://unrelated.example.com", "https://other.example.com"},
+			},
+			session: session,
+		}),
+		Entry("with no accepted audience", tokenRequirementsTableInput{
+			options: &options.TokenRequirements{
+				Audiences: []string{"https://unrelated.example.com"},
+			},
+			session:                 session,
+			expectedErr:             "the token was not issued for an accepted audience",
+			expectedWWWAuthenticate: `Bearer error="insufficient_scope", error_description="the token was not issued for an accepted audience"`,
+		}),
+		Entry("with a session not loaded from a bearer token", tokenRequirementsTableInput{
+			options: &options.TokenRequirements{
+				Scopes: []string{"orders:read"},
+			},
+			session:                 &sessionsapi.SessionState{User: "john"},
+			expectedErr:             "the token is missing the required scope orders:read",
+			expectedWWWAuthenticate: `Bearer error="insufficient_scope", error_description="the token is missing the required scope orders:read", scope="orders:read"`,
+		}),
+		Entry("with no session", tokenRequirementsTableInput{
+			options: &options.TokenRequirements{
+				Audiences: []string{"https://api.example.com"},
+			},
+			session:                 nil,
+			expectedErr:             "the token was not issued for an accepted audience",
+			expectedWWWAuthenticate: `Bearer error="insufficient_scope", error_description="the token was not issued for an accepted audience"`,
+		}),
+	)
+})
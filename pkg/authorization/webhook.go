@@ -0,0 +1,247 @@
+package authorization
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	ipapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/ip"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/ip"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
+)
+
+// DefaultWebhookTimeout is the default duration to wait for the authorization
+// webhook to respond.
+const DefaultWebhookTimeout = 5 * time.Second
+
+// WebhookDecision is the response of the authorization webhook.
+type WebhookDecision struct {
+	// Allow is true when the session may access the request.
+	Allow bool `json:"allow"`
+
+	// Reason optionally describes the decision and is written to the auth log
+	// when access is denied.
+	Reason string `json:"reason,omitempty"`
+
+	// Headers are passed on with an allowed request.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// webhookRequest is the document sent to the authorization webhook.
+type webhookRequest struct {
+	User              string              `json:"user"`
+	Email             string              `json:"email"`
+	PreferredUsername string              `json:"preferredUsername,omitempty"`
+	Groups            []string            `json:"groups"`
+	ProviderID        string              `json:"providerID,omitempty"`
+	Claims            map[string][]string `json:"claims,omitempty"`
+	Method            string              `json:"method"`
+	Host              string              `json:"host"`
+	Path              string              `json:"path"`
+	ClientIP          string              `json:"clientIP"`
+}
+
+// Webhook asks an external service whether a session may access a request.
+type Webhook struct {
+	url      string
+	claims   []string
+	timeout  time.Duration
+	failOpen bool
+	ipParser ipapi.RealClientIPParser
+	cache    *decisionCache
+}
+
+// NewWebhook constructs a Webhook from the webhook options.
+// The IP parser is used to determine the client IP sent to the service and
+// may be nil.
+func NewWebhook(opts *options.AuthorizationWebhook, ipParser ipapi.RealClientIPParser) (*Webhook, error) {
+	if opts.URL == "" {
+		return nil, errors.New("authorization webhook url is required")
+	}
+
+	w := &Webhook{
+		url:      opts.URL,
+		claims:   opts.Claims,
+		timeout:  DefaultWebhookTimeout,
+		failOpen: opts.FailOpen,
+		ipParser: ipParser,
+	}
+	if opts.Timeout != nil && opts.Timeout.Duration() > 0 {
+		w.timeout = opts.Timeout.Duration()
+	}
+	if opts.CacheTTL != nil && opts.CacheTTL.Duration() > 0 {
+		w.cache = newDecisionCache(opts.CacheTTL.Duration())
+	}
+
+	return w, nil
+}
+
+// Check returns the decision of the webhook for the session and request.
+// Decisions are cached per session and route when a cache TTL is configured.
+// When the webhook cannot be reached or responds with an invalid decision,
+// the request is allowed only if the webhook is configured to fail open.
+func (w *Webhook) Check(req *http.Request, session *sessionsapi.SessionState) *WebhookDecision {
+	doc := w.buildRequest(req, session)
+
+	var key string
+	if w.cache != nil {
+		key = cacheKey(session, doc)
+		if decision, ok := w.cache.get(key); ok {
+			return decision
+		}
+	}
+
+	decision, err := w.call(req.Context(), doc)
+	if err != nil {
+		logger.Errorf("Error calling authorization webhook: %v", err)
+		return &WebhookDecision{
+			Allow:  w.failOpen,
+			Reason: fmt.Sprintf("authorization webhook failed: %v", err),
+		}
+	}
+
+	if w.cache != nil {
+		w.cache.set(key, decision)
+	}
+	return decision
+}
+
+// buildRequest builds the document describing the session and request.
+// The method, host and path are those policies are evaluated against: the
+// request being proxied, or the forwarded request for requests to the auth
+// endpoint.
+func (w *Webhook) buildRequest(req *http.Request, session *sessionsapi.SessionState) *webhookRequest {
+	method, host, path, _ := requestTarget(req)
+
+	doc := &webhookRequest{
+		User:              session.User,
+		Email:             session.Email,
+		PreferredUsername: session.PreferredUsername,
+		Groups:            session.Groups,
+		ProviderID:        session.ProviderID,
+		Method:            method,
+		Host:              host,
+		Path:              path,
+		ClientIP:          ip.GetClientString(w.ipParser, req, false),
+	}
+	if doc.Groups == nil {
+		doc.Groups = []string{}
+	}
+
+	if len(w.claims) > 0 {
+		doc.Claims = make(map[string][]string, len(w.claims))
+		for _, claim := range w.claims {
+			doc.Claims[claim] = session.GetClaim(claim)
+		}
+	}
+
+	return doc
+}
+
+// call POSTs the document to the webhook and decodes the decision.
+func (w *Webhook) call(ctx context.Context, doc *webhookRequest) (*WebhookDecision, error) {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling request: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	decision := &WebhookDecision{}
+	err = requests.New(w.url).
+		WithContext(ctx).
+		WithMethod("POST").
+		WithBody(bytes.NewReader(body)).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("Accept", "application/json").
+		Do().
+		UnmarshalInto(decision)
+	if err != nil {
+		return nil, err
+	}
+
+	return decision, nil
+}
+
+// cacheKey identifies the session and route of a request.
+// The session is identified by its provider, user and issue time so that
+// the key is stable across token refreshes.
+func cacheKey(session *sessionsapi.SessionState, doc *webhookRequest) string {
+	var issuedAt string
+	if t := session.IssueTime(); t != nil {
+		issuedAt = t.UTC().Format(time.RFC3339Nano)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(strings.Join([]string{
+		session.ProviderID, session.User, session.Email, issuedAt,
+		doc.Method, doc.Host, doc.Path,
+	}, "\x00")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// decisionCache holds webhook decisions until they expire.
+type decisionCache struct {
+	ttl time.Duration
+
+	lock      sync.Mutex
+	entries   map[string]cachedDecision
+	lastSweep time.Time
+	clock     clock.Clock
+}
+
+type cachedDecision struct {
+	decision *WebhookDecision
+	expires  time.Time
+}
+
+func newDecisionCache(ttl time.Duration) *decisionCache {
+	return &decisionCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedDecision),
+	}
+}
+
+// get returns the cached decision if it has not expired.
+func (c *decisionCache) get(key string) (*WebhookDecision, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !c.clock.Now().Before(entry.expires) {
+		return nil, false
+	}
+	return entry.decision, true
+}
+
+// set caches the decision for the TTL.
+// Expired entries are removed at most once per TTL.
+func (c *decisionCache) set(key string, decision *WebhookDecision) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.clock.Now()
+	if now.Sub(c.lastSweep) >= c.ttl {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	c.entries[key] = cachedDecision{decision: decision, expires: now.Add(c.ttl)}
+}
@@ -0,0 +1,238 @@
+package authorization
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Webhook", func() {
+	var (
+		server   *httptest.Server
+		calls    int32
+		received *webhookRequest
+		respond  func(rw http.ResponseWriter, doc *webhookRequest)
+		session  *sessionsapi.SessionState
+	)
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&calls, 0)
+		received = nil
+		respond = func(rw http.ResponseWriter, doc *webhookRequest) {
+			rw.Write([]byte(`{"allow": true, "headers": {"X-Tenant": "a"}}`))
+		}
+
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			defer GinkgoRecover()
+			atomic.AddInt32(&calls, 1)
+
+			Expect(req.Method).To(Equal("POST"))
+			Expect(req.Header.Get("Content-Type")).To(Equal("application/json"))
+
+			doc := &webhookRequest{}
+			Expect(json.NewDecoder(req.Body).Decode(doc)).To(Succeed())
+			received = doc
+			respond(rw, doc)
+		}))
+
+		created := time.Unix(1700000000, 0)
+		session = &sessionsapi.SessionState{
+			User:              "john",
+			Email:             "john@example.com",
+			PreferredUsername: "Johnny",
+			Groups:            []string{"devs"},
+			CreatedAt:         &created,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newWebhook := func(modify func(*options.AuthorizationWebhook)) *Webhook {
+		opts := &options.AuthorizationWebhook{URL: server.URL}
+		if modify != nil {
+			modify(opts)
+		}
+		webhook, err := NewWebhook(opts, nil)
+		Expect(err).ToNot(HaveOccurred())
+		return webhook
+	}
+
+	It("sends the session and request to the webhook", func() {
+		webhook := newWebhook(func(opts *options.AuthorizationWebhook) {
+			opts.Claims = []string{"preferred_username"}
+		})
+
+		req := httptest.NewRequest("PUT", "http://app.example.com/foo/bar?a=b", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+
+		decision := webhook.Check(req, session)
+		Expect(decision.Allow).To(BeTrue())
+		Expect(decision.Headers).To(Equal(map[string]string{"X-Tenant": "a"}))
+
+		Expect(received).To(Equal(&webhookRequest{
+			User:              "john",
+			Email:             "john@example.com",
+			PreferredUsername: "Johnny",
+			Groups:            []string{"devs"},
+			Claims:            map[string][]string{"preferred_username": {"Johnny"}},
+			Method:            "PUT",
+			Host:              "app.example.com",
+			Path:              "/foo/bar",
+			ClientIP:          "10.0.0.1",
+		}))
+	})
+
+	Context("with forwarded headers", func() {
+		newForwardedRequest := func(target string, authRequest bool) *http.Request {
+			req := httptest.NewRequest("GET", target, nil)
+			req.Header.Set("X-Forwarded-Method", "DELETE")
+			req.Header.Set("X-Forwarded-Host", "forwarded.example.com")
+			req.Header.Set("X-Forwarded-Uri", "/forwarded")
+			return middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{
+				ReverseProxy: true,
+				AuthRequest:  authRequest,
+			})
+		}
+
+		It("sends the path a request is proxied with", func() {
+			webhook := newWebhook(nil)
+
+			webhook.Check(newForwardedRequest("http://app.example.com/admin", false), session)
+			Expect(received.Method).To(Equal("GET"))
+			Expect(received.Host).To(Equal("app.example.com"))
+			Expect(received.Path).To(Equal("/admin"))
+		})
+
+		It("sends the forwarded request of auth requests", func() {
+			webhook := newWebhook(nil)
+
+			webhook.Check(newForwardedRequest("http://proxy.example.com/oauth2/auth", true), session)
+			Expect(received.Method).To(Equal("DELETE"))
+			Expect(received.Host).To(Equal("forwarded.example.com"))
+			Expect(received.Path).To(Equal("/forwarded"))
+		})
+
+		It("caches decisions per proxied path", func() {
+			ttl := options.Duration(time.Minute)
+			webhook := newWebhook(func(opts *options.AuthorizationWebhook) {
+				opts.CacheTTL = &ttl
+			})
+
+			webhook.Check(newForwardedRequest("http://app.example.com/public", false), session)
+			webhook.Check(newForwardedRequest("http://app.example.com/admin", false), session)
+			Expect(atomic.LoadInt32(&calls)).To(BeEquivalentTo(2))
+			Expect(received.Path).To(Equal("/admin"))
+		})
+	})
+
+	It("returns a deny decision with its reason", func() {
+		respond = func(rw http.ResponseWriter, _ *webhookRequest) {
+			rw.Write([]byte(`{"allow": false, "reason": "not on call"}`))
+		}
+		webhook := newWebhook(nil)
+
+		decision := webhook.Check(httptest.NewRequest("GET", "/", nil), session)
+		Expect(decision.Allow).To(BeFalse())
+		Expect(decision.Reason).To(Equal("not on call"))
+	})
+
+	Context("when the webhook fails", func() {
+		BeforeEach(func() {
+			respond = func(rw http.ResponseWriter, _ *webhookRequest) {
+				rw.WriteHeader(http.StatusInternalServerError)
+			}
+		})
+
+		It("fails closed by default", func() {
+			webhook := newWebhook(nil)
+
+			decision := webhook.Check(httptest.NewRequest("GET", "/", nil), session)
+			Expect(decision.Allow).To(BeFalse())
+			Expect(decision.Reason).To(ContainSubstring("authorization webhook failed"))
+		})
+
+		It("fails open when configured", func() {
+			webhook := newWebhook(func(opts *options.AuthorizationWebhook) {
+				opts.FailOpen = true
+			})
+
+			decision := webhook.Check(httptest.NewRequest("GET", "/", nil), session)
+			Expect(decision.Allow).To(BeTrue())
+		})
+
+		It("does not cache failures", func() {
+			ttl := options.Duration(time.Minute)
+			webhook := newWebhook(func(opts *options.AuthorizationWebhook) {
+				opts.CacheTTL = &ttl
+			})
+
+			webhook.Check(httptest.NewRequest("GET", "/", nil), session)
+			webhook.Check(httptest.NewRequest("GET", "/", nil), session)
+			Expect(atomic.LoadInt32(&calls)).To(BeEquivalentTo(2))
+		})
+	})
+
+	Context("with a cache TTL", func() {
+		var webhook *Webhook
+
+		BeforeEach(func() {
+			ttl := options.Duration(time.Minute)
+			webhook = newWebhook(func(opts *options.AuthorizationWebhook) {
+				opts.CacheTTL = &ttl
+			})
+		})
+
+		AfterEach(func() {
+			webhook.cache.clock.Reset()
+		})
+
+		It("caches decisions per session and route", func() {
+			webhook.Check(httptest.NewRequest("GET", "/foo", nil), session)
+			webhook.Check(httptest.NewRequest("GET", "/foo", nil), session)
+			Expect(atomic.LoadInt32(&calls)).To(BeEquivalentTo(1))
+
+			webhook.Check(httptest.NewRequest("POST", "/foo", nil), session)
+			webhook.Check(httptest.NewRequest("GET", "/bar", nil), session)
+			Expect(atomic.LoadInt32(&calls)).To(BeEquivalentTo(3))
+
+			other := &sessionsapi.SessionState{User: "jane", Email: "jane@example.com"}
+			webhook.Check(httptest.NewRequest("GET", "/foo", nil), other)
+			Expect(atomic.LoadInt32(&calls)).To(BeEquivalentTo(4))
+		})
+
+		It("keeps cached decisions when the session is refreshed", func() {
+			issued := *session.CreatedAt
+			session.IssuedAt = &issued
+			webhook.Check(httptest.NewRequest("GET", "/foo", nil), session)
+
+			refreshed := issued.Add(time.Hour)
+			session.CreatedAt = &refreshed
+			webhook.Check(httptest.NewRequest("GET", "/foo", nil), session)
+			Expect(atomic.LoadInt32(&calls)).To(BeEquivalentTo(1))
+		})
+
+		It("calls the webhook again once the decision expires", func() {
+			webhook.cache.clock.Set(time.Now())
+			webhook.Check(httptest.NewRequest("GET", "/foo", nil), session)
+
+			Expect(webhook.cache.clock.Add(2 * time.Minute)).To(Succeed())
+			webhook.Check(httptest.NewRequest("GET", "/foo", nil), session)
+			Expect(atomic.LoadInt32(&calls)).To(BeEquivalentTo(2))
+		})
+	})
+
+	It("requires a url", func() {
+		_, err := NewWebhook(&options.AuthorizationWebhook{}, nil)
+		Expect(err).To(MatchError("authorization webhook url is required"))
+	})
+})
@@ -21,6 +21,7 @@ type CSRF interface {
 	CheckOAuthState(string) bool
 	CheckOIDCNonce(string) bool
 	GetCodeVerifier() string
+	GetProviderID() string
 
 	SetSessionNonce(s *sessions.SessionState)
 
@@ -44,6 +45,11 @@ type csrf struct {
 	// authentication code.
 	CodeVerifier string `msgpack:"cv,omitempty"`
 
+	// ProviderID holds the ID of the provider the authentication flow was
+	// started with so that the callback can be redeemed against the same
+	// provider.
+	ProviderID string `msgpack:"p,omitempty"`
+
 	cookieOpts *options.Cookie
 	time       clock.Clock
 }
@@ -52,7 +58,7 @@ type csrf struct {
 const csrfStateLength int = 9
 
 // NewCSRF creates a CSRF with random nonces
-func NewCSRF(opts *options.Cookie, codeVerifier string) (CSRF, error) {
+func NewCSRF(opts *options.Cookie, codeVerifier, providerID string) (CSRF, error) {
 	state, err := encryption.Nonce(32)
 	if err != nil {
 		return nil, err
@@ -66,6 +72,7 @@ func NewCSRF(opts *options.Cookie, codeVerifier string) (CSRF, error) {
 		OAuthState:   state,
 		OIDCNonce:    nonce,
 		CodeVerifier: codeVerifier,
+		ProviderID:   providerID,
 
 		cookieOpts: opts,
 	}, nil
@@ -106,6 +113,11 @@ func (c *csrf) GetCodeVerifier() string {
 	return c.CodeVerifier
 }
 
+// GetProviderID returns the ID of the provider the flow was started with
+func (c *csrf) GetProviderID() string {
+	return c.ProviderID
+}
+
 // HashOAuthState returns the hash of the OAuth state nonce
 func (c *csrf) HashOAuthState() string {
 	return encryption.HashNonce(c.OAuthState)
@@ -170,7 +182,7 @@ func (c *csrf) encodeCookie() (string, error) {
 		return "", fmt.Errorf("error marshalling CSRF to msgpack: %v", err)
 	}
 
-	encrypted, err := encrypt(packed, c.cookieOpts)
+	encrypted, err := encrypt(packed, c.cookieOpts.Secret)
 	if err != nil {
 		return "", err
 	}
@@ -181,12 +193,13 @@ func (c *csrf) encodeCookie() (string, error) {
 // decodeCSRFCookie validates the signature then decrypts and decodes a CSRF
 // cookie into a CSRF struct
 func decodeCSRFCookie(cookie *http.Cookie, opts *options.Cookie) (*csrf, error) {
-	val, _, ok := encryption.Validate(cookie, opts.Secret, opts.Expire)
+	secrets := opts.Secrets()
+	val, _, secretIndex, ok := encryption.ValidateWithSeeds(cookie, secrets, opts.Expire)
 	if !ok {
 		return nil, errors.New("CSRF cookie failed validation")
 	}
 
-	decrypted, err := decrypt(val, opts)
+	decrypted, err := decrypt(val, secrets[secretIndex])
 	if err != nil {
 		return nil, err
 	}
@@ -227,22 +240,22 @@ func ExtractStateSubstring(state string) string {
 	return stateSubstring
 }
 
-func encrypt(data []byte, opts *options.Cookie) ([]byte, error) {
-	cipher, err := makeCipher(opts)
+func encrypt(data []byte, secret string) ([]byte, error) {
+	cipher, err := makeCipher(secret)
 	if err != nil {
 		return nil, err
 	}
 	return cipher.Encrypt(data)
 }
 
-func decrypt(data []byte, opts *options.Cookie) ([]byte, error) {
-	cipher, err := makeCipher(opts)
+func decrypt(data []byte, secret string) ([]byte, error) {
+	cipher, err := makeCipher(secret)
 	if err != nil {
 		return nil, err
 	}
 	return cipher.Decrypt(data)
 }
 
-func makeCipher(opts *options.Cookie) (encryption.Cipher, error) {
-	return encryption.NewCFBCipher(encryption.SecretBytes(opts.Secret))
+func makeCipher(secret string) (encryption.Cipher, error) {
+	return encryption.NewCFBCipher(encryption.SecretBytes(secret))
 }
@@ -36,7 +36,7 @@ var _ = Describe("CSRF Cookie Tests", func() {
 		}
 
 		var err error
-		publicCSRF, err = NewCSRF(cookieOpts, "verifier")
+		publicCSRF, err = NewCSRF(cookieOpts, "verifier", "provider")
 		Expect(err).ToNot(HaveOccurred())
 
 		privateCSRF = publicCSRF.(*csrf)
@@ -49,10 +49,11 @@ var _ = Describe("CSRF Cookie Tests", func() {
 			Expect(privateCSRF.OIDCNonce).ToNot(BeEmpty())
 			Expect(privateCSRF.OAuthState).ToNot(Equal(privateCSRF.OIDCNonce))
 			Expect(privateCSRF.CodeVerifier).To(Equal("verifier"))
+			Expect(privateCSRF.ProviderID).To(Equal("provider"))
 		})
 
 		It("makes unique nonces between multiple CSRFs", func() {
-			other, err := NewCSRF(cookieOpts, "verifier")
+			other, err := NewCSRF(cookieOpts, "verifier", "provider")
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(privateCSRF.OAuthState).ToNot(Equal(other.(*csrf).OAuthState))
@@ -108,6 +109,7 @@ var _ = Describe("CSRF Cookie Tests", func() {
 			Expect(decoded).ToNot(BeNil())
 			Expect(decoded.OAuthState).To(Equal([]byte(csrfState)))
 			Expect(decoded.OIDCNonce).To(Equal([]byte(csrfNonce)))
+			Expect(decoded.GetProviderID()).To(Equal("provider"))
 		})
 
 		It("signs the encoded cookie value", func() {
@@ -122,6 +124,29 @@ var _ = Describe("CSRF Cookie Tests", func() {
 			_, _, valid := encryption.Validate(cookie, cookieOpts.Secret, cookieOpts.Expire)
 			Expect(valid).To(BeTrue())
 		})
+
+		It("decodes cookies encoded with a previous secret", func() {
+			privateCSRF.OAuthState = []byte(csrfState)
+
+			encoded, err := privateCSRF.encodeCookie()
+			Expect(err).ToNot(HaveOccurred())
+
+			cookie := &http.Cookie{
+				Name:  privateCSRF.cookieName(),
+				Value: encoded,
+			}
+			rotatedOpts := *cookieOpts
+			rotatedOpts.Secret = "0123456789abcdefghijklmnopqrstuv"
+			rotatedOpts.PreviousSecrets = []string{cookieOpts.Secret}
+
+			decoded, err := decodeCSRFCookie(cookie, &rotatedOpts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.OAuthState).To(Equal([]byte(csrfState)))
+
+			rotatedOpts.PreviousSecrets = nil
+			_, err = decodeCSRFCookie(cookie, &rotatedOpts)
+			Expect(err).To(MatchError("CSRF cookie failed validation"))
+		})
 	})
 
 	Context("Cookie Management", func() {
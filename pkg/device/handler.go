@@ -0,0 +1,374 @@
+package device
+
+import (
+	// Import embed to allow importing the verification page template
+	_ "embed"
+
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	requestutil "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests/util"
+)
+
+const (
+	// GrantType is the grant type of token requests (RFC 8628, section 3.4)
+	GrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+	// VerificationPath is the path of the verification page, relative to the
+	// proxy prefix
+	VerificationPath = "/device"
+)
+
+//go:embed verification.html
+var verificationTemplate string
+
+var verificationPage = template.Must(template.New("verification.html").Parse(verificationTemplate))
+
+// Opts configures the device authorization handler
+type Opts struct {
+	// Store keeps the pending requests
+	Store *Store
+	// Issuer issues the bearer tokens of approved requests
+	Issuer sessionsapi.TokenIssuer
+	// ClientIDs are the clients allowed to request device codes
+	ClientIDs []string
+
+	ProxyPrefix string
+	// ForceHTTPS makes the verification URI use https, whatever the scheme of
+	// the request
+	ForceHTTPS bool
+	// CodeLifetime is how long users have to approve a request
+	CodeLifetime time.Duration
+	// PollInterval is the minimum time between two polls of a client
+	PollInterval time.Duration
+	// TokenLifetime is how long issued tokens are valid for
+	TokenLifetime time.Duration
+	// CSRFSecret protects the verification form from cross-site requests.
+	// The form is signed with a key derived from the secret.
+	CSRFSecret string
+}
+
+// Handler serves the endpoints of the OAuth 2.0 Device Authorization Grant
+// (RFC 8628). Clients request a device code and a user code from ServeCode,
+// the user approves the request on the verification page with their
+// session, and the client then exchanges the device code for a bearer token
+// that loads that session at ServeToken.
+type Handler struct {
+	opts    Opts
+	csrfKey []byte
+}
+
+// NewHandler creates a Handler
+func NewHandler(opts Opts) *Handler {
+	mac := hmac.New(sha256.New, []byte(opts.CSRFSecret))
+	mac.Write([]byte("device-csrf"))
+	return &Handler{opts: opts, csrfKey: mac.Sum(nil)}
+}
+
+// codeResponse is the device authorization response (RFC 8628, section 3.2)
+type codeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// tokenResponse is the access token response (RFC 6749, section 5.1)
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in,omitempty"`
+}
+
+// ServeCode starts a device authorization request for one of the allowed
+// clients
+func (h *Handler) ServeCode(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		writeError(rw, http.StatusMethodNotAllowed, "invalid_request", "the device authorization endpoint only accepts POST requests")
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		writeError(rw, http.StatusBadRequest, "invalid_request", "the request body could not be parsed")
+		return
+	}
+
+	clientID := req.PostForm.Get("client_id")
+	if !slices.Contains(h.opts.ClientIDs, clientID) {
+		writeError(rw, http.StatusUnauthorized, "invalid_client", "the client is not allowed to request device codes")
+		return
+	}
+
+	deviceCode, request, err := h.opts.Store.Create(req.Context(), clientID, h.opts.CodeLifetime, h.opts.PollInterval)
+	switch {
+	case errors.Is(err, ErrTooManyPending):
+		writeError(rw, http.StatusTooManyRequests, "temporarily_unavailable", "too many device codes are pending")
+		return
+	case err != nil:
+		logger.Errorf("Error creating device authorization request: %v", err)
+		writeError(rw, http.StatusInternalServerError, "server_error", "the request could not be stored")
+		return
+	}
+
+	verificationURI := h.verificationURI(req)
+	userCode := FormatUserCode(request.UserCode)
+	writeJSON(rw, http.StatusOK, codeResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + userCode,
+		ExpiresIn:               int64(h.opts.CodeLifetime / time.Second),
+		Interval:                int64(h.opts.PollInterval / time.Second),
+	})
+}
+
+// verificationURI returns the absolute URL of the verification page, as seen
+// by the client
+func (h *Handler) verificationURI(req *http.Request) string {
+	scheme := requestutil.GetRequestProto(req)
+	if scheme == "" {
+		scheme = "http"
+		if req.TLS != nil {
+			scheme = "https"
+		}
+	}
+	if h.opts.ForceHTTPS {
+		scheme = "https"
+	}
+	return scheme + "://" + requestutil.GetRequestHost(req) + h.opts.ProxyPrefix + VerificationPath
+}
+
+// ServeToken exchanges the device code of an approved request for a bearer
+// token. Until the request is approved, clients are told to keep polling.
+func (h *Handler) ServeToken(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		writeError(rw, http.StatusMethodNotAllowed, "invalid_request", "the token endpoint only accepts POST requests")
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		writeError(rw, http.StatusBadRequest, "invalid_request", "the request body could not be parsed")
+		return
+	}
+	if req.PostForm.Get("grant_type") != GrantType {
+		writeError(rw, http.StatusBadRequest, "unsupported_grant_type", "only the device_code grant type is supported")
+		return
+	}
+	deviceCode := req.PostForm.Get("device_code")
+	if deviceCode == "" {
+		writeError(rw, http.StatusBadRequest, "invalid_request", "missing device_code parameter")
+		return
+	}
+
+	ctx := req.Context()
+	lock := h.opts.Store.Lock(deviceCode)
+	if err := lock.Obtain(ctx, lockExpiration); err != nil {
+		writeError(rw, http.StatusBadRequest, "slow_down", "")
+		return
+	}
+	defer func() {
+		if err := lock.Release(context.Background()); err != nil {
+			logger.Errorf("Error releasing device authorization lock: %v", err)
+		}
+	}()
+
+	request, err := h.opts.Store.LoadByDeviceCode(ctx, deviceCode)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(rw, http.StatusBadRequest, "expired_token", "the device code has expired")
+		return
+	case err != nil:
+		logger.Errorf("Error loading device authorization request: %v", err)
+		writeError(rw, http.StatusInternalServerError, "server_error", "the request could not be loaded")
+		return
+	}
+	if request.ClientID != req.PostForm.Get("client_id") {
+		writeError(rw, http.StatusBadRequest, "invalid_grant", "the device code was issued to another client")
+		return
+	}
+
+	ok, err := h.opts.Store.Poll(ctx, deviceCode, request)
+	if err != nil {
+		logger.Errorf("Error saving device authorization request: %v", err)
+		writeError(rw, http.StatusInternalServerError, "server_error", "the request could not be stored")
+		return
+	}
+	if !ok {
+		writeError(rw, http.StatusBadRequest, "slow_down", "")
+		return
+	}
+
+	switch request.Status {
+	case StatusApproved:
+		h.issueToken(rw, req, deviceCode, request)
+	case StatusDenied:
+		h.deleteRequest(ctx, deviceCode, request)
+		writeError(rw, http.StatusBadRequest, "access_denied", "the user denied the request")
+	default:
+		writeError(rw, http.StatusBadRequest, "authorization_pending", "")
+	}
+}
+
+// issueToken issues a bearer token for the session that approved the request.
+// The request is removed so that the device code can only be redeemed once.
+func (h *Handler) issueToken(rw http.ResponseWriter, req *http.Request, deviceCode string, request *Request) {
+	session, err := h.opts.Store.Session(request)
+	if err != nil {
+		logger.Errorf("Error decoding device authorization session: %v", err)
+		writeError(rw, http.StatusInternalServerError, "server_error", "the session could not be loaded")
+		return
+	}
+	h.deleteRequest(req.Context(), deviceCode, request)
+
+	token, err := h.opts.Issuer.IssueToken(req, session)
+	if err != nil {
+		logger.Errorf("Error issuing device authorization token: %v", err)
+		writeError(rw, http.StatusInternalServerError, "server_error", "the token could not be issued")
+		return
+	}
+
+	logger.Printf("Issued a device authorization token for %s to client %q", session.Email, request.ClientID)
+	writeJSON(rw, http.StatusOK, tokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(h.opts.TokenLifetime / time.Second),
+	})
+}
+
+func (h *Handler) deleteRequest(ctx context.Context, deviceCode string, request *Request) {
+	if err := h.opts.Store.Delete(ctx, deviceCode, request); err != nil {
+		logger.Errorf("Error deleting device authorization request: %v", err)
+	}
+}
+
+// verificationData is rendered by the verification page
+type verificationData struct {
+	ProxyPrefix string
+	UserCode    string
+	ClientID    string
+	CSRFToken   string
+	Message     string
+	// Confirm is true when the user is asked to approve a request
+	Confirm bool
+	// Done is true once the user approved or denied the request
+	Done bool
+}
+
+// ServeVerification serves the page on which the signed in user enters the
+// user code shown by the client and approves or denies the request.
+func (h *Handler) ServeVerification(rw http.ResponseWriter, req *http.Request, session *sessionsapi.SessionState) {
+	data := verificationData{ProxyPrefix: h.opts.ProxyPrefix}
+
+	switch req.Method {
+	case http.MethodGet:
+		data.UserCode = req.URL.Query().Get("user_code")
+	case http.MethodPost:
+		data.UserCode = req.PostFormValue("user_code")
+	default:
+		rw.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if data.UserCode == "" {
+		h.renderVerification(rw, http.StatusOK, data)
+		return
+	}
+
+	request, err := h.opts.Store.LoadByUserCode(req.Context(), data.UserCode)
+	if err == nil && request.Status != StatusPending {
+		err = ErrNotFound
+	}
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			logger.Errorf("Error loading device authorization request: %v", err)
+		}
+		data.Message = "The code is invalid or has expired."
+		h.renderVerification(rw, http.StatusNotFound, data)
+		return
+	}
+	data.UserCode = FormatUserCode(request.UserCode)
+	data.ClientID = request.ClientID
+
+	if req.Method == http.MethodGet {
+		data.Confirm = true
+		data.CSRFToken = h.csrfToken(request.UserCode, session)
+		h.renderVerification(rw, http.StatusOK, data)
+		return
+	}
+
+	if !hmac.Equal([]byte(req.PostFormValue("csrf_token")), []byte(h.csrfToken(request.UserCode, session))) {
+		http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	if req.PostFormValue("action") == "approve" {
+		err = h.opts.Store.Approve(req.Context(), request.UserCode, session)
+		data.Message = "The device has been signed in. You may close this window."
+	} else {
+		err = h.opts.Store.Deny(req.Context(), request.UserCode)
+		data.Message = "The request has been denied."
+	}
+	if err != nil {
+		logger.Errorf("Error updating device authorization request: %v", err)
+		data.Message = "The request could not be updated."
+		h.renderVerification(rw, http.StatusInternalServerError, data)
+		return
+	}
+	data.Done = true
+	h.renderVerification(rw, http.StatusOK, data)
+}
+
+func (h *Handler) renderVerification(rw http.ResponseWriter, code int, data verificationData) {
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(code)
+	if err := verificationPage.Execute(rw, data); err != nil {
+		logger.Errorf("Error rendering device verification page: %v", err)
+	}
+}
+
+// csrfToken binds the verification form to the request and to the session of
+// the user, so that other sites cannot approve requests on their behalf.
+// The session is identified by when the user signed in, which does not
+// change when the session is refreshed while the form is shown.
+func (h *Handler) csrfToken(userCode string, session *sessionsapi.SessionState) string {
+	var issuedAt int64
+	if issueTime := session.IssueTime(); issueTime != nil {
+		issuedAt = issueTime.UnixNano()
+	}
+	mac := hmac.New(sha256.New, h.csrfKey)
+	for _, v := range []string{userCode, session.User, session.Email, strconv.FormatInt(issuedAt, 10)} {
+		mac.Write([]byte(v + "\x00"))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// writeError writes an OAuth 2.0 error response (RFC 6749, section 5.2)
+func writeError(rw http.ResponseWriter, code int, errorCode, description string) {
+	body := map[string]string{"error": errorCode}
+	if description != "" {
+		body["error_description"] = description
+	}
+	writeJSON(rw, code, body)
+}
+
+func writeJSON(rw http.ResponseWriter, code int, body interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(code)
+	if err := json.NewEncoder(rw).Encode(body); err != nil {
+		logger.Errorf("Error encoding device authorization response: %v", err)
+	}
+}
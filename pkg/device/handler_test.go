@@ -0,0 +1,430 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/memory"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type fakeIssuer struct {
+	sessions []*sessionsapi.SessionState
+}
+
+func (f *fakeIssuer) IssueToken(_ *http.Request, s *sessionsapi.SessionState) (string, error) {
+	f.sessions = append(f.sessions, s)
+	return "issued-token", nil
+}
+
+var _ = Describe("Device Authorization Handler", func() {
+	const cookieSecret = "0123456789abcdefghijklmnopqrstuv"
+
+	var store *Store
+	var issuer *fakeIssuer
+	var handler *Handler
+	var session *sessionsapi.SessionState
+	now := time.Unix(1700000000, 0)
+
+	BeforeEach(func() {
+		var err error
+		store, err = NewStore(tests.NewMockStore(), "_oauth2_proxy", cookieSecret, 2)
+		Expect(err).ToNot(HaveOccurred())
+		store.Clock.Set(now)
+
+		issuer = &fakeIssuer{}
+		handler = NewHandler(Opts{
+			Store:         store,
+			Issuer:        issuer,
+			ClientIDs:     []string{"cli"},
+			ProxyPrefix:   "/oauth2",
+			CodeLifetime:  10 * time.Minute,
+			PollInterval:  5 * time.Second,
+			TokenLifetime: time.Hour,
+			CSRFSecret:    cookieSecret,
+		})
+
+		createdAt := now.Add(-time.Minute)
+		session = &sessionsapi.SessionState{User: "alice", Email: "alice@example.com", AccessToken: "AccessToken", CreatedAt: &createdAt}
+	})
+
+	postCode := func(clientID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "https://proxy.example.com/oauth2/device/code", strings.NewReader(url.Values{"client_id": {clientID}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rw := httptest.NewRecorder()
+		handler.ServeCode(rw, req)
+		return rw
+	}
+
+	requestCode := func(clientID string) codeResponse {
+		rw := postCode(clientID)
+		Expect(rw.Code).To(Equal(http.StatusOK))
+
+		var response codeResponse
+		Expect(json.Unmarshal(rw.Body.Bytes(), &response)).To(Succeed())
+		return response
+	}
+
+	requestToken := func(params url.Values) (int, map[string]interface{}) {
+		req := httptest.NewRequest(http.MethodPost, "https://proxy.example.com/oauth2/device/token", strings.NewReader(params.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rw := httptest.NewRecorder()
+		handler.ServeToken(rw, req)
+
+		body := map[string]interface{}{}
+		Expect(json.Unmarshal(rw.Body.Bytes(), &body)).To(Succeed())
+		return rw.Code, body
+	}
+
+	poll := func(deviceCode string) (int, map[string]interface{}) {
+		return requestToken(url.Values{"grant_type": {GrantType}, "device_code": {deviceCode}, "client_id": {"cli"}})
+	}
+
+	verify := func(method string, params url.Values) *httptest.ResponseRecorder {
+		var req *http.Request
+		if method == http.MethodGet {
+			req = httptest.NewRequest(method, "https://proxy.example.com/oauth2/device?"+params.Encode(), nil)
+		} else {
+			req = httptest.NewRequest(method, "https://proxy.example.com/oauth2/device", strings.NewReader(params.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeVerification(rw, req, session)
+		return rw
+	}
+
+	csrfPattern := regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+	confirm := func(userCode string) string {
+		rw := verify(http.MethodGet, url.Values{"user_code": {userCode}})
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Body.String()).To(ContainSubstring("<strong>cli</strong>"))
+
+		match := csrfPattern.FindStringSubmatch(rw.Body.String())
+		Expect(match).To(HaveLen(2))
+		return match[1]
+	}
+
+	It("issues a token once the user approves the request", func() {
+		code := requestCode("cli")
+		Expect(code.UserCode).To(MatchRegexp(`^[BCDFGHJKLMNPQRSTVWXZ]{4}-[BCDFGHJKLMNPQRSTVWXZ]{4}$`))
+		Expect(code.VerificationURI).To(Equal("https://proxy.example.com/oauth2/device"))
+		Expect(code.VerificationURIComplete).To(Equal("https://proxy.example.com/oauth2/device?user_code=" + code.UserCode))
+		Expect(code.ExpiresIn).To(BeEquivalentTo(600))
+		Expect(code.Interval).To(BeEquivalentTo(5))
+
+		status, body := poll(code.DeviceCode)
+		Expect(status).To(Equal(http.StatusBadRequest))
+		Expect(body["error"]).To(Equal("authorization_pending"))
+
+		// Users may enter the code in lower case and without the separator
+		csrfToken := confirm(strings.ToLower(strings.ReplaceAll(code.UserCode, "-", "")))
+		rw := verify(http.MethodPost, url.Values{"user_code": {code.UserCode}, "csrf_token": {csrfToken}, "action": {"approve"}})
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Body.String()).To(ContainSubstring("The device has been signed in."))
+
+		store.Clock.Set(now.Add(5 * time.Second))
+		status, body = poll(code.DeviceCode)
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(map[string]interface{}{"access_token": "issued-token", "token_type": "Bearer", "expires_in": float64(3600)}))
+		Expect(issuer.sessions).To(HaveLen(1))
+		Expect(issuer.sessions[0].Email).To(Equal("alice@example.com"))
+		Expect(issuer.sessions[0].AccessToken).To(Equal("AccessToken"))
+
+		// Device codes can only be redeemed once
+		store.Clock.Set(now.Add(10 * time.Second))
+		status, body = poll(code.DeviceCode)
+		Expect(status).To(Equal(http.StatusBadRequest))
+		Expect(body["error"]).To(Equal("expired_token"))
+	})
+
+	It("issues a session that refreshes independently of the approving session", func() {
+		// The sessions are created two minutes ago, so that both are refreshed
+		clock.Set(time.Now().Add(-2 * time.Minute))
+		defer clock.Reset()
+
+		manager := persistence.NewManager(tests.NewMockStore(), &options.Cookie{
+			Name:   "_oauth2_proxy",
+			Secret: cookieSecret,
+			Expire: time.Hour,
+		})
+		handler = NewHandler(Opts{
+			Store:         store,
+			Issuer:        manager,
+			ClientIDs:     []string{"cli"},
+			ProxyPrefix:   "/oauth2",
+			CodeLifetime:  10 * time.Minute,
+			PollInterval:  5 * time.Second,
+			TokenLifetime: time.Hour,
+			CSRFSecret:    cookieSecret,
+		})
+
+		session.RefreshToken = "RefreshToken"
+		session.CreatedAtNow()
+		rw := httptest.NewRecorder()
+		Expect(manager.Save(rw, middlewareapi.AddRequestScope(httptest.NewRequest(http.MethodGet, "https://proxy.example.com/", nil), &middlewareapi.RequestScope{}), session)).To(Succeed())
+		cookies := rw.Result().Cookies()
+		Expect(cookies).To(HaveLen(1))
+
+		code := requestCode("cli")
+		csrfToken := confirm(code.UserCode)
+		rw = verify(http.MethodPost, url.Values{"user_code": {code.UserCode}, "csrf_token": {csrfToken}, "action": {"approve"}})
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(session.RefreshToken).To(Equal("RefreshToken"))
+
+		store.Clock.Set(now.Add(5 * time.Second))
+		status, body := poll(code.DeviceCode)
+		Expect(status).To(Equal(http.StatusOK))
+		token, ok := body["access_token"].(string)
+		Expect(ok).To(BeTrue())
+
+		// The provider rotates the refresh token and rejects the previous one
+		validRefreshToken := "RefreshToken"
+		loader := middleware.NewStoredSessionLoader(&middleware.StoredSessionLoaderOptions{
+			SessionStore:  manager,
+			RefreshPeriod: time.Minute,
+			RefreshSession: func(_ context.Context, s *sessionsapi.SessionState) (bool, error) {
+				if s.RefreshToken == "" {
+					return false, nil
+				}
+				if s.RefreshToken != validRefreshToken {
+					return false, providers.ErrRefreshTokenRejected
+				}
+				validRefreshToken = "RotatedRefreshToken"
+				s.AccessToken = "RefreshedAccessToken"
+				s.RefreshToken = validRefreshToken
+				return true, nil
+			},
+			ValidateSession: func(context.Context, *sessionsapi.SessionState) bool { return true },
+			Registerer:      prometheus.NewRegistry(),
+		})
+		load := func(req *http.Request) *sessionsapi.SessionState {
+			req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+			var loaded *sessionsapi.SessionState
+			loader(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				loaded = middlewareapi.GetRequestScope(r).Session
+			})).ServeHTTP(httptest.NewRecorder(), req)
+			return loaded
+		}
+		browserRequest := func() *http.Request {
+			req := httptest.NewRequest(http.MethodGet, "https://proxy.example.com/", nil)
+			req.AddCookie(cookies[0])
+			return req
+		}
+		deviceRequest := func() *http.Request {
+			req := httptest.NewRequest(http.MethodGet, "https://proxy.example.com/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			return req
+		}
+
+		clock.Reset()
+		browserSession := load(browserRequest())
+		Expect(browserSession).ToNot(BeNil())
+		Expect(browserSession.AccessToken).To(Equal("RefreshedAccessToken"))
+		Expect(browserSession.RefreshToken).To(Equal("RotatedRefreshToken"))
+
+		deviceSession := load(deviceRequest())
+		Expect(deviceSession).ToNot(BeNil())
+		Expect(deviceSession.AccessToken).To(Equal("AccessToken"))
+		Expect(deviceSession.RefreshToken).To(BeEmpty())
+
+		Expect(load(browserRequest())).ToNot(BeNil())
+		Expect(load(deviceRequest())).ToNot(BeNil())
+	})
+
+	It("rejects the token request once the user denies the request", func() {
+		code := requestCode("cli")
+
+		csrfToken := confirm(code.UserCode)
+		rw := verify(http.MethodPost, url.Values{"user_code": {code.UserCode}, "csrf_token": {csrfToken}, "action": {"deny"}})
+		Expect(rw.Code).To(Equal(http.StatusOK))
+
+		status, body := poll(code.DeviceCode)
+		Expect(status).To(Equal(http.StatusBadRequest))
+		Expect(body["error"]).To(Equal("access_denied"))
+		Expect(issuer.sessions).To(BeEmpty())
+	})
+
+	It("rejects approvals without a valid CSRF token", func() {
+		code := requestCode("cli")
+
+		rw := verify(http.MethodPost, url.Values{"user_code": {code.UserCode}, "action": {"approve"}})
+		Expect(rw.Code).To(Equal(http.StatusForbidden))
+
+		// The token is bound to the session of the user
+		csrfToken := confirm(code.UserCode)
+		session.Email = "mallory@example.com"
+		rw = verify(http.MethodPost, url.Values{"user_code": {code.UserCode}, "csrf_token": {csrfToken}, "action": {"approve"}})
+		Expect(rw.Code).To(Equal(http.StatusForbidden))
+
+		status, body := poll(code.DeviceCode)
+		Expect(status).To(Equal(http.StatusBadRequest))
+		Expect(body["error"]).To(Equal("authorization_pending"))
+	})
+
+	It("keeps the CSRF token valid when the session is refreshed", func() {
+		code := requestCode("cli")
+
+		csrfToken := confirm(code.UserCode)
+		issuedAt := *session.CreatedAt
+		refreshedAt := now
+		session.IssuedAt = &issuedAt
+		session.CreatedAt = &refreshedAt
+		rw := verify(http.MethodPost, url.Values{"user_code": {code.UserCode}, "csrf_token": {csrfToken}, "action": {"approve"}})
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Body.String()).To(ContainSubstring("The device has been signed in."))
+	})
+
+	It("rejects code requests of other clients", func() {
+		rw := postCode("other")
+		Expect(rw.Code).To(Equal(http.StatusUnauthorized))
+		Expect(rw.Body.String()).To(ContainSubstring(`"error":"invalid_client"`))
+	})
+
+	It("limits the number of pending requests", func() {
+		denied := requestCode("cli")
+		requestCode("cli")
+
+		rw := postCode("cli")
+		Expect(rw.Code).To(Equal(http.StatusTooManyRequests))
+
+		// Requests no longer count once they are redeemed
+		csrfToken := confirm(denied.UserCode)
+		rw = verify(http.MethodPost, url.Values{"user_code": {denied.UserCode}, "csrf_token": {csrfToken}, "action": {"deny"}})
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		_, body := poll(denied.DeviceCode)
+		Expect(body["error"]).To(Equal("access_denied"))
+		requestCode("cli")
+		Expect(postCode("cli").Code).To(Equal(http.StatusTooManyRequests))
+
+		// or expire
+		store.Clock.Set(now.Add(10 * time.Minute))
+		requestCode("cli")
+	})
+
+	It("does not update requests while a poll holds their lock", func() {
+		memoryStore, err := memory.NewStore(options.MemoryStoreOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		store, err = NewStore(memoryStore, "_oauth2_proxy", cookieSecret, 0)
+		Expect(err).ToNot(HaveOccurred())
+		store.Clock.Set(now)
+		handler = NewHandler(Opts{
+			Store:         store,
+			Issuer:        issuer,
+			ClientIDs:     []string{"cli"},
+			ProxyPrefix:   "/oauth2",
+			CodeLifetime:  10 * time.Minute,
+			PollInterval:  5 * time.Second,
+			TokenLifetime: time.Hour,
+			CSRFSecret:    cookieSecret,
+		})
+		code := requestCode("cli")
+
+		lock := store.Lock(code.DeviceCode)
+		Expect(lock.Obtain(context.Background(), time.Minute)).To(Succeed())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		Expect(store.Approve(ctx, code.UserCode, session)).ToNot(Succeed())
+		request, err := store.LoadByDeviceCode(context.Background(), code.DeviceCode)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(request.Status).To(Equal(StatusPending))
+
+		Expect(lock.Release(context.Background())).To(Succeed())
+		Expect(store.Approve(context.Background(), code.UserCode, session)).To(Succeed())
+		request, err = store.LoadByDeviceCode(context.Background(), code.DeviceCode)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(request.Status).To(Equal(StatusApproved))
+	})
+
+	It("slows down clients that poll too often", func() {
+		code := requestCode("cli")
+
+		status, body := poll(code.DeviceCode)
+		Expect(status).To(Equal(http.StatusBadRequest))
+		Expect(body["error"]).To(Equal("authorization_pending"))
+
+		store.Clock.Set(now.Add(time.Second))
+		_, body = poll(code.DeviceCode)
+		Expect(body["error"]).To(Equal("slow_down"))
+
+		// The interval was increased to 10 seconds
+		store.Clock.Set(now.Add(9 * time.Second))
+		_, body = poll(code.DeviceCode)
+		Expect(body["error"]).To(Equal("slow_down"))
+
+		// and then to 15 seconds
+		store.Clock.Set(now.Add(24 * time.Second))
+		_, body = poll(code.DeviceCode)
+		Expect(body["error"]).To(Equal("authorization_pending"))
+	})
+
+	It("expires requests", func() {
+		code := requestCode("cli")
+		store.Clock.Set(now.Add(10 * time.Minute))
+
+		status, body := poll(code.DeviceCode)
+		Expect(status).To(Equal(http.StatusBadRequest))
+		Expect(body["error"]).To(Equal("expired_token"))
+
+		rw := verify(http.MethodGet, url.Values{"user_code": {code.UserCode}})
+		Expect(rw.Code).To(Equal(http.StatusNotFound))
+		Expect(rw.Body.String()).To(ContainSubstring("The code is invalid or has expired."))
+	})
+
+	It("shows the code entry form", func() {
+		rw := verify(http.MethodGet, url.Values{})
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Body.String()).To(ContainSubstring(`name="user_code" placeholder="XXXX-XXXX"`))
+	})
+
+	type tokenErrorTableInput struct {
+		params        url.Values
+		expectedError string
+	}
+
+	DescribeTable("rejects invalid token requests",
+		func(in tokenErrorTableInput) {
+			code := requestCode("cli")
+			params := url.Values{}
+			for k, v := range in.params {
+				params[k] = []string{strings.ReplaceAll(v[0], "DEVICE_CODE", code.DeviceCode)}
+			}
+
+			status, body := requestToken(params)
+			Expect(status).To(Equal(http.StatusBadRequest))
+			Expect(body["error"]).To(Equal(in.expectedError))
+		},
+		Entry("with another grant type", tokenErrorTableInput{
+			params:        url.Values{"grant_type": {"authorization_code"}, "device_code": {"DEVICE_CODE"}, "client_id": {"cli"}},
+			expectedError: "unsupported_grant_type",
+		}),
+		Entry("without a device code", tokenErrorTableInput{
+			params:        url.Values{"grant_type": {GrantType}, "client_id": {"cli"}},
+			expectedError: "invalid_request",
+		}),
+		Entry("with an unknown device code", tokenErrorTableInput{
+			params:        url.Values{"grant_type": {GrantType}, "device_code": {"unknown"}, "client_id": {"cli"}},
+			expectedError: "expired_token",
+		}),
+		Entry("with another client", tokenErrorTableInput{
+			params:        url.Values{"grant_type": {GrantType}, "device_code": {"DEVICE_CODE"}, "client_id": {"other"}},
+			expectedError: "invalid_grant",
+		}),
+	)
+})
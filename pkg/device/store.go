@@ -0,0 +1,370 @@
+package device
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+)
+
+const (
+	// userCodeCharset leaves out vowels, so that user codes do not spell
+	// words, and characters that are easily confused (RFC 8628, section 6.1)
+	userCodeCharset = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength  = 8
+
+	deviceCodeBytes = 32
+
+	// lockExpiration bounds how long a poll or an update holds the lock on a
+	// request
+	lockExpiration = 10 * time.Second
+
+	// lockRetryInterval is how long updates wait between attempts to obtain
+	// the lock on a request
+	lockRetryInterval = 50 * time.Millisecond
+)
+
+// Status is the state of a device authorization request
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusApproved Status = "approved"
+	StatusDenied   Status = "denied"
+)
+
+// ErrNotFound is returned when a device authorization request does not exist
+// or has expired.
+var ErrNotFound = errors.New("device authorization request not found")
+
+// ErrTooManyPending is returned when a request is created while the maximum
+// number of requests is pending
+var ErrTooManyPending = errors.New("too many pending device authorization requests")
+
+// Request is a pending device authorization request
+type Request struct {
+	UserCode string    `json:"userCode"`
+	ClientID string    `json:"clientID,omitempty"`
+	Status   Status    `json:"status"`
+	Expires  time.Time `json:"expires"`
+	// Interval is the minimum time between two polls of the client
+	Interval time.Duration `json:"interval"`
+	LastPoll time.Time     `json:"lastPoll,omitempty"`
+	// Session is the encrypted session of the user that approved the request
+	Session []byte `json:"session,omitempty"`
+}
+
+// Store keeps device authorization requests in a persistent session store.
+// Requests are stored under a key derived from the device code, and the user
+// code references that key so that the verification page can find them.
+// Sessions of approved requests are encrypted with the cookie secret.
+// The requests created by the Store are counted until they are deleted or
+// expire, so that clients cannot fill the persistent store with requests.
+type Store struct {
+	// Clock is used to expire requests
+	Clock clock.Clock
+
+	store  persistence.Store
+	prefix string
+	cipher encryption.Cipher
+
+	mu         sync.Mutex
+	pending    map[string]time.Time
+	maxPending int
+}
+
+// NewStore creates a Store that keeps requests in the persistent store,
+// under keys prefixed with the cookie name. At most maxPending requests
+// created by the Store are pending at once, unless maxPending is zero.
+func NewStore(store persistence.Store, cookieName, cookieSecret string, maxPending int) (*Store, error) {
+	cipher, err := encryption.NewGCMCipher(encryption.SecretBytes(cookieSecret))
+	if err != nil {
+		return nil, fmt.Errorf("could not create cipher: %v", err)
+	}
+	return &Store{
+		store:      store,
+		prefix:     cookieName + "-device-",
+		cipher:     cipher,
+		pending:    map[string]time.Time{},
+		maxPending: maxPending,
+	}, nil
+}
+
+// Create stores a new pending request and returns its device code
+func (s *Store) Create(ctx context.Context, clientID string, lifetime, interval time.Duration) (string, *Request, error) {
+	deviceCode, err := newDeviceCode()
+	if err != nil {
+		return "", nil, fmt.Errorf("could not generate device code: %v", err)
+	}
+	userCode, err := newUserCode()
+	if err != nil {
+		return "", nil, fmt.Errorf("could not generate user code: %v", err)
+	}
+
+	request := &Request{
+		UserCode: userCode,
+		ClientID: clientID,
+		Status:   StatusPending,
+		Expires:  s.Clock.Now().Add(lifetime),
+		Interval: interval,
+	}
+	key := s.deviceKey(deviceCode)
+	if err := s.reserve(key, request.Expires); err != nil {
+		return "", nil, err
+	}
+	if err := s.save(ctx, key, request); err != nil {
+		s.release(key)
+		return "", nil, err
+	}
+	if err := s.store.Save(ctx, s.userKey(userCode), []byte(key), lifetime); err != nil {
+		s.release(key)
+		return "", nil, fmt.Errorf("could not save user code: %v", err)
+	}
+	return deviceCode, request, nil
+}
+
+// LoadByDeviceCode returns the request with the device code
+func (s *Store) LoadByDeviceCode(ctx context.Context, deviceCode string) (*Request, error) {
+	return s.load(ctx, s.deviceKey(deviceCode))
+}
+
+// LoadByUserCode returns the request with the user code. The user code is
+// normalized first, so that users may enter it in lower case and without
+// the separator.
+func (s *Store) LoadByUserCode(ctx context.Context, userCode string) (*Request, error) {
+	key, err := s.store.Load(ctx, s.userKey(NormalizeUserCode(userCode)))
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return s.load(ctx, string(key))
+}
+
+// Poll records a poll of the client. It returns false when the client polls
+// faster than the interval, in which case the interval is increased.
+func (s *Store) Poll(ctx context.Context, deviceCode string, request *Request) (bool, error) {
+	now := s.Clock.Now()
+	ok := request.LastPoll.IsZero() || now.Sub(request.LastPoll) >= request.Interval
+	if !ok {
+		// Clients must add 5 seconds to their interval (RFC 8628, section 3.5)
+		request.Interval += 5 * time.Second
+	}
+	request.LastPoll = now
+	return ok, s.save(ctx, s.deviceKey(deviceCode), request)
+}
+
+// Approve stores the session of the user with the request with the user code.
+// The refresh token of the session is left out: the device gets a session of
+// its own, and sharing the refresh token would invalidate one of the two
+// sessions as soon as the other one refreshes it.
+func (s *Store) Approve(ctx context.Context, userCode string, session *sessionsapi.SessionState) error {
+	device := *session
+	device.RefreshToken = ""
+
+	return s.update(ctx, userCode, func(request *Request) error {
+		encoded, err := device.EncodeSessionState(s.cipher, true)
+		if err != nil {
+			return fmt.Errorf("could not encode session: %v", err)
+		}
+		request.Status = StatusApproved
+		request.Session = encoded
+		return nil
+	})
+}
+
+// Deny marks the request with the user code as denied
+func (s *Store) Deny(ctx context.Context, userCode string) error {
+	return s.update(ctx, userCode, func(request *Request) error {
+		request.Status = StatusDenied
+		return nil
+	})
+}
+
+// Session returns the session of an approved request
+func (s *Store) Session(request *Request) (*sessionsapi.SessionState, error) {
+	return sessionsapi.DecodeSessionState(request.Session, s.cipher, true)
+}
+
+// Delete removes the request with the device code and its user code
+func (s *Store) Delete(ctx context.Context, deviceCode string, request *Request) error {
+	if err := s.store.Clear(ctx, s.userKey(request.UserCode)); err != nil {
+		return fmt.Errorf("could not clear user code: %v", err)
+	}
+	if err := s.store.Clear(ctx, s.deviceKey(deviceCode)); err != nil {
+		return fmt.Errorf("could not clear device code: %v", err)
+	}
+	s.release(s.deviceKey(deviceCode))
+	return nil
+}
+
+// Lock returns a lock on the request with the device code, so that
+// concurrent polls cannot redeem it twice
+func (s *Store) Lock(deviceCode string) sessionsapi.Lock {
+	return s.store.Lock(s.deviceKey(deviceCode))
+}
+
+// update changes the pending request with the user code.
+// The lock on the request is held while it is changed, so that the change is
+// not lost to a poll that saves the request at the same time.
+func (s *Store) update(ctx context.Context, userCode string, change func(*Request) error) error {
+	userCode = NormalizeUserCode(userCode)
+	key, err := s.store.Load(ctx, s.userKey(userCode))
+	if err != nil {
+		return ErrNotFound
+	}
+
+	lock, err := s.lock(ctx, string(key))
+	if err != nil {
+		return fmt.Errorf("could not lock device authorization request: %v", err)
+	}
+	defer func() {
+		if err := lock.Release(context.Background()); err != nil {
+			logger.Errorf("Error releasing device authorization lock: %v", err)
+		}
+	}()
+
+	request, err := s.load(ctx, string(key))
+	if err != nil {
+		return err
+	}
+	if request.Status != StatusPending {
+		return ErrNotFound
+	}
+	if err := change(request); err != nil {
+		return err
+	}
+	return s.save(ctx, string(key), request)
+}
+
+// lock obtains the lock on the request under the key.
+// Obtaining the lock is retried until it expires from any previous holder.
+func (s *Store) lock(ctx context.Context, key string) (sessionsapi.Lock, error) {
+	lock := s.store.Lock(key)
+	deadline := time.Now().Add(lockExpiration)
+	for {
+		err := lock.Obtain(ctx, lockExpiration)
+		if !errors.Is(err, sessionsapi.ErrLockNotObtained) {
+			return lock, err
+		}
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// reserve counts a new pending request under the key, unless the maximum
+// number of requests is pending. Expired requests are no longer counted.
+func (s *Store) reserve(key string, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxPending > 0 && len(s.pending) >= s.maxPending {
+		now := s.Clock.Now()
+		for k, e := range s.pending {
+			if !now.Before(e) {
+				delete(s.pending, k)
+			}
+		}
+		if len(s.pending) >= s.maxPending {
+			return ErrTooManyPending
+		}
+	}
+	s.pending[key] = expires
+	return nil
+}
+
+// release stops counting the request under the key as pending
+func (s *Store) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, key)
+}
+
+func (s *Store) load(ctx context.Context, key string) (*Request, error) {
+	value, err := s.store.Load(ctx, key)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	request := &Request{}
+	if err := json.Unmarshal(value, request); err != nil {
+		return nil, fmt.Errorf("could not decode device authorization request: %v", err)
+	}
+	if !s.Clock.Now().Before(request.Expires) {
+		return nil, ErrNotFound
+	}
+	return request, nil
+}
+
+func (s *Store) save(ctx context.Context, key string, request *Request) error {
+	value, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("could not encode device authorization request: %v", err)
+	}
+	if err := s.store.Save(ctx, key, value, request.Expires.Sub(s.Clock.Now())); err != nil {
+		return fmt.Errorf("could not save device authorization request: %v", err)
+	}
+	return nil
+}
+
+// deviceKey hashes the device code, so that the store does not hold the
+// codes that clients redeem for tokens
+func (s *Store) deviceKey(deviceCode string) string {
+	return s.prefix + encryption.HashKey(deviceCode)
+}
+
+func (s *Store) userKey(userCode string) string {
+	return s.prefix + "user-" + userCode
+}
+
+// NormalizeUserCode converts a user code entered by a user to the form it was
+// generated in, without the separator and in upper case
+func NormalizeUserCode(userCode string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(userCode))
+}
+
+// FormatUserCode splits the user code in two with a separator, so that it is
+// easier to read and type
+func FormatUserCode(userCode string) string {
+	if len(userCode) != userCodeLength {
+		return userCode
+	}
+	return userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:]
+}
+
+func newDeviceCode() (string, error) {
+	b := make([]byte, deviceCodeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func newUserCode() (string, error) {
+	code := make([]byte, userCodeLength)
+	max := big.NewInt(int64(len(userCodeCharset)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeCharset[n.Int64()]
+	}
+	return string(code), nil
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"hash"
 	"io"
@@ -71,6 +72,18 @@ func Validate(cookie *http.Cookie, seed string, expiration time.Duration) (value
 	return
 }
 
+// ValidateWithSeeds ensures a cookie is properly signed by one of the seeds.
+// It returns the index of the seed that signed the cookie, so that callers can
+// decrypt the value with the matching key.
+func ValidateWithSeeds(cookie *http.Cookie, seeds []string, expiration time.Duration) (value []byte, t time.Time, seedIndex int, ok bool) {
+	for i, seed := range seeds {
+		if value, t, ok = Validate(cookie, seed, expiration); ok {
+			return value, t, i, true
+		}
+	}
+	return nil, time.Time{}, 0, false
+}
+
 // SignedValue returns a cookie that is signed and can later be checked with Validate
 func SignedValue(seed string, key string, value []byte, now time.Time) (string, error) {
 	encodedValue := base64.URLEncoding.EncodeToString(value)
@@ -83,6 +96,13 @@ func SignedValue(seed string, key string, value []byte, now time.Time) (string,
 	return cookieVal, nil
 }
 
+// HashKey returns the hex encoded SHA-256 hash of the values, for use as a key
+// that does not expose the values, such as tokens, it is derived from
+func HashKey(values ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(values, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
 // GenerateCodeVerifierString returns a base64 encoded string of n random bytes
 func GenerateCodeVerifierString(n int) (string, error) {
 	data := make([]byte, n)
@@ -130,6 +130,35 @@ func TestValidate(t *testing.T) {
 	assert.Equal(t, validValue, expectedValue)
 }
 
+func TestValidateWithSeeds(t *testing.T) {
+	oldSeed := "0123456789abcdef"
+	newSeed := "fedcba9876543210"
+	key := "cookie-name"
+	now := time.Now()
+
+	signed, err := SignedValue(oldSeed, key, []byte("I am soooo encoded"), now)
+	assert.NoError(t, err)
+	cookie := &http.Cookie{Name: key, Value: signed}
+
+	value, timestamp, seedIndex, ok := ValidateWithSeeds(cookie, []string{newSeed, oldSeed}, time.Hour)
+	assert.True(t, ok)
+	assert.Equal(t, 1, seedIndex)
+	assert.Equal(t, time.Unix(now.Unix(), 0), timestamp)
+	assert.Equal(t, []byte("I am soooo encoded"), value)
+
+	_, _, _, ok = ValidateWithSeeds(cookie, []string{newSeed}, time.Hour)
+	assert.False(t, ok)
+}
+
+func TestHashKey(t *testing.T) {
+	sum := sha256.Sum256([]byte("token"))
+	assert.Equal(t, fmt.Sprintf("%x", sum), HashKey("token"))
+
+	// The values are separated, so that different values do not share keys
+	assert.NotEqual(t, HashKey("ab", "c"), HashKey("a", "bc"))
+	assert.Equal(t, HashKey("a", "b"), HashKey("a", "b"))
+}
+
 func TestGenerateCodeVerifierString(t *testing.T) {
 	randomString, err := GenerateCodeVerifierString(96)
 	assert.NoError(t, err)
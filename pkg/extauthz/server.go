@@ -0,0 +1,201 @@
+package extauthz
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Register returns a function that registers the Envoy external authorization
+// service with a gRPC server.
+// Each Check request is converted to an HTTP request and served by the
+// handler. A 2xx response allows the request, any headers the handler set or
+// removed on the request are forwarded to the upstream and the headers of the
+// response are added to the response to the client. Any other response is
+// returned to the client as the denied response.
+func Register(handler http.Handler) func(*grpc.Server) {
+	return func(s *grpc.Server) {
+		authv3.RegisterAuthorizationServer(s, &authorizationServer{handler: handler})
+	}
+}
+
+// authorizationServer implements the envoy.service.auth.v3.Authorization
+// service.
+type authorizationServer struct {
+	handler http.Handler
+}
+
+// Check implements authv3.AuthorizationServer.
+func (s *authorizationServer) Check(ctx context.Context, check *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	req, err := newHTTPRequest(ctx, check)
+	if err != nil {
+		return nil, err
+	}
+	original := req.Header.Clone()
+
+	rw := newResponseRecorder()
+	s.handler.ServeHTTP(rw, req)
+
+	if rw.code >= 200 && rw.code < 300 {
+		return okResponse(original, req.Header, rw.header), nil
+	}
+	return deniedResponse(rw), nil
+}
+
+// newHTTPRequest builds an HTTP request from the attributes of the check
+// request.
+func newHTTPRequest(ctx context.Context, check *authv3.CheckRequest) (*http.Request, error) {
+	attrs := check.GetAttributes().GetRequest().GetHttp()
+	if attrs == nil {
+		return nil, errors.New("check request has no http attributes")
+	}
+
+	scheme := attrs.GetScheme()
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, attrs.GetMethod(), fmt.Sprintf("%s://%s%s", scheme, attrs.GetHost(), attrs.GetPath()), nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request in check request: %v", err)
+	}
+	req.Host = attrs.GetHost()
+	req.Proto = attrs.GetProtocol()
+
+	for name, value := range attrs.GetHeaders() {
+		if !strings.HasPrefix(name, ":") {
+			req.Header.Set(name, value)
+		}
+	}
+	for _, h := range attrs.GetHeaderMap().GetHeaders() {
+		if strings.HasPrefix(h.GetKey(), ":") {
+			continue
+		}
+		value := h.GetValue()
+		if value == "" {
+			value = string(h.GetRawValue())
+		}
+		req.Header.Add(h.GetKey(), value)
+	}
+
+	if addr := check.GetAttributes().GetSource().GetAddress().GetSocketAddress(); addr != nil {
+		req.RemoteAddr = net.JoinHostPort(addr.GetAddress(), strconv.FormatUint(uint64(addr.GetPortValue()), 10))
+	}
+
+	return req, nil
+}
+
+// okResponse allows the request.
+// Headers that differ from the original request headers are set on the
+// upstream request and those that were removed are removed from it.
+func okResponse(original, modified, response http.Header) *authv3.CheckResponse {
+	ok := &authv3.OkHttpResponse{}
+
+	for name, values := range modified {
+		if !slices.Equal(original.Values(name), values) {
+			ok.Headers = append(ok.Headers, headerValueOption(name, strings.Join(values, ",")))
+		}
+	}
+	for name := range original {
+		if _, exists := modified[name]; !exists {
+			ok.HeadersToRemove = append(ok.HeadersToRemove, strings.ToLower(name))
+		}
+	}
+	for name, values := range response {
+		for _, value := range values {
+			ok.ResponseHeadersToAdd = append(ok.ResponseHeadersToAdd, appendedHeaderValueOption(name, value))
+		}
+	}
+
+	return &authv3.CheckResponse{
+		Status:       &status.Status{Code: int32(codes.OK)},
+		HttpResponse: &authv3.CheckResponse_OkResponse{OkResponse: ok},
+	}
+}
+
+// deniedResponse denies the request with the response of the handler.
+func deniedResponse(rw *responseRecorder) *authv3.CheckResponse {
+	denied := &authv3.DeniedHttpResponse{
+		Status: &typev3.HttpStatus{Code: typev3.StatusCode(rw.code)},
+		Body:   rw.body.String(),
+	}
+	for name, values := range rw.header {
+		for _, value := range values {
+			denied.Headers = append(denied.Headers, appendedHeaderValueOption(name, value))
+		}
+	}
+
+	code := codes.PermissionDenied
+	if rw.code == http.StatusUnauthorized {
+		code = codes.Unauthenticated
+	}
+
+	return &authv3.CheckResponse{
+		Status:       &status.Status{Code: int32(code)},
+		HttpResponse: &authv3.CheckResponse_DeniedResponse{DeniedResponse: denied},
+	}
+}
+
+func headerValueOption(name, value string) *corev3.HeaderValueOption {
+	return &corev3.HeaderValueOption{
+		Header:       &corev3.HeaderValue{Key: name, Value: value},
+		AppendAction: corev3.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+	}
+}
+
+func appendedHeaderValueOption(name, value string) *corev3.HeaderValueOption {
+	return &corev3.HeaderValueOption{
+		Header:       &corev3.HeaderValue{Key: name, Value: value},
+		AppendAction: corev3.HeaderValueOption_APPEND_IF_EXISTS_OR_ADD,
+	}
+}
+
+// responseRecorder captures the response written by the handler.
+type responseRecorder struct {
+	header      http.Header
+	body        bytes.Buffer
+	code        int
+	wroteHeader bool
+}
+
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{
+		header: make(http.Header),
+		code:   http.StatusOK,
+	}
+}
+
+// Header implements http.ResponseWriter
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+// Write implements http.ResponseWriter
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	return r.body.Write(b)
+}
+
+// WriteHeader implements http.ResponseWriter
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.wroteHeader {
+		return
+	}
+	r.code = code
+	r.wroteHeader = true
+}
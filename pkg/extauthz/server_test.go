@@ -0,0 +1,118 @@
+package extauthz
+
+import (
+	"context"
+	"net/http"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+)
+
+var _ = Describe("Authorization Server", func() {
+	var (
+		received *http.Request
+		handler  http.HandlerFunc
+	)
+
+	check := &authv3.CheckRequest{
+		Attributes: &authv3.AttributeContext{
+			Source: &authv3.AttributeContext_Peer{
+				Address: &corev3.Address{
+					Address: &corev3.Address_SocketAddress{
+						SocketAddress: &corev3.SocketAddress{
+							Address:       "10.0.0.1",
+							PortSpecifier: &corev3.SocketAddress_PortValue{PortValue: 4321},
+						},
+					},
+				},
+			},
+			Request: &authv3.AttributeContext_Request{
+				Http: &authv3.AttributeContext_HttpRequest{
+					Method: "GET",
+					Scheme: "https",
+					Host:   "app.example.com",
+					Path:   "/foo?bar=baz",
+					Headers: map[string]string{
+						":authority":    "app.example.com",
+						"cookie":        "_oauth2_proxy=abc",
+						"authorization": "Bearer token",
+						"x-keep":        "keep",
+					},
+				},
+			},
+		},
+	}
+
+	serve := func() *authv3.CheckResponse {
+		s := &authorizationServer{handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			received = req
+			handler(rw, req)
+		})}
+		resp, err := s.Check(context.Background(), check)
+		Expect(err).ToNot(HaveOccurred())
+		return resp
+	}
+
+	It("converts the check request to an HTTP request", func() {
+		handler = func(rw http.ResponseWriter, _ *http.Request) {}
+		serve()
+
+		Expect(received.Method).To(Equal("GET"))
+		Expect(received.URL.String()).To(Equal("https://app.example.com/foo?bar=baz"))
+		Expect(received.Host).To(Equal("app.example.com"))
+		Expect(received.RemoteAddr).To(Equal("10.0.0.1:4321"))
+		Expect(received.Header.Get("Cookie")).To(Equal("_oauth2_proxy=abc"))
+		Expect(received.Header).ToNot(HaveKey(":authority"))
+	})
+
+	It("allows the request with the modified request headers", func() {
+		handler = func(rw http.ResponseWriter, req *http.Request) {
+			req.Header.Del("Authorization")
+			req.Header.Set("X-Forwarded-User", "john")
+			rw.Header().Add("Set-Cookie", "_oauth2_proxy=refreshed")
+			rw.WriteHeader(http.StatusOK)
+		}
+		resp := serve()
+
+		Expect(resp.GetStatus().GetCode()).To(BeEquivalentTo(codes.OK))
+		ok := resp.GetOkResponse()
+		Expect(ok).ToNot(BeNil())
+		Expect(ok.GetHeaders()).To(ConsistOf(headerValueOption("X-Forwarded-User", "john")))
+		Expect(ok.GetHeadersToRemove()).To(ConsistOf("authorization"))
+		Expect(ok.GetResponseHeadersToAdd()).To(ConsistOf(appendedHeaderValueOption("Set-Cookie", "_oauth2_proxy=refreshed")))
+	})
+
+	It("denies the request with the response of the handler", func() {
+		handler = func(rw http.ResponseWriter, req *http.Request) {
+			http.Redirect(rw, req, "/oauth2/sign_in?rd=https%3A%2F%2Fapp.example.com%2Ffoo", http.StatusFound)
+		}
+		resp := serve()
+
+		Expect(resp.GetStatus().GetCode()).To(BeEquivalentTo(codes.PermissionDenied))
+		denied := resp.GetDeniedResponse()
+		Expect(denied).ToNot(BeNil())
+		Expect(denied.GetStatus().GetCode()).To(Equal(typev3.StatusCode_Found))
+		Expect(denied.GetHeaders()).To(ContainElement(appendedHeaderValueOption("Location", "/oauth2/sign_in?rd=https%3A%2F%2Fapp.example.com%2Ffoo")))
+	})
+
+	It("denies unauthenticated requests as unauthenticated", func() {
+		handler = func(rw http.ResponseWriter, _ *http.Request) {
+			http.Error(rw, "Unauthorized", http.StatusUnauthorized)
+		}
+		resp := serve()
+
+		Expect(resp.GetStatus().GetCode()).To(BeEquivalentTo(codes.Unauthenticated))
+		Expect(resp.GetDeniedResponse().GetStatus().GetCode()).To(Equal(typev3.StatusCode_Unauthorized))
+		Expect(resp.GetDeniedResponse().GetBody()).To(Equal("Unauthorized\n"))
+	})
+
+	It("rejects check requests without http attributes", func() {
+		s := &authorizationServer{handler: http.NotFoundHandler()}
+		_, err := s.Check(context.Background(), &authv3.CheckRequest{})
+		Expect(err).To(MatchError("check request has no http attributes"))
+	})
+})
@@ -8,12 +8,23 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options/util"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 )
 
+// IdentityTokenClaim is the claim of a ClaimSource that injects an identity
+// token signed by the proxy for the session, rather than a claim of the
+// session.
+const IdentityTokenClaim = "identity_token"
+
 type Injector interface {
 	Inject(http.Header, *sessionsapi.SessionState)
 }
 
+// TokenSigner signs the identity tokens injected for the IdentityTokenClaim
+type TokenSigner interface {
+	Sign(*sessionsapi.SessionState) (string, error)
+}
+
 type injector struct {
 	valueInjectors []valueInjector
 }
@@ -25,10 +36,16 @@ func (i injector) Inject(header http.Header, session *sessionsapi.SessionState)
 }
 
 func NewInjector(headers []options.Header) (Injector, error) {
+	return NewInjectorWithSigner(headers, nil)
+}
+
+// NewInjectorWithSigner creates an Injector that signs the identity tokens
+// of headers sourced from the IdentityTokenClaim with the signer.
+func NewInjectorWithSigner(headers []options.Header, signer TokenSigner) (Injector, error) {
 	injectors := []valueInjector{}
 	for _, header := range headers {
 		for _, value := range header.Values {
-			injector, err := newValueinjector(header.Name, value)
+			injector, err := newValueinjector(header.Name, value, signer)
 			if err != nil {
 				return nil, fmt.Errorf("error building injector for header %q: %v", header.Name, err)
 			}
@@ -43,10 +60,12 @@ type valueInjector interface {
 	inject(http.Header, *sessionsapi.SessionState)
 }
 
-func newValueinjector(name string, value options.HeaderValue) (valueInjector, error) {
+func newValueinjector(name string, value options.HeaderValue, signer TokenSigner) (valueInjector, error) {
 	switch {
 	case value.SecretSource != nil && value.ClaimSource == nil:
 		return newSecretInjector(name, value.SecretSource)
+	case value.SecretSource == nil && value.ClaimSource != nil && value.ClaimSource.Claim == IdentityTokenClaim:
+		return newIdentityTokenInjector(name, value.ClaimSource, signer)
 	case value.SecretSource == nil && value.ClaimSource != nil:
 		return newClaimInjector(name, value.ClaimSource)
 	default:
@@ -116,3 +135,24 @@ func newClaimInjector(name string, source *options.ClaimSource) (valueInjector,
 		}), nil
 	}
 }
+
+func newIdentityTokenInjector(name string, source *options.ClaimSource, signer TokenSigner) (valueInjector, error) {
+	if signer == nil {
+		return nil, fmt.Errorf("claim %q requires an identity token to be configured", IdentityTokenClaim)
+	}
+	if source.BasicAuthPassword != nil {
+		return nil, fmt.Errorf("claim %q cannot be converted into a basic auth header", IdentityTokenClaim)
+	}
+
+	return newInjectorFunc(func(header http.Header, session *sessionsapi.SessionState) {
+		if session == nil {
+			return
+		}
+		token, err := signer.Sign(session)
+		if err != nil {
+			logger.Errorf("Error signing identity token for header %q: %v", name, err)
+			return
+		}
+		header.Add(name, source.Prefix+token)
+	}), nil
+}
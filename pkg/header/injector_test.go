@@ -413,4 +413,70 @@ var _ = Describe("Injector Suite", func() {
 			}),
 		)
 	})
+
+	Context("NewInjectorWithSigner", func() {
+		identityTokenHeader := func(source options.ClaimSource) []options.Header {
+			source.Claim = IdentityTokenClaim
+			return []options.Header{
+				{
+					Name:   "Authorization",
+					Values: []options.HeaderValue{{ClaimSource: &source}},
+				},
+			}
+		}
+
+		It("injects the signed identity token", func() {
+			injector, err := NewInjectorWithSigner(identityTokenHeader(options.ClaimSource{Prefix: "Bearer "}), fakeSigner{})
+			Expect(err).ToNot(HaveOccurred())
+
+			headers := http.Header{}
+			injector.Inject(headers, &sessionsapi.SessionState{User: "user-123"})
+			Expect(headers).To(Equal(http.Header{
+				"Authorization": []string{"Bearer token-for-user-123"},
+			}))
+		})
+
+		It("does not inject a token without a session", func() {
+			injector, err := NewInjectorWithSigner(identityTokenHeader(options.ClaimSource{}), fakeSigner{})
+			Expect(err).ToNot(HaveOccurred())
+
+			headers := http.Header{}
+			injector.Inject(headers, nil)
+			Expect(headers).To(BeEmpty())
+		})
+
+		It("does not inject a token that could not be signed", func() {
+			injector, err := NewInjectorWithSigner(identityTokenHeader(options.ClaimSource{}), fakeSigner{err: errors.New("signing failed")})
+			Expect(err).ToNot(HaveOccurred())
+
+			headers := http.Header{}
+			injector.Inject(headers, &sessionsapi.SessionState{User: "user-123"})
+			Expect(headers).To(BeEmpty())
+		})
+
+		It("requires a signer", func() {
+			injector, err := NewInjector(identityTokenHeader(options.ClaimSource{}))
+			Expect(err).To(MatchError("error building injector for header \"Authorization\": claim \"identity_token\" requires an identity token to be configured"))
+			Expect(injector).To(BeNil())
+		})
+
+		It("does not convert the token into a basic auth header", func() {
+			injector, err := NewInjectorWithSigner(identityTokenHeader(options.ClaimSource{
+				BasicAuthPassword: &options.SecretSource{Value: []byte("password")},
+			}), fakeSigner{})
+			Expect(err).To(MatchError("error building injector for header \"Authorization\": claim \"identity_token\" cannot be converted into a basic auth header"))
+			Expect(injector).To(BeNil())
+		})
+	})
 })
+
+type fakeSigner struct {
+	err error
+}
+
+func (s fakeSigner) Sign(session *sessionsapi.SessionState) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	return "token-for-" + session.User, nil
+}
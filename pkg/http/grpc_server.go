@@ -0,0 +1,93 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// GRPCOpts contains the information required to set up a gRPC server.
+type GRPCOpts struct {
+	// Register is called to register the gRPC services with the server.
+	Register func(*grpc.Server)
+
+	// BindAddress is the address the plaintext gRPC server should listen on.
+	BindAddress string
+
+	// SecureBindAddress is the address the TLS gRPC server should listen on.
+	SecureBindAddress string
+
+	// TLS is the TLS configuration for the server.
+	TLS *options.TLS
+}
+
+// NewGRPCServer creates a new Server serving gRPC from the options given.
+// The listeners are configured in the same way as for the HTTP server.
+func NewGRPCServer(opts GRPCOpts) (Server, error) {
+	if opts.Register == nil {
+		return nil, errors.New("no gRPC services to register")
+	}
+
+	listeners := &server{}
+	if err := listeners.setupListener(Opts{BindAddress: opts.BindAddress}); err != nil {
+		return nil, fmt.Errorf("error setting up listener: %v", err)
+	}
+	if err := listeners.setupTLSListener(Opts{
+		SecureBindAddress: opts.SecureBindAddress,
+		TLS:               opts.TLS,
+		nextProtos:        []string{"h2"},
+	}); err != nil {
+		return nil, fmt.Errorf("error setting up TLS listener: %v", err)
+	}
+
+	srv := grpc.NewServer()
+	opts.Register(srv)
+
+	return &grpcServer{
+		server:      srv,
+		listener:    listeners.listener,
+		tlsListener: listeners.tlsListener,
+	}, nil
+}
+
+// grpcServer is an implementation of the Server interface for gRPC services.
+type grpcServer struct {
+	server *grpc.Server
+
+	listener    net.Listener
+	tlsListener net.Listener
+}
+
+// Start serves gRPC on the configured listeners.
+// It will block until the context is cancelled, at which point the server is
+// gracefully stopped.
+func (s *grpcServer) Start(ctx context.Context) error {
+	g, groupCtx := errgroup.WithContext(ctx)
+
+	for _, listener := range []net.Listener{s.listener, s.tlsListener} {
+		if listener == nil {
+			continue
+		}
+		l := listener
+		g.Go(func() error {
+			if err := s.server.Serve(l); err != nil {
+				return fmt.Errorf("could not start gRPC server: %v", err)
+			}
+			return nil
+		})
+	}
+
+	g.Go(func() error {
+		<-groupCtx.Done()
+		s.server.GracefulStop()
+		return nil
+	})
+
+	return g.Wait()
+}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+var _ = Describe("gRPC Server", func() {
+	It("requires services to register", func() {
+		_, err := NewGRPCServer(GRPCOpts{BindAddress: "127.0.0.1:0"})
+		Expect(err).To(MatchError("no gRPC services to register"))
+	})
+
+	It("serves the registered services until the context is cancelled", func() {
+		srv, err := NewGRPCServer(GRPCOpts{
+			Register: func(s *grpc.Server) {
+				healthpb.RegisterHealthServer(s, health.NewServer())
+			},
+			BindAddress: "127.0.0.1:0",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		startErr := make(chan error)
+		go func() {
+			startErr <- srv.Start(ctx)
+		}()
+
+		conn, err := grpc.NewClient(srv.(*grpcServer).listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.GetStatus()).To(Equal(healthpb.HealthCheckResponse_SERVING))
+
+		cancel()
+		Eventually(startErr).Should(Receive(BeNil()))
+	})
+
+	It("does not listen on disabled addresses", func() {
+		srv, err := NewGRPCServer(GRPCOpts{
+			Register:          func(*grpc.Server) {},
+			BindAddress:       "-",
+			SecureBindAddress: "",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(srv.(*grpcServer).listener).To(BeNil())
+		Expect(srv.(*grpcServer).tlsListener).To(BeNil())
+	})
+})
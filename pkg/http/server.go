@@ -40,6 +40,10 @@ type Opts struct {
 
 	// Let testing infrastructure circumvent parsing file descriptors
 	fdFiles []*os.File
+
+	// nextProtos overrides the ALPN protocols offered by the TLS listener.
+	// Defaults to http/1.1.
+	nextProtos []string
 }
 
 // NewServer creates a new Server from the options given.
@@ -139,6 +143,9 @@ func (s *server) setupTLSListener(opts Opts) error {
 		MaxVersion: tls.VersionTLS13,
 		NextProtos: []string{"http/1.1"},
 	}
+	if len(opts.nextProtos) > 0 {
+		config.NextProtos = opts.nextProtos
+	}
 	if opts.TLS == nil {
 		return errors.New("no TLS config provided")
 	}
@@ -0,0 +1,13 @@
+package httpsig
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestHTTPSigSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "HTTP Message Signatures")
+}
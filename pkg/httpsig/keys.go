@@ -0,0 +1,143 @@
+package httpsig
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+const (
+	// AlgorithmHMACSHA256 is the `hmac-sha256` algorithm, signing with a
+	// shared secret
+	AlgorithmHMACSHA256 = "hmac-sha256"
+
+	// AlgorithmEd25519 is the `ed25519` algorithm, signing with an Ed25519
+	// private key and verifying with its public key
+	AlgorithmEd25519 = "ed25519"
+)
+
+// errInvalidSignature is returned when a signature does not match the
+// signature base
+var errInvalidSignature = errors.New("signature does not match")
+
+// Key signs or verifies signatures.
+type Key interface {
+	// ID is the key ID, sent as the `keyid` parameter of signatures
+	ID() string
+
+	// Algorithm is the algorithm of the key, sent as the `alg` parameter of
+	// signatures
+	Algorithm() string
+
+	// canSign reports whether the key can create signatures
+	canSign() bool
+	sign(base []byte) ([]byte, error)
+	verify(base, signature []byte) error
+}
+
+// NewHMACSHA256Key creates a key that signs and verifies signatures with the
+// shared secret.
+func NewHMACSHA256Key(id string, secret []byte) Key {
+	return &hmacKey{id: id, secret: secret}
+}
+
+type hmacKey struct {
+	id     string
+	secret []byte
+}
+
+func (k *hmacKey) ID() string        { return k.id }
+func (k *hmacKey) Algorithm() string { return AlgorithmHMACSHA256 }
+func (k *hmacKey) canSign() bool     { return true }
+
+func (k *hmacKey) sign(base []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write(base)
+	return mac.Sum(nil), nil
+}
+
+func (k *hmacKey) verify(base, signature []byte) error {
+	expected, _ := k.sign(base)
+	if !hmac.Equal(expected, signature) {
+		return errInvalidSignature
+	}
+	return nil
+}
+
+// NewEd25519PrivateKey creates a key that signs and verifies signatures with
+// the Ed25519 private key.
+func NewEd25519PrivateKey(id string, private ed25519.PrivateKey) Key {
+	return &ed25519Key{
+		id:      id,
+		private: private,
+		public:  private.Public().(ed25519.PublicKey),
+	}
+}
+
+// NewEd25519PublicKey creates a key that verifies signatures with the
+// Ed25519 public key.
+func NewEd25519PublicKey(id string, public ed25519.PublicKey) Key {
+	return &ed25519Key{id: id, public: public}
+}
+
+type ed25519Key struct {
+	id      string
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+func (k *ed25519Key) ID() string        { return k.id }
+func (k *ed25519Key) Algorithm() string { return AlgorithmEd25519 }
+func (k *ed25519Key) canSign() bool     { return k.private != nil }
+
+func (k *ed25519Key) sign(base []byte) ([]byte, error) {
+	if k.private == nil {
+		return nil, errors.New("an Ed25519 public key cannot create signatures")
+	}
+	return ed25519.Sign(k.private, base), nil
+}
+
+func (k *ed25519Key) verify(base, signature []byte) error {
+	if !ed25519.Verify(k.public, base, signature) {
+		return errInvalidSignature
+	}
+	return nil
+}
+
+// ParseEd25519PrivateKey parses a PEM encoded PKCS #8 Ed25519 private key.
+func ParseEd25519PrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("key is not PEM encoded")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse private key: %v", err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is a %T, not an Ed25519 key", key)
+	}
+	return private, nil
+}
+
+// ParseEd25519PublicKey parses a PEM encoded PKIX Ed25519 public key.
+func ParseEd25519PublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("key is not PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse public key: %v", err)
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is a %T, not an Ed25519 key", key)
+	}
+	return public, nil
+}
@@ -0,0 +1,283 @@
+package httpsig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	// SignatureInputHeader holds the covered components and parameters of
+	// the signatures of a message
+	SignatureInputHeader = "Signature-Input"
+
+	// SignatureHeader holds the signatures of a message
+	SignatureHeader = "Signature"
+
+	// ContentDigestHeader holds the RFC 9530 digest of the message content
+	ContentDigestHeader = "Content-Digest"
+
+	// DefaultMaxBodySize is the size of the largest request content that is
+	// read to compute or check its Content-Digest, when no maximum is
+	// configured
+	DefaultMaxBodySize = 10 << 20
+)
+
+// ErrBodyTooLarge is returned when the Content-Digest of a request is
+// covered, but its content is larger than the maximum body size
+var ErrBodyTooLarge = errors.New("request body is too large to digest")
+
+// Derived components of requests, named after the message instead of a
+// header
+const (
+	ComponentMethod        = "@method"
+	ComponentTargetURI     = "@target-uri"
+	ComponentAuthority     = "@authority"
+	ComponentScheme        = "@scheme"
+	ComponentRequestTarget = "@request-target"
+	ComponentPath          = "@path"
+	ComponentQuery         = "@query"
+
+	// ComponentContentDigest covers the Content-Digest header, which is
+	// checked against the content of the message
+	ComponentContentDigest = "content-digest"
+)
+
+// derivedComponents are the derived components that can be covered by
+// request signatures
+var derivedComponents = map[string]struct{}{
+	ComponentMethod:        {},
+	ComponentTargetURI:     {},
+	ComponentAuthority:     {},
+	ComponentScheme:        {},
+	ComponentRequestTarget: {},
+	ComponentPath:          {},
+	ComponentQuery:         {},
+}
+
+// validateComponent checks that the component can be covered by signatures
+func validateComponent(component string) error {
+	if strings.HasPrefix(component, "@") {
+		if _, ok := derivedComponents[component]; !ok {
+			return fmt.Errorf("unsupported derived component %q", component)
+		}
+		return nil
+	}
+	if component == "" || component != strings.ToLower(component) || !isPrintableASCII(component) || strings.ContainsAny(component, " \"") {
+		return fmt.Errorf("invalid component %q: headers must be lowercase field names", component)
+	}
+	return nil
+}
+
+// message is the view of a request the signature base is created from.
+// The proxy signs client requests, which are described by the URL, while
+// upstreams verify server requests, which are described by the request line.
+type message struct {
+	method        string
+	scheme        string
+	authority     string
+	requestTarget string
+	header        http.Header
+}
+
+// newClientMessage describes a request the proxy is about to send
+func newClientMessage(req *http.Request) *message {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	return newMessage(req, req.URL.Scheme == "https", host, req.URL.RequestURI())
+}
+
+// newServerMessage describes a request received by a server
+func newServerMessage(req *http.Request) *message {
+	target := req.RequestURI
+	if target == "" {
+		target = req.URL.RequestURI()
+	}
+	return newMessage(req, req.TLS != nil, req.Host, target)
+}
+
+func newMessage(req *http.Request, secure bool, host, target string) *message {
+	scheme := "http"
+	if secure {
+		scheme = "https"
+	}
+	return &message{
+		method:        req.Method,
+		scheme:        scheme,
+		authority:     normalizeAuthority(scheme, host),
+		requestTarget: target,
+		header:        req.Header,
+	}
+}
+
+// normalizeAuthority lowercases the host and removes the default port of the
+// scheme
+func normalizeAuthority(scheme, host string) string {
+	host = strings.ToLower(host)
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+			if strings.Contains(h, ":") {
+				return "[" + h + "]"
+			}
+			return h
+		}
+	}
+	return host
+}
+
+// has reports whether the message has a value for the component
+func (m *message) has(component string) bool {
+	if strings.HasPrefix(component, "@") {
+		return true
+	}
+	return len(m.header.Values(component)) > 0
+}
+
+// value returns the value of the component in the signature base
+func (m *message) value(component string) (string, error) {
+	path, query, hasQuery := strings.Cut(m.requestTarget, "?")
+
+	switch component {
+	case ComponentMethod:
+		return m.method, nil
+	case ComponentTargetURI:
+		return m.scheme + "://" + m.authority + m.requestTarget, nil
+	case ComponentAuthority:
+		return m.authority, nil
+	case ComponentScheme:
+		return m.scheme, nil
+	case ComponentRequestTarget:
+		return m.requestTarget, nil
+	case ComponentPath:
+		if path == "" {
+			return "/", nil
+		}
+		return path, nil
+	case ComponentQuery:
+		if !hasQuery {
+			return "?", nil
+		}
+		return "?" + query, nil
+	}
+
+	values := m.header.Values(component)
+	if len(values) == 0 {
+		return "", fmt.Errorf("covered component %q is missing", component)
+	}
+	trimmed := make([]string, 0, len(values))
+	for _, value := range values {
+		trimmed = append(trimmed, strings.TrimSpace(value))
+	}
+	return strings.Join(trimmed, ", "), nil
+}
+
+// signatureBase creates the signature base of the components, followed by
+// the signature parameters
+func (m *message) signatureBase(components []string, signatureParams string) ([]byte, error) {
+	var b strings.Builder
+	for _, component := range components {
+		value, err := m.value(component)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(serializeString(component))
+		b.WriteString(": ")
+		b.WriteString(value)
+		b.WriteByte('\n')
+	}
+	b.WriteString(`"@signature-params": `)
+	b.WriteString(signatureParams)
+	return []byte(b.String()), nil
+}
+
+// readBody reads the content of the request, leaving the request body
+// readable again. Content larger than the maximum size is not read into
+// memory.
+func readBody(req *http.Request, maxSize int64) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.ContentLength > maxSize {
+		return nil, ErrBodyTooLarge
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("could not read request body: %v", err)
+	}
+	if int64(len(body)) > maxSize {
+		return nil, ErrBodyTooLarge
+	}
+	if err := req.Body.Close(); err != nil {
+		return nil, fmt.Errorf("could not close request body: %v", err)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+// setContentDigest sets the SHA-256 Content-Digest of the request content
+func setContentDigest(req *http.Request, maxBodySize int64) error {
+	body, err := readBody(req, maxBodySize)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(body)
+	req.Header.Set(ContentDigestHeader, "sha-256=:"+base64.StdEncoding.EncodeToString(digest[:])+":")
+	return nil
+}
+
+// digestAlgorithms are the Content-Digest algorithms that are checked
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+// verifyContentDigest checks the Content-Digest of the request against its
+// content. Every supported digest must match, and at least one is required.
+func verifyContentDigest(req *http.Request, maxBodySize int64) error {
+	members, err := parseDictionary(strings.Join(req.Header.Values(ContentDigestHeader), ", "))
+	if err != nil {
+		return fmt.Errorf("invalid %s header: %v", ContentDigestHeader, err)
+	}
+	body, err := readBody(req, maxBodySize)
+	if err != nil {
+		return err
+	}
+
+	verified := false
+	for _, m := range members {
+		newHash, ok := digestAlgorithms[m.key]
+		if !ok {
+			continue
+		}
+		expected, ok := m.item.value.([]byte)
+		if m.isInner || !ok {
+			return fmt.Errorf("invalid %s digest in %s header", m.key, ContentDigestHeader)
+		}
+		h := newHash()
+		h.Write(body)
+		if subtle.ConstantTimeCompare(h.Sum(nil), expected) != 1 {
+			return fmt.Errorf("%s digest does not match the content", m.key)
+		}
+		verified = true
+	}
+	if !verified {
+		return errors.New("no supported digest in the " + ContentDigestHeader + " header")
+	}
+	return nil
+}
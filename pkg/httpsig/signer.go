@@ -0,0 +1,165 @@
+// Package httpsig signs and verifies requests with RFC 9421 HTTP Message
+// Signatures.
+//
+// OAuth2 Proxy signs the requests it proxies to an upstream with a Signer.
+// Upstream services written in Go verify the signatures with a Verifier.
+package httpsig
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+)
+
+// DefaultLabel is the label of signatures when no label is configured
+const DefaultLabel = "sig1"
+
+// SignerOptions configures a Signer
+type SignerOptions struct {
+	// Key signs the requests
+	Key Key
+
+	// Components are the components covered by the signatures.
+	// Headers missing from a request are not covered by its signature.
+	Components []string
+
+	// Label identifies the signature in the Signature-Input and Signature
+	// headers. Defaults to DefaultLabel.
+	Label string
+
+	// Tag is the optional `tag` parameter of the signatures, identifying the
+	// application the signatures are intended for
+	Tag string
+
+	// Lifetime sets the `expires` parameter of the signatures to the
+	// creation time plus the lifetime, when set
+	Lifetime time.Duration
+
+	// MaxBodySize is the size of the largest request content that is read
+	// to set its Content-Digest. Signing a larger request fails with
+	// ErrBodyTooLarge. Defaults to DefaultMaxBodySize.
+	MaxBodySize int64
+}
+
+// Signer signs requests with an HTTP Message Signature
+type Signer struct {
+	// Clock is used to set the creation time of signatures
+	Clock clock.Clock
+
+	key         Key
+	components  []string
+	label       string
+	tag         string
+	lifetime    time.Duration
+	maxBodySize int64
+}
+
+// NewSigner creates a Signer from the options
+func NewSigner(opts SignerOptions) (*Signer, error) {
+	if opts.Key == nil {
+		return nil, errors.New("a key is required")
+	}
+	if !opts.Key.canSign() {
+		return nil, fmt.Errorf("key %q cannot create signatures", opts.Key.ID())
+	}
+	if !isPrintableASCII(opts.Key.ID()) || !isPrintableASCII(opts.Tag) {
+		return nil, errors.New("key ID and tag must be printable ASCII")
+	}
+	if len(opts.Components) == 0 {
+		return nil, errors.New("at least one component must be covered")
+	}
+
+	components := make([]string, 0, len(opts.Components))
+	for _, component := range opts.Components {
+		if err := validateComponent(component); err != nil {
+			return nil, err
+		}
+		components = append(components, component)
+	}
+
+	label := opts.Label
+	if label == "" {
+		label = DefaultLabel
+	}
+	if !isKey(label) {
+		return nil, fmt.Errorf("invalid label %q: labels must be lowercase structured field keys", label)
+	}
+
+	maxBodySize := opts.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+
+	return &Signer{
+		key:         opts.Key,
+		components:  components,
+		label:       label,
+		tag:         opts.Tag,
+		lifetime:    opts.Lifetime,
+		maxBodySize: maxBodySize,
+	}, nil
+}
+
+// SignRequest signs a request that is about to be sent, setting its
+// Signature-Input and Signature headers. When the Content-Digest is covered,
+// the request content is read to set the digest.
+func (s *Signer) SignRequest(req *http.Request) error {
+	msg := newClientMessage(req)
+
+	components := make([]string, 0, len(s.components))
+	for _, component := range s.components {
+		if component == ComponentContentDigest {
+			if err := setContentDigest(req, s.maxBodySize); err != nil {
+				return err
+			}
+		}
+		if msg.has(component) {
+			components = append(components, component)
+		}
+	}
+
+	signatureParams := s.signatureParams(components, s.Clock.Now())
+	base, err := msg.signatureBase(components, signatureParams)
+	if err != nil {
+		return err
+	}
+	signature, err := s.key.sign(base)
+	if err != nil {
+		return fmt.Errorf("could not sign request: %v", err)
+	}
+
+	req.Header.Set(SignatureInputHeader, s.label+"="+signatureParams)
+	req.Header.Set(SignatureHeader, s.label+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+	return nil
+}
+
+// signatureParams serializes the covered components and the parameters of a
+// signature
+func (s *Signer) signatureParams(components []string, created time.Time) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, component := range components {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(serializeString(component))
+	}
+	b.WriteByte(')')
+
+	b.WriteString(";created=" + strconv.FormatInt(created.Unix(), 10))
+	if s.lifetime > 0 {
+		b.WriteString(";expires=" + strconv.FormatInt(created.Add(s.lifetime).Unix(), 10))
+	}
+	b.WriteString(";keyid=" + serializeString(s.key.ID()))
+	b.WriteString(";alg=" + serializeString(s.key.Algorithm()))
+	if s.tag != "" {
+		b.WriteString(";tag=" + serializeString(s.tag))
+	}
+	return b.String()
+}
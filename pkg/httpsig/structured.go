@@ -0,0 +1,355 @@
+package httpsig
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// This file implements the subset of RFC 8941 Structured Field Values used
+// by the Signature-Input, Signature and Content-Digest fields: dictionaries
+// whose members are inner lists or byte sequences, with parameters.
+
+// token is a structured field token, which is serialized without quotes
+type token string
+
+// param is a parameter of a structured field item or inner list
+type param struct {
+	key   string
+	value interface{}
+}
+
+// params are the ordered parameters of an item or inner list
+type params []param
+
+// get returns the value of the parameter with the key
+func (p params) get(key string) (interface{}, bool) {
+	for _, param := range p {
+		if param.key == key {
+			return param.value, true
+		}
+	}
+	return nil, false
+}
+
+// item is a bare item with its parameters
+type item struct {
+	value  interface{}
+	params params
+}
+
+// member is a member of a dictionary. The value is either an inner list or an
+// item.
+type member struct {
+	key     string
+	isInner bool
+	inner   []item
+	item    item
+	params  params
+
+	// raw is the member value as serialized in the field, the signature base
+	// covers the signature parameters exactly as they were received
+	raw string
+}
+
+// parseDictionary parses a structured field dictionary
+func parseDictionary(field string) ([]member, error) {
+	p := &sfParser{s: field}
+	p.skipSpaces()
+
+	members := []member{}
+	for !p.done() {
+		m, err := p.parseMember()
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+
+		p.skipOWS()
+		if p.done() {
+			break
+		}
+		if p.next() != ',' {
+			return nil, fmt.Errorf("expected ',' after dictionary member %q", m.key)
+		}
+		p.skipOWS()
+		if p.done() {
+			return nil, fmt.Errorf("trailing ',' in dictionary")
+		}
+	}
+	return members, nil
+}
+
+type sfParser struct {
+	s string
+	i int
+}
+
+func (p *sfParser) done() bool {
+	return p.i >= len(p.s)
+}
+
+func (p *sfParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.s[p.i]
+}
+
+func (p *sfParser) next() byte {
+	c := p.peek()
+	p.i++
+	return c
+}
+
+func (p *sfParser) skipSpaces() {
+	for p.peek() == ' ' {
+		p.i++
+	}
+}
+
+func (p *sfParser) skipOWS() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.i++
+	}
+}
+
+func (p *sfParser) parseMember() (member, error) {
+	key, err := p.parseKey()
+	if err != nil {
+		return member{}, err
+	}
+	m := member{key: key}
+
+	if p.peek() != '=' {
+		// A member without a value is the boolean true
+		m.item = item{value: true}
+		m.params, err = p.parseParams()
+		return m, err
+	}
+	p.i++
+
+	start := p.i
+	if p.peek() == '(' {
+		m.isInner = true
+		m.inner, err = p.parseInnerList()
+	} else {
+		m.item.value, err = p.parseBareItem()
+	}
+	if err != nil {
+		return member{}, err
+	}
+	m.params, err = p.parseParams()
+	if err != nil {
+		return member{}, err
+	}
+	if !m.isInner {
+		m.item.params = m.params
+	}
+	m.raw = p.s[start:p.i]
+	return m, nil
+}
+
+func (p *sfParser) parseInnerList() ([]item, error) {
+	if p.next() != '(' {
+		return nil, fmt.Errorf("expected '(' at the start of an inner list")
+	}
+
+	items := []item{}
+	for !p.done() {
+		p.skipSpaces()
+		if p.peek() == ')' {
+			p.i++
+			return items, nil
+		}
+
+		value, err := p.parseBareItem()
+		if err != nil {
+			return nil, err
+		}
+		itemParams, err := p.parseParams()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item{value: value, params: itemParams})
+
+		if c := p.peek(); c != ' ' && c != ')' {
+			return nil, fmt.Errorf("expected ' ' or ')' in inner list")
+		}
+	}
+	return nil, fmt.Errorf("unterminated inner list")
+}
+
+func (p *sfParser) parseParams() (params, error) {
+	result := params{}
+	for p.peek() == ';' {
+		p.i++
+		p.skipSpaces()
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+
+		var value interface{} = true
+		if p.peek() == '=' {
+			p.i++
+			value, err = p.parseBareItem()
+			if err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, param{key: key, value: value})
+	}
+	return result, nil
+}
+
+func (p *sfParser) parseKey() (string, error) {
+	start := p.i
+	c := p.peek()
+	if !isLowerAlpha(c) && c != '*' {
+		return "", fmt.Errorf("invalid key at offset %d", p.i)
+	}
+	for !p.done() {
+		c := p.peek()
+		if !isLowerAlpha(c) && !isDigit(c) && !strings.ContainsRune("_-.*", rune(c)) {
+			break
+		}
+		p.i++
+	}
+	return p.s[start:p.i], nil
+}
+
+func (p *sfParser) parseBareItem() (interface{}, error) {
+	c := p.peek()
+	switch {
+	case c == '-' || isDigit(c):
+		return p.parseInteger()
+	case c == '"':
+		return p.parseString()
+	case c == ':':
+		return p.parseByteSequence()
+	case c == '?':
+		return p.parseBoolean()
+	case c == '*' || isAlpha(c):
+		return p.parseToken(), nil
+	default:
+		return nil, fmt.Errorf("invalid item at offset %d", p.i)
+	}
+}
+
+func (p *sfParser) parseInteger() (int64, error) {
+	start := p.i
+	if p.peek() == '-' {
+		p.i++
+	}
+	for isDigit(p.peek()) {
+		p.i++
+	}
+	if p.peek() == '.' {
+		return 0, fmt.Errorf("decimals are not supported")
+	}
+	return strconv.ParseInt(p.s[start:p.i], 10, 64)
+}
+
+func (p *sfParser) parseString() (string, error) {
+	p.i++
+	var b strings.Builder
+	for !p.done() {
+		c := p.next()
+		switch {
+		case c == '\\':
+			escaped := p.next()
+			if escaped != '"' && escaped != '\\' {
+				return "", fmt.Errorf("invalid escape in string")
+			}
+			b.WriteByte(escaped)
+		case c == '"':
+			return b.String(), nil
+		case c < 0x20 || c > 0x7e:
+			return "", fmt.Errorf("invalid character in string")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+func (p *sfParser) parseByteSequence() ([]byte, error) {
+	p.i++
+	end := strings.IndexByte(p.s[p.i:], ':')
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated byte sequence")
+	}
+	encoded := p.s[p.i : p.i+end]
+	p.i += end + 1
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+func (p *sfParser) parseBoolean() (bool, error) {
+	p.i++
+	switch p.next() {
+	case '1':
+		return true, nil
+	case '0':
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid boolean")
+	}
+}
+
+func (p *sfParser) parseToken() token {
+	start := p.i
+	for !p.done() {
+		c := p.peek()
+		if c <= 0x20 || c >= 0x7f || strings.ContainsRune("\"(),;<=>?@[\\]{}", rune(c)) {
+			break
+		}
+		p.i++
+	}
+	return token(p.s[start:p.i])
+}
+
+// isKey reports whether the string is a valid dictionary key
+func isKey(s string) bool {
+	p := &sfParser{s: s}
+	_, err := p.parseKey()
+	return err == nil && p.done()
+}
+
+// serializeString serializes a structured field string
+func serializeString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// isPrintableASCII reports whether the string can be serialized as a
+// structured field string
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func isLowerAlpha(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
+
+func isAlpha(c byte) bool {
+	return isLowerAlpha(c) || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
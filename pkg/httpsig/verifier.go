@@ -0,0 +1,257 @@
+package httpsig
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+)
+
+// allowedClockSkew is how far in the future the creation time of a signature
+// may be, to allow for clock differences between the signer and the verifier
+const allowedClockSkew = time.Minute
+
+// DefaultRequiredComponents are the components every signature must cover
+// when no required components are configured: the request line and the user
+// the proxy authenticated
+var DefaultRequiredComponents = []string{ComponentMethod, ComponentTargetURI, "x-forwarded-user"}
+
+var (
+	// ErrMissingSignature is returned when a request is not signed
+	ErrMissingSignature = errors.New("request is not signed")
+
+	// ErrUnknownKey is returned when no signature of a request was created
+	// with a key known to the verifier
+	ErrUnknownKey = errors.New("request is not signed with a known key")
+)
+
+// VerifierOptions configures a Verifier
+type VerifierOptions struct {
+	// Keys verify the signatures, matched by the `keyid` parameter
+	Keys []Key
+
+	// RequiredComponents are the components every signature must cover.
+	// Defaults to DefaultRequiredComponents, which rejects requests without
+	// an X-Forwarded-User header.
+	//
+	// The signature of a request leaves out headers missing from the
+	// request, and a valid signature says nothing about the components it
+	// does not cover: every header the handler trusts, such as the identity
+	// headers set by the proxy, must be required.
+	RequiredComponents []string
+
+	// Label restricts verification to the signature with the label.
+	// By default the first signature with a known key is verified.
+	Label string
+
+	// Tag, when set, must be the `tag` parameter of the signature
+	Tag string
+
+	// MaxAge rejects signatures created longer ago, when set
+	MaxAge time.Duration
+
+	// MaxBodySize is the size of the largest request content that is read
+	// to check its Content-Digest. Larger requests are rejected with
+	// ErrBodyTooLarge. Defaults to DefaultMaxBodySize.
+	MaxBodySize int64
+}
+
+// Verifier verifies the HTTP Message Signatures of requests
+type Verifier struct {
+	// Clock is used to check the creation and expiry of signatures
+	Clock clock.Clock
+
+	keys        map[string]Key
+	required    []string
+	label       string
+	tag         string
+	maxAge      time.Duration
+	maxBodySize int64
+}
+
+// NewVerifier creates a Verifier from the options
+func NewVerifier(opts VerifierOptions) (*Verifier, error) {
+	if len(opts.Keys) == 0 {
+		return nil, errors.New("at least one key is required")
+	}
+
+	keys := map[string]Key{}
+	for _, key := range opts.Keys {
+		if _, ok := keys[key.ID()]; ok {
+			return nil, fmt.Errorf("multiple keys with ID %q", key.ID())
+		}
+		keys[key.ID()] = key
+	}
+	required := opts.RequiredComponents
+	if len(required) == 0 {
+		required = DefaultRequiredComponents
+	}
+	for _, component := range required {
+		if err := validateComponent(component); err != nil {
+			return nil, err
+		}
+	}
+
+	maxBodySize := opts.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+
+	return &Verifier{
+		keys:        keys,
+		required:    required,
+		label:       opts.Label,
+		tag:         opts.Tag,
+		maxAge:      opts.MaxAge,
+		maxBodySize: maxBodySize,
+	}, nil
+}
+
+// Verify verifies the signature of a request received by a server.
+// When the Content-Digest is covered, the request content is read to check
+// the digest, and left readable for the handler.
+func (v *Verifier) Verify(req *http.Request) error {
+	if len(req.Header.Values(SignatureInputHeader)) == 0 || len(req.Header.Values(SignatureHeader)) == 0 {
+		return ErrMissingSignature
+	}
+
+	inputs, err := parseDictionary(strings.Join(req.Header.Values(SignatureInputHeader), ", "))
+	if err != nil {
+		return fmt.Errorf("invalid %s header: %v", SignatureInputHeader, err)
+	}
+	signatures, err := parseDictionary(strings.Join(req.Header.Values(SignatureHeader), ", "))
+	if err != nil {
+		return fmt.Errorf("invalid %s header: %v", SignatureHeader, err)
+	}
+
+	for _, input := range inputs {
+		if v.label != "" && input.key != v.label {
+			continue
+		}
+		keyID, _ := input.params.get("keyid")
+		id, ok := keyID.(string)
+		if !ok {
+			continue
+		}
+		key, ok := v.keys[id]
+		if !ok {
+			continue
+		}
+		return v.verify(req, input, signatures, key)
+	}
+	return ErrUnknownKey
+}
+
+// verify verifies a single signature of the request with the key
+func (v *Verifier) verify(req *http.Request, input member, signatures []member, key Key) error {
+	if !input.isInner {
+		return fmt.Errorf("signature %q has no covered components", input.key)
+	}
+	if alg, ok := input.params.get("alg"); ok && alg != key.Algorithm() {
+		return fmt.Errorf("signature %q has algorithm %v, but key %q uses %s", input.key, alg, key.ID(), key.Algorithm())
+	}
+	if v.tag != "" {
+		if tag, _ := input.params.get("tag"); tag != v.tag {
+			return fmt.Errorf("signature %q does not have the tag %q", input.key, v.tag)
+		}
+	}
+	if err := v.checkTimes(input); err != nil {
+		return err
+	}
+
+	components := make([]string, 0, len(input.inner))
+	for _, item := range input.inner {
+		component, ok := item.value.(string)
+		if !ok || len(item.params) > 0 {
+			return fmt.Errorf("signature %q covers an unsupported component", input.key)
+		}
+		components = append(components, component)
+	}
+	for _, required := range v.required {
+		if !contains(components, required) {
+			return fmt.Errorf("signature %q does not cover the required component %q", input.key, required)
+		}
+	}
+
+	var signature []byte
+	for _, m := range signatures {
+		if m.key == input.key {
+			signature, _ = m.item.value.([]byte)
+		}
+	}
+	if signature == nil {
+		return fmt.Errorf("signature %q is missing from the %s header", input.key, SignatureHeader)
+	}
+
+	base, err := newServerMessage(req).signatureBase(components, input.raw)
+	if err != nil {
+		return err
+	}
+	if err := key.verify(base, signature); err != nil {
+		return fmt.Errorf("invalid signature %q: %v", input.key, err)
+	}
+
+	if contains(components, ComponentContentDigest) {
+		return verifyContentDigest(req, v.maxBodySize)
+	}
+	return nil
+}
+
+// checkTimes checks the creation and expiry of the signature
+func (v *Verifier) checkTimes(input member) error {
+	now := v.Clock.Now()
+
+	createdParam, _ := input.params.get("created")
+	createdUnix, ok := createdParam.(int64)
+	if !ok {
+		return fmt.Errorf("signature %q has no creation time", input.key)
+	}
+	created := time.Unix(createdUnix, 0)
+	if created.After(now.Add(allowedClockSkew)) {
+		return fmt.Errorf("signature %q was created in the future", input.key)
+	}
+	if v.maxAge > 0 && now.Sub(created) > v.maxAge {
+		return fmt.Errorf("signature %q is older than %s", input.key, v.maxAge)
+	}
+
+	if expiresParam, ok := input.params.get("expires"); ok {
+		expiresUnix, ok := expiresParam.(int64)
+		if !ok {
+			return fmt.Errorf("signature %q has an invalid expiry", input.key)
+		}
+		if !now.Before(time.Unix(expiresUnix, 0)) {
+			return fmt.Errorf("signature %q has expired", input.key)
+		}
+	}
+	return nil
+}
+
+// Middleware rejects requests without a valid signature with a 401
+// Unauthorized response, and requests too large to check their content with
+// a 413 Request Entity Too Large response.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		err := v.Verify(req)
+		if errors.Is(err, ErrBodyTooLarge) {
+			http.Error(rw, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(rw, "invalid request signature", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
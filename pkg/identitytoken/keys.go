@@ -0,0 +1,106 @@
+package identitytoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingKey is a private key that signs tokens
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private crypto.Signer
+
+	// retiresAt is when a replaced key is no longer published.
+	// The zero time means the key is published until it is removed from the
+	// configuration.
+	retiresAt time.Time
+}
+
+// isRetired reports whether the key is past its grace period
+func (k *signingKey) isRetired(now time.Time) bool {
+	return !k.retiresAt.IsZero() && !now.Before(k.retiresAt)
+}
+
+// publicJWK returns the public key as a JSON Web Key
+func (k *signingKey) publicJWK() jose.JSONWebKey {
+	return jose.JSONWebKey{
+		Key:       k.private.Public(),
+		KeyID:     k.id,
+		Algorithm: k.method.Alg(),
+		Use:       "sig",
+	}
+}
+
+// parseSigningKey parses a PEM encoded RSA or EC private key.
+// Without an ID, the key is identified by its RFC 7638 thumbprint.
+func parseSigningKey(id string, data []byte) (*signingKey, error) {
+	var private crypto.Signer
+	if rsaKey, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		private = rsaKey
+	} else if ecKey, err := jwt.ParseECPrivateKeyFromPEM(data); err == nil {
+		private = ecKey
+	} else {
+		return nil, fmt.Errorf("key is not a PEM encoded RSA or EC private key")
+	}
+	return newSigningKey(id, private)
+}
+
+// generateSigningKey generates a P-256 EC key
+func generateSigningKey() (*signingKey, error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate signing key: %v", err)
+	}
+	return newSigningKey("", private)
+}
+
+func newSigningKey(id string, private crypto.Signer) (*signingKey, error) {
+	method, err := signingMethod(private)
+	if err != nil {
+		return nil, err
+	}
+
+	if id == "" {
+		thumbprint, err := (&jose.JSONWebKey{Key: private.Public()}).Thumbprint(crypto.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("could not compute key thumbprint: %v", err)
+		}
+		id = base64.RawURLEncoding.EncodeToString(thumbprint)
+	}
+
+	return &signingKey{
+		id:      id,
+		method:  method,
+		private: private,
+	}, nil
+}
+
+// signingMethod returns the signing method of the key: RS256 for RSA keys
+// and the ECDSA method matching the curve for EC keys
+func signingMethod(private crypto.Signer) (jwt.SigningMethod, error) {
+	switch key := private.(type) {
+	case *rsa.PrivateKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("unsupported EC curve %s", key.Curve.Params().Name)
+	}
+	return nil, fmt.Errorf("unsupported key type %T", private)
+}
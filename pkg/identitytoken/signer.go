@@ -0,0 +1,254 @@
+package identitytoken
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options/util"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+const (
+	defaultLifetime            = 5 * time.Minute
+	defaultKeyRotationInterval = 24 * time.Hour
+)
+
+// defaultClaims are the session claims included in the tokens when no claims
+// are configured
+var defaultClaims = []string{"email", "groups", "preferred_username"}
+
+// Signer issues identity tokens for sessions and publishes the keys that
+// verify them as a JSON Web Key Set.
+type Signer struct {
+	// Clock is used to issue tokens and to rotate generated keys
+	Clock clock.Clock
+
+	issuer    string
+	audiences []string
+	lifetime  time.Duration
+	claims    []string
+
+	// generated is true when the proxy generates and rotates its own keys
+	generated        bool
+	rotationInterval time.Duration
+	gracePeriod      time.Duration
+
+	mu   sync.RWMutex
+	keys []*signingKey
+	// next is the generated key that replaces the current key on the next
+	// rotation. It is published a rotation interval before it signs tokens,
+	// so that upstreams that cache the JWKS know it by then.
+	next *signingKey
+}
+
+// NewSigner creates a Signer from the identity token options.
+// Without configured signing keys, the Signer generates a key, which is
+// replaced by Rotate, and the key that replaces it.
+func NewSigner(opts *options.IdentityToken) (*Signer, error) {
+	s := &Signer{
+		issuer:           opts.Issuer,
+		audiences:        opts.Audiences,
+		lifetime:         opts.Lifetime.Duration(),
+		claims:           opts.Claims,
+		rotationInterval: opts.KeyRotationInterval.Duration(),
+		gracePeriod:      opts.KeyGracePeriod.Duration(),
+	}
+	if s.lifetime <= 0 {
+		s.lifetime = defaultLifetime
+	}
+	if len(s.claims) == 0 {
+		s.claims = defaultClaims
+	}
+	if s.rotationInterval <= 0 {
+		s.rotationInterval = defaultKeyRotationInterval
+	}
+	if s.gracePeriod <= 0 {
+		s.gracePeriod = s.lifetime
+	}
+
+	for i, keyOpts := range opts.SigningKeys {
+		if keyOpts.Key == nil {
+			return nil, fmt.Errorf("signing key %d has no key", i)
+		}
+		data, err := util.GetSecretValue(keyOpts.Key)
+		if err != nil {
+			return nil, fmt.Errorf("could not load signing key %d: %v", i, err)
+		}
+		key, err := parseSigningKey(keyOpts.ID, data)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse signing key %d: %v", i, err)
+		}
+		s.keys = append(s.keys, key)
+	}
+
+	if len(s.keys) == 0 {
+		s.generated = true
+		key, err := generateSigningKey()
+		if err != nil {
+			return nil, err
+		}
+		s.keys = []*signingKey{key}
+		if s.next, err = generateSigningKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Sign issues a token for the session, signed with the current key.
+func (s *Signer) Sign(session *sessionsapi.SessionState) (string, error) {
+	if session == nil {
+		return "", errors.New("no session")
+	}
+
+	now := s.Clock.Now()
+	claims := jwt.MapClaims{
+		"iss": s.issuer,
+		"sub": session.User,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(s.lifetime).Unix(),
+		"jti": uuid.New().String(),
+	}
+	if len(s.audiences) == 1 {
+		claims["aud"] = s.audiences[0]
+	} else if len(s.audiences) > 1 {
+		claims["aud"] = s.audiences
+	}
+	for _, claim := range s.claims {
+		if value, ok := claimValue(claim, session.GetClaim(claim)); ok {
+			claims[claim] = value
+		}
+	}
+
+	key := s.currentKey()
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.private)
+}
+
+// claimValue converts the values of a session claim into a claim of the
+// token. A single value becomes a string, several values a list. Groups are
+// always a list.
+func claimValue(claim string, values []string) (interface{}, bool) {
+	nonEmpty := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" {
+			nonEmpty = append(nonEmpty, value)
+		}
+	}
+
+	switch {
+	case len(nonEmpty) == 0:
+		return nil, false
+	case len(nonEmpty) == 1 && claim != "groups":
+		return nonEmpty[0], true
+	default:
+		return nonEmpty, true
+	}
+}
+
+func (s *Signer) currentKey() *signingKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys[0]
+}
+
+// JWKS returns the public keys that verify the tokens: the current key,
+// followed by the next generated key, the configured previous keys and the
+// replaced generated keys that are still within their grace period.
+func (s *Signer) JWKS() jose.JSONWebKeySet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := s.Clock.Now()
+	jwks := jose.JSONWebKeySet{}
+	keys := s.keys
+	if s.next != nil {
+		keys = append([]*signingKey{s.keys[0], s.next}, s.keys[1:]...)
+	}
+	for _, key := range keys {
+		if key.isRetired(now) {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, key.publicJWK())
+	}
+	return jwks
+}
+
+// ServeJWKS serves the JSON Web Key Set of the signer
+func (s *Signer) ServeJWKS(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(s.JWKS()); err != nil {
+		logger.Errorf("Error encoding identity token JWKS: %v", err)
+	}
+}
+
+// Run rotates the generated keys every rotation interval until the context
+// is cancelled. Configured keys are never rotated by the proxy.
+func (s *Signer) Run(ctx context.Context) {
+	if !s.generated {
+		return
+	}
+
+	ticker := time.NewTicker(s.rotationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Rotate(); err != nil {
+				logger.Errorf("Unable to rotate the identity token signing key: %v", err)
+			}
+		}
+	}
+}
+
+// Rotate replaces the generated signing key with the next key, which has
+// been published since the previous rotation, and generates a new next key.
+// The replaced key is published for the grace period, and keys past their
+// grace period are removed.
+func (s *Signer) Rotate() error {
+	if !s.generated {
+		return errors.New("configured signing keys cannot be rotated by the proxy")
+	}
+
+	next, err := generateSigningKey()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Clock.Now()
+	key := s.next
+	s.next = next
+	keys := []*signingKey{key}
+	for i, previous := range s.keys {
+		if i == 0 {
+			previous.retiresAt = now.Add(s.gracePeriod)
+		}
+		if !previous.isRetired(now) {
+			keys = append(keys, previous)
+		}
+	}
+	s.keys = keys
+
+	logger.Printf("Rotated the identity token signing key, new key ID: %s", key.id)
+	return nil
+}
@@ -0,0 +1,267 @@
+package identitytoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Signer Suite", func() {
+	var now time.Time
+	var session *sessionsapi.SessionState
+
+	BeforeEach(func() {
+		now = time.Now().Truncate(time.Second)
+		session = &sessionsapi.SessionState{
+			User:              "user-123",
+			Email:             "user@example.com",
+			PreferredUsername: "user",
+			Groups:            []string{"admins"},
+			AccessToken:       "access-token",
+			Claims:            map[string][]string{"department": {"sre", "ops"}},
+		}
+	})
+
+	newSigner := func(opts *options.IdentityToken) *Signer {
+		signer, err := NewSigner(opts)
+		Expect(err).ToNot(HaveOccurred())
+		signer.Clock.Set(now)
+		DeferCleanup(func() { signer.Clock.Reset() })
+		return signer
+	}
+
+	// verify parses the token with the keys published by the signer
+	verify := func(signer *Signer, token string) (jwt.MapClaims, string) {
+		jwks := signer.JWKS()
+		claims := jwt.MapClaims{}
+		parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+			kid, _ := t.Header["kid"].(string)
+			keys := jwks.Key(kid)
+			if len(keys) == 0 {
+				return nil, fmt.Errorf("unknown key %q", kid)
+			}
+			return keys[0].Key, nil
+		}, jwt.WithTimeFunc(func() time.Time { return now }))
+		Expect(err).ToNot(HaveOccurred())
+		return claims, parsed.Header["kid"].(string)
+	}
+
+	Context("Sign", func() {
+		It("issues a token verified by the published keys", func() {
+			signer := newSigner(&options.IdentityToken{
+				Issuer:    "https://proxy.example.com",
+				Audiences: []string{"upstream"},
+			})
+
+			token, err := signer.Sign(session)
+			Expect(err).ToNot(HaveOccurred())
+
+			claims, _ := verify(signer, token)
+			Expect(claims).To(HaveKeyWithValue("iss", "https://proxy.example.com"))
+			Expect(claims).To(HaveKeyWithValue("aud", "upstream"))
+			Expect(claims).To(HaveKeyWithValue("sub", "user-123"))
+			Expect(claims).To(HaveKeyWithValue("email", "user@example.com"))
+			Expect(claims).To(HaveKeyWithValue("preferred_username", "user"))
+			Expect(claims).To(HaveKeyWithValue("groups", []interface{}{"admins"}))
+			Expect(claims).To(HaveKeyWithValue("iat", float64(now.Unix())))
+			Expect(claims).To(HaveKeyWithValue("exp", float64(now.Add(defaultLifetime).Unix())))
+			Expect(claims).To(HaveKey("jti"))
+			Expect(claims).ToNot(HaveKey("access_token"))
+		})
+
+		It("includes the configured claims", func() {
+			signer := newSigner(&options.IdentityToken{
+				Issuer:    "https://proxy.example.com",
+				Audiences: []string{"first", "second"},
+				Lifetime:  durationPtr(time.Minute),
+				Claims:    []string{"email", "department", "missing"},
+			})
+
+			token, err := signer.Sign(session)
+			Expect(err).ToNot(HaveOccurred())
+
+			claims, _ := verify(signer, token)
+			Expect(claims).To(HaveKeyWithValue("aud", []interface{}{"first", "second"}))
+			Expect(claims).To(HaveKeyWithValue("exp", float64(now.Add(time.Minute).Unix())))
+			Expect(claims).To(HaveKeyWithValue("email", "user@example.com"))
+			Expect(claims).To(HaveKeyWithValue("department", []interface{}{"sre", "ops"}))
+			Expect(claims).ToNot(HaveKey("missing"))
+			Expect(claims).ToNot(HaveKey("groups"))
+		})
+
+		It("does not sign a token without a session", func() {
+			signer := newSigner(&options.IdentityToken{Issuer: "https://proxy.example.com"})
+
+			_, err := signer.Sign(nil)
+			Expect(err).To(MatchError("no session"))
+		})
+	})
+
+	Context("with configured signing keys", func() {
+		It("signs with an RSA key identified by its thumbprint", func() {
+			rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).ToNot(HaveOccurred())
+			signer := newSigner(&options.IdentityToken{
+				Issuer:      "https://proxy.example.com",
+				SigningKeys: []options.SigningKey{{Key: pemSecret("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))}},
+			})
+
+			token, err := signer.Sign(session)
+			Expect(err).ToNot(HaveOccurred())
+			_, kid := verify(signer, token)
+
+			thumbprint, err := (&jose.JSONWebKey{Key: rsaKey.Public()}).Thumbprint(crypto.SHA256)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(kid).To(Equal(base64.RawURLEncoding.EncodeToString(thumbprint)))
+			Expect(signer.JWKS().Keys[0].Algorithm).To(Equal("RS256"))
+		})
+
+		It("signs with the first key and publishes the others", func() {
+			current, previous := generateECKey(elliptic.P384()), generateECKey(elliptic.P256())
+			signer := newSigner(&options.IdentityToken{
+				Issuer: "https://proxy.example.com",
+				SigningKeys: []options.SigningKey{
+					{ID: "current", Key: pemSecret("EC PRIVATE KEY", current)},
+					{ID: "previous", Key: pemSecret("EC PRIVATE KEY", previous)},
+				},
+			})
+
+			token, err := signer.Sign(session)
+			Expect(err).ToNot(HaveOccurred())
+			_, kid := verify(signer, token)
+			Expect(kid).To(Equal("current"))
+
+			jwks := signer.JWKS()
+			Expect(jwks.Keys).To(HaveLen(2))
+			Expect(jwks.Keys[0].KeyID).To(Equal("current"))
+			Expect(jwks.Keys[0].Algorithm).To(Equal("ES384"))
+			Expect(jwks.Keys[1].KeyID).To(Equal("previous"))
+			Expect(jwks.Keys[1].Algorithm).To(Equal("ES256"))
+		})
+
+		It("does not rotate configured keys", func() {
+			signer := newSigner(&options.IdentityToken{
+				Issuer:      "https://proxy.example.com",
+				SigningKeys: []options.SigningKey{{Key: pemSecret("EC PRIVATE KEY", generateECKey(elliptic.P256()))}},
+			})
+
+			Expect(signer.Rotate()).To(MatchError("configured signing keys cannot be rotated by the proxy"))
+		})
+
+		It("fails with an invalid key", func() {
+			_, err := NewSigner(&options.IdentityToken{
+				Issuer:      "https://proxy.example.com",
+				SigningKeys: []options.SigningKey{{Key: &options.SecretSource{Value: []byte("not a key")}}},
+			})
+			Expect(err).To(MatchError("could not parse signing key 0: key is not a PEM encoded RSA or EC private key"))
+		})
+	})
+
+	Context("with generated signing keys", func() {
+		It("publishes the replaced key for the grace period", func() {
+			signer := newSigner(&options.IdentityToken{
+				Issuer:         "https://proxy.example.com",
+				KeyGracePeriod: durationPtr(10 * time.Minute),
+			})
+
+			oldToken, err := signer.Sign(session)
+			Expect(err).ToNot(HaveOccurred())
+			_, oldKid := verify(signer, oldToken)
+
+			Expect(signer.Rotate()).To(Succeed())
+
+			newToken, err := signer.Sign(session)
+			Expect(err).ToNot(HaveOccurred())
+			_, newKid := verify(signer, newToken)
+			Expect(newKid).ToNot(Equal(oldKid))
+
+			// Tokens signed before the rotation can still be verified
+			verify(signer, oldToken)
+			Expect(signer.JWKS().Keys).To(HaveLen(3))
+
+			signer.Clock.Add(10 * time.Minute)
+			jwks := signer.JWKS()
+			Expect(jwks.Keys).To(HaveLen(2))
+			Expect(jwks.Keys[0].KeyID).To(Equal(newKid))
+
+			// The retired key is removed on the next rotation
+			Expect(signer.Rotate()).To(Succeed())
+			Expect(signer.keys).To(HaveLen(2))
+		})
+
+		It("publishes the next key a rotation before signing with it", func() {
+			signer := newSigner(&options.IdentityToken{Issuer: "https://proxy.example.com"})
+
+			jwks := signer.JWKS()
+			Expect(jwks.Keys).To(HaveLen(2))
+			nextKid := jwks.Keys[1].KeyID
+
+			token, err := signer.Sign(session)
+			Expect(err).ToNot(HaveOccurred())
+			_, kid := verify(signer, token)
+			Expect(kid).To(Equal(jwks.Keys[0].KeyID))
+
+			Expect(signer.Rotate()).To(Succeed())
+			token, err = signer.Sign(session)
+			Expect(err).ToNot(HaveOccurred())
+			_, kid = verify(signer, token)
+			Expect(kid).To(Equal(nextKid))
+			Expect(signer.JWKS().Keys[1].KeyID).ToNot(Equal(nextKid))
+		})
+	})
+
+	Context("ServeJWKS", func() {
+		It("serves the published keys", func() {
+			signer := newSigner(&options.IdentityToken{Issuer: "https://proxy.example.com"})
+
+			rw := httptest.NewRecorder()
+			signer.ServeJWKS(rw, httptest.NewRequest(http.MethodGet, "/oauth2/jwks.json", nil))
+			Expect(rw.Code).To(Equal(http.StatusOK))
+			Expect(rw.Header().Get("Content-Type")).To(Equal("application/json"))
+
+			jwks := jose.JSONWebKeySet{}
+			Expect(json.Unmarshal(rw.Body.Bytes(), &jwks)).To(Succeed())
+			// The current and the next key
+			Expect(jwks.Keys).To(HaveLen(2))
+			Expect(jwks.Keys[0].IsPublic()).To(BeTrue())
+			Expect(jwks.Keys[0].Use).To(Equal("sig"))
+			Expect(jwks.Keys[0].Algorithm).To(Equal("ES256"))
+		})
+	})
+})
+
+func durationPtr(d time.Duration) *options.Duration {
+	duration := options.Duration(d)
+	return &duration
+}
+
+func pemSecret(blockType string, der []byte) *options.SecretSource {
+	return &options.SecretSource{
+		Value: pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}),
+	}
+}
+
+func generateECKey(curve elliptic.Curve) []byte {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	der, err := x509.MarshalECPrivateKey(key)
+	Expect(err).ToNot(HaveOccurred())
+	return der
+}
@@ -19,6 +19,15 @@ import (
 // AuthStatus defines the different types of auth logging that occur
 type AuthStatus string
 
+// AuthRefreshTokenRejected indicates that a session was cleared because the
+// provider rejected its refresh token, for example when a rotated refresh
+// token was reused
+const AuthRefreshTokenRejected AuthStatus = "AuthRefreshTokenRejected"
+
+// AuthSessionEvicted indicates that a session was removed to keep the user
+// within the concurrent session limit
+const AuthSessionEvicted AuthStatus = "AuthSessionEvicted"
+
 // Level indicates the log level for log messages
 type Level int
 
@@ -11,8 +11,11 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/header"
 )
 
-func NewRequestHeaderInjector(headers []options.Header) (alice.Constructor, error) {
-	headerInjector, err := newRequestHeaderInjector(headers)
+// NewRequestHeaderInjector creates a middleware that injects the headers into
+// the request. The signer signs the identity tokens of the headers, and may
+// be nil when no identity token is configured.
+func NewRequestHeaderInjector(headers []options.Header, signer header.TokenSigner) (alice.Constructor, error) {
+	headerInjector, err := newRequestHeaderInjector(headers, signer)
 	if err != nil {
 		return nil, fmt.Errorf("error building request header injector: %v", err)
 	}
@@ -59,8 +62,8 @@ func stripHeaders(headers []string, next http.Handler) http.Handler {
 	})
 }
 
-func newRequestHeaderInjector(headers []options.Header) (alice.Constructor, error) {
-	injector, err := header.NewInjector(headers)
+func newRequestHeaderInjector(headers []options.Header, signer header.TokenSigner) (alice.Constructor, error) {
+	injector, err := header.NewInjectorWithSigner(headers, signer)
 	if err != nil {
 		return nil, fmt.Errorf("error building request injector: %v", err)
 	}
@@ -82,8 +85,11 @@ func injectRequestHeaders(injector header.Injector, next http.Handler) http.Hand
 	})
 }
 
-func NewResponseHeaderInjector(headers []options.Header) (alice.Constructor, error) {
-	headerInjector, err := newResponseHeaderInjector(headers)
+// NewResponseHeaderInjector creates a middleware that injects the headers into
+// the response. The signer signs the identity tokens of the headers, and may
+// be nil when no identity token is configured.
+func NewResponseHeaderInjector(headers []options.Header, signer header.TokenSigner) (alice.Constructor, error) {
+	headerInjector, err := newResponseHeaderInjector(headers, signer)
 	if err != nil {
 		return nil, fmt.Errorf("error building response header injector: %v", err)
 	}
@@ -91,8 +97,8 @@ func NewResponseHeaderInjector(headers []options.Header) (alice.Constructor, err
 	return headerInjector, nil
 }
 
-func newResponseHeaderInjector(headers []options.Header) (alice.Constructor, error) {
-	injector, err := header.NewInjector(headers)
+func newResponseHeaderInjector(headers []options.Header, signer header.TokenSigner) (alice.Constructor, error) {
+	injector, err := header.NewInjectorWithSigner(headers, signer)
 	if err != nil {
 		return nil, fmt.Errorf("error building response injector: %v", err)
 	}
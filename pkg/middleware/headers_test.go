@@ -37,7 +37,7 @@ var _ = Describe("Headers Suite", func() {
 			// Create the handler with a next handler that will capture the headers
 			// from the request
 			var gotHeaders http.Header
-			injector, err := NewRequestHeaderInjector(in.headers)
+			injector, err := NewRequestHeaderInjector(in.headers, nil)
 			if in.expectedErr != "" {
 				Expect(err).To(MatchError(in.expectedErr))
 				return
@@ -87,6 +87,30 @@ var _ = Describe("Headers Suite", func() {
 			},
 			expectedErr: "",
 		}),
+		Entry("with an extra claim valued header", headersTableInput{
+			headers: []options.Header{
+				{
+					Name: "Department",
+					Values: []options.HeaderValue{
+						{
+							ClaimSource: &options.ClaimSource{
+								Claim: "org.department",
+							},
+						},
+					},
+				},
+			},
+			initialHeaders: http.Header{},
+			session: &sessionsapi.SessionState{
+				Claims: map[string][]string{
+					"org.department": {"engineering"},
+				},
+			},
+			expectedHeaders: http.Header{
+				"Department": []string{"engineering"},
+			},
+			expectedErr: "",
+		}),
 		Entry("with a claim valued header (without preservation)", headersTableInput{
 			headers: []options.Header{
 				{
@@ -227,7 +251,7 @@ var _ = Describe("Headers Suite", func() {
 			// Create the handler with a next handler that will capture the headers
 			// from the request
 			var gotHeaders http.Header
-			injector, err := NewResponseHeaderInjector(in.headers)
+			injector, err := NewResponseHeaderInjector(in.headers, nil)
 			if in.expectedErr != "" {
 				Expect(err).To(MatchError(in.expectedErr))
 				return
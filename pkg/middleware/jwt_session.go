@@ -10,24 +10,40 @@ import (
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
 	k8serrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
 const jwtRegexFormat = `^ey[a-zA-Z0-9_-]*\.ey[a-zA-Z0-9_-]*\.[a-zA-Z0-9_-]+$`
 
+// bearerTokenRegexFormat is the syntax of bearer tokens (RFC 6750 section 2.1)
+const bearerTokenRegexFormat = `^[a-zA-Z0-9._~+/-]+=*$`
+
 func NewJwtSessionLoader(sessionLoaders []middlewareapi.TokenToSessionFunc) alice.Constructor {
+	return NewJwtSessionLoaderWithIntrospection(sessionLoaders, nil)
+}
+
+// NewJwtSessionLoaderWithIntrospection creates a JWT session loader that also
+// accepts opaque bearer tokens, loading their sessions with the
+// introspectionLoaders. JWTs that none of the sessionLoaders can verify are
+// introspected too, unless they were rejected for their audience.
+func NewJwtSessionLoaderWithIntrospection(sessionLoaders, introspectionLoaders []middlewareapi.TokenToSessionFunc) alice.Constructor {
 	js := &jwtSessionLoader{
-		jwtRegex:       regexp.MustCompile(jwtRegexFormat),
-		sessionLoaders: sessionLoaders,
+		jwtRegex:             regexp.MustCompile(jwtRegexFormat),
+		bearerRegex:          regexp.MustCompile(bearerTokenRegexFormat),
+		sessionLoaders:       sessionLoaders,
+		introspectionLoaders: introspectionLoaders,
 	}
 	return js.loadSession
 }
 
-// jwtSessionLoader is responsible for loading sessions from JWTs in
-// Authorization headers.
+// jwtSessionLoader is responsible for loading sessions from JWTs, and
+// opaque tokens when introspection is enabled, in Authorization headers.
 type jwtSessionLoader struct {
-	jwtRegex       *regexp.Regexp
-	sessionLoaders []middlewareapi.TokenToSessionFunc
+	jwtRegex             *regexp.Regexp
+	bearerRegex          *regexp.Regexp
+	sessionLoaders       []middlewareapi.TokenToSessionFunc
+	introspectionLoaders []middlewareapi.TokenToSessionFunc
 }
 
 // loadSession attempts to load a session from a JWT stored in an Authorization
@@ -53,6 +69,7 @@ func (j *jwtSessionLoader) loadSession(next http.Handler) http.Handler {
 
 		// Add the session to the scope if it was found
 		scope.Session = session
+		scope.BearerToken = session != nil
 		next.ServeHTTP(rw, req)
 	})
 }
@@ -73,12 +90,32 @@ func (j *jwtSessionLoader) getJwtSession(req *http.Request) (*sessionsapi.Sessio
 
 	// This leading error message only occurs if all session loaders fail
 	errs := []error{errors.New("unable to verify bearer token")}
-	for _, loader := range j.sessionLoaders {
-		session, err := loader(req.Context(), token)
-		if err != nil {
-			errs = append(errs, err)
-			continue
+	load := func(loaders []middlewareapi.TokenToSessionFunc) *sessionsapi.SessionState {
+		for _, loader := range loaders {
+			session, err := loader(req.Context(), token)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			return session
+		}
+		return nil
+	}
+
+	// Opaque tokens can only be introspected
+	if j.jwtRegex.MatchString(token) {
+		if session := load(j.sessionLoaders); session != nil {
+			return session, nil
+		}
+		// A JWT issued for another audience must not be accepted by the
+		// introspection endpoint instead
+		for _, err := range errs {
+			if errors.Is(err, internaloidc.ErrInvalidAudience) {
+				return nil, k8serrors.NewAggregate(errs)
+			}
 		}
+	}
+	if session := load(j.introspectionLoaders); session != nil {
 		return session, nil
 	}
 
@@ -97,6 +134,11 @@ func (j *jwtSessionLoader) findTokenFromHeader(header string) (string, error) {
 		return token, nil
 	}
 
+	if tokenType == "Bearer" && len(j.introspectionLoaders) > 0 && j.bearerRegex.MatchString(token) {
+		// Found an opaque bearer token to introspect
+		return token, nil
+	}
+
 	if tokenType == "Basic" {
 		// Check if we have a Bearer token masquerading in Basic
 		return j.getBasicToken(token)
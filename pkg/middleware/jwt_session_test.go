@@ -17,6 +17,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	k8serrors "k8s.io/apimachinery/pkg/util/errors"
@@ -65,6 +66,7 @@ Nnc3a3lGVWFCNUMxQnNJcnJMTWxka1dFaHluYmI4Ongtb2F1dGgtYmFzaWM=`
 		IDToken:     verifiedToken,
 		Email:       "john@example.com",
 		User:        "1234567890",
+		Audiences:   []string{"https://test.myapp.com"},
 		ExpiresOn:   &verifiedSessionExpiry,
 	}
 
@@ -120,6 +122,8 @@ Nnc3a3lGVWFCNUMxQnNJcnJMTWxka1dFaHluYmI4Ongtb2F1dGgtYmFzaWM=`
 				handler.ServeHTTP(rw, req)
 
 				Expect(gotSession).To(Equal(in.expectedSession))
+				// Only sessions loaded by the handler are marked as bearer token sessions
+				Expect(scope.BearerToken).To(Equal(in.existingSession == nil && in.expectedSession != nil))
 			},
 			Entry("<no value>", jwtSessionLoaderTableInput{
 				authorizationHeader: "",
@@ -165,6 +169,105 @@ Nnc3a3lGVWFCNUMxQnNJcnJMTWxka1dFaHluYmI4Ongtb2F1dGgtYmFzaWM=`
 
 	})
 
+	Context("JwtSessionLoader with introspection", func() {
+		const opaqueToken = "2YotnFZFEjr1zCsicMWpAA"
+		var introspectedSession = &sessionsapi.SessionState{
+			AccessToken: opaqueToken,
+			User:        "introspected",
+		}
+
+		var introspected []string
+
+		BeforeEach(func() {
+			introspected = nil
+		})
+
+		introspect := func(_ context.Context, token string) (*sessionsapi.SessionState, error) {
+			introspected = append(introspected, token)
+			if token != opaqueToken {
+				return nil, errors.New("token is not active")
+			}
+			return introspectedSession, nil
+		}
+
+		type introspectionTableInput struct {
+			authorizationHeader  string
+			clientID             string
+			expectedSession      *sessionsapi.SessionState
+			expectedIntrospected []string
+		}
+
+		DescribeTable("with an authorization header",
+			func(in introspectionTableInput) {
+				clientID := in.clientID
+				if clientID == "" {
+					clientID = "https://test.myapp.com"
+				}
+				verifier := internaloidc.NewVerifier(
+					oidc.NewVerifier(
+						"https://issuer.example.com",
+						noOpKeySet{},
+						&oidc.Config{
+							SkipClientIDCheck: true,
+							SkipExpiryCheck:   true,
+						},
+					),
+					internaloidc.IDTokenVerificationOptions{
+						AudienceClaims: []string{"aud"},
+						ClientID:       clientID,
+					},
+				).Verify
+
+				req := httptest.NewRequest("", "/", nil)
+				req.Header.Set("Authorization", in.authorizationHeader)
+				req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+
+				var gotSession *sessionsapi.SessionState
+				handler := NewJwtSessionLoaderWithIntrospection(
+					[]middlewareapi.TokenToSessionFunc{middlewareapi.CreateTokenToSessionFunc(verifier)},
+					[]middlewareapi.TokenToSessionFunc{introspect},
+				)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					gotSession = middlewareapi.GetRequestScope(r).Session
+				}))
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+
+				Expect(gotSession).To(Equal(in.expectedSession))
+				Expect(introspected).To(Equal(in.expectedIntrospected))
+			},
+			Entry("Bearer <opaqueToken>", introspectionTableInput{
+				authorizationHeader:  fmt.Sprintf("Bearer %s", opaqueToken),
+				expectedSession:      introspectedSession,
+				expectedIntrospected: []string{opaqueToken},
+			}),
+			Entry("Bearer <invalid opaque token>", introspectionTableInput{
+				authorizationHeader:  "Bearer abc{def}",
+				expectedSession:      nil,
+				expectedIntrospected: nil,
+			}),
+			Entry("Bearer <verifiedToken> is not introspected", introspectionTableInput{
+				authorizationHeader:  fmt.Sprintf("Bearer %s", verifiedToken),
+				expectedSession:      verifiedSession,
+				expectedIntrospected: nil,
+			}),
+			Entry("Bearer <verifiedToken> for another audience is not introspected", introspectionTableInput{
+				authorizationHeader:  fmt.Sprintf("Bearer %s", verifiedToken),
+				clientID:             "https://other.myapp.com",
+				expectedSession:      nil,
+				expectedIntrospected: nil,
+			}),
+			Entry("Bearer <nonVerifiedToken> is introspected", introspectionTableInput{
+				authorizationHeader:  fmt.Sprintf("Bearer %s", validToken),
+				expectedSession:      nil,
+				expectedIntrospected: []string{validToken},
+			}),
+			Entry("Basic Base64(any-user:any-password) is not introspected", introspectionTableInput{
+				authorizationHeader:  "Basic YW55LXVzZXI6YW55LXBhc3N3b3Jk",
+				expectedSession:      nil,
+				expectedIntrospected: nil,
+			}),
+		)
+	})
+
 	Context("getJWTSession", func() {
 		var j *jwtSessionLoader
 		const nonVerifiedToken = validToken
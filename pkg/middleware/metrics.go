@@ -120,3 +120,27 @@ func registerRequestsLatencyHistogram(registerer prometheus.Registerer) *prometh
 
 	return histogram
 }
+
+// registerSessionRefreshesCounter registers the
+// 'oauth2_proxy_session_refreshes_total' metric
+// This keeps a tally of the attempts to refresh sessions with the provider
+// bucketed by what triggered the refresh and whether it succeeded
+func registerSessionRefreshesCounter(registerer prometheus.Registerer) *prometheus.CounterVec {
+	counter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oauth2_proxy_session_refreshes_total",
+			Help: "Total number of session refreshes by trigger and result.",
+		},
+		[]string{"trigger", "result"},
+	)
+
+	if err := registerer.Register(counter); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			counter = are.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return counter
+}
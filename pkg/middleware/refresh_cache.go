@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+)
+
+// How long a refreshed session is reused by requests that still hold the
+// refresh token it was refreshed with.
+// TODO: This should probably be configurable by the end user.
+const sessionRefreshReuseDuration = 30 * time.Second
+
+// refreshCache holds recently refreshed sessions by the refresh token they
+// were refreshed with.
+// Providers that rotate refresh tokens reject a refresh token once it was
+// used. Requests that loaded the session before it was refreshed, such as
+// concurrent requests with the same session cookie, reuse the refreshed
+// session instead of refreshing it again with the used refresh token.
+// The refresh tokens are hashed, so that used refresh tokens are not kept in
+// memory.
+type refreshCache struct {
+	// Clock is used to expire refreshed sessions
+	Clock clock.Clock
+
+	mu       sync.Mutex
+	sessions map[string]refreshedSession
+}
+
+type refreshedSession struct {
+	session   sessionsapi.SessionState
+	expiresAt time.Time
+}
+
+func newRefreshCache() *refreshCache {
+	return &refreshCache{
+		sessions: map[string]refreshedSession{},
+	}
+}
+
+// get returns the session refreshed with the refresh token, if it was
+// refreshed recently
+func (c *refreshCache) get(refreshToken string) (*sessionsapi.SessionState, bool) {
+	if c == nil || refreshToken == "" {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := encryption.HashKey(refreshToken)
+	refreshed, ok := c.sessions[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.Clock.Now().Before(refreshed.expiresAt) {
+		delete(c.sessions, key)
+		return nil, false
+	}
+	session := refreshed.session
+	return &session, true
+}
+
+// put records the session refreshed with the refresh token, and removes
+// the sessions that are no longer reused
+func (c *refreshCache) put(refreshToken string, session *sessionsapi.SessionState) {
+	if c == nil || refreshToken == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.Clock.Now()
+	for key, refreshed := range c.sessions {
+		if !now.Before(refreshed.expiresAt) {
+			delete(c.sessions, key)
+		}
+	}
+
+	refreshed := *session
+	refreshed.Lock = nil
+	c.sessions[encryption.HashKey(refreshToken)] = refreshedSession{
+		session:   refreshed,
+		expiresAt: now.Add(sessionRefreshReuseDuration),
+	}
+}
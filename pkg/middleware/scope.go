@@ -5,7 +5,9 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/justinas/alice"
+	ipapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/ip"
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/ip"
 )
 
 func NewScope(reverseProxy bool, idHeader string) alice.Constructor {
@@ -21,6 +23,19 @@ func NewScope(reverseProxy bool, idHeader string) alice.Constructor {
 	}
 }
 
+// NewClientIP records the IP address of the client in the request scope.
+// The real client IP parser is only set when running behind a reverse proxy.
+func NewClientIP(parser ipapi.RealClientIPParser) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if scope := middlewareapi.GetRequestScope(req); scope != nil {
+				scope.ClientIP = ip.GetClientString(parser, req, false)
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
 // genRequestID sets a request-wide ID for use in logging or error pages.
 // If a RequestID header is set, it uses that. Otherwise, it generates a random
 // UUID for the lifespan of the request.
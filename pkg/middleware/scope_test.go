@@ -5,7 +5,9 @@ import (
 	"net/http/httptest"
 
 	"github.com/google/uuid"
+	ipapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/ip"
 	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/ip"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -115,6 +117,37 @@ var _ = Describe("Scope Suite", func() {
 			})
 		})
 	})
+
+	Context("NewClientIP", func() {
+		var nextRequest *http.Request
+
+		serve := func(parser ipapi.RealClientIPParser) {
+			request, err := http.NewRequest("", "http://127.0.0.1/", nil)
+			Expect(err).ToNot(HaveOccurred())
+			request.RemoteAddr = "10.0.0.1:43670"
+			request.Header.Set("X-Forwarded-For", "192.168.0.10, 10.0.0.1")
+
+			handler := NewScope(false, testRequestHeader)(NewClientIP(parser)(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					nextRequest = r
+					w.WriteHeader(200)
+				})))
+			handler.ServeHTTP(httptest.NewRecorder(), request)
+		}
+
+		It("sets the ClientIP to the remote address without a parser", func() {
+			serve(nil)
+			Expect(middlewareapi.GetRequestScope(nextRequest).ClientIP).To(Equal("10.0.0.1"))
+		})
+
+		It("sets the ClientIP to the real client IP with a parser", func() {
+			parser, err := ip.GetRealClientIPParser("X-Forwarded-For")
+			Expect(err).ToNot(HaveOccurred())
+
+			serve(parser)
+			Expect(middlewareapi.GetRequestScope(nextRequest).ClientIP).To(Equal("192.168.0.10"))
+		})
+	})
 })
 
 type mockRand struct{}
@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
+)
+
+// defaultIdleIntervals is the number of refresh intervals a session is
+// refreshed for after its last use when no idle duration is configured
+const defaultIdleIntervals = 3
+
+// BackgroundRefresher refreshes the sessions used on this instance before
+// they need a refresh, so that requests do not wait for the provider.
+//
+// Only sessions in a persistent session store can be refreshed this way.
+// The refresher keeps the session cookie of every session loaded by the
+// stored session loader in memory, to load the session again outside of a
+// request. Sessions are tracked until they have not been used for longer
+// than the idle duration, so that unused sessions are not kept alive.
+type BackgroundRefresher struct {
+	// Clock is used to track when sessions were last used
+	Clock clock.Clock
+
+	cookieName string
+	interval   time.Duration
+	idle       time.Duration
+
+	// loader is set by the stored session loader the refresher is passed to
+	loader *storedSessionLoader
+
+	mu       sync.Mutex
+	sessions map[string]*trackedSession
+}
+
+// trackedSession is a session used on this instance
+type trackedSession struct {
+	cookie   *http.Cookie
+	lastUsed time.Time
+}
+
+// NewBackgroundRefresher creates a BackgroundRefresher for the sessions
+// identified by the named cookie. It refreshes sessions every interval once
+// run, until they have not been used for the idle duration.
+// Without an idle duration, sessions are refreshed for a few intervals after
+// their last use: later requests refresh the session themselves.
+func NewBackgroundRefresher(cookieName string, interval, idle time.Duration) *BackgroundRefresher {
+	if idle <= 0 {
+		idle = defaultIdleIntervals * interval
+	}
+	return &BackgroundRefresher{
+		cookieName: cookieName,
+		interval:   interval,
+		idle:       idle,
+		sessions:   map[string]*trackedSession{},
+	}
+}
+
+// Run refreshes the tracked sessions every interval until the context is
+// cancelled.
+func (r *BackgroundRefresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Refresh(ctx)
+		}
+	}
+}
+
+// Refresh refreshes the tracked sessions that need a refresh, and stops
+// tracking sessions that are no longer used or valid.
+func (r *BackgroundRefresher) Refresh(ctx context.Context) {
+	if r.loader == nil {
+		return
+	}
+
+	for key, cookie := range r.usedSessions() {
+		if err := r.refresh(ctx, cookie); err != nil {
+			logger.Errorf("Unable to refresh session in the background: %v", err)
+			r.forget(key)
+		}
+	}
+}
+
+// usedSessions returns the cookies of the sessions used within the idle
+// duration, and forgets the others.
+func (r *BackgroundRefresher) usedSessions() map[string]*http.Cookie {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.Clock.Now()
+	cookies := map[string]*http.Cookie{}
+	for key, tracked := range r.sessions {
+		if now.Sub(tracked.lastUsed) > r.idle {
+			delete(r.sessions, key)
+			continue
+		}
+		cookies[key] = tracked.cookie
+	}
+	return cookies
+}
+
+// refresh loads the session of the cookie and refreshes it if needed.
+// An error means the session can no longer be refreshed.
+func (r *BackgroundRefresher) refresh(ctx context.Context, cookie *http.Cookie) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	if err != nil {
+		return err
+	}
+	req.AddCookie(cookie)
+	req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{
+		RequestID: uuid.New().String(),
+	})
+
+	s := r.loader
+	session, err := s.store.Load(req)
+	if err != nil {
+		return fmt.Errorf("could not load session: %v", err)
+	}
+	if session == nil {
+		return fmt.Errorf("session no longer exists")
+	}
+	if (s.isRevoked != nil && s.isRevoked(session)) || session.IsPastLifetime(s.maxLifetime) || session.IsIdle(s.idleTimeout) {
+		return fmt.Errorf("session (%s) is no longer valid", session)
+	}
+
+	rw := newDiscardResponseWriter()
+	err = s.refreshSessionIfNeededBy(rw, req, session, refreshTriggerBackground)
+	if errors.Is(err, providers.ErrRefreshTokenRejected) {
+		// Clear the session, as a request using it would
+		if clearErr := s.store.Clear(rw, req); clearErr != nil {
+			logger.Errorf("Error removing session: %v", clearErr)
+		}
+	}
+	return err
+}
+
+// track records that the session of the request was used
+func (r *BackgroundRefresher) track(req *http.Request, session *sessionsapi.SessionState) {
+	cookie, err := req.Cookie(r.cookieName)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[trackingKey(cookie, session)] = &trackedSession{
+		cookie:   &http.Cookie{Name: cookie.Name, Value: cookie.Value},
+		lastUsed: r.Clock.Now(),
+	}
+}
+
+func (r *BackgroundRefresher) forget(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, key)
+}
+
+// trackingKey identifies a session across the cookies issued for it.
+// A session saved by a request is sent with a newly signed cookie, so the
+// sign in of the user is used where it is known.
+func trackingKey(cookie *http.Cookie, session *sessionsapi.SessionState) string {
+	if session.IssuedAt == nil {
+		return cookie.Value
+	}
+	return fmt.Sprintf("%s|%s|%s|%d", session.ProviderID, session.User, session.Email, session.IssuedAt.UnixNano())
+}
+
+// discardResponseWriter is the http.ResponseWriter of background refreshes.
+// The cookie set when saving a session is discarded, the session keeps its
+// ticket and the cookie held by the user stays valid.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func newDiscardResponseWriter() *discardResponseWriter {
+	return &discardResponseWriter{header: http.Header{}}
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(int) {}
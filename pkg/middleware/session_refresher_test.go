@@ -0,0 +1,199 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Background Session Refresher Suite", func() {
+	const cookieName = "_oauth2_proxy"
+
+	var ctx = context.Background()
+	var now time.Time
+	var sessions map[string]*sessionsapi.SessionState
+	var refreshes []string
+	var refreshErr error
+	var registry *prometheus.Registry
+	var refresher *BackgroundRefresher
+	var handler http.Handler
+
+	BeforeEach(func() {
+		now = time.Now()
+		refreshes = nil
+		refreshErr = nil
+		registry = prometheus.NewRegistry()
+
+		issuedAt := now.Add(-time.Hour)
+		sessions = map[string]*sessionsapi.SessionState{
+			"first": {
+				User:         "first",
+				RefreshToken: "first",
+				IssuedAt:     &issuedAt,
+				CreatedAt:    &issuedAt,
+				ExpiresOn:    &now,
+				Lock:         &sessionsapi.NoOpLock{},
+			},
+		}
+
+		store := &fakeSessionStore{
+			LoadFunc: func(req *http.Request) (*sessionsapi.SessionState, error) {
+				cookie, err := req.Cookie(cookieName)
+				if err != nil {
+					return nil, err
+				}
+				session, ok := sessions[cookie.Value]
+				if !ok {
+					return nil, nil
+				}
+				loaded := *session
+				return &loaded, nil
+			},
+			SaveFunc: func(_ http.ResponseWriter, req *http.Request, s *sessionsapi.SessionState) error {
+				cookie, err := req.Cookie(cookieName)
+				if err != nil {
+					return err
+				}
+				saved := *s
+				sessions[cookie.Value] = &saved
+				return nil
+			},
+			ClearFunc: func(_ http.ResponseWriter, req *http.Request) error {
+				cookie, err := req.Cookie(cookieName)
+				if err != nil {
+					return err
+				}
+				delete(sessions, cookie.Value)
+				return nil
+			},
+		}
+
+		refresher = NewBackgroundRefresher(cookieName, time.Minute, 30*time.Minute)
+		refresher.Clock.Set(now)
+		DeferCleanup(func() { refresher.Clock.Reset() })
+
+		handler = NewStoredSessionLoader(&StoredSessionLoaderOptions{
+			SessionStore: store,
+			RefreshSession: func(_ context.Context, s *sessionsapi.SessionState) (bool, error) {
+				refreshes = append(refreshes, s.User)
+				if refreshErr != nil {
+					return false, refreshErr
+				}
+				expiresOn := now.Add(time.Hour)
+				s.ExpiresOn = &expiresOn
+				return true, nil
+			},
+			ValidateSession: func(context.Context, *sessionsapi.SessionState) bool {
+				return true
+			},
+			RefreshSkew:         5 * time.Minute,
+			BackgroundRefresher: refresher,
+			Registerer:          registry,
+		})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	})
+
+	// useSession serves a request with the session cookie, without needing a
+	// refresh, so that the refresher tracks it
+	useSession := func(value string) {
+		expiresOn := now.Add(time.Hour)
+		session := *sessions[value]
+		session.ExpiresOn = &expiresOn
+		sessions[value] = &session
+
+		req := httptest.NewRequest("", "/", nil)
+		req.AddCookie(&http.Cookie{Name: cookieName, Value: value})
+		req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		sessions[value].ExpiresOn = &now
+	}
+
+	refreshCount := func(trigger, result string) float64 {
+		return testutil.ToFloat64(registerSessionRefreshesCounter(registry).WithLabelValues(trigger, result))
+	}
+
+	It("refreshes used sessions whose access token is about to expire", func() {
+		useSession("first")
+		Expect(refreshes).To(BeEmpty())
+
+		refresher.Refresh(ctx)
+		Expect(refreshes).To(ConsistOf("first"))
+		Expect(sessions["first"].ExpiresOn).To(Equal(ptr(now.Add(time.Hour))))
+		Expect(refreshCount(refreshTriggerBackground, refreshResultSuccess)).To(Equal(float64(1)))
+
+		// The refreshed session does not need another refresh
+		refresher.Refresh(ctx)
+		Expect(refreshes).To(HaveLen(1))
+	})
+
+	It("does not refresh sessions that were not used", func() {
+		refresher.Refresh(ctx)
+		Expect(refreshes).To(BeEmpty())
+	})
+
+	It("stops refreshing sessions once they are no longer used", func() {
+		useSession("first")
+		refresher.Clock.Set(now.Add(time.Hour))
+
+		refresher.Refresh(ctx)
+		Expect(refreshes).To(BeEmpty())
+		Expect(refresher.sessions).To(BeEmpty())
+	})
+
+	It("tracks sessions for a few intervals without an idle duration", func() {
+		refresher = NewBackgroundRefresher(cookieName, time.Minute, 0)
+		Expect(refresher.idle).To(Equal(defaultIdleIntervals * time.Minute))
+	})
+
+	It("stops refreshing sessions that no longer exist", func() {
+		useSession("first")
+		delete(sessions, "first")
+
+		refresher.Refresh(ctx)
+		Expect(refreshes).To(BeEmpty())
+		Expect(refresher.sessions).To(BeEmpty())
+	})
+
+	It("counts failed refreshes", func() {
+		useSession("first")
+		refreshErr = errors.New("refresh token is revoked")
+
+		refresher.Refresh(ctx)
+		Expect(refreshes).To(ConsistOf("first"))
+		Expect(refreshCount(refreshTriggerBackground, refreshResultFailure)).To(Equal(float64(1)))
+		Expect(refreshCount(refreshTriggerBackground, refreshResultSuccess)).To(Equal(float64(0)))
+	})
+
+	It("clears sessions whose refresh token is rejected", func() {
+		useSession("first")
+		refreshErr = fmt.Errorf("unable to redeem refresh token: %w", providers.ErrRefreshTokenRejected)
+
+		refresher.Refresh(ctx)
+		Expect(refreshes).To(ConsistOf("first"))
+		Expect(sessions).ToNot(HaveKey("first"))
+		Expect(refresher.sessions).To(BeEmpty())
+	})
+
+	It("tracks a session once across cookies", func() {
+		sessions["second"] = sessions["first"]
+		useSession("first")
+		useSession("second")
+
+		Expect(refresher.sessions).To(HaveLen(1))
+	})
+})
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -12,6 +12,7 @@ import (
 	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/providers"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
@@ -48,6 +49,35 @@ type StoredSessionLoaderOptions struct {
 	// If the sesssion is older than `RefreshPeriod` but the provider doesn't
 	// refresh it, we must re-validate using this validation.
 	ValidateSession func(context.Context, *sessionsapi.SessionState) bool
+
+	// Reports whether the session has been revoked by the provider.
+	// This option is optional and is only needed for session stores that
+	// cannot remove revoked sessions themselves.
+	IsRevoked func(*sessionsapi.SessionState) bool
+
+	// How long a session may go unused before it is cleared.
+	// Zero disables the idle timeout.
+	IdleTimeout time.Duration
+
+	// How long after sign in a session is cleared, regardless of refreshes.
+	// Zero disables the maximum lifetime.
+	MaxLifetime time.Duration
+
+	// The granularity of the recorded session activity.
+	// The session is only saved when the activity moves into a new interval.
+	ActivityInterval time.Duration
+
+	// How long before the access token expires the session is refreshed.
+	// Zero disables refreshing sessions based on the access token expiry.
+	RefreshSkew time.Duration
+
+	// Refreshes the sessions loaded by this loader in the background.
+	// This option is optional and only works with persistent session stores.
+	BackgroundRefresher *BackgroundRefresher
+
+	// Registers the session refresh metrics.
+	// Defaults to the default prometheus.Registerer.
+	Registerer prometheus.Registerer
 }
 
 // NewStoredSessionLoader creates a new storedSessionLoader which loads
@@ -55,11 +85,27 @@ type StoredSessionLoaderOptions struct {
 // If no session is found, the request will be passed to the nex handler.
 // If a session was loader by a previous handler, it will not be replaced.
 func NewStoredSessionLoader(opts *StoredSessionLoaderOptions) alice.Constructor {
+	registerer := opts.Registerer
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
 	ss := &storedSessionLoader{
 		store:            opts.SessionStore,
 		refreshPeriod:    opts.RefreshPeriod,
 		sessionRefresher: opts.RefreshSession,
 		sessionValidator: opts.ValidateSession,
+		isRevoked:        opts.IsRevoked,
+		idleTimeout:      opts.IdleTimeout,
+		maxLifetime:      opts.MaxLifetime,
+		activityInterval: opts.ActivityInterval,
+		refreshSkew:      opts.RefreshSkew,
+		refreshes:        registerSessionRefreshesCounter(registerer),
+		refresher:        opts.BackgroundRefresher,
+		refreshCache:     newRefreshCache(),
+	}
+	if ss.refresher != nil {
+		ss.refresher.loader = ss
 	}
 	return ss.loadSession
 }
@@ -71,6 +117,14 @@ type storedSessionLoader struct {
 	refreshPeriod    time.Duration
 	sessionRefresher func(context.Context, *sessionsapi.SessionState) (bool, error)
 	sessionValidator func(context.Context, *sessionsapi.SessionState) bool
+	isRevoked        func(*sessionsapi.SessionState) bool
+	idleTimeout      time.Duration
+	maxLifetime      time.Duration
+	activityInterval time.Duration
+	refreshSkew      time.Duration
+	refreshes        *prometheus.CounterVec
+	refresher        *BackgroundRefresher
+	refreshCache     *refreshCache
 }
 
 // loadSession attempts to load a session as identified by the request cookies.
@@ -98,6 +152,10 @@ func (s *storedSessionLoader) loadSession(next http.Handler) http.Handler {
 			}
 		}
 
+		if session != nil && s.refresher != nil {
+			s.refresher.track(req, session)
+		}
+
 		// Add the session to the scope if it was found
 		scope.Session = session
 		next.ServeHTTP(rw, req)
@@ -113,19 +171,83 @@ func (s *storedSessionLoader) getValidatedSession(rw http.ResponseWriter, req *h
 		return nil, err
 	}
 
+	if s.isRevoked != nil && s.isRevoked(session) {
+		return nil, fmt.Errorf("session (%s) has been revoked", session)
+	}
+
+	if session.IsPastLifetime(s.maxLifetime) {
+		return nil, fmt.Errorf("session (%s) has exceeded the maximum lifetime", session)
+	}
+
+	if session.IsIdle(s.idleTimeout) {
+		return nil, fmt.Errorf("session (%s) has exceeded the idle timeout", session)
+	}
+
 	err = s.refreshSessionIfNeeded(rw, req, session)
 	if err != nil {
 		return nil, fmt.Errorf("error refreshing access token for session (%s): %v", session, err)
 	}
 
+	if err := s.recordActivity(rw, req, session); err != nil {
+		// The request is still served, the activity is recorded again by the
+		// next request.
+		logger.Errorf("Unable to record session activity: %v", err)
+	}
+
 	return session, nil
 }
 
+// recordActivity updates the last activity of the session when the idle
+// timeout is enabled.
+// To avoid rewriting the session on every request, the session is only saved
+// when the activity moves into a new interval.
+func (s *storedSessionLoader) recordActivity(rw http.ResponseWriter, req *http.Request, session *sessionsapi.SessionState) error {
+	if s.idleTimeout <= 0 || !session.TouchActivity(s.activityInterval) {
+		return nil
+	}
+	lastActivity := session.LastActivity
+
+	err := session.ObtainLock(req.Context(), sessionRefreshLockDuration)
+	if errors.Is(err, sessionsapi.ErrLockNotObtained) {
+		// Another request is updating the session
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error occurred while trying to obtain lock: %v", err)
+	}
+	defer func() {
+		if err := session.ReleaseLock(req.Context()); err != nil {
+			logger.Errorf("unable to release lock: %v", err)
+		}
+	}()
+
+	// Reload the session so that changes saved by other requests since it
+	// was loaded are not overwritten.
+	freshSession, err := s.store.Load(req)
+	if err != nil {
+		return fmt.Errorf("could not load session: %v", err)
+	}
+	if freshSession == nil {
+		return errors.New("session no longer exists, it may have been removed by another request")
+	}
+	lock := session.Lock
+	*session = *freshSession
+	session.Lock = lock
+	session.LastActivity = lastActivity
+
+	return s.store.Save(rw, req, session)
+}
+
 // refreshSessionIfNeeded will attempt to refresh a session if the session
-// is older than the refresh period.
+// is older than the refresh period, or its access token is about to expire.
 // Success or fail, we will then validate the session.
 func (s *storedSessionLoader) refreshSessionIfNeeded(rw http.ResponseWriter, req *http.Request, session *sessionsapi.SessionState) error {
-	if !needsRefresh(s.refreshPeriod, session) {
+	return s.refreshSessionIfNeededBy(rw, req, session, refreshTriggerRequest)
+}
+
+// refreshSessionIfNeededBy refreshes the session if needed, recording the
+// trigger of the refresh in the metrics.
+func (s *storedSessionLoader) refreshSessionIfNeededBy(rw http.ResponseWriter, req *http.Request, session *sessionsapi.SessionState, trigger string) error {
+	if s.refreshReason(session) == "" {
 		// Refresh is disabled or the session is not old enough, do nothing
 		return nil
 	}
@@ -162,6 +284,13 @@ func (s *storedSessionLoader) refreshSessionIfNeeded(rw http.ResponseWriter, req
 		}
 	}()
 
+	// Providers that rotate refresh tokens only accept each refresh token
+	// once, so the lock must be held until the rotated refresh token is saved,
+	// even when the provider takes longer than the lock duration.
+	// Deferred after the release so that it stops before the lock is released.
+	stopKeepAlive := keepLockAlive(req.Context(), session.Lock, sessionRefreshLockDuration)
+	defer stopKeepAlive()
+
 	// Reload the session in case it was changed underneath us.
 	freshSession, err := s.store.Load(req)
 	if err != nil {
@@ -179,33 +308,141 @@ func (s *storedSessionLoader) refreshSessionIfNeeded(rw http.ResponseWriter, req
 	// Loading from the session store creates a new lock in the session.
 	session.Lock = lock
 
-	if !needsRefresh(s.refreshPeriod, session) {
+	reason := s.refreshReason(session)
+	if reason == "" {
 		// The session must have already been refreshed while we were waiting to
 		// obtain the lock.
 		return nil
 	}
 
 	// We are holding the lock and the session needs a refresh
-	logger.Printf("Refreshing session - User: %s; SessionAge: %s", session.User, session.Age())
-	if err := s.refreshSession(rw, req, session); err != nil {
+	logger.Printf("Refreshing session - User: %s; SessionAge: %s; Reason: %s; Trigger: %s", session.User, session.Age(), reason, trigger)
+	err = s.refreshSession(rw, req, session, trigger)
+	if errors.Is(err, providers.ErrRefreshTokenRejected) {
+		// The refresh token is no longer valid, it may have been revoked or
+		// reused after it was rotated. The session cannot be trusted anymore.
+		s.recordRefresh(trigger, refreshResultFailure)
+		logger.PrintAuthf(session.Email, req, logger.AuthRefreshTokenRejected, "Session refresh token rejected (%s, %s): %v", reason, trigger, err)
+		return err
+	} else if err != nil {
 		// If a preemptive refresh fails, we still keep the session
 		// if validateSession succeeds.
+		s.recordRefresh(trigger, refreshResultFailure)
 		logger.Errorf("Unable to refresh session: %v", err)
+		logger.PrintAuthf(session.Email, req, logger.AuthError, "Session refresh failed (%s, %s): %v", reason, trigger, err)
+	} else {
+		s.recordRefresh(trigger, refreshResultSuccess)
 	}
 
 	// Validate all sessions after any Redeem/Refresh operation (fail or success)
 	return s.validateSession(req.Context(), session)
 }
 
-// needsRefresh determines whether we should attempt to refresh a session or not.
-func needsRefresh(refreshPeriod time.Duration, session *sessionsapi.SessionState) bool {
-	return refreshPeriod > time.Duration(0) && session.Age() > refreshPeriod
+// keepLockAlive refreshes the lock every half of its expiration until the
+// returned function is called. The returned function waits for the last
+// lock refresh to finish.
+func keepLockAlive(ctx context.Context, lock sessionsapi.Lock, expiration time.Duration) func() {
+	if lock == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(expiration / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := lock.Refresh(ctx, expiration); err != nil {
+					logger.Errorf("unable to refresh lock: %v", err)
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+const (
+	// refreshTriggerRequest labels refreshes of sessions loaded by a request
+	refreshTriggerRequest = "request"
+	// refreshTriggerBackground labels refreshes by the BackgroundRefresher
+	refreshTriggerBackground = "background"
+
+	refreshResultSuccess = "success"
+	refreshResultFailure = "failure"
+)
+
+// recordRefresh counts a refresh attempt in the metrics
+func (s *storedSessionLoader) recordRefresh(trigger, result string) {
+	if s.refreshes != nil {
+		s.refreshes.WithLabelValues(trigger, result).Inc()
+	}
+}
+
+const (
+	// refreshReasonAge is the reason of refreshing a session that is older
+	// than the refresh period
+	refreshReasonAge = "cookie age"
+	// refreshReasonExpiry is the reason of refreshing a session whose access
+	// token expires within the refresh skew
+	refreshReasonExpiry = "token expiry"
+)
+
+// refreshReason determines whether we should attempt to refresh a session or
+// not, and returns why. An empty reason means no refresh is needed.
+// Sessions are only refreshed for their access token expiry when they have a
+// refresh token, as they could not be refreshed otherwise.
+func (s *storedSessionLoader) refreshReason(session *sessionsapi.SessionState) string {
+	switch {
+	case s.refreshPeriod > time.Duration(0) && session.Age() > s.refreshPeriod:
+		return refreshReasonAge
+	case s.refreshSkew > time.Duration(0) && session.RefreshToken != "" && session.AccessTokenExpiresWithin(s.refreshSkew):
+		return refreshReasonExpiry
+	}
+	return ""
 }
 
 // refreshSession attempts to refresh the session with the provider
 // and will save the session if it was updated.
-func (s *storedSessionLoader) refreshSession(rw http.ResponseWriter, req *http.Request, session *sessionsapi.SessionState) error {
+// A session that was recently refreshed with the same refresh token, for
+// example by a concurrent request, is replaced with the refreshed session
+// instead, as the provider may have rotated the refresh token.
+func (s *storedSessionLoader) refreshSession(rw http.ResponseWriter, req *http.Request, session *sessionsapi.SessionState, trigger string) error {
+	// Sessions saved before their issue time was recorded were issued when
+	// they were created. The refresh resets the creation time, which may
+	// already happen in the provider, so it is kept for the maximum lifetime.
+	var issuedAt *time.Time
+	if session.IssuedAt == nil && session.CreatedAt != nil {
+		createdAt := *session.CreatedAt
+		issuedAt = &createdAt
+	}
+
+	refreshToken := session.RefreshToken
+	if refreshed, ok := s.refreshCache.get(refreshToken); ok {
+		logger.Printf("Reusing recently refreshed session - User: %s", session.User)
+		lock, lastActivity := session.Lock, session.LastActivity
+		*session = *refreshed
+		session.Lock = lock
+		session.LastActivity = lastActivity
+		return s.saveRefreshedSession(rw, req, session, trigger)
+	}
+
 	refreshed, err := s.sessionRefresher(req.Context(), session)
+	if errors.Is(err, providers.ErrRefreshTokenRejected) {
+		return fmt.Errorf("error refreshing tokens: %w", err)
+	}
 	if err != nil && !errors.Is(err, providers.ErrNotImplemented) {
 		return fmt.Errorf("error refreshing tokens: %v", err)
 	}
@@ -225,12 +462,32 @@ func (s *storedSessionLoader) refreshSession(rw http.ResponseWriter, req *http.R
 		return nil
 	}
 
+	if issuedAt != nil {
+		session.IssuedAt = issuedAt
+	}
+
 	// If we refreshed, update the `CreatedAt` time to reset the refresh timer
 	// (In case underlying provider implementations forget)
 	session.CreatedAtNow()
+	if err == nil {
+		s.refreshCache.put(refreshToken, session)
+	}
 
 	// Because the session was refreshed, make sure to save it
-	err = s.store.Save(rw, req, session)
+	return s.saveRefreshedSession(rw, req, session, trigger)
+}
+
+// saveRefreshedSession saves the session after it was refreshed.
+// The activity of requests is saved with the refreshed session, so that
+// recordActivity does not save the session again. Reloading the session there
+// would read the session the request was made with from a cookie store, and
+// overwrite the refreshed session.
+func (s *storedSessionLoader) saveRefreshedSession(rw http.ResponseWriter, req *http.Request, session *sessionsapi.SessionState, trigger string) error {
+	if trigger == refreshTriggerRequest && s.idleTimeout > 0 {
+		session.TouchActivity(s.activityInterval)
+	}
+
+	err := s.store.Save(rw, req, session)
 	if err != nil {
 		logger.PrintAuthf(session.Email, req, logger.AuthError, "error saving session: %v", err)
 		return fmt.Errorf("error saving session: %v", err)
@@ -81,6 +81,25 @@ func (l *testLockConcurrent) Release(_ context.Context) error {
 	return nil
 }
 
+// testLockRefreshes counts the refreshes of the lock
+type testLockRefreshes struct {
+	testLockConcurrent
+	refreshes int
+}
+
+func (l *testLockRefreshes) Refresh(_ context.Context, _ time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refreshes++
+	return nil
+}
+
+func (l *testLockRefreshes) count() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.refreshes
+}
+
 var _ = Describe("Stored Session Suite", func() {
 	const (
 		refresh        = "Refresh"
@@ -95,6 +114,7 @@ var _ = Describe("Stored Session Suite", func() {
 		now := time.Now()
 		createdPast := now.Add(-5 * time.Minute)
 		createdFuture := now.Add(5 * time.Minute)
+		lastActivity := now.Truncate(time.Minute)
 
 		var defaultRefreshFunc = func(_ context.Context, ss *sessionsapi.SessionState) (bool, error) {
 			switch ss.RefreshToken {
@@ -170,6 +190,9 @@ var _ = Describe("Stored Session Suite", func() {
 			refreshPeriod   time.Duration
 			refreshSession  func(context.Context, *sessionsapi.SessionState) (bool, error)
 			validateSession func(context.Context, *sessionsapi.SessionState) bool
+			isRevoked       func(*sessionsapi.SessionState) bool
+			idleTimeout     time.Duration
+			maxLifetime     time.Duration
 		}
 
 		DescribeTable("when serving a request",
@@ -186,10 +209,14 @@ var _ = Describe("Stored Session Suite", func() {
 				rw := httptest.NewRecorder()
 
 				opts := &StoredSessionLoaderOptions{
-					SessionStore:    in.store,
-					RefreshPeriod:   in.refreshPeriod,
-					RefreshSession:  in.refreshSession,
-					ValidateSession: in.validateSession,
+					SessionStore:     in.store,
+					RefreshPeriod:    in.refreshPeriod,
+					RefreshSession:   in.refreshSession,
+					ValidateSession:  in.validateSession,
+					IsRevoked:        in.isRevoked,
+					IdleTimeout:      in.idleTimeout,
+					MaxLifetime:      in.maxLifetime,
+					ActivityInterval: time.Minute,
 				}
 
 				// Create the handler with a next handler that will capture the session
@@ -287,6 +314,7 @@ var _ = Describe("Stored Session Suite", func() {
 				expectedSession: &sessionsapi.SessionState{
 					RefreshToken: "Refreshed",
 					CreatedAt:    &now,
+					IssuedAt:     &createdPast,
 					ExpiresOn:    &createdFuture,
 					Lock:         &sessionsapi.NoOpLock{},
 				},
@@ -333,6 +361,78 @@ var _ = Describe("Stored Session Suite", func() {
 				refreshSession:  defaultRefreshFunc,
 				validateSession: defaultValidateFunc,
 			}),
+			Entry("when the session has been revoked", storedSessionLoaderTableInput{
+				requestHeaders: http.Header{
+					"Cookie": []string{"_oauth2_proxy=NoRefreshSession"},
+				},
+				existingSession: nil,
+				expectedSession: nil,
+				store:           defaultSessionStore,
+				refreshPeriod:   1 * time.Minute,
+				refreshSession:  defaultRefreshFunc,
+				validateSession: defaultValidateFunc,
+				isRevoked:       func(*sessionsapi.SessionState) bool { return true },
+			}),
+			Entry("when the session has not been revoked", storedSessionLoaderTableInput{
+				requestHeaders: http.Header{
+					"Cookie": []string{"_oauth2_proxy=NoRefreshSession"},
+				},
+				existingSession: nil,
+				expectedSession: &sessionsapi.SessionState{
+					RefreshToken: noRefresh,
+					CreatedAt:    &createdPast,
+					ExpiresOn:    &createdFuture,
+					Lock:         &sessionsapi.NoOpLock{},
+				},
+				store:           defaultSessionStore,
+				refreshPeriod:   1 * time.Minute,
+				refreshSession:  defaultRefreshFunc,
+				validateSession: defaultValidateFunc,
+				isRevoked:       func(*sessionsapi.SessionState) bool { return false },
+			}),
+			Entry("when the session has exceeded the maximum lifetime", storedSessionLoaderTableInput{
+				requestHeaders: http.Header{
+					"Cookie": []string{"_oauth2_proxy=NoRefreshSession"},
+				},
+				existingSession: nil,
+				expectedSession: nil,
+				store:           defaultSessionStore,
+				refreshPeriod:   1 * time.Minute,
+				refreshSession:  defaultRefreshFunc,
+				validateSession: defaultValidateFunc,
+				maxLifetime:     1 * time.Minute,
+			}),
+			Entry("when the session has exceeded the idle timeout", storedSessionLoaderTableInput{
+				requestHeaders: http.Header{
+					"Cookie": []string{"_oauth2_proxy=NoRefreshSession"},
+				},
+				existingSession: nil,
+				expectedSession: nil,
+				store:           defaultSessionStore,
+				refreshPeriod:   1 * time.Minute,
+				refreshSession:  defaultRefreshFunc,
+				validateSession: defaultValidateFunc,
+				idleTimeout:     1 * time.Minute,
+			}),
+			Entry("when the session is within the idle timeout and maximum lifetime", storedSessionLoaderTableInput{
+				requestHeaders: http.Header{
+					"Cookie": []string{"_oauth2_proxy=NoRefreshSession"},
+				},
+				existingSession: nil,
+				expectedSession: &sessionsapi.SessionState{
+					RefreshToken: noRefresh,
+					CreatedAt:    &createdPast,
+					ExpiresOn:    &createdFuture,
+					LastActivity: &lastActivity,
+					Lock:         &sessionsapi.NoOpLock{},
+				},
+				store:           defaultSessionStore,
+				refreshPeriod:   1 * time.Minute,
+				refreshSession:  defaultRefreshFunc,
+				validateSession: defaultValidateFunc,
+				idleTimeout:     10 * time.Minute,
+				maxLifetime:     10 * time.Minute,
+			}),
 		)
 
 		type storedSessionLoaderConcurrentTableInput struct {
@@ -438,6 +538,7 @@ var _ = Describe("Stored Session Suite", func() {
 	Context("refreshSessionIfNeeded", func() {
 		type refreshSessionIfNeededTableInput struct {
 			refreshPeriod            time.Duration
+			refreshSkew              time.Duration
 			session                  *sessionsapi.SessionState
 			concurrentSessionRefresh bool
 			expectedErr              error
@@ -448,6 +549,7 @@ var _ = Describe("Stored Session Suite", func() {
 
 		createdPast := time.Now().Add(-5 * time.Minute)
 		createdFuture := time.Now().Add(5 * time.Minute)
+		expiresSoon := time.Now().Add(30 * time.Second)
 
 		DescribeTable("with a session",
 			func(in refreshSessionIfNeededTableInput) {
@@ -475,6 +577,7 @@ var _ = Describe("Stored Session Suite", func() {
 
 				s := &storedSessionLoader{
 					refreshPeriod: in.refreshPeriod,
+					refreshSkew:   in.refreshSkew,
 					store:         store,
 					sessionRefresher: func(_ context.Context, ss *sessionsapi.SessionState) (bool, error) {
 						refreshed = true
@@ -614,6 +717,47 @@ var _ = Describe("Stored Session Suite", func() {
 				expectValidated:      true,
 				expectedLockObtained: true,
 			}),
+			Entry("when the access token expires within the refresh skew", refreshSessionIfNeededTableInput{
+				refreshPeriod: 1 * time.Hour,
+				refreshSkew:   1 * time.Minute,
+				session: &sessionsapi.SessionState{
+					RefreshToken: refresh,
+					CreatedAt:    &createdPast,
+					ExpiresOn:    &expiresSoon,
+					Lock:         &testLock{},
+				},
+				expectedErr:          nil,
+				expectRefreshed:      true,
+				expectValidated:      true,
+				expectedLockObtained: true,
+			}),
+			Entry("when the access token does not expire within the refresh skew", refreshSessionIfNeededTableInput{
+				refreshPeriod: 1 * time.Hour,
+				refreshSkew:   1 * time.Minute,
+				session: &sessionsapi.SessionState{
+					RefreshToken: refresh,
+					CreatedAt:    &createdPast,
+					ExpiresOn:    &createdFuture,
+					Lock:         &testLock{},
+				},
+				expectedErr:          nil,
+				expectRefreshed:      false,
+				expectValidated:      false,
+				expectedLockObtained: false,
+			}),
+			Entry("when the access token expires within the refresh skew without a refresh token", refreshSessionIfNeededTableInput{
+				refreshPeriod: 1 * time.Hour,
+				refreshSkew:   1 * time.Minute,
+				session: &sessionsapi.SessionState{
+					CreatedAt: &createdPast,
+					ExpiresOn: &expiresSoon,
+					Lock:      &testLock{},
+				},
+				expectedErr:          nil,
+				expectRefreshed:      false,
+				expectValidated:      false,
+				expectedLockObtained: false,
+			}),
 			Entry("when the session is not refreshed by the provider", refreshSessionIfNeededTableInput{
 				refreshPeriod: 1 * time.Minute,
 				session: &sessionsapi.SessionState{
@@ -670,7 +814,7 @@ var _ = Describe("Stored Session Suite", func() {
 
 				req := httptest.NewRequest("", "/", nil)
 				req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
-				err := s.refreshSession(nil, req, in.session)
+				err := s.refreshSession(nil, req, in.session, refreshTriggerRequest)
 				if in.expectedErr != nil {
 					Expect(err).To(MatchError(in.expectedErr))
 				} else {
@@ -719,6 +863,230 @@ var _ = Describe("Stored Session Suite", func() {
 		)
 	})
 
+	Context("with refresh token rotation", func() {
+		const rotated = "Rotated"
+
+		var refreshes int
+		var refreshErr error
+		var saved []*sessionsapi.SessionState
+		var cleared bool
+		var s *storedSessionLoader
+
+		newRequest := func() *http.Request {
+			req := httptest.NewRequest("", "/", nil)
+			return middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+		}
+
+		BeforeEach(func() {
+			refreshes = 0
+			refreshErr = nil
+			saved = nil
+			cleared = false
+
+			s = &storedSessionLoader{
+				refreshPeriod: time.Minute,
+				store: &fakeSessionStore{
+					LoadFunc: func(*http.Request) (*sessionsapi.SessionState, error) {
+						createdPast := time.Now().Add(-5 * time.Minute)
+						return &sessionsapi.SessionState{
+							AccessToken:  "Old",
+							RefreshToken: refresh,
+							CreatedAt:    &createdPast,
+						}, nil
+					},
+					SaveFunc: func(_ http.ResponseWriter, _ *http.Request, ss *sessionsapi.SessionState) error {
+						saved = append(saved, ss)
+						return nil
+					},
+					ClearFunc: func(http.ResponseWriter, *http.Request) error {
+						cleared = true
+						return nil
+					},
+				},
+				sessionRefresher: func(_ context.Context, ss *sessionsapi.SessionState) (bool, error) {
+					refreshes++
+					if refreshErr != nil {
+						return false, refreshErr
+					}
+					ss.AccessToken = fmt.Sprintf("New%d", refreshes)
+					ss.RefreshToken = rotated
+					return true, nil
+				},
+				sessionValidator: func(context.Context, *sessionsapi.SessionState) bool {
+					return true
+				},
+				refreshCache: newRefreshCache(),
+			}
+			s.refreshCache.Clock.Set(time.Now())
+			DeferCleanup(func() { s.refreshCache.Clock.Reset() })
+		})
+
+		It("reuses the session refreshed with the same refresh token", func() {
+			first := &sessionsapi.SessionState{RefreshToken: refresh}
+			Expect(s.refreshSession(nil, newRequest(), first, refreshTriggerRequest)).To(Succeed())
+
+			second := &sessionsapi.SessionState{RefreshToken: refresh, Lock: &testLock{}}
+			Expect(s.refreshSession(nil, newRequest(), second, refreshTriggerRequest)).To(Succeed())
+
+			Expect(refreshes).To(Equal(1))
+			Expect(saved).To(HaveLen(2))
+			Expect(second.AccessToken).To(Equal("New1"))
+			Expect(second.RefreshToken).To(Equal(rotated))
+			Expect(second.Lock).To(Equal(&testLock{}))
+		})
+
+		It("refreshes again once the refreshed session is no longer reused", func() {
+			Expect(s.refreshSession(nil, newRequest(), &sessionsapi.SessionState{RefreshToken: refresh}, refreshTriggerRequest)).To(Succeed())
+			Expect(s.refreshCache.Clock.Add(sessionRefreshReuseDuration)).To(Succeed())
+
+			session := &sessionsapi.SessionState{RefreshToken: refresh}
+			Expect(s.refreshSession(nil, newRequest(), session, refreshTriggerRequest)).To(Succeed())
+			Expect(refreshes).To(Equal(2))
+			Expect(session.AccessToken).To(Equal("New2"))
+		})
+
+		It("clears the session when the provider rejects the refresh token", func() {
+			refreshErr = fmt.Errorf("unable to redeem refresh token: %w", providers.ErrRefreshTokenRejected)
+
+			req := newRequest()
+			req.AddCookie(&http.Cookie{Name: "_oauth2_proxy", Value: "Session"})
+			var loaded *sessionsapi.SessionState
+			s.loadSession(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+				loaded = middlewareapi.GetRequestScope(req).Session
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			Expect(refreshes).To(Equal(1))
+			Expect(loaded).To(BeNil())
+			Expect(cleared).To(BeTrue())
+			Expect(saved).To(BeEmpty())
+		})
+
+		It("keeps the creation time as the issue time of sessions without one", func() {
+			createdPast := time.Now().Add(-5 * time.Minute)
+			session := &sessionsapi.SessionState{RefreshToken: refresh, CreatedAt: &createdPast}
+			Expect(s.refreshSession(nil, newRequest(), session, refreshTriggerRequest)).To(Succeed())
+
+			Expect(session.IssuedAt).To(Equal(&createdPast))
+			Expect(session.CreatedAt.After(createdPast)).To(BeTrue())
+			Expect(session.IsPastLifetime(time.Minute)).To(BeTrue())
+		})
+
+		It("keeps the session when the refresh fails for another reason", func() {
+			refreshErr = errors.New("provider is unavailable")
+
+			createdPast := time.Now().Add(-5 * time.Minute)
+			session := &sessionsapi.SessionState{RefreshToken: refresh, CreatedAt: &createdPast}
+			err := s.refreshSessionIfNeeded(nil, newRequest(), session)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(refreshes).To(Equal(1))
+		})
+	})
+
+	Context("keepLockAlive", func() {
+		It("refreshes the lock until it is stopped", func() {
+			lock := &testLockRefreshes{}
+			stop := keepLockAlive(context.Background(), lock, 20*time.Millisecond)
+
+			Eventually(lock.count).Should(BeNumerically(">=", 2))
+			stop()
+			refreshes := lock.count()
+			Consistently(lock.count, 50*time.Millisecond).Should(Equal(refreshes))
+		})
+	})
+
+	Context("recordActivity", func() {
+		now := time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC)
+		var saved []*sessionsapi.SessionState
+		var s *storedSessionLoader
+
+		BeforeEach(func() {
+			clock.Set(now)
+			saved = nil
+			s = &storedSessionLoader{
+				store: &fakeSessionStore{
+					LoadFunc: func(*http.Request) (*sessionsapi.SessionState, error) {
+						return &sessionsapi.SessionState{AccessToken: "Fresh"}, nil
+					},
+					SaveFunc: func(_ http.ResponseWriter, _ *http.Request, ss *sessionsapi.SessionState) error {
+						saved = append(saved, ss)
+						return nil
+					},
+				},
+				idleTimeout:      30 * time.Minute,
+				activityInterval: time.Minute,
+			}
+		})
+
+		AfterEach(func() {
+			clock.Reset()
+		})
+
+		It("saves the reloaded session when the activity interval changes", func() {
+			lastActivity := now.Add(-time.Minute).Truncate(time.Minute)
+			session := &sessionsapi.SessionState{AccessToken: "Stale", LastActivity: &lastActivity}
+			req := httptest.NewRequest("", "/", nil)
+
+			Expect(s.recordActivity(httptest.NewRecorder(), req, session)).To(Succeed())
+			Expect(saved).To(HaveLen(1))
+			Expect(session.AccessToken).To(Equal("Fresh"))
+			Expect(*session.LastActivity).To(Equal(now.Truncate(time.Minute)))
+		})
+
+		It("does not save the session within the same activity interval", func() {
+			lastActivity := now.Truncate(time.Minute)
+			session := &sessionsapi.SessionState{LastActivity: &lastActivity}
+			req := httptest.NewRequest("", "/", nil)
+
+			Expect(s.recordActivity(httptest.NewRecorder(), req, session)).To(Succeed())
+			Expect(saved).To(BeEmpty())
+		})
+
+		It("saves the activity with a session refreshed by the request", func() {
+			// Like a cookie store, the store loads the session the request was
+			// made with until the response is received
+			createdPast := now.Add(-5 * time.Minute)
+			lastActivity := now.Add(-time.Minute).Truncate(time.Minute)
+			s.store.(*fakeSessionStore).LoadFunc = func(*http.Request) (*sessionsapi.SessionState, error) {
+				return &sessionsapi.SessionState{
+					AccessToken:  "Stale",
+					RefreshToken: refresh,
+					CreatedAt:    &createdPast,
+					LastActivity: &lastActivity,
+				}, nil
+			}
+			s.refreshPeriod = time.Minute
+			s.refreshCache = newRefreshCache()
+			s.sessionRefresher = func(_ context.Context, ss *sessionsapi.SessionState) (bool, error) {
+				ss.AccessToken = "Refreshed"
+				ss.RefreshToken = "Rotated"
+				return true, nil
+			}
+			s.sessionValidator = func(context.Context, *sessionsapi.SessionState) bool {
+				return true
+			}
+
+			req := middlewareapi.AddRequestScope(httptest.NewRequest("", "/", nil), &middlewareapi.RequestScope{})
+			session, err := s.getValidatedSession(httptest.NewRecorder(), req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(session.RefreshToken).To(Equal("Rotated"))
+
+			Expect(saved).To(HaveLen(1))
+			Expect(saved[0].AccessToken).To(Equal("Refreshed"))
+			Expect(saved[0].RefreshToken).To(Equal("Rotated"))
+			Expect(*saved[0].LastActivity).To(Equal(now.Truncate(time.Minute)))
+		})
+
+		It("does not record activity without an idle timeout", func() {
+			s.idleTimeout = 0
+			session := &sessionsapi.SessionState{}
+			req := httptest.NewRequest("", "/", nil)
+
+			Expect(s.recordActivity(httptest.NewRecorder(), req, session)).To(Succeed())
+			Expect(saved).To(BeEmpty())
+			Expect(session.LastActivity).To(BeNil())
+		})
+	})
+
 	Context("validateSession", func() {
 		var s *storedSessionLoader
 
@@ -0,0 +1,59 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// BackChannelLogoutEvent is the member of the `events` claim that identifies
+// a Logout Token as defined by OpenID Connect Back-Channel Logout 1.0.
+const BackChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// LogoutToken holds the claims of a verified Logout Token that identify the
+// sessions to be logged out.
+type LogoutToken struct {
+	Issuer    string
+	Subject   string
+	SessionID string
+}
+
+// logoutTokenClaims are the claims of a Logout Token that are validated
+// in addition to the standard ID Token claims.
+type logoutTokenClaims struct {
+	Subject   string                 `json:"sub"`
+	SessionID string                 `json:"sid"`
+	Events    map[string]interface{} `json:"events"`
+	Nonce     *string                `json:"nonce"`
+}
+
+// VerifyLogoutToken verifies a Logout Token with the provider's ID Token
+// verifier and validates the claims required by the Back-Channel Logout
+// specification.
+func VerifyLogoutToken(ctx context.Context, verifier IDTokenVerifier, rawLogoutToken string) (*LogoutToken, error) {
+	token, err := verifier.Verify(ctx, rawLogoutToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims logoutTokenClaims
+	if err := token.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse logout token claims: %v", err)
+	}
+
+	if _, ok := claims.Events[BackChannelLogoutEvent]; !ok {
+		return nil, fmt.Errorf("logout token events claim does not contain %q", BackChannelLogoutEvent)
+	}
+	if claims.Nonce != nil {
+		return nil, errors.New("logout token must not contain a nonce claim")
+	}
+	if claims.Subject == "" && claims.SessionID == "" {
+		return nil, errors.New("logout token must contain a sub or sid claim")
+	}
+
+	return &LogoutToken{
+		Issuer:    token.Issuer,
+		Subject:   claims.Subject,
+		SessionID: claims.SessionID,
+	}, nil
+}
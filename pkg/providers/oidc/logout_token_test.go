@@ -0,0 +1,94 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("VerifyLogoutToken", func() {
+	type verifyLogoutTokenTableInput struct {
+		claims         map[string]interface{}
+		expectedToken  *LogoutToken
+		expectedErrMsg string
+	}
+
+	events := map[string]interface{}{
+		BackChannelLogoutEvent: map[string]interface{}{},
+	}
+
+	DescribeTable("when verifying a logout token",
+		func(in verifyLogoutTokenTableInput) {
+			in.claims["iss"] = "https://foo"
+			in.claims["aud"] = "1226737"
+			rawClaims, err := json.Marshal(in.claims)
+			Expect(err).ToNot(HaveOccurred())
+
+			token, err := createToken(rawClaims)
+			Expect(err).ToNot(HaveOccurred())
+
+			verifier := NewVerifier(oidc.NewVerifier("https://foo", &testVerifier{jwk: token.PublicKey}, &oidc.Config{
+				ClientID:        "1226737",
+				SkipExpiryCheck: true,
+			}), IDTokenVerificationOptions{
+				AudienceClaims: []string{"aud"},
+				ClientID:       "1226737",
+			})
+
+			logoutToken, err := VerifyLogoutToken(context.Background(), verifier, token.Token)
+			if in.expectedErrMsg != "" {
+				Expect(err).To(MatchError(in.expectedErrMsg))
+				Expect(logoutToken).To(BeNil())
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+			Expect(logoutToken).To(Equal(in.expectedToken))
+		},
+		Entry("with a subject and session ID", verifyLogoutTokenTableInput{
+			claims: map[string]interface{}{
+				"sub":    "subject",
+				"sid":    "session",
+				"events": events,
+			},
+			expectedToken: &LogoutToken{
+				Issuer:    "https://foo",
+				Subject:   "subject",
+				SessionID: "session",
+			},
+		}),
+		Entry("with only a session ID", verifyLogoutTokenTableInput{
+			claims: map[string]interface{}{
+				"sid":    "session",
+				"events": events,
+			},
+			expectedToken: &LogoutToken{
+				Issuer:    "https://foo",
+				SessionID: "session",
+			},
+		}),
+		Entry("without the logout event", verifyLogoutTokenTableInput{
+			claims: map[string]interface{}{
+				"sub":    "subject",
+				"events": map[string]interface{}{"http://example.com/event": map[string]interface{}{}},
+			},
+			expectedErrMsg: "logout token events claim does not contain \"http://schemas.openid.net/event/backchannel-logout\"",
+		}),
+		Entry("with a nonce", verifyLogoutTokenTableInput{
+			claims: map[string]interface{}{
+				"sub":    "subject",
+				"events": events,
+				"nonce":  "nonce",
+			},
+			expectedErrMsg: "logout token must not contain a nonce claim",
+		}),
+		Entry("without a subject or session ID", verifyLogoutTokenTableInput{
+			claims: map[string]interface{}{
+				"events": events,
+			},
+			expectedErrMsg: "logout token must contain a sub or sid claim",
+		}),
+	)
+})
@@ -16,6 +16,9 @@ type providerJSON struct {
 	TokenURL             string   `json:"token_endpoint"`
 	JWKsURL              string   `json:"jwks_uri"`
 	UserInfoURL          string   `json:"userinfo_endpoint"`
+	EndSessionURL        string   `json:"end_session_endpoint"`
+	IntrospectionURL     string   `json:"introspection_endpoint"`
+	PARURL               string   `json:"pushed_authorization_request_endpoint"`
 	CodeChallengeAlgs    []string `json:"code_challenge_methods_supported"`
 	SupportedSigningAlgs []string `json:"id_token_signing_alg_values_supported"`
 }
@@ -27,6 +30,13 @@ type Endpoints struct {
 	TokenURL    string
 	JWKsURL     string
 	UserInfoURL string
+	// EndSessionURL is only set when the provider supports RP-Initiated Logout
+	EndSessionURL string
+	// IntrospectionURL is only set when the provider supports Token Introspection
+	IntrospectionURL string
+	// PushedAuthorizationRequestURL is only set when the provider supports
+	// Pushed Authorization Requests
+	PushedAuthorizationRequestURL string
 }
 
 // PKCE holds information relevant to the PKCE (code challenge) support of the
@@ -69,6 +79,9 @@ func NewProvider(ctx context.Context, issuerURL string, skipIssuerVerification b
 		tokenURL:             p.TokenURL,
 		jwksURL:              p.JWKsURL,
 		userInfoURL:          p.UserInfoURL,
+		endSessionURL:        p.EndSessionURL,
+		introspectionURL:     p.IntrospectionURL,
+		parURL:               p.PARURL,
 		codeChallengeAlgs:    p.CodeChallengeAlgs,
 		supportedSigningAlgs: p.SupportedSigningAlgs,
 	}, nil
@@ -80,6 +93,9 @@ type discoveryProvider struct {
 	tokenURL             string
 	jwksURL              string
 	userInfoURL          string
+	endSessionURL        string
+	introspectionURL     string
+	parURL               string
 	codeChallengeAlgs    []string
 	supportedSigningAlgs []string
 }
@@ -87,10 +103,13 @@ type discoveryProvider struct {
 // Endpoints returns the discovered endpoints needed for an authentication provider.
 func (p *discoveryProvider) Endpoints() Endpoints {
 	return Endpoints{
-		AuthURL:     p.authURL,
-		TokenURL:    p.tokenURL,
-		JWKsURL:     p.jwksURL,
-		UserInfoURL: p.userInfoURL,
+		AuthURL:                       p.authURL,
+		TokenURL:                      p.tokenURL,
+		JWKsURL:                       p.jwksURL,
+		UserInfoURL:                   p.userInfoURL,
+		EndSessionURL:                 p.endSessionURL,
+		IntrospectionURL:              p.introspectionURL,
+		PushedAuthorizationRequestURL: p.parURL,
 	}
 }
 
@@ -121,6 +121,27 @@ var _ = Describe("Provider", func() {
 
 		Expect(provider.SupportedSigningAlgs()).To(ConsistOf("RS256", "HS256"))
 	})
+
+	It("with end session, introspection and pushed authorization request endpoints on the provider, should populate their URLs", func() {
+		m, err := mockoidc.NewServer(nil)
+		Expect(err).ToNot(HaveOccurred())
+		m.AddMiddleware(newEndSessionIssuerMiddleware(m))
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(m.Start(ln, nil)).To(Succeed())
+		defer func() {
+			Expect(m.Shutdown()).To(Succeed())
+		}()
+
+		provider, err := NewProvider(context.Background(), m.Issuer(), false)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(provider.Endpoints().EndSessionURL).To(Equal(m.Issuer() + "/logout"))
+		Expect(provider.Endpoints().IntrospectionURL).To(Equal(m.Issuer() + "/introspect"))
+		Expect(provider.Endpoints().PushedAuthorizationRequestURL).To(Equal(m.Issuer() + "/par"))
+	})
 })
 
 func newInvalidIssuerMiddleware(m *mockoidc.MockOIDC) func(http.Handler) http.Handler {
@@ -182,6 +203,28 @@ func newSigningAlgsIssuerMiddleware(m *mockoidc.MockOIDC) func(http.Handler) htt
 	}
 }
 
+func newEndSessionIssuerMiddleware(m *mockoidc.MockOIDC) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			p := providerJSON{
+				Issuer:           m.Issuer(),
+				AuthURL:          m.AuthorizationEndpoint(),
+				TokenURL:         m.TokenEndpoint(),
+				JWKsURL:          m.JWKSEndpoint(),
+				UserInfoURL:      m.UserinfoEndpoint(),
+				EndSessionURL:    m.Issuer() + "/logout",
+				IntrospectionURL: m.Issuer() + "/introspect",
+				PARURL:           m.Issuer() + "/par",
+			}
+			data, err := json.Marshal(p)
+			if err != nil {
+				rw.WriteHeader(500)
+			}
+			rw.Write(data)
+		})
+	}
+}
+
 func newBadRequestMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
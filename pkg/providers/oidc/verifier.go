@@ -2,12 +2,26 @@ package oidc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 )
 
+// ErrInvalidAudience matches the errors of tokens that are rejected because
+// their audience is not allowed
+var ErrInvalidAudience = errors.New("invalid audience")
+
+// audienceError is the error of a token rejected for its audience
+type audienceError struct {
+	msg string
+}
+
+func (e *audienceError) Error() string { return e.msg }
+
+func (e *audienceError) Is(target error) bool { return target == ErrInvalidAudience }
+
 // idTokenVerifier allows an ID Token to be verified against the issue and provided keys.
 type IDTokenVerifier interface {
 	Verify(context.Context, string) (*oidc.IDToken, error)
@@ -80,8 +94,8 @@ func (v *idTokenVerifier) verifyAudience(token *oidc.IDToken, claims map[string]
 		}
 	}
 
-	return false, fmt.Errorf("audience claims %v do not exist in claims: %v",
-		v.verificationOptions.AudienceClaims, claims)
+	return false, &audienceError{fmt.Sprintf("audience claims %v do not exist in claims: %v",
+		v.verificationOptions.AudienceClaims, claims)}
 }
 
 func (v *idTokenVerifier) isValidAudience(claim string, audience []string, allowedAudiences map[string]struct{}) (bool, error) {
@@ -91,9 +105,9 @@ func (v *idTokenVerifier) isValidAudience(claim string, audience []string, allow
 		}
 	}
 
-	return false, fmt.Errorf(
+	return false, &audienceError{fmt.Sprintf(
 		"audience from claim %s with value %s does not match with any of allowed audiences %v",
-		claim, audience, allowedAudiences)
+		claim, audience, allowedAudiences)}
 }
 
 func (v *idTokenVerifier) interfaceSliceToString(slice interface{}) []string {
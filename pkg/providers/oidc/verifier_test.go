@@ -42,6 +42,7 @@ var _ = Describe("Verify", func() {
 		})
 		Expect(err).To(MatchError("audience from claim aud with value [1226737] does not match with " +
 			"any of allowed audiences map[7817818:{}]"))
+		Expect(err).To(MatchError(ErrInvalidAudience))
 		Expect(result).To(BeNil())
 	})
 
@@ -147,6 +148,7 @@ var _ = Describe("Verify", func() {
 
 		Expect(err).To(MatchError("audience claims [not_exists] do not exist in claims: " +
 			"map[aud:1226737 client_id:1226737 iss:https://foo]"))
+		Expect(err).To(MatchError(ErrInvalidAudience))
 		Expect(result).To(BeNil())
 	})
 
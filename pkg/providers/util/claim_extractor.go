@@ -22,6 +22,10 @@ type ClaimExtractor interface {
 
 	// GetClaimInto fetches a named claim and puts the value into the destination.
 	GetClaimInto(claim string, dst interface{}) (bool, error)
+
+	// GetTokenClaimInto fetches a named claim from the ID Token only, without
+	// looking it up at the profile URL, and puts the value into the destination.
+	GetTokenClaimInto(claim string, dst interface{}) (bool, error)
 }
 
 // NewClaimExtractor constructs a new ClaimExtractor from the raw ID Token.
@@ -46,6 +50,22 @@ func NewClaimExtractor(ctx context.Context, idToken string, profileURL *url.URL,
 	}, nil
 }
 
+// NewJSONClaimExtractor constructs a new ClaimExtractor from a JSON object of
+// claims, such as a token introspection response. Claims missing from the
+// object are not looked up anywhere else.
+func NewJSONClaimExtractor(payload []byte) (ClaimExtractor, error) {
+	claims, err := simplejson.NewJson(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse claims: %v", err)
+	}
+
+	return &claimExtractor{
+		ctx:         context.Background(),
+		profileURL:  &url.URL{},
+		tokenClaims: claims,
+	}, nil
+}
+
 // claimExtractor implements the ClaimExtractor interface
 type claimExtractor struct {
 	profileURL     *url.URL
@@ -124,6 +144,20 @@ func (c *claimExtractor) GetClaimInto(claim string, dst interface{}) (bool, erro
 	return true, nil
 }
 
+// GetTokenClaimInto loads a claim of the ID Token and places it into the
+// destination interface, without fetching the profile URL.
+func (c *claimExtractor) GetTokenClaimInto(claim string, dst interface{}) (bool, error) {
+	value := getClaimFrom(claim, c.tokenClaims)
+	if claim == "" || value == nil {
+		return false, nil
+	}
+	if err := coerceClaim(value, dst); err != nil {
+		return false, fmt.Errorf("could no coerce claim: %v", err)
+	}
+
+	return true, nil
+}
+
 // This has been copied from https://github.com/coreos/go-oidc/blob/8d771559cf6e5111c9b9159810d0e4538e7cdc82/verify.go#L120-L130
 // We use it to grab the raw ID Token payload so that we can parse it into the JSON library.
 func parseJWT(p string) ([]byte, error) {
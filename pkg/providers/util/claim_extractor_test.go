@@ -292,6 +292,38 @@ var _ = Describe("Claim Extractor Suite", func() {
 		Expect(counter).To(BeEquivalentTo(1))
 	})
 
+	It("GetTokenClaimInto should not call the profile URL", func() {
+		var counter int32
+		countRequestsHandler := func(rw http.ResponseWriter, _ *http.Request) {
+			atomic.AddInt32(&counter, 1)
+			rw.Write([]byte(basicProfileURLPayload))
+		}
+
+		claimExtractor, serverClose, err := newTestClaimExtractor(testClaimExtractorOpts{
+			idTokenPayload:        `{"sub": "idTokenSubject"}`,
+			setProfileURL:         true,
+			profileRequestHeaders: newAuthorizedHeader(),
+			profileRequestHandler: countRequestsHandler,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		if serverClose != nil {
+			defer serverClose()
+		}
+
+		var subject string
+		exists, err := claimExtractor.GetTokenClaimInto("sub", &subject)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exists).To(BeTrue())
+		Expect(subject).To(Equal("idTokenSubject"))
+
+		var user string
+		exists, err = claimExtractor.GetTokenClaimInto("user", &user)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exists).To(BeFalse())
+		Expect(user).To(BeEmpty())
+		Expect(counter).To(BeEquivalentTo(0))
+	})
+
 	It("GetClaim should not return an error with a non-nil empty ProfileURL", func() {
 		claims, serverClose, err := newTestClaimExtractor(testClaimExtractorOpts{
 			idTokenPayload:        "{}",
@@ -310,6 +342,25 @@ var _ = Describe("Claim Extractor Suite", func() {
 		Expect(value).To(BeNil())
 	})
 
+	It("NewJSONClaimExtractor should only read claims from the JSON object", func() {
+		claims, err := NewJSONClaimExtractor([]byte(`{"active": true, "sub": "subject", "nested": {"groups": ["a", "b"]}}`))
+		Expect(err).ToNot(HaveOccurred())
+
+		var groups []string
+		exists, err := claims.GetClaimInto("nested.groups", &groups)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exists).To(BeTrue())
+		Expect(groups).To(ConsistOf("a", "b"))
+
+		value, exists, err := claims.GetClaim("email")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exists).To(BeFalse())
+		Expect(value).To(BeNil())
+
+		_, err = NewJSONClaimExtractor([]byte("not json"))
+		Expect(err).To(MatchError(ContainSubstring("failed to parse claims")))
+	})
+
 	type getClaimIntoTableInput struct {
 		testClaimExtractorOpts
 		into          interface{}
@@ -7,9 +7,10 @@ import (
 )
 
 const (
-	XForwardedProto = "X-Forwarded-Proto"
-	XForwardedHost  = "X-Forwarded-Host"
-	XForwardedURI   = "X-Forwarded-Uri"
+	XForwardedProto  = "X-Forwarded-Proto"
+	XForwardedHost   = "X-Forwarded-Host"
+	XForwardedURI    = "X-Forwarded-Uri"
+	XForwardedMethod = "X-Forwarded-Method"
 )
 
 // GetRequestProto returns the request scheme or X-Forwarded-Proto if present
@@ -43,6 +44,16 @@ func GetRequestURI(req *http.Request) string {
 	return uri
 }
 
+// GetRequestMethod returns the request method or X-Forwarded-Method if present
+// and the request is proxied.
+func GetRequestMethod(req *http.Request) string {
+	method := req.Header.Get(XForwardedMethod)
+	if !IsProxied(req) || method == "" {
+		method = req.Method
+	}
+	return method
+}
+
 // IsProxied determines if a request was from a proxy based on the RequestScope
 // ReverseProxy tracker.
 func IsProxied(req *http.Request) bool {
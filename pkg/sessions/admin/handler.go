@@ -0,0 +1,139 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+const (
+	sessionsPath = "/sessions"
+	sessionPath  = "/sessions/{id}"
+)
+
+// sessionList is the response body of a request to list sessions
+type sessionList struct {
+	Sessions []sessionsapi.SessionInfo `json:"sessions"`
+}
+
+// NewHandler returns the handler of the session admin API.
+// Requests must present the token as a bearer token in the Authorization
+// header.
+//
+// The API serves the following endpoints:
+//   - GET /sessions?user=<user> lists the sessions of a user
+//   - DELETE /sessions?user=<user> revokes all sessions of a user
+//   - DELETE /sessions/<id> revokes a single session
+//
+// The user may be given as either the user name or the email address.
+// Users are looked up for the provider given by the `provider` parameter,
+// which defaults to the default provider.
+func NewHandler(admin sessionsapi.SessionAdministrator, token, defaultProviderID string) http.Handler {
+	h := &handler{admin: admin, defaultProviderID: defaultProviderID}
+
+	r := mux.NewRouter()
+	r.Use(requireToken(token))
+	r.Path(sessionsPath).Methods(http.MethodGet).HandlerFunc(h.listSessions)
+	r.Path(sessionsPath).Methods(http.MethodDelete).HandlerFunc(h.revokeUserSessions)
+	r.Path(sessionPath).Methods(http.MethodDelete).HandlerFunc(h.revokeSession)
+	return r
+}
+
+type handler struct {
+	admin             sessionsapi.SessionAdministrator
+	defaultProviderID string
+}
+
+// providerID returns the provider whose users the request looks up
+func (h *handler) providerID(req *http.Request) string {
+	if providerID := req.URL.Query().Get("provider"); providerID != "" {
+		return providerID
+	}
+	return h.defaultProviderID
+}
+
+func (h *handler) listSessions(rw http.ResponseWriter, req *http.Request) {
+	user := req.URL.Query().Get("user")
+	if user == "" {
+		writeError(rw, http.StatusBadRequest, "missing user parameter")
+		return
+	}
+
+	sessions, err := h.admin.ListSessions(req.Context(), h.providerID(req), user)
+	if err != nil {
+		logger.Errorf("Error listing sessions: %v", err)
+		writeError(rw, http.StatusInternalServerError, "sessions could not be listed")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(sessionList{Sessions: sessions}); err != nil {
+		logger.Errorf("Error encoding sessions: %v", err)
+	}
+}
+
+func (h *handler) revokeUserSessions(rw http.ResponseWriter, req *http.Request) {
+	user := req.URL.Query().Get("user")
+	if user == "" {
+		writeError(rw, http.StatusBadRequest, "missing user parameter")
+		return
+	}
+
+	if err := h.admin.RevokeUserSessions(req.Context(), h.providerID(req), user); err != nil {
+		logger.Errorf("Error revoking sessions: %v", err)
+		writeError(rw, http.StatusInternalServerError, "sessions could not be revoked")
+		return
+	}
+
+	logger.Printf("Session admin API revoked all sessions of user %q of provider %q", user, h.providerID(req))
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) revokeSession(rw http.ResponseWriter, req *http.Request) {
+	id := mux.Vars(req)["id"]
+
+	err := h.admin.RevokeSession(req.Context(), id)
+	switch {
+	case errors.Is(err, sessionsapi.ErrSessionNotFound):
+		writeError(rw, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		logger.Errorf("Error revoking session: %v", err)
+		writeError(rw, http.StatusInternalServerError, "session could not be revoked")
+		return
+	}
+
+	logger.Printf("Session admin API revoked session %q", id)
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// requireToken rejects requests that do not present the token as a bearer
+// token.
+func requireToken(token string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			presented, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+				rw.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(rw, http.StatusUnauthorized, "invalid or missing token")
+				return
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// writeError writes a JSON error response
+func writeError(rw http.ResponseWriter, code int, message string) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	if err := json.NewEncoder(rw).Encode(map[string]string{"error": message}); err != nil {
+		logger.Errorf("Error encoding error response: %v", err)
+	}
+}
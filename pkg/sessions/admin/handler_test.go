@@ -0,0 +1,142 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	testToken      = "admin-token"
+	testProviderID = "corp"
+)
+
+// fakeAdministrator holds sessions by provider ID and user
+type fakeAdministrator struct {
+	sessions map[string][]sessionsapi.SessionInfo
+	revoked  []string
+}
+
+func (f *fakeAdministrator) ListSessions(_ context.Context, providerID, user string) ([]sessionsapi.SessionInfo, error) {
+	return f.sessions[providerID+"/"+user], nil
+}
+
+func (f *fakeAdministrator) RevokeSession(_ context.Context, id string) error {
+	for _, sessions := range f.sessions {
+		for _, session := range sessions {
+			if session.ID == id {
+				f.revoked = append(f.revoked, id)
+				return nil
+			}
+		}
+	}
+	return sessionsapi.ErrSessionNotFound
+}
+
+func (f *fakeAdministrator) RevokeUserSessions(_ context.Context, providerID, user string) error {
+	for _, session := range f.sessions[providerID+"/"+user] {
+		f.revoked = append(f.revoked, session.ID)
+	}
+	return nil
+}
+
+var _ = Describe("Session Admin API", func() {
+	var admin *fakeAdministrator
+	var handler http.Handler
+
+	BeforeEach(func() {
+		admin = &fakeAdministrator{
+			sessions: map[string][]sessionsapi.SessionInfo{
+				"corp/alice": {
+					{ID: "_oauth2_proxy-1", User: "alice", IP: "10.0.0.1", UserAgent: "browser"},
+					{ID: "_oauth2_proxy-2", User: "alice"},
+				},
+				"other/alice": {
+					{ID: "_oauth2_proxy-3", ProviderID: "other", User: "alice"},
+				},
+			},
+		}
+		handler = NewHandler(admin, testToken, testProviderID)
+	})
+
+	serve := func(method, target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		return rw
+	}
+
+	type authTableInput struct {
+		method string
+		target string
+		token  string
+	}
+
+	DescribeTable("rejects requests without a valid token",
+		func(in authTableInput) {
+			rw := serve(in.method, in.target, in.token)
+			Expect(rw.Code).To(Equal(http.StatusUnauthorized))
+			Expect(rw.Header().Get("WWW-Authenticate")).To(Equal("Bearer"))
+			Expect(admin.revoked).To(BeEmpty())
+		},
+		Entry("list without a token", authTableInput{method: http.MethodGet, target: "/sessions?user=alice"}),
+		Entry("list with a wrong token", authTableInput{method: http.MethodGet, target: "/sessions?user=alice", token: "wrong"}),
+		Entry("revoke with a wrong token", authTableInput{method: http.MethodDelete, target: "/sessions/_oauth2_proxy-1", token: "wrong"}),
+		Entry("revoke user with a wrong token", authTableInput{method: http.MethodDelete, target: "/sessions?user=alice", token: "wrong"}),
+	)
+
+	It("lists the sessions of a user", func() {
+		rw := serve(http.MethodGet, "/sessions?user=alice", testToken)
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		var body sessionList
+		Expect(json.Unmarshal(rw.Body.Bytes(), &body)).To(Succeed())
+		Expect(body.Sessions).To(Equal(admin.sessions["corp/alice"]))
+	})
+
+	It("lists the sessions of a user of another provider", func() {
+		rw := serve(http.MethodGet, "/sessions?user=alice&provider=other", testToken)
+		Expect(rw.Code).To(Equal(http.StatusOK))
+
+		var body sessionList
+		Expect(json.Unmarshal(rw.Body.Bytes(), &body)).To(Succeed())
+		Expect(body.Sessions).To(Equal(admin.sessions["other/alice"]))
+	})
+
+	It("requires a user to list sessions", func() {
+		rw := serve(http.MethodGet, "/sessions", testToken)
+		Expect(rw.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("revokes a single session", func() {
+		rw := serve(http.MethodDelete, "/sessions/_oauth2_proxy-2", testToken)
+		Expect(rw.Code).To(Equal(http.StatusNoContent))
+		Expect(admin.revoked).To(ConsistOf("_oauth2_proxy-2"))
+	})
+
+	It("returns not found for an unknown session", func() {
+		rw := serve(http.MethodDelete, "/sessions/unknown", testToken)
+		Expect(rw.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("revokes all sessions of a user", func() {
+		rw := serve(http.MethodDelete, "/sessions?user=alice", testToken)
+		Expect(rw.Code).To(Equal(http.StatusNoContent))
+		Expect(admin.revoked).To(ConsistOf("_oauth2_proxy-1", "_oauth2_proxy-2"))
+	})
+
+	It("revokes all sessions of a user of another provider", func() {
+		rw := serve(http.MethodDelete, "/sessions?user=alice&provider=other", testToken)
+		Expect(rw.Code).To(Equal(http.StatusNoContent))
+		Expect(admin.revoked).To(ConsistOf("_oauth2_proxy-3"))
+	})
+})
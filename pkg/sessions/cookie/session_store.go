@@ -31,6 +31,10 @@ type SessionStore struct {
 	Cookie       *options.Cookie
 	CookieCipher encryption.Cipher
 	Minimal      bool
+
+	// PreviousCookieCiphers decrypt sessions sealed with the previous cookie
+	// secrets, in the same order.
+	PreviousCookieCiphers []encryption.Cipher
 }
 
 // Save takes a sessions.SessionState and stores the information from it
@@ -54,12 +58,22 @@ func (s *SessionStore) Load(req *http.Request) (*sessions.SessionState, error) {
 		// always http.ErrNoCookie
 		return nil, err
 	}
-	val, _, ok := encryption.Validate(c, s.Cookie.Secret, s.Cookie.Expire)
+	val, _, secretIndex, ok := encryption.ValidateWithSeeds(c, s.Cookie.Secrets(), s.Cookie.Expire)
 	if !ok {
 		return nil, errors.New("cookie signature not valid")
 	}
 
-	session, err := sessions.DecodeSessionState(val, s.CookieCipher, true)
+	// Sessions sealed with a previous secret are sealed with the current
+	// secret the next time they are saved.
+	cipher := s.CookieCipher
+	if secretIndex > 0 {
+		if secretIndex > len(s.PreviousCookieCiphers) {
+			return nil, errors.New("no cipher for the previous cookie secret")
+		}
+		cipher = s.PreviousCookieCiphers[secretIndex-1]
+	}
+
+	session, err := sessions.DecodeSessionState(val, cipher, true)
 	if err != nil {
 		return nil, err
 	}
@@ -151,10 +165,20 @@ func NewCookieSessionStore(opts *options.SessionOptions, cookieOpts *options.Coo
 		return nil, fmt.Errorf("error initialising cipher: %v", err)
 	}
 
+	previousCiphers := make([]encryption.Cipher, 0, len(cookieOpts.PreviousSecrets))
+	for _, secret := range cookieOpts.PreviousSecrets {
+		previousCipher, err := encryption.NewCFBCipher(encryption.SecretBytes(secret))
+		if err != nil {
+			return nil, fmt.Errorf("error initialising cipher for previous cookie secret: %v", err)
+		}
+		previousCiphers = append(previousCiphers, previousCipher)
+	}
+
 	return &SessionStore{
-		CookieCipher: cipher,
-		Cookie:       cookieOpts,
-		Minimal:      opts.Cookie.Minimal,
+		CookieCipher:          cipher,
+		PreviousCookieCiphers: previousCiphers,
+		Cookie:                cookieOpts,
+		Minimal:               opts.Cookie.Minimal,
 	}, nil
 }
 
@@ -0,0 +1,84 @@
+package etcd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// NewEtcdClient makes an etcd client for the endpoints of the options
+func NewEtcdClient(opts options.EtcdStoreOptions) (*clientv3.Client, error) {
+	if len(opts.Endpoints) == 0 {
+		return nil, errors.New("no etcd endpoints configured")
+	}
+
+	tlsConfig, err := newTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return clientv3.New(clientv3.Config{
+		Endpoints:   opts.Endpoints,
+		Username:    opts.Username,
+		Password:    opts.Password,
+		TLS:         tlsConfig,
+		DialTimeout: opts.DialTimeout,
+		// The client logs retries of unavailable endpoints, errors are
+		// reported by the session store instead
+		Logger: zap.NewNop(),
+	})
+}
+
+// newTLSConfig builds the TLS configuration of the client.
+// TLS is used when any endpoint uses https or any TLS option is given.
+func newTLSConfig(opts options.EtcdStoreOptions) (*tls.Config, error) {
+	useTLS := opts.CAPath != "" || opts.CertPath != "" || opts.InsecureSkipTLSVerify
+	for _, endpoint := range opts.Endpoints {
+		useTLS = useTLS || strings.HasPrefix(endpoint, "https://")
+	}
+	if !useTLS {
+		return nil, nil
+	}
+
+	/* #nosec */
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: opts.InsecureSkipTLSVerify,
+	}
+
+	if opts.CAPath != "" {
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil {
+			logger.Errorf("failed to load system cert pool for etcd connection, falling back to empty cert pool")
+		}
+		if rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+		certs, err := os.ReadFile(opts.CAPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %q, %v", opts.CAPath, err)
+		}
+
+		// Append our cert to the system pool
+		if ok := rootCAs.AppendCertsFromPEM(certs); !ok {
+			logger.Errorf("no certs appended, using system certs only")
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if opts.CertPath != "" || opts.KeyPath != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertPath, opts.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load etcd client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
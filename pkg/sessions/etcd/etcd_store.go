@@ -0,0 +1,341 @@
+package etcd
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// SessionStore is an implementation of the persistence.Store
+// interface that stores sessions in etcd.
+// Every key is attached to a lease matching its expiration, so that etcd
+// removes expired sessions, index members and locks.
+type SessionStore struct {
+	Client *clientv3.Client
+
+	// Clock is used to expire sessions, indexes and locks.
+	// Leases only expire with a precision of seconds, so values also record
+	// when they expire and are not returned once expired.
+	Clock clock.Clock
+
+	prefix string
+}
+
+// record is a value stored in etcd along with when it expires
+type record struct {
+	value []byte
+	// expiresAt is the expiry in unix milliseconds, 0 never expires
+	expiresAt int64
+}
+
+// NewEtcdSessionStore initialises a new instance of the SessionStore and
+// wraps it in a persistence.Manager
+func NewEtcdSessionStore(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessions.SessionStore, error) {
+	client, err := NewEtcdClient(opts.Etcd)
+	if err != nil {
+		return nil, fmt.Errorf("error constructing etcd client: %v", err)
+	}
+
+	return persistence.NewManager(NewStore(client, opts.Etcd.KeyPrefix), cookieOpts), nil
+}
+
+// NewStore creates a SessionStore that writes its keys below the prefix
+func NewStore(client *clientv3.Client, prefix string) *SessionStore {
+	return &SessionStore{
+		Client: client,
+		prefix: prefix,
+	}
+}
+
+// Save stores the value under the key, attached to a lease that expires
+// with the value
+func (store *SessionStore) Save(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	lease, err := store.grant(ctx, exp)
+	if err != nil {
+		return fmt.Errorf("error saving etcd session: %v", err)
+	}
+	op := store.putOp(store.sessionKey(key), record{value: value, expiresAt: store.expiresAt(exp)}, lease)
+	if _, err := store.commit(ctx, lease, nil, op); err != nil {
+		return fmt.Errorf("error saving etcd session: %v", err)
+	}
+	return nil
+}
+
+// Load reads the record of the session key. A record whose lease etcd has
+// not revoked yet is not returned once it has expired.
+func (store *SessionStore) Load(ctx context.Context, key string) ([]byte, error) {
+	r, _, err := store.get(ctx, store.sessionKey(key))
+	if err != nil {
+		return nil, fmt.Errorf("error loading etcd session: %v", err)
+	}
+	if r == nil {
+		return nil, errors.New("error loading etcd session: session not found")
+	}
+	return r.value, nil
+}
+
+// Clear deletes the session key
+func (store *SessionStore) Clear(ctx context.Context, key string) error {
+	if _, err := store.Client.Delete(ctx, store.sessionKey(key)); err != nil {
+		return fmt.Errorf("error clearing the session from etcd: %v", err)
+	}
+	return nil
+}
+
+// Lock creates a lock on the session held in its lock key
+func (store *SessionStore) Lock(key string) sessions.Lock {
+	return persistence.NewTokenLock(store, store.lockKey(key))
+}
+
+// VerifyConnection verifies the etcd cluster is reachable and has a leader
+// by performing a linearizable read
+func (store *SessionStore) VerifyConnection(ctx context.Context) error {
+	if _, err := store.Client.Get(ctx, store.prefix, clientv3.WithCountOnly()); err != nil {
+		return fmt.Errorf("error connecting to etcd: %v", err)
+	}
+	return nil
+}
+
+// AddToIndex writes the key of the member below the prefix of the index
+// and, in the same transaction, attaches the members that would expire
+// sooner to the lease of the member
+func (store *SessionStore) AddToIndex(ctx context.Context, index string, member string, exp time.Duration) error {
+	resp, err := store.Client.Get(ctx, store.indexPrefix(index), clientv3.WithPrefix())
+	if err != nil {
+		return fmt.Errorf("error loading etcd session index: %v", err)
+	}
+
+	expiresAt := store.expiresAt(exp)
+	memberKey := store.indexKey(index, member)
+	keys := []string{memberKey}
+	for _, kv := range resp.Kvs {
+		r, err := decodeRecord(kv.Value)
+		if err != nil {
+			return fmt.Errorf("error loading etcd session index: %v", err)
+		}
+		if string(kv.Key) == memberKey || store.expired(r) {
+			continue
+		}
+		if r.expiresAt != 0 && (expiresAt == 0 || r.expiresAt < expiresAt) {
+			keys = append(keys, string(kv.Key))
+		}
+	}
+
+	lease, err := store.grant(ctx, exp)
+	if err != nil {
+		return fmt.Errorf("error adding to etcd session index: %v", err)
+	}
+	ops := make([]clientv3.Op, 0, len(keys))
+	for _, key := range keys {
+		ops = append(ops, store.putOp(key, record{expiresAt: expiresAt}, lease))
+	}
+	if _, err := store.commit(ctx, lease, nil, ops...); err != nil {
+		return fmt.Errorf("error adding to etcd session index: %v", err)
+	}
+	return nil
+}
+
+// LoadIndex reads the members from the keys below the prefix of the index
+func (store *SessionStore) LoadIndex(ctx context.Context, index string) ([]string, error) {
+	prefix := store.indexPrefix(index)
+	resp, err := store.Client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("error loading etcd session index: %v", err)
+	}
+
+	members := []string{}
+	for _, kv := range resp.Kvs {
+		r, err := decodeRecord(kv.Value)
+		if err != nil {
+			return nil, fmt.Errorf("error loading etcd session index: %v", err)
+		}
+		if store.expired(r) {
+			continue
+		}
+		member, err := url.PathUnescape(strings.TrimPrefix(string(kv.Key), prefix))
+		if err != nil {
+			return nil, fmt.Errorf("error loading etcd session index: %v", err)
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// RemoveFromIndex deletes the keys of the members of the index in one
+// transaction
+func (store *SessionStore) RemoveFromIndex(ctx context.Context, index string, members ...string) error {
+	ops := make([]clientv3.Op, 0, len(members))
+	for _, member := range members {
+		ops = append(ops, clientv3.OpDelete(store.indexKey(index, member)))
+	}
+	if _, err := store.Client.Txn(ctx).Then(ops...).Commit(); err != nil {
+		return fmt.Errorf("error removing from etcd session index: %v", err)
+	}
+	return nil
+}
+
+// Close closes the connections to etcd
+func (store *SessionStore) Close() error {
+	return store.Client.Close()
+}
+
+// get reads the record stored under the key along with the revision the key
+// was last modified at. The record is nil if the key does not exist or has
+// expired, the revision is 0 if the key does not exist.
+func (store *SessionStore) get(ctx context.Context, key string) (*record, int64, error) {
+	resp, err := store.Client.Get(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, 0, nil
+	}
+
+	kv := resp.Kvs[0]
+	r, err := decodeRecord(kv.Value)
+	if err != nil {
+		return nil, 0, err
+	}
+	if store.expired(r) {
+		return nil, kv.ModRevision, nil
+	}
+	return &r, kv.ModRevision, nil
+}
+
+// grant grants a lease that expires after the duration.
+// A value that never expires is not attached to a lease, so no lease is
+// granted for it.
+func (store *SessionStore) grant(ctx context.Context, exp time.Duration) (clientv3.LeaseID, error) {
+	if exp <= 0 {
+		return clientv3.NoLease, nil
+	}
+
+	lease, err := store.Client.Grant(ctx, leaseTTL(exp))
+	if err != nil {
+		return clientv3.NoLease, fmt.Errorf("error granting etcd lease: %v", err)
+	}
+	return lease.ID, nil
+}
+
+// putOp returns an operation that stores the record attached to the lease.
+// The operation returns the previous value of the key, so that commit can
+// revoke the lease it was attached to.
+func (store *SessionStore) putOp(key string, r record, lease clientv3.LeaseID) clientv3.Op {
+	opts := []clientv3.OpOption{clientv3.WithPrevKV()}
+	if lease != clientv3.NoLease {
+		opts = append(opts, clientv3.WithLease(lease))
+	}
+	return clientv3.OpPut(key, encodeRecord(r), opts...)
+}
+
+// commit applies the put operations in a transaction when the comparisons
+// succeed, and returns whether they did.
+// Every write of a key attaches it to a newly granted lease, so the lease
+// that is not used by the transaction, or the leases that the keys are no
+// longer attached to, are revoked rather than kept by etcd until they expire.
+func (store *SessionStore) commit(ctx context.Context, granted clientv3.LeaseID, cmps []clientv3.Cmp, ops ...clientv3.Op) (bool, error) {
+	resp, err := store.Client.Txn(ctx).If(cmps...).Then(ops...).Commit()
+	if err != nil {
+		// The transaction may have been applied, the granted lease expires
+		return false, err
+	}
+	if !resp.Succeeded {
+		store.revokeUnusedLease(ctx, granted)
+		return false, nil
+	}
+
+	previous := map[clientv3.LeaseID]struct{}{}
+	for _, r := range resp.Responses {
+		put := r.GetResponsePut()
+		if put == nil || put.PrevKv == nil {
+			continue
+		}
+		if lease := clientv3.LeaseID(put.PrevKv.Lease); lease != clientv3.NoLease && lease != granted {
+			previous[lease] = struct{}{}
+		}
+	}
+	for lease := range previous {
+		store.revokeUnusedLease(ctx, lease)
+	}
+	return true, nil
+}
+
+// revokeUnusedLease revokes the lease unless keys are still attached to it.
+// Leases are never reused once their keys have been written again, so no
+// key is attached to the lease after it has been found unused.
+// Errors are ignored, the lease is removed by etcd once it expires anyway.
+func (store *SessionStore) revokeUnusedLease(ctx context.Context, lease clientv3.LeaseID) {
+	if lease == clientv3.NoLease {
+		return
+	}
+
+	resp, err := store.Client.TimeToLive(ctx, lease, clientv3.WithAttachedKeys())
+	if err != nil || resp.TTL <= 0 || len(resp.Keys) > 0 {
+		return
+	}
+	_, _ = store.Client.Revoke(ctx, lease)
+}
+
+func (store *SessionStore) sessionKey(key string) string {
+	return store.prefix + "sessions/" + key
+}
+
+func (store *SessionStore) lockKey(key string) string {
+	return store.prefix + "locks/" + key
+}
+
+// indexPrefix returns the prefix of the keys of the members of an index.
+// The index is escaped so that the prefix of one index never matches the
+// members of another.
+func (store *SessionStore) indexPrefix(index string) string {
+	return store.prefix + "indexes/" + url.PathEscape(index) + "/"
+}
+
+func (store *SessionStore) indexKey(index string, member string) string {
+	return store.indexPrefix(index) + url.PathEscape(member)
+}
+
+// expiresAt returns the expiry of a record that expires after the duration
+func (store *SessionStore) expiresAt(exp time.Duration) int64 {
+	if exp <= 0 {
+		return 0
+	}
+	return store.Clock.Now().Add(exp).UnixMilli()
+}
+
+// expired returns true if the record expires and its expiry has passed
+func (store *SessionStore) expired(r record) bool {
+	return r.expiresAt != 0 && r.expiresAt <= store.Clock.Now().UnixMilli()
+}
+
+// leaseTTL returns the TTL in seconds of a lease that outlives the duration
+func leaseTTL(exp time.Duration) int64 {
+	return int64((exp + time.Second - 1) / time.Second)
+}
+
+// encodeRecord encodes the record as its expiry followed by its value
+func encodeRecord(r record) string {
+	b := make([]byte, 8, 8+len(r.value))
+	binary.BigEndian.PutUint64(b, uint64(r.expiresAt))
+	return string(append(b, r.value...))
+}
+
+func decodeRecord(b []byte) (record, error) {
+	if len(b) < 8 {
+		return record{}, errors.New("invalid etcd record")
+	}
+	return record{
+		expiresAt: int64(binary.BigEndian.Uint64(b[:8])),
+		value:     b[8:],
+	}, nil
+}
@@ -0,0 +1,236 @@
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var _ = Describe("Etcd SessionStore Tests", func() {
+	var keyPrefix string
+	var stores []*SessionStore
+	var now time.Time
+	var testCount int
+
+	BeforeEach(func() {
+		// Every test writes below its own prefix of the shared etcd server
+		testCount++
+		keyPrefix = fmt.Sprintf("/oauth2-proxy-test-%d/", testCount)
+		stores = nil
+		now = time.Now()
+	})
+
+	JustAfterEach(func() {
+		for _, store := range stores {
+			Expect(store.Close()).To(Succeed())
+		}
+	})
+
+	etcdOptions := func() options.EtcdStoreOptions {
+		return options.EtcdStoreOptions{
+			Endpoints:   []string{etcdEndpoint},
+			KeyPrefix:   keyPrefix,
+			DialTimeout: 5 * time.Second,
+		}
+	}
+
+	tests.RunSessionStoreTests(
+		func(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessionsapi.SessionStore, error) {
+			opts.Type = options.EtcdSessionStoreType
+			opts.Etcd = etcdOptions()
+
+			ss, err := NewEtcdSessionStore(opts, cookieOpts)
+			if err != nil {
+				return nil, err
+			}
+			// Capture the store so that we can control its clock and close it
+			store := ss.(*persistence.Manager).Store.(*SessionStore)
+			store.Clock.Set(now)
+			stores = append(stores, store)
+			return ss, nil
+		},
+		func(d time.Duration) error {
+			now = now.Add(d)
+			for _, store := range stores {
+				if err := store.Clock.Add(d); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+
+	Context("with a store", func() {
+		var store *SessionStore
+		ctx := context.Background()
+
+		BeforeEach(func() {
+			client, err := NewEtcdClient(etcdOptions())
+			Expect(err).ToNot(HaveOccurred())
+			store = NewStore(client, keyPrefix)
+			store.Clock.Set(now)
+			stores = append(stores, store)
+		})
+
+		It("attaches sessions to a lease matching their expiration", func() {
+			Expect(store.Save(ctx, "key", []byte("value"), 90*time.Second)).To(Succeed())
+
+			resp, err := store.Client.Get(ctx, keyPrefix+"sessions/key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Kvs).To(HaveLen(1))
+
+			lease, err := store.Client.TimeToLive(ctx, clientv3.LeaseID(resp.Kvs[0].Lease))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lease.GrantedTTL).To(Equal(int64(90)))
+		})
+
+		It("revokes the lease of a session that is saved again", func() {
+			leaseOf := func(key string) clientv3.LeaseID {
+				resp, err := store.Client.Get(ctx, keyPrefix+key)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.Kvs).To(HaveLen(1))
+				return clientv3.LeaseID(resp.Kvs[0].Lease)
+			}
+
+			Expect(store.Save(ctx, "key", []byte("value"), time.Hour)).To(Succeed())
+			first := leaseOf("sessions/key")
+			Expect(store.Save(ctx, "key", []byte("value"), time.Hour)).To(Succeed())
+			Expect(leaseOf("sessions/key")).ToNot(Equal(first))
+
+			lease, err := store.Client.TimeToLive(ctx, first)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lease.TTL).To(Equal(int64(-1)))
+		})
+
+		It("keeps the lease of index members that are not extended", func() {
+			Expect(store.AddToIndex(ctx, "index", "first", time.Hour)).To(Succeed())
+			resp, err := store.Client.Get(ctx, keyPrefix+"indexes/index/first")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Kvs).To(HaveLen(1))
+			first := clientv3.LeaseID(resp.Kvs[0].Lease)
+
+			Expect(store.AddToIndex(ctx, "index", "second", time.Minute)).To(Succeed())
+			lease, err := store.Client.TimeToLive(ctx, first, clientv3.WithAttachedKeys())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lease.TTL).To(BeNumerically(">", 0))
+			Expect(lease.Keys).To(HaveLen(1))
+		})
+
+		It("does not attach sessions without an expiration to a lease", func() {
+			Expect(store.Save(ctx, "key", []byte("value"), 0)).To(Succeed())
+
+			resp, err := store.Client.Get(ctx, keyPrefix+"sessions/key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Kvs).To(HaveLen(1))
+			Expect(resp.Kvs[0].Lease).To(BeZero())
+
+			Expect(store.Clock.Add(24 * time.Hour)).To(Succeed())
+			value, err := store.Load(ctx, "key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal([]byte("value")))
+		})
+
+		It("does not load expired sessions", func() {
+			Expect(store.Save(ctx, "key", []byte("value"), time.Minute)).To(Succeed())
+			value, err := store.Load(ctx, "key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal([]byte("value")))
+
+			Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+			_, err = store.Load(ctx, "key")
+			Expect(err).To(MatchError("error loading etcd session: session not found"))
+		})
+
+		It("extends the expiration of all members of an index", func() {
+			Expect(store.AddToIndex(ctx, "index", "first", time.Minute)).To(Succeed())
+			Expect(store.AddToIndex(ctx, "index", "second", time.Hour)).To(Succeed())
+
+			Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+			members, err := store.LoadIndex(ctx, "index")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(members).To(ConsistOf("first", "second"))
+		})
+
+		It("keeps indexes with a common prefix apart", func() {
+			Expect(store.AddToIndex(ctx, "user", "first", time.Hour)).To(Succeed())
+			Expect(store.AddToIndex(ctx, "user/other", "second", time.Hour)).To(Succeed())
+
+			members, err := store.LoadIndex(ctx, "user")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(members).To(ConsistOf("first"))
+		})
+
+		It("verifies the connection", func() {
+			Expect(store.VerifyConnection(ctx)).To(Succeed())
+		})
+
+		Context("with a lock", func() {
+			var lock, other sessionsapi.Lock
+
+			BeforeEach(func() {
+				lock = store.Lock("ticket")
+				other = store.Lock("ticket")
+				Expect(lock.Obtain(ctx, time.Minute)).To(Succeed())
+			})
+
+			It("cannot be obtained by another holder", func() {
+				Expect(other.Obtain(ctx, time.Minute)).To(MatchError(sessionsapi.ErrLockNotObtained))
+				Expect(other.Release(ctx)).To(MatchError(sessionsapi.ErrNotLocked))
+				Expect(other.Refresh(ctx, time.Minute)).To(MatchError(sessionsapi.ErrNotLocked))
+			})
+
+			It("can be obtained by another holder once released", func() {
+				Expect(lock.Release(ctx)).To(Succeed())
+				Expect(other.Obtain(ctx, time.Minute)).To(Succeed())
+			})
+
+			It("can be obtained by another holder once expired", func() {
+				Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+				Expect(other.Obtain(ctx, time.Minute)).To(Succeed())
+				Expect(lock.Release(ctx)).To(MatchError(sessionsapi.ErrNotLocked))
+			})
+
+			It("is extended when refreshed", func() {
+				Expect(lock.Refresh(ctx, 5*time.Minute)).To(Succeed())
+				Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+
+				locked, err := other.Peek(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(locked).To(BeTrue())
+				Expect(other.Obtain(ctx, time.Minute)).To(MatchError(sessionsapi.ErrLockNotObtained))
+			})
+		})
+	})
+
+	Context("NewEtcdClient", func() {
+		It("requires an endpoint", func() {
+			_, err := NewEtcdClient(options.EtcdStoreOptions{})
+			Expect(err).To(MatchError("no etcd endpoints configured"))
+		})
+
+		It("fails with a missing CA", func() {
+			_, err := NewEtcdClient(options.EtcdStoreOptions{
+				Endpoints: []string{"https://127.0.0.1:2379"},
+				CAPath:    "/does/not/exist/ca.pem",
+			})
+			Expect(err).To(MatchError(ContainSubstring("failed to load \"/does/not/exist/ca.pem\"")))
+		})
+
+		It("fails with a missing client certificate", func() {
+			_, err := NewEtcdClient(options.EtcdStoreOptions{
+				Endpoints: []string{"https://127.0.0.1:2379"},
+				CertPath:  "/does/not/exist/cert.pem",
+				KeyPath:   "/does/not/exist/key.pem",
+			})
+			Expect(err).To(MatchError(ContainSubstring("failed to load etcd client certificate")))
+		})
+	})
+})
@@ -0,0 +1,53 @@
+package etcd
+
+import (
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+// etcdEndpoint is the client URL of the embedded etcd server of the suite
+var etcdEndpoint string
+
+func TestEtcdSuite(t *testing.T) {
+	logger.SetOutput(GinkgoWriter)
+	logger.SetErrOutput(GinkgoWriter)
+
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Etcd SessionStore")
+}
+
+var _ = BeforeSuite(func() {
+	clientURL := url.URL{Scheme: "http", Host: freeAddress()}
+	peerURL := url.URL{Scheme: "http", Host: freeAddress()}
+
+	cfg := embed.NewConfig()
+	cfg.Dir = GinkgoT().TempDir()
+	cfg.LogLevel = "error"
+	cfg.ListenClientUrls = []url.URL{clientURL}
+	cfg.AdvertiseClientUrls = []url.URL{clientURL}
+	cfg.ListenPeerUrls = []url.URL{peerURL}
+	cfg.AdvertisePeerUrls = []url.URL{peerURL}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+
+	server, err := embed.StartEtcd(cfg)
+	Expect(err).ToNot(HaveOccurred())
+	DeferCleanup(server.Close)
+
+	Eventually(server.Server.ReadyNotify(), 30*time.Second).Should(BeClosed())
+	etcdEndpoint = clientURL.String()
+})
+
+// freeAddress returns a local address with a port that is not in use
+func freeAddress() string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ToNot(HaveOccurred())
+	defer listener.Close()
+	return listener.Addr().String()
+}
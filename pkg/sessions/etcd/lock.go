@@ -0,0 +1,93 @@
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// The locks of the sessions are held in etcd keys attached to leases, so
+// that etcd removes the lock of a crashed holder. Changes to a lock key are
+// made in transactions conditional on the revision the key was read at, so
+// that the lock holds across replicas.
+var _ persistence.LockStore = (*SessionStore)(nil)
+
+// InsertLock writes the token to the lock key unless it holds a lock that
+// has not expired.
+func (store *SessionStore) InsertLock(ctx context.Context, key, token string, expiration time.Duration) (bool, error) {
+	held, revision, err := store.get(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("error obtaining etcd session lock: %v", err)
+	}
+	if held != nil {
+		return false, nil
+	}
+
+	ok, err := store.putLock(ctx, key, revision, token, expiration)
+	if err != nil {
+		return false, fmt.Errorf("error obtaining etcd session lock: %v", err)
+	}
+	return ok, nil
+}
+
+// ExtendLock writes the token to the lock key again, attached to a new lease.
+func (store *SessionStore) ExtendLock(ctx context.Context, key, token string, expiration time.Duration) (bool, error) {
+	held, revision, err := store.get(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("error refreshing etcd session lock: %v", err)
+	}
+	if held == nil || string(held.value) != token {
+		return false, nil
+	}
+
+	ok, err := store.putLock(ctx, key, revision, token, expiration)
+	if err != nil {
+		return false, fmt.Errorf("error refreshing etcd session lock: %v", err)
+	}
+	return ok, nil
+}
+
+// IsLocked reads whether the lock key exists and has not expired.
+func (store *SessionStore) IsLocked(ctx context.Context, key string) (bool, error) {
+	held, _, err := store.get(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("error reading etcd session lock: %v", err)
+	}
+	return held != nil, nil
+}
+
+// DeleteLock deletes the lock key if it still holds the token.
+func (store *SessionStore) DeleteLock(ctx context.Context, key, token string) (bool, error) {
+	held, revision, err := store.get(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("error releasing etcd session lock: %v", err)
+	}
+	if held == nil || string(held.value) != token {
+		return false, nil
+	}
+
+	resp, err := store.Client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", revision)).
+		Then(clientv3.OpDelete(key)).
+		Commit()
+	if err != nil {
+		return false, fmt.Errorf("error releasing etcd session lock: %v", err)
+	}
+	return resp.Succeeded, nil
+}
+
+// putLock writes the token to the lock key, unless the key was modified
+// since the revision it was read at
+func (store *SessionStore) putLock(ctx context.Context, key string, revision int64, token string, expiration time.Duration) (bool, error) {
+	lease, err := store.grant(ctx, expiration)
+	if err != nil {
+		return false, err
+	}
+
+	op := store.putOp(key, record{value: []byte(token), expiresAt: store.expiresAt(expiration)}, lease)
+	return store.commit(ctx, lease,
+		[]clientv3.Cmp{clientv3.Compare(clientv3.ModRevision(key), "=", revision)}, op)
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+)
+
+// The locks of the sessions are held in a map guarded by the lock mutex of
+// the store. Locks are never saved to the snapshot.
+var _ persistence.LockStore = (*SessionStore)(nil)
+
+// heldLock records the holder of a lock and when the lock expires
+type heldLock struct {
+	token     string
+	expiresAt time.Time
+}
+
+// InsertLock records the token as the holder of the key unless the lock of
+// another holder has not expired.
+func (store *SessionStore) InsertLock(_ context.Context, key, token string, expiration time.Duration) (bool, error) {
+	store.lockMu.Lock()
+	defer store.lockMu.Unlock()
+
+	if held, ok := store.locks[key]; ok && !store.expired(held.expiresAt) {
+		return false, nil
+	}
+	store.locks[key] = heldLock{token: token, expiresAt: store.Clock.Now().Add(expiration)}
+	return true, nil
+}
+
+// ExtendLock moves the expiry of the lock of the token.
+func (store *SessionStore) ExtendLock(_ context.Context, key, token string, expiration time.Duration) (bool, error) {
+	store.lockMu.Lock()
+	defer store.lockMu.Unlock()
+
+	if !store.heldWith(key, token) {
+		return false, nil
+	}
+	store.locks[key] = heldLock{token: token, expiresAt: store.Clock.Now().Add(expiration)}
+	return true, nil
+}
+
+// IsLocked looks up whether the key has a lock that has not expired.
+func (store *SessionStore) IsLocked(_ context.Context, key string) (bool, error) {
+	store.lockMu.Lock()
+	defer store.lockMu.Unlock()
+
+	held, ok := store.locks[key]
+	return ok && !store.expired(held.expiresAt), nil
+}
+
+// DeleteLock forgets the lock of the token.
+func (store *SessionStore) DeleteLock(_ context.Context, key, token string) (bool, error) {
+	store.lockMu.Lock()
+	defer store.lockMu.Unlock()
+
+	if !store.heldWith(key, token) {
+		return false, nil
+	}
+	delete(store.locks, key)
+	return true, nil
+}
+
+// heldWith returns true if the key has a lock held with the token that has
+// not expired. The lock mutex must be held.
+func (store *SessionStore) heldWith(key, token string) bool {
+	held, ok := store.locks[key]
+	return ok && held.token == token && !store.expired(held.expiresAt)
+}
@@ -0,0 +1,342 @@
+package memory
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+)
+
+const (
+	// shardCount is the number of independently locked shards the sessions
+	// are spread over, to reduce lock contention between requests
+	shardCount = 16
+
+	// reapInterval is how often expired sessions, indexes and locks are
+	// removed from memory
+	reapInterval = time.Minute
+)
+
+// SessionStore is an implementation of the persistence.Store
+// interface that stores sessions in the memory of the process.
+// Sessions are not shared between replicas and are lost on restart, unless
+// a snapshot path is configured.
+type SessionStore struct {
+	// Clock is used to expire sessions, indexes and locks
+	Clock clock.Clock
+
+	shards [shardCount]*shard
+
+	// indexes holds the expiry of the members of each index and memberOf
+	// the indexes of each member, so that a member is removed from its
+	// indexes when its entry is removed
+	indexMu  sync.Mutex
+	indexes  map[string]map[string]time.Time
+	memberOf map[string]map[string]struct{}
+
+	lockMu sync.Mutex
+	locks  map[string]heldLock
+
+	snapshotPath string
+	reaper       persistence.Reaper
+}
+
+// entry is a value stored in a shard. A zero expiresAt never expires.
+type entry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// shard holds part of the sessions, ordered from most to least recently used
+type shard struct {
+	mu       sync.Mutex
+	items    map[string]*list.Element
+	lru      *list.List
+	capacity int
+}
+
+// NewMemorySessionStore initialises a new instance of the SessionStore,
+// restores the snapshot of a previous run, starts removing expired sessions
+// in the background and wraps it in a persistence.Manager
+func NewMemorySessionStore(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessions.SessionStore, error) {
+	store, err := NewStore(opts.Memory)
+	if err != nil {
+		return nil, err
+	}
+	store.StartReaper(reapInterval)
+
+	return persistence.NewManager(store, cookieOpts), nil
+}
+
+// NewStore creates an empty store, or restores the store from its snapshot
+// when a snapshot path is configured and a snapshot exists
+func NewStore(opts options.MemoryStoreOptions) (*SessionStore, error) {
+	store := &SessionStore{
+		indexes:      map[string]map[string]time.Time{},
+		memberOf:     map[string]map[string]struct{}{},
+		locks:        map[string]heldLock{},
+		snapshotPath: opts.SnapshotPath,
+	}
+
+	// Spread the capacity over the shards, rounding up so that the store
+	// holds at least the configured number of entries
+	capacity := 0
+	if opts.Capacity > 0 {
+		capacity = (opts.Capacity + shardCount - 1) / shardCount
+	}
+	for i := range store.shards {
+		store.shards[i] = &shard{
+			items:    map[string]*list.Element{},
+			lru:      list.New(),
+			capacity: capacity,
+		}
+	}
+
+	if store.snapshotPath != "" {
+		if err := store.restore(); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// Save stores the value under the key, replacing any existing value.
+// When the shard of the key is full, the least recently used entry is
+// removed.
+func (store *SessionStore) Save(_ context.Context, key string, value []byte, exp time.Duration) error {
+	store.set(key, append([]byte{}, value...), store.expiresAt(exp))
+	return nil
+}
+
+// Load returns a copy of the value of the entry of the key and marks the
+// entry as the most recently used. An expired entry is removed instead.
+func (store *SessionStore) Load(_ context.Context, key string) ([]byte, error) {
+	s := store.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.items[key]
+	if !ok {
+		return nil, errors.New("error loading memory session: session not found")
+	}
+	e := elem.Value.(*entry)
+	if store.expired(e.expiresAt) {
+		store.remove(s, elem)
+		return nil, errors.New("error loading memory session: session not found")
+	}
+	s.lru.MoveToFront(elem)
+	return append([]byte{}, e.value...), nil
+}
+
+// Clear removes the entry of the key from its shard and the key from the
+// indexes it is a member of
+func (store *SessionStore) Clear(_ context.Context, key string) error {
+	s := store.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.items[key]; ok {
+		store.remove(s, elem)
+	}
+	return nil
+}
+
+// Lock creates a lock on the session held in the memory of the store
+func (store *SessionStore) Lock(key string) sessions.Lock {
+	return persistence.NewTokenLock(store, key)
+}
+
+// VerifyConnection always succeeds as there is no connection to verify
+func (store *SessionStore) VerifyConnection(_ context.Context) error {
+	return nil
+}
+
+// AddToIndex records the member in the map of the index and extends the
+// expiry of the other members to at least the given duration.
+// The member is removed from the index when the entry stored under the
+// member is removed, so the indexes do not outgrow the capacity of the store.
+func (store *SessionStore) AddToIndex(_ context.Context, index string, member string, exp time.Duration) error {
+	expiresAt := store.expiresAt(exp)
+
+	store.indexMu.Lock()
+	defer store.indexMu.Unlock()
+
+	store.addMember(index, member, expiresAt)
+	members := store.indexes[index]
+	for m, memberExpiresAt := range members {
+		if !memberExpiresAt.IsZero() && (expiresAt.IsZero() || memberExpiresAt.Before(expiresAt)) {
+			members[m] = expiresAt
+		}
+	}
+	return nil
+}
+
+// LoadIndex lists the members of the index that have not expired
+func (store *SessionStore) LoadIndex(_ context.Context, index string) ([]string, error) {
+	store.indexMu.Lock()
+	defer store.indexMu.Unlock()
+
+	members := []string{}
+	for member, expiresAt := range store.indexes[index] {
+		if !store.expired(expiresAt) {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+// RemoveFromIndex deletes the members from the map of the index, and the
+// index once it is empty
+func (store *SessionStore) RemoveFromIndex(_ context.Context, index string, members ...string) error {
+	store.indexMu.Lock()
+	defer store.indexMu.Unlock()
+
+	for _, member := range members {
+		store.removeMember(index, member)
+	}
+	return nil
+}
+
+// StartReaper removes expired sessions, indexes and locks every interval
+// until the store is closed.
+// Expired entries are never returned by the store, the reaper only reclaims
+// their memory.
+func (store *SessionStore) StartReaper(interval time.Duration) {
+	store.reaper.Start(interval, store.Reap)
+}
+
+// Reap removes expired sessions, indexes and locks
+func (store *SessionStore) Reap() {
+	for _, s := range store.shards {
+		s.mu.Lock()
+		for _, elem := range s.items {
+			if store.expired(elem.Value.(*entry).expiresAt) {
+				store.remove(s, elem)
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	store.indexMu.Lock()
+	for index, members := range store.indexes {
+		for member, expiresAt := range members {
+			if store.expired(expiresAt) {
+				store.removeMember(index, member)
+			}
+		}
+	}
+	store.indexMu.Unlock()
+
+	store.lockMu.Lock()
+	for key, held := range store.locks {
+		if store.expired(held.expiresAt) {
+			delete(store.locks, key)
+		}
+	}
+	store.lockMu.Unlock()
+}
+
+// Close stops the reaper and, when a snapshot path is configured, saves the
+// sessions to the snapshot so that they can be restored on the next start
+func (store *SessionStore) Close() error {
+	store.reaper.Stop()
+	if store.snapshotPath == "" {
+		return nil
+	}
+	if err := store.snapshot(); err != nil {
+		return err
+	}
+	logger.Printf("Saved memory sessions to %s", store.snapshotPath)
+	return nil
+}
+
+// set stores the entry in its shard as the most recently used entry
+func (store *SessionStore) set(key string, value []byte, expiresAt time.Time) {
+	s := store.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.items[key]; ok {
+		e := elem.Value.(*entry)
+		e.value = value
+		e.expiresAt = expiresAt
+		s.lru.MoveToFront(elem)
+		return
+	}
+
+	s.items[key] = s.lru.PushFront(&entry{key: key, value: value, expiresAt: expiresAt})
+	for s.capacity > 0 && s.lru.Len() > s.capacity {
+		store.remove(s, s.lru.Back())
+	}
+}
+
+// shard returns the shard the key is stored in
+func (store *SessionStore) shard(key string) *shard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return store.shards[h.Sum32()%shardCount]
+}
+
+// expiresAt returns the expiry of an entry that expires after the duration.
+// An entry that never expires has a zero expiry.
+func (store *SessionStore) expiresAt(exp time.Duration) time.Time {
+	if exp <= 0 {
+		return time.Time{}
+	}
+	return store.Clock.Now().Add(exp)
+}
+
+// expired returns true if the expiry is set and has passed
+func (store *SessionStore) expired(expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && !store.Clock.Now().Before(expiresAt)
+}
+
+// remove removes an element from the shard and its key from the indexes it
+// is a member of. The shard must be locked.
+func (store *SessionStore) remove(s *shard, elem *list.Element) {
+	key := elem.Value.(*entry).key
+	s.lru.Remove(elem)
+	delete(s.items, key)
+
+	store.indexMu.Lock()
+	defer store.indexMu.Unlock()
+	for index := range store.memberOf[key] {
+		store.removeMember(index, key)
+	}
+}
+
+// addMember records the member of the index. The index mutex must be held.
+func (store *SessionStore) addMember(index, member string, expiresAt time.Time) {
+	if _, ok := store.indexes[index]; !ok {
+		store.indexes[index] = map[string]time.Time{}
+	}
+	store.indexes[index][member] = expiresAt
+
+	if _, ok := store.memberOf[member]; !ok {
+		store.memberOf[member] = map[string]struct{}{}
+	}
+	store.memberOf[member][index] = struct{}{}
+}
+
+// removeMember forgets the member of the index, and the index once it is
+// empty. The index mutex must be held.
+func (store *SessionStore) removeMember(index, member string) {
+	delete(store.indexes[index], member)
+	if len(store.indexes[index]) == 0 {
+		delete(store.indexes, index)
+	}
+
+	delete(store.memberOf[member], index)
+	if len(store.memberOf[member]) == 0 {
+		delete(store.memberOf, member)
+	}
+}
@@ -0,0 +1,275 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Memory SessionStore Tests", func() {
+	var store *SessionStore
+
+	BeforeEach(func() {
+		store = nil
+	})
+
+	JustAfterEach(func() {
+		if store != nil {
+			Expect(store.Close()).To(Succeed())
+		}
+	})
+
+	tests.RunSessionStoreTests(
+		func(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessionsapi.SessionStore, error) {
+			opts.Type = options.MemorySessionStoreType
+			opts.Memory = options.MemoryStoreOptions{Capacity: 1000}
+
+			ss, err := NewMemorySessionStore(opts, cookieOpts)
+			if err != nil {
+				return nil, err
+			}
+
+			// Share the sessions between the stores created within a test,
+			// as the stores of other types share their database
+			manager := ss.(*persistence.Manager)
+			if store == nil {
+				store = manager.Store.(*SessionStore)
+				store.Clock.Set(time.Now())
+				return ss, nil
+			}
+			if err := manager.Store.(*SessionStore).Close(); err != nil {
+				return nil, err
+			}
+			manager.Store = store
+			return ss, nil
+		},
+		func(d time.Duration) error {
+			return store.Clock.Add(d)
+		},
+	)
+
+	Context("with a store", func() {
+		ctx := context.Background()
+
+		BeforeEach(func() {
+			var err error
+			store, err = NewStore(options.MemoryStoreOptions{Capacity: 2 * shardCount})
+			Expect(err).ToNot(HaveOccurred())
+			store.Clock.Set(time.Now())
+		})
+
+		It("does not load expired sessions", func() {
+			Expect(store.Save(ctx, "key", []byte("value"), time.Minute)).To(Succeed())
+			value, err := store.Load(ctx, "key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal([]byte("value")))
+
+			Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+			_, err = store.Load(ctx, "key")
+			Expect(err).To(MatchError("error loading memory session: session not found"))
+		})
+
+		It("keeps sessions without an expiration", func() {
+			Expect(store.Save(ctx, "key", []byte("value"), 0)).To(Succeed())
+			Expect(store.Clock.Add(24 * time.Hour)).To(Succeed())
+			store.Reap()
+
+			value, err := store.Load(ctx, "key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal([]byte("value")))
+		})
+
+		It("reaps expired sessions, indexes and locks", func() {
+			Expect(store.Save(ctx, "expired", []byte("value"), time.Minute)).To(Succeed())
+			Expect(store.Save(ctx, "current", []byte("value"), time.Hour)).To(Succeed())
+			Expect(store.AddToIndex(ctx, "index", "expired", time.Minute)).To(Succeed())
+			Expect(store.Lock("expired").Obtain(ctx, time.Minute)).To(Succeed())
+
+			Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+			store.Reap()
+
+			Expect(store.len()).To(Equal(1))
+			Expect(store.indexes).To(BeEmpty())
+			Expect(store.locks).To(BeEmpty())
+		})
+
+		It("removes the least recently used sessions when full", func() {
+			// Keys of the same shard, more than the shard can hold
+			keys := keysOfShard(store, store.shard("first"), 3)
+			Expect(store.Save(ctx, keys[0], []byte("first"), time.Hour)).To(Succeed())
+			Expect(store.Save(ctx, keys[1], []byte("second"), time.Hour)).To(Succeed())
+
+			// Use the first session, leaving the second as least recently used
+			_, err := store.Load(ctx, keys[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(store.Save(ctx, keys[2], []byte("third"), time.Hour)).To(Succeed())
+
+			_, err = store.Load(ctx, keys[0])
+			Expect(err).ToNot(HaveOccurred())
+			_, err = store.Load(ctx, keys[1])
+			Expect(err).To(MatchError("error loading memory session: session not found"))
+			_, err = store.Load(ctx, keys[2])
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("removes the least recently used sessions from their indexes", func() {
+			keys := keysOfShard(store, store.shard("first"), 3)
+			for _, key := range keys {
+				Expect(store.Save(ctx, key, []byte("value"), time.Hour)).To(Succeed())
+				Expect(store.AddToIndex(ctx, "index", key, time.Hour)).To(Succeed())
+			}
+
+			members, err := store.LoadIndex(ctx, "index")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(members).To(ConsistOf(keys[1], keys[2]))
+			Expect(store.memberOf).ToNot(HaveKey(keys[0]))
+
+			Expect(store.Clear(ctx, keys[1])).To(Succeed())
+			members, err = store.LoadIndex(ctx, "index")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(members).To(ConsistOf(keys[2]))
+		})
+
+		It("does not share values with callers", func() {
+			value := []byte("value")
+			Expect(store.Save(ctx, "key", value, time.Hour)).To(Succeed())
+			value[0] = 'V'
+
+			loaded, err := store.Load(ctx, "key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loaded).To(Equal([]byte("value")))
+		})
+
+		It("extends the expiration of all members of an index", func() {
+			Expect(store.AddToIndex(ctx, "index", "first", time.Minute)).To(Succeed())
+			Expect(store.AddToIndex(ctx, "index", "second", time.Hour)).To(Succeed())
+
+			Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+			members, err := store.LoadIndex(ctx, "index")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(members).To(ConsistOf("first", "second"))
+		})
+
+		Context("with a lock", func() {
+			var lock, other sessionsapi.Lock
+
+			BeforeEach(func() {
+				lock = store.Lock("ticket")
+				other = store.Lock("ticket")
+				Expect(lock.Obtain(ctx, time.Minute)).To(Succeed())
+			})
+
+			It("cannot be obtained by another holder", func() {
+				Expect(other.Obtain(ctx, time.Minute)).To(MatchError(sessionsapi.ErrLockNotObtained))
+				Expect(other.Release(ctx)).To(MatchError(sessionsapi.ErrNotLocked))
+				Expect(other.Refresh(ctx, time.Minute)).To(MatchError(sessionsapi.ErrNotLocked))
+			})
+
+			It("can be obtained by another holder once released", func() {
+				Expect(lock.Release(ctx)).To(Succeed())
+				Expect(other.Obtain(ctx, time.Minute)).To(Succeed())
+			})
+
+			It("can be obtained by another holder once expired", func() {
+				Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+				Expect(other.Obtain(ctx, time.Minute)).To(Succeed())
+				Expect(lock.Release(ctx)).To(MatchError(sessionsapi.ErrNotLocked))
+			})
+
+			It("is extended when refreshed", func() {
+				Expect(lock.Refresh(ctx, 5*time.Minute)).To(Succeed())
+				Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+
+				locked, err := other.Peek(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(locked).To(BeTrue())
+				Expect(other.Obtain(ctx, time.Minute)).To(MatchError(sessionsapi.ErrLockNotObtained))
+			})
+		})
+	})
+
+	Context("with a snapshot path", func() {
+		var snapshotPath string
+		ctx := context.Background()
+
+		BeforeEach(func() {
+			snapshotPath = filepath.Join(GinkgoT().TempDir(), "sessions.snapshot")
+
+			previous, err := NewStore(options.MemoryStoreOptions{SnapshotPath: snapshotPath})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(previous.Save(ctx, "session", []byte("value"), time.Hour)).To(Succeed())
+			Expect(previous.Save(ctx, "expired", []byte("value"), time.Nanosecond)).To(Succeed())
+			Expect(previous.AddToIndex(ctx, "index", "session", time.Hour)).To(Succeed())
+			Expect(previous.Lock("session").Obtain(ctx, time.Hour)).To(Succeed())
+			Expect(previous.Close()).To(Succeed())
+			Expect(snapshotPath).To(BeAnExistingFile())
+		})
+
+		It("restores the sessions and indexes saved on close", func() {
+			var err error
+			store, err = NewStore(options.MemoryStoreOptions{SnapshotPath: snapshotPath})
+			Expect(err).ToNot(HaveOccurred())
+
+			value, err := store.Load(ctx, "session")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal([]byte("value")))
+			_, err = store.Load(ctx, "expired")
+			Expect(err).To(HaveOccurred())
+
+			members, err := store.LoadIndex(ctx, "index")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(members).To(ConsistOf("session"))
+
+			locked, err := store.Lock("session").Peek(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(locked).To(BeFalse())
+		})
+
+		It("removes the snapshot once restored", func() {
+			var err error
+			store, err = NewStore(options.MemoryStoreOptions{SnapshotPath: snapshotPath})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snapshotPath).ToNot(BeAnExistingFile())
+		})
+
+		It("fails to start with a corrupt snapshot", func() {
+			Expect(os.WriteFile(snapshotPath, []byte("corrupt"), 0600)).To(Succeed())
+
+			_, err := NewStore(options.MemoryStoreOptions{SnapshotPath: snapshotPath})
+			Expect(err).To(MatchError(ContainSubstring("error reading memory session snapshot")))
+		})
+	})
+})
+
+// len returns the number of entries in the store, including expired entries
+// that have not been removed yet
+func (store *SessionStore) len() int {
+	n := 0
+	for _, s := range store.shards {
+		s.mu.Lock()
+		n += s.lru.Len()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// keysOfShard returns n keys that are stored in the shard
+func keysOfShard(store *SessionStore, s *shard, n int) []string {
+	keys := []string{}
+	for i := 0; len(keys) < n; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if store.shard(key) == s {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
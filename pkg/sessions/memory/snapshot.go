@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// snapshot is the content of a snapshot file.
+// Session values are the encrypted sessions saved by the persistence.Manager,
+// the snapshot does not contain any session in plain text.
+type snapshot struct {
+	Sessions []snapshotSession
+	Indexes  []snapshotIndexMember
+}
+
+type snapshotSession struct {
+	Key       string
+	Value     []byte
+	ExpiresAt time.Time
+}
+
+type snapshotIndexMember struct {
+	Index     string
+	Member    string
+	ExpiresAt time.Time
+}
+
+// snapshot writes the sessions and indexes that have not expired to the
+// snapshot file. Locks are not saved, they do not outlive the process.
+// The snapshot is written to a temporary file first so that an interrupted
+// write does not leave a corrupt snapshot behind.
+func (store *SessionStore) snapshot() error {
+	var snap snapshot
+	for _, s := range store.shards {
+		s.mu.Lock()
+		// Oldest first so that restoring the snapshot preserves the LRU order
+		for elem := s.lru.Back(); elem != nil; elem = elem.Prev() {
+			e := elem.Value.(*entry)
+			if !store.expired(e.expiresAt) {
+				snap.Sessions = append(snap.Sessions, snapshotSession{Key: e.key, Value: e.value, ExpiresAt: e.expiresAt})
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	store.indexMu.Lock()
+	for index, members := range store.indexes {
+		for member, expiresAt := range members {
+			if !store.expired(expiresAt) {
+				snap.Indexes = append(snap.Indexes, snapshotIndexMember{Index: index, Member: member, ExpiresAt: expiresAt})
+			}
+		}
+	}
+	store.indexMu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(store.snapshotPath), filepath.Base(store.snapshotPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating memory session snapshot: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(&snap); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing memory session snapshot: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing memory session snapshot: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), store.snapshotPath); err != nil {
+		return fmt.Errorf("error writing memory session snapshot: %v", err)
+	}
+	return nil
+}
+
+// restore loads the sessions and indexes of the snapshot file, skipping those
+// that expired while the proxy was not running.
+// The snapshot is removed once restored: if the process does not shut down
+// cleanly, sessions cleared since the start must not be restored from a
+// stale snapshot on the next start.
+func (store *SessionStore) restore() error {
+	f, err := os.Open(store.snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error opening memory session snapshot: %v", err)
+	}
+	defer f.Close()
+
+	var snap snapshot
+	if err := gob.NewDecoder(f).Decode(&snap); err != nil {
+		return fmt.Errorf("error reading memory session snapshot %s: %v", store.snapshotPath, err)
+	}
+
+	restored := 0
+	for _, s := range snap.Sessions {
+		if !store.expired(s.ExpiresAt) {
+			store.set(s.Key, s.Value, s.ExpiresAt)
+			restored++
+		}
+	}
+	for _, m := range snap.Indexes {
+		if store.expired(m.ExpiresAt) {
+			continue
+		}
+		store.addMember(m.Index, m.Member, m.ExpiresAt)
+	}
+
+	if err := os.Remove(store.snapshotPath); err != nil {
+		return fmt.Errorf("error removing restored memory session snapshot: %v", err)
+	}
+	logger.Printf("Restored %d memory sessions from %s", restored, store.snapshotPath)
+	return nil
+}
@@ -0,0 +1,181 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+)
+
+// ticketIDPattern matches the IDs created by newTicket
+var ticketIDPattern = regexp.MustCompile(`^(.+)-[0-9a-f]{32}$`)
+
+// infoKey returns the Store key of the session info recorded for a ticket
+func infoKey(ticketID string) string {
+	return ticketID + "-info"
+}
+
+// newSessionInfo builds the session info recorded alongside a session so that
+// it can be listed without the ticket secret held in the user's cookie.
+func (m *Manager) newSessionInfo(req *http.Request, ticketID string, s *sessions.SessionState) *sessions.SessionInfo {
+	expiresAt := time.Now().Add(m.Options.Expire)
+	info := &sessions.SessionInfo{
+		ID:         ticketID,
+		ProviderID: s.ProviderID,
+		User:       s.User,
+		Email:      s.Email,
+		Subject:    s.Subject,
+		SessionID:  s.SessionID,
+		CreatedAt:  s.CreatedAt,
+		IssuedAt:   s.IssueTime(),
+		ExpiresAt:  &expiresAt,
+		UserAgent:  req.UserAgent(),
+	}
+	if scope := middlewareapi.GetRequestScope(req); scope != nil {
+		info.IP = scope.ClientIP
+	}
+	return info
+}
+
+// saveInfo encrypts the session info with the cookie secret and saves it in
+// the Store with the same expiration as the session.
+func (m *Manager) saveInfo(ctx context.Context, w *sessionWriter, info *sessions.SessionInfo) error {
+	c, err := makeInfoCipher(m.Options.Secret)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("error encoding session info: %v", err)
+	}
+	ciphertext, err := c.Encrypt(data)
+	if err != nil {
+		return fmt.Errorf("error encrypting session info: %v", err)
+	}
+	return w.save(ctx, infoKey(info.ID), ciphertext, m.Options.Expire)
+}
+
+// loadInfo loads the session info recorded for a ticket
+func (m *Manager) loadInfo(ctx context.Context, ticketID string) (*sessions.SessionInfo, error) {
+	ciphertext, err := m.Store.Load(ctx, infoKey(ticketID))
+	if err != nil {
+		return nil, err
+	}
+
+	// Session info sealed with a previous cookie secret is sealed with the
+	// current secret the next time the session is saved.
+	var data []byte
+	for _, secret := range m.Options.Secrets() {
+		c, err := makeInfoCipher(secret)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = c.Decrypt(ciphertext); err == nil {
+			break
+		}
+	}
+	if data == nil {
+		return nil, errors.New("error decrypting session info")
+	}
+	info := &sessions.SessionInfo{}
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil, fmt.Errorf("error decoding session info: %v", err)
+	}
+	return info, nil
+}
+
+// makeInfoCipher makes an AES-GCM cipher out of a cookie secret
+func makeInfoCipher(secret string) (encryption.Cipher, error) {
+	c, err := encryption.NewGCMCipher(encryption.SecretBytes(secret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to make an AES-GCM cipher from the cookie secret: %v", err)
+	}
+	return c, nil
+}
+
+// ListSessions returns the sessions of the user of the provider with the given
+// user name or email address. Sessions that have expired since they were
+// indexed are omitted.
+// It implements the sessions.SessionAdministrator interface.
+func (m *Manager) ListSessions(ctx context.Context, providerID, user string) ([]sessions.SessionInfo, error) {
+	tickets, err := m.Store.LoadIndex(ctx, indexKey(m.Options, userIndexType, providerID, user))
+	if err != nil {
+		return nil, fmt.Errorf("error loading session index: %v", err)
+	}
+
+	infos := []sessions.SessionInfo{}
+	for _, ticketID := range tickets {
+		info, err := m.loadInfo(ctx, ticketID)
+		if err != nil {
+			continue
+		}
+		infos = append(infos, *info)
+	}
+	return infos, nil
+}
+
+// RevokeSession clears the session with the given ticket ID.
+// It implements the sessions.SessionAdministrator interface.
+func (m *Manager) RevokeSession(ctx context.Context, id string) error {
+	if match := ticketIDPattern.FindStringSubmatch(id); match == nil || match[1] != m.Options.Name {
+		return sessions.ErrSessionNotFound
+	}
+
+	info, err := m.loadInfo(ctx, id)
+	if err != nil {
+		return sessions.ErrSessionNotFound
+	}
+	return m.clearTicket(ctx, id, info)
+}
+
+// RevokeUserSessions clears all sessions of the user of the provider with the
+// given user name or email address.
+// It implements the sessions.SessionAdministrator interface.
+func (m *Manager) RevokeUserSessions(ctx context.Context, providerID, user string) error {
+	index := indexKey(m.Options, userIndexType, providerID, user)
+	tickets, err := m.Store.LoadIndex(ctx, index)
+	if err != nil {
+		return fmt.Errorf("error loading session index: %v", err)
+	}
+
+	for _, ticketID := range tickets {
+		// Tickets without session info are still cleared
+		info, _ := m.loadInfo(ctx, ticketID)
+		if err := m.clearTicket(ctx, ticketID, info); err != nil {
+			return err
+		}
+	}
+
+	if len(tickets) == 0 {
+		return nil
+	}
+	return m.Store.RemoveFromIndex(ctx, index, tickets...)
+}
+
+// clearTicket clears the session and session info stored for a ticket and
+// removes the ticket from the indexes recorded in the session info.
+func (m *Manager) clearTicket(ctx context.Context, ticketID string, info *sessions.SessionInfo) error {
+	if err := m.Store.Clear(ctx, ticketID); err != nil {
+		return fmt.Errorf("error clearing session: %v", err)
+	}
+	if err := m.Store.Clear(ctx, infoKey(ticketID)); err != nil {
+		return fmt.Errorf("error clearing session info: %v", err)
+	}
+	if info == nil {
+		return nil
+	}
+
+	for _, index := range infoIndexes(m.Options, info) {
+		if err := m.Store.RemoveFromIndex(ctx, index, ticketID); err != nil {
+			return fmt.Errorf("error removing session from index: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package persistence
+
+import (
+	"fmt"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+)
+
+const (
+	subjectIndexType   = "sub"
+	sessionIDIndexType = "sid"
+	userIndexType      = "user"
+)
+
+// indexKey builds the Store key of a secondary index that maps a session
+// attribute to the session tickets that share it.
+// The value is hashed so that user identifiers are not exposed in the keys
+// of the persistent store.
+func indexKey(cookieOpts *options.Cookie, indexType, providerID, value string) string {
+	return fmt.Sprintf("%s-%s-%s", cookieOpts.Name, indexType, encryption.HashKey(providerID, value))
+}
+
+// sessionIndexes returns the keys of all secondary indexes the session
+// should be recorded in.
+func sessionIndexes(cookieOpts *options.Cookie, s *sessions.SessionState) []string {
+	return indexes(cookieOpts, s.ProviderID, s.Subject, s.SessionID, s.User, s.Email)
+}
+
+// infoIndexes returns the keys of all secondary indexes the session described
+// by the session info is recorded in.
+func infoIndexes(cookieOpts *options.Cookie, info *sessions.SessionInfo) []string {
+	return indexes(cookieOpts, info.ProviderID, info.Subject, info.SessionID, info.User, info.Email)
+}
+
+// indexes returns the keys of the secondary indexes for the session
+// attributes. The user index holds the sessions of a provider under both the
+// user name and the email address.
+func indexes(cookieOpts *options.Cookie, providerID, subject, sessionID, user, email string) []string {
+	var keys []string
+	if subject != "" {
+		keys = append(keys, indexKey(cookieOpts, subjectIndexType, providerID, subject))
+	}
+	if sessionID != "" {
+		keys = append(keys, indexKey(cookieOpts, sessionIDIndexType, providerID, sessionID))
+	}
+	if user != "" {
+		keys = append(keys, indexKey(cookieOpts, userIndexType, providerID, user))
+	}
+	if email != "" && email != user {
+		keys = append(keys, indexKey(cookieOpts, userIndexType, providerID, email))
+	}
+	return keys
+}
@@ -16,4 +16,26 @@ type Store interface {
 	Clear(context.Context, string) error
 	Lock(key string) sessions.Lock
 	VerifyConnection(context.Context) error
+
+	// AddToIndex adds a member to the set stored under the index key.
+	// The expiration of the index is extended to at least the given duration.
+	AddToIndex(ctx context.Context, index string, member string, exp time.Duration) error
+	// LoadIndex returns the members of the set stored under the index key.
+	LoadIndex(ctx context.Context, index string) ([]string, error)
+	// RemoveFromIndex removes members from the set stored under the index key.
+	RemoveFromIndex(ctx context.Context, index string, members ...string) error
+}
+
+// Pipeliner is implemented by a Store that can send several writes in a
+// single round trip. The Manager uses it to write a session, its info and
+// its indexes together.
+type Pipeliner interface {
+	Pipeline() Pipeline
+}
+
+// Pipeline queues writes to a Store until Exec is called
+type Pipeline interface {
+	Save(ctx context.Context, key string, value []byte, exp time.Duration)
+	AddToIndex(ctx context.Context, index string, member string, exp time.Duration)
+	Exec(ctx context.Context) error
 }
@@ -0,0 +1,97 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+const (
+	// sessionLimitLockExpiration bounds how long a replica holds the lock on
+	// the sessions of a user while enforcing the concurrent session limit
+	sessionLimitLockExpiration = 5 * time.Second
+	// sessionLimitLockRetryInterval is the wait between attempts to obtain the
+	// lock on the sessions of a user
+	sessionLimitLockRetryInterval = 50 * time.Millisecond
+)
+
+// limitedUser returns the user whose sessions count towards the concurrent
+// session limit of the session
+func limitedUser(s *sessions.SessionState) string {
+	if s.User != "" {
+		return s.User
+	}
+	return s.Email
+}
+
+// lockUserSessions obtains the Store lock on the sessions of a user of the
+// provider so that the concurrent session limit is enforced atomically across
+// replicas.
+// Obtaining the lock is retried until it expires from any previous holder.
+func (m *Manager) lockUserSessions(ctx context.Context, providerID, user string) (sessions.Lock, error) {
+	lock := m.Store.Lock(indexKey(m.Options, userIndexType, providerID, user))
+	deadline := time.Now().Add(sessionLimitLockExpiration)
+	for {
+		err := lock.Obtain(ctx, sessionLimitLockExpiration)
+		if !errors.Is(err, sessions.ErrLockNotObtained) {
+			return lock, err
+		}
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(sessionLimitLockRetryInterval):
+		}
+	}
+}
+
+// enforceSessionLimit makes room for a new session of the user of the
+// provider within the concurrent session limit. Depending on the policy, the
+// oldest sessions of the user are evicted or sessions.ErrSessionLimitExceeded
+// is returned.
+// The lock on the sessions of the user must be held by the caller.
+func (m *Manager) enforceSessionLimit(req *http.Request, providerID, user string) error {
+	existing, err := m.ListSessions(req.Context(), providerID, user)
+	if err != nil {
+		return err
+	}
+	excess := len(existing) - m.MaxConcurrent + 1
+	if excess <= 0 {
+		return nil
+	}
+	if m.RejectOverLimit {
+		return fmt.Errorf("%w: user has %d active sessions", sessions.ErrSessionLimitExceeded, len(existing))
+	}
+
+	sort.Slice(existing, func(i, j int) bool {
+		return issuedBefore(existing[i], existing[j])
+	})
+	for i := range existing[:excess] {
+		info := existing[i]
+		if err := m.clearTicket(req.Context(), info.ID, &info); err != nil {
+			return fmt.Errorf("error evicting session: %v", err)
+		}
+		logger.PrintAuthf(user, req, logger.AuthSessionEvicted, "Evicted oldest session %s: concurrent session limit of %d reached", info.ID, m.MaxConcurrent)
+	}
+	return nil
+}
+
+// issuedBefore orders sessions by the time the user signed in, with sessions
+// of unknown age first. Unlike the creation time, the issue time is not reset
+// when a session is refreshed.
+func issuedBefore(a, b sessions.SessionInfo) bool {
+	aIssued, bIssued := a.IssueTime(), b.IssueTime()
+	if aIssued == nil || bIssued == nil {
+		return aIssued == nil && bIssued != nil
+	}
+	return aIssued.Before(*bIssued)
+}
@@ -0,0 +1,177 @@
+package persistence
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Concurrent Session Limit Tests", func() {
+	var manager *Manager
+
+	BeforeEach(func() {
+		manager = NewManager(tests.NewMockStore(), &options.Cookie{
+			Name:   "_oauth2_proxy",
+			Secret: "0123456789abcdefghijklmnopqrstuv",
+			Expire: time.Hour,
+		})
+		manager.MaxConcurrent = 2
+	})
+
+	saveSession := func(session *sessionsapi.SessionState, existing *http.Request) (*http.Request, error) {
+		saveReq := existing
+		if saveReq == nil {
+			saveReq = httptest.NewRequest("GET", "http://example.com/", nil)
+		}
+		saveReq = middlewareapi.AddRequestScope(saveReq, &middlewareapi.RequestScope{})
+		rw := httptest.NewRecorder()
+		if err := manager.Save(rw, saveReq, session); err != nil {
+			return nil, err
+		}
+
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		for _, cookie := range rw.Result().Cookies() {
+			req.AddCookie(cookie)
+		}
+		return req, nil
+	}
+
+	saveForProvider := func(providerID, user string, age time.Duration, existing *http.Request) (*http.Request, error) {
+		created := time.Now().Add(-age)
+		return saveSession(&sessionsapi.SessionState{
+			ProviderID:  providerID,
+			User:        user,
+			Email:       user + "@example.com",
+			AccessToken: "AccessToken",
+			CreatedAt:   &created,
+		}, existing)
+	}
+
+	save := func(user string, age time.Duration, existing *http.Request) (*http.Request, error) {
+		return saveForProvider("", user, age, existing)
+	}
+
+	expectLoaded := func(req *http.Request, loaded bool) {
+		_, err := manager.Load(req)
+		if loaded {
+			Expect(err).ToNot(HaveOccurred())
+		} else {
+			Expect(err).To(HaveOccurred())
+		}
+	}
+
+	Context("with the evict oldest policy", func() {
+		It("evicts the oldest session of the user", func() {
+			oldest, err := save("alice", 2*time.Minute, nil)
+			Expect(err).ToNot(HaveOccurred())
+			older, err := save("alice", time.Minute, nil)
+			Expect(err).ToNot(HaveOccurred())
+			other, err := save("bob", 3*time.Minute, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			newest, err := save("alice", 0, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			expectLoaded(oldest, false)
+			expectLoaded(older, true)
+			expectLoaded(newest, true)
+			expectLoaded(other, true)
+
+			infos, err := manager.ListSessions(newest.Context(), "", "alice")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(infos).To(HaveLen(2))
+		})
+
+		It("evicts the session that signed in first, even if it was refreshed since", func() {
+			issued := time.Now().Add(-2 * time.Minute)
+			session := &sessionsapi.SessionState{
+				User:        "alice",
+				Email:       "alice@example.com",
+				AccessToken: "AccessToken",
+				CreatedAt:   &issued,
+				IssuedAt:    &issued,
+			}
+			first, err := saveSession(session, nil)
+			Expect(err).ToNot(HaveOccurred())
+			second, err := save("alice", time.Minute, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			// A refresh resets the creation time of the first session
+			session.CreatedAtNow()
+			_, err = saveSession(session, first)
+			Expect(err).ToNot(HaveOccurred())
+
+			newest, err := save("alice", 0, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			expectLoaded(first, false)
+			expectLoaded(second, true)
+			expectLoaded(newest, true)
+		})
+
+		It("counts the sessions of each provider separately", func() {
+			first, err := saveForProvider("corp", "alice", 2*time.Minute, nil)
+			Expect(err).ToNot(HaveOccurred())
+			second, err := saveForProvider("corp", "alice", time.Minute, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			other, err := saveForProvider("other", "alice", 0, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			expectLoaded(first, true)
+			expectLoaded(second, true)
+			expectLoaded(other, true)
+		})
+
+		It("does not count a session that is saved again", func() {
+			first, err := save("alice", time.Minute, nil)
+			Expect(err).ToNot(HaveOccurred())
+			second, err := save("alice", 0, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = save("alice", time.Minute, first)
+			Expect(err).ToNot(HaveOccurred())
+
+			expectLoaded(first, true)
+			expectLoaded(second, true)
+		})
+	})
+
+	Context("with the reject policy", func() {
+		BeforeEach(func() {
+			manager.RejectOverLimit = true
+		})
+
+		It("rejects a new session over the limit", func() {
+			first, err := save("alice", 2*time.Minute, nil)
+			Expect(err).ToNot(HaveOccurred())
+			second, err := save("alice", time.Minute, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = save("alice", 0, nil)
+			Expect(err).To(MatchError(sessionsapi.ErrSessionLimitExceeded))
+
+			expectLoaded(first, true)
+			expectLoaded(second, true)
+		})
+
+		It("accepts a new session once a session has been cleared", func() {
+			first, err := save("alice", 2*time.Minute, nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = save("alice", time.Minute, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(manager.Clear(httptest.NewRecorder(), first)).To(Succeed())
+
+			_, err = save("alice", 0, nil)
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+})
@@ -3,11 +3,14 @@ package persistence
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 )
 
 // Manager wraps a Store and handles the implementation details of the
@@ -15,6 +18,13 @@ import (
 type Manager struct {
 	Store   Store
 	Options *options.Cookie
+
+	// MaxConcurrent limits the number of sessions each user may hold.
+	// Zero means no limit.
+	MaxConcurrent int
+	// RejectOverLimit rejects new sessions over the limit instead of evicting
+	// the oldest sessions of the user.
+	RejectOverLimit bool
 }
 
 // NewManager creates a Manager that can wrap a Store and manage the
@@ -36,24 +46,134 @@ func (m *Manager) Save(rw http.ResponseWriter, req *http.Request, s *sessions.Se
 
 	tckt, err := decodeTicketFromRequest(req, m.Options)
 	if err != nil {
-		tckt, err = newTicket(m.Options)
+		tckt, err = m.saveNewSession(req, s)
 		if err != nil {
-			return fmt.Errorf("error creating a session ticket: %v", err)
+			return err
 		}
+	} else if err := m.saveSession(req, tckt, s); err != nil {
+		return err
 	}
 
-	err = tckt.saveSession(s, func(key string, val []byte, exp time.Duration) error {
-		return m.Store.Save(req.Context(), key, val, exp)
+	// Clients that present the ticket as a bearer token do not keep cookies
+	if tckt.bearer {
+		return nil
+	}
+	return tckt.setCookie(rw, req, s)
+}
+
+// IssueToken saves the session under a new ticket and returns the ticket,
+// signed in the same way as the session cookie, as a bearer token.
+// Requests that present the token in the Authorization header load the
+// session as if they carried the session cookie, until the token expires
+// with the cookie expiry, counted from when the token is issued.
+// It implements the sessions.TokenIssuer interface.
+func (m *Manager) IssueToken(req *http.Request, s *sessions.SessionState) (string, error) {
+	s.CreatedAtNow()
+
+	tckt, err := m.saveNewSession(req, s)
+	if err != nil {
+		return "", err
+	}
+	return encryption.SignedValue(m.Options.Secret, m.Options.Name, []byte(tckt.encodeTicket()), *s.CreatedAt)
+}
+
+// saveNewSession saves the session under a new ticket
+func (m *Manager) saveNewSession(req *http.Request, s *sessions.SessionState) (*ticket, error) {
+	tckt, err := newTicket(m.Options)
+	if err != nil {
+		return nil, fmt.Errorf("error creating a session ticket: %v", err)
+	}
+
+	// A new ticket means a new session that counts towards the limit
+	if user := limitedUser(s); m.MaxConcurrent > 0 && user != "" {
+		lock, err := m.lockUserSessions(req.Context(), s.ProviderID, user)
+		if err != nil {
+			return nil, fmt.Errorf("error locking sessions of user: %v", err)
+		}
+		defer func() {
+			if err := lock.Release(req.Context()); err != nil {
+				logger.Errorf("unable to release lock on sessions of user: %v", err)
+			}
+		}()
+
+		if err := m.enforceSessionLimit(req, s.ProviderID, user); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := m.saveSession(req, tckt, s); err != nil {
+		return nil, err
+	}
+	return tckt, nil
+}
+
+// saveSession saves the session with its info and indexes under the ticket
+func (m *Manager) saveSession(req *http.Request, tckt *ticket, s *sessions.SessionState) error {
+	w := m.newSessionWriter()
+	err := tckt.saveSession(s, func(key string, val []byte, exp time.Duration) error {
+		return w.save(req.Context(), key, val, exp)
 	})
 	if err != nil {
 		return err
 	}
 
-	return tckt.setCookie(rw, req, s)
+	if err := m.saveInfo(req.Context(), w, m.newSessionInfo(req, tckt.id, s)); err != nil {
+		return fmt.Errorf("error saving session info: %v", err)
+	}
+
+	for _, index := range sessionIndexes(m.Options, s) {
+		if err := w.addToIndex(req.Context(), index, tckt.id, m.Options.Expire); err != nil {
+			return fmt.Errorf("error indexing session: %v", err)
+		}
+	}
+
+	if err := w.exec(req.Context()); err != nil {
+		return fmt.Errorf("error saving session: %v", err)
+	}
+	return nil
+}
+
+// sessionWriter writes the entries of a saved session to the Store. When the
+// Store is a Pipeliner, the writes are queued and sent together by exec.
+type sessionWriter struct {
+	store    Store
+	pipeline Pipeline
+}
+
+func (m *Manager) newSessionWriter() *sessionWriter {
+	w := &sessionWriter{store: m.Store}
+	if pipeliner, ok := m.Store.(Pipeliner); ok {
+		w.pipeline = pipeliner.Pipeline()
+	}
+	return w
+}
+
+func (w *sessionWriter) save(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	if w.pipeline != nil {
+		w.pipeline.Save(ctx, key, value, exp)
+		return nil
+	}
+	return w.store.Save(ctx, key, value, exp)
+}
+
+func (w *sessionWriter) addToIndex(ctx context.Context, index string, member string, exp time.Duration) error {
+	if w.pipeline != nil {
+		w.pipeline.AddToIndex(ctx, index, member, exp)
+		return nil
+	}
+	return w.store.AddToIndex(ctx, index, member, exp)
+}
+
+func (w *sessionWriter) exec(ctx context.Context) error {
+	if w.pipeline == nil {
+		return nil
+	}
+	return w.pipeline.Exec(ctx)
 }
 
 // Load reads sessions.SessionState information from a session store. It will
-// use the session ticket from the http.Request's cookie.
+// use the session ticket from the http.Request's cookie, or from a bearer
+// token issued by IssueToken.
 func (m *Manager) Load(req *http.Request) (*sessions.SessionState, error) {
 	tckt, err := decodeTicketFromRequest(req, m.Options)
 	if err != nil {
@@ -87,12 +207,75 @@ func (m *Manager) Clear(rw http.ResponseWriter, req *http.Request) error {
 	}
 
 	tckt.clearCookie(rw, req)
-	return tckt.clearSession(func(key string) error {
+
+	// Load the session before clearing it so that the ticket can be removed
+	// from the secondary indexes. A session that cannot be loaded may already
+	// have expired and is cleared regardless.
+	session, loadErr := tckt.loadSession(
+		func(key string) ([]byte, error) {
+			return m.Store.Load(req.Context(), key)
+		},
+		m.Store.Lock,
+	)
+
+	err = tckt.clearSession(func(key string) error {
 		return m.Store.Clear(req.Context(), key)
 	})
+	if err != nil {
+		return err
+	}
+	if err := m.Store.Clear(req.Context(), infoKey(tckt.id)); err != nil {
+		return fmt.Errorf("error clearing session info: %v", err)
+	}
+	if loadErr != nil {
+		return nil
+	}
+
+	for _, index := range sessionIndexes(m.Options, session) {
+		if err := m.Store.RemoveFromIndex(req.Context(), index, tckt.id); err != nil {
+			return fmt.Errorf("error removing session from index: %v", err)
+		}
+	}
+	return nil
+}
+
+// RevokeSessions clears all sessions issued by the provider with the given
+// provider session ID. When no session ID is given, all sessions of the
+// subject are cleared instead.
+func (m *Manager) RevokeSessions(ctx context.Context, providerID, subject, sessionID string) error {
+	index := indexKey(m.Options, subjectIndexType, providerID, subject)
+	if sessionID != "" {
+		index = indexKey(m.Options, sessionIDIndexType, providerID, sessionID)
+	}
+
+	tickets, err := m.Store.LoadIndex(ctx, index)
+	if err != nil {
+		return fmt.Errorf("error loading session index: %v", err)
+	}
+
+	for _, ticketID := range tickets {
+		info, _ := m.loadInfo(ctx, ticketID)
+		if err := m.clearTicket(ctx, ticketID, info); err != nil {
+			return err
+		}
+	}
+
+	if len(tickets) == 0 {
+		return nil
+	}
+	return m.Store.RemoveFromIndex(ctx, index, tickets...)
 }
 
 // VerifyConnection validates the underlying store is ready and connected
 func (m *Manager) VerifyConnection(ctx context.Context) error {
 	return m.Store.VerifyConnection(ctx)
 }
+
+// Close releases the resources of the underlying store, if it holds any.
+// It is called when the proxy shuts down.
+func (m *Manager) Close() error {
+	if closer, ok := m.Store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package persistence
+
+import (
+	"sync"
+	"time"
+)
+
+// Reaper periodically removes the expired entries of a store that does not
+// remove them by itself. Such stores never return expired entries, the
+// reaper only reclaims the space they take.
+type Reaper struct {
+	mu   sync.Mutex
+	done chan struct{}
+}
+
+// Start calls reap every interval until the reaper is stopped.
+// It does nothing when the interval is not positive or the reaper is
+// already running.
+func (r *Reaper) Start(interval time.Duration, reap func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if interval <= 0 || r.done != nil {
+		return
+	}
+	r.done = make(chan struct{})
+
+	go func(done chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				reap()
+			}
+		}
+	}(r.done)
+}
+
+// Stop stops the reaper if it is running
+func (r *Reaper) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.done != nil {
+		close(r.done)
+		r.done = nil
+	}
+}
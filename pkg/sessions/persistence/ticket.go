@@ -41,6 +41,9 @@ type ticket struct {
 	id      string
 	secret  []byte
 	options *options.Cookie
+
+	// bearer is set when the ticket was presented as a bearer token
+	bearer bool
 }
 
 // newTicket creates a new ticket. The ID & secret will be randomly created
@@ -138,21 +141,44 @@ func decodeTicket(encTicket string, cookieOpts *options.Cookie) (*ticket, error)
 
 // decodeTicketFromRequest retrieves a potential ticket cookie from a request
 // and decodes it to a ticket.
+// Requests without the cookie may present the signed ticket as a bearer token
+// instead.
 func decodeTicketFromRequest(req *http.Request, cookieOpts *options.Cookie) (*ticket, error) {
+	bearer := false
 	requestCookie, err := req.Cookie(cookieOpts.Name)
 	if err != nil {
-		// Don't wrap this error to allow `err == http.ErrNoCookie` checks
-		return nil, err
+		token, ok := bearerTicket(req)
+		if !ok {
+			// Don't wrap this error to allow `err == http.ErrNoCookie` checks
+			return nil, err
+		}
+		requestCookie = &http.Cookie{Name: cookieOpts.Name, Value: token}
+		bearer = true
 	}
 
 	// An existing cookie exists, try to retrieve the ticket
-	val, _, ok := encryption.Validate(requestCookie, cookieOpts.Secret, cookieOpts.Expire)
+	val, _, _, ok := encryption.ValidateWithSeeds(requestCookie, cookieOpts.Secrets(), cookieOpts.Expire)
 	if !ok {
-		return nil, fmt.Errorf("session ticket cookie failed validation: %v", err)
+		return nil, errors.New("session ticket cookie failed validation")
 	}
 
 	// Valid cookie, decode the ticket
-	return decodeTicket(string(val), cookieOpts)
+	tckt, err := decodeTicket(string(val), cookieOpts)
+	if err != nil {
+		return nil, err
+	}
+	tckt.bearer = bearer
+	return tckt, nil
+}
+
+// bearerTicket returns the bearer token of the request when it has the form
+// of a signed ticket, which other bearer tokens such as JWTs do not have
+func bearerTicket(req *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || strings.Count(token, "|") != 2 {
+		return "", false
+	}
+	return token, true
 }
 
 // saveSession encodes the SessionState with the ticket's secret and persists
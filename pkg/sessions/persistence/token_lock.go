@@ -0,0 +1,113 @@
+package persistence
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+)
+
+// LockStore holds the locks of TokenLocks. A lock records a random token of
+// its holder, so that only the holder can extend or delete it, and expires
+// so that a holder that never releases it does not keep the session locked.
+// Each method must be atomic across the replicas that share the store.
+type LockStore interface {
+	// InsertLock records the token as the holder of the lock on the key
+	// unless the key holds a lock that has not expired, and reports whether
+	// it did.
+	InsertLock(ctx context.Context, key, token string, expiration time.Duration) (bool, error)
+
+	// ExtendLock extends the expiration of the lock on the key if it is held
+	// with the token and has not expired, and reports whether it did.
+	ExtendLock(ctx context.Context, key, token string, expiration time.Duration) (bool, error)
+
+	// IsLocked reports whether the key holds a lock that has not expired.
+	IsLocked(ctx context.Context, key string) (bool, error)
+
+	// DeleteLock removes the lock on the key if it is held with the token,
+	// and reports whether it did.
+	DeleteLock(ctx context.Context, key, token string) (bool, error)
+}
+
+// TokenLock is a sessions.Lock held in a LockStore under a random token
+// generated each time it is obtained.
+type TokenLock struct {
+	store LockStore
+	key   string
+	token string
+}
+
+// NewTokenLock creates a lock on the key held in the store
+func NewTokenLock(store LockStore, key string) *TokenLock {
+	return &TokenLock{store: store, key: key}
+}
+
+// Obtain obtains the lock unless another holder has a lock on the key that
+// has not expired.
+func (l *TokenLock) Obtain(ctx context.Context, expiration time.Duration) error {
+	token, err := newLockToken()
+	if err != nil {
+		return err
+	}
+
+	ok, err := l.store.InsertLock(ctx, l.key, token, expiration)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return sessions.ErrLockNotObtained
+	}
+	l.token = token
+	return nil
+}
+
+// Refresh extends the expiration of a lock held by this TokenLock.
+func (l *TokenLock) Refresh(ctx context.Context, expiration time.Duration) error {
+	if l.token == "" {
+		return sessions.ErrNotLocked
+	}
+
+	ok, err := l.store.ExtendLock(ctx, l.key, l.token, expiration)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return sessions.ErrNotLocked
+	}
+	return nil
+}
+
+// Peek returns true if any holder has a lock on the key that has not expired.
+func (l *TokenLock) Peek(ctx context.Context) (bool, error) {
+	return l.store.IsLocked(ctx, l.key)
+}
+
+// Release releases a lock held by this TokenLock.
+func (l *TokenLock) Release(ctx context.Context) error {
+	if l.token == "" {
+		return sessions.ErrNotLocked
+	}
+	token := l.token
+	l.token = ""
+
+	ok, err := l.store.DeleteLock(ctx, l.key, token)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return sessions.ErrNotLocked
+	}
+	return nil
+}
+
+// newLockToken generates the random token of a lock holder
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating session lock token: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,104 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeLockStore holds the token of each locked key. Locks do not expire.
+type fakeLockStore struct {
+	tokens map[string]string
+	err    error
+}
+
+func (f *fakeLockStore) InsertLock(_ context.Context, key, token string, _ time.Duration) (bool, error) {
+	if _, ok := f.tokens[key]; ok || f.err != nil {
+		return false, f.err
+	}
+	f.tokens[key] = token
+	return true, nil
+}
+
+func (f *fakeLockStore) ExtendLock(_ context.Context, key, token string, _ time.Duration) (bool, error) {
+	return f.tokens[key] == token, f.err
+}
+
+func (f *fakeLockStore) IsLocked(_ context.Context, key string) (bool, error) {
+	_, ok := f.tokens[key]
+	return ok, f.err
+}
+
+func (f *fakeLockStore) DeleteLock(_ context.Context, key, token string) (bool, error) {
+	if f.tokens[key] != token || f.err != nil {
+		return false, f.err
+	}
+	delete(f.tokens, key)
+	return true, nil
+}
+
+var _ = Describe("Token Lock Tests", func() {
+	ctx := context.Background()
+	var store *fakeLockStore
+	var lock, other *TokenLock
+
+	BeforeEach(func() {
+		store = &fakeLockStore{tokens: map[string]string{}}
+		lock = NewTokenLock(store, "ticket")
+		other = NewTokenLock(store, "ticket")
+		Expect(lock.Obtain(ctx, time.Minute)).To(Succeed())
+	})
+
+	It("holds the lock under a random token", func() {
+		Expect(store.tokens["ticket"]).To(HaveLen(32))
+		Expect(lock.Refresh(ctx, time.Minute)).To(Succeed())
+
+		locked, err := other.Peek(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(locked).To(BeTrue())
+	})
+
+	It("cannot be obtained, refreshed or released by another holder", func() {
+		Expect(other.Obtain(ctx, time.Minute)).To(MatchError(sessionsapi.ErrLockNotObtained))
+		Expect(other.Refresh(ctx, time.Minute)).To(MatchError(sessionsapi.ErrNotLocked))
+		Expect(other.Release(ctx)).To(MatchError(sessionsapi.ErrNotLocked))
+	})
+
+	It("can be obtained by another holder once released", func() {
+		Expect(lock.Release(ctx)).To(Succeed())
+		Expect(lock.Release(ctx)).To(MatchError(sessionsapi.ErrNotLocked))
+		Expect(other.Obtain(ctx, time.Minute)).To(Succeed())
+	})
+
+	It("returns the errors of the store", func() {
+		store.err = errors.New("unavailable")
+		Expect(other.Obtain(ctx, time.Minute)).To(MatchError("unavailable"))
+		Expect(lock.Refresh(ctx, time.Minute)).To(MatchError("unavailable"))
+		Expect(lock.Release(ctx)).To(MatchError("unavailable"))
+	})
+})
+
+var _ = Describe("Reaper Tests", func() {
+	It("reaps every interval until stopped", func() {
+		var calls int32
+		reaper := &Reaper{}
+		reaper.Start(time.Millisecond, func() { atomic.AddInt32(&calls, 1) })
+		Eventually(func() int32 { return atomic.LoadInt32(&calls) }).Should(BeNumerically(">=", 2))
+
+		reaper.Stop()
+		stopped := atomic.LoadInt32(&calls)
+		Consistently(func() int32 { return atomic.LoadInt32(&calls) }, 20*time.Millisecond).Should(BeNumerically("<=", stopped+1))
+		reaper.Stop()
+	})
+
+	It("does not reap without an interval", func() {
+		reaper := &Reaper{}
+		reaper.Start(0, func() { Fail("reaped without an interval") })
+		reaper.Stop()
+	})
+})
@@ -15,6 +15,12 @@ type Client interface {
 	Set(ctx context.Context, key string, value []byte, expiration time.Duration) error
 	Del(ctx context.Context, key string) error
 	Ping(ctx context.Context) error
+	SAdd(ctx context.Context, key string, member string, expiration time.Duration) error
+	SMembers(ctx context.Context, key string) ([]string, error)
+	SRem(ctx context.Context, key string, members ...string) error
+	Pipeline() redis.Pipeliner
+	AddHook(hook redis.Hook)
+	Close() error
 }
 
 var _ Client = (*client)(nil)
@@ -49,6 +55,27 @@ func (c *client) Ping(ctx context.Context) error {
 	return c.Client.Ping(ctx).Err()
 }
 
+func (c *client) SAdd(ctx context.Context, key string, member string, expiration time.Duration) error {
+	_, err := c.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, key, member)
+		pipe.Expire(ctx, key, expiration)
+		return nil
+	})
+	return err
+}
+
+func (c *client) SMembers(ctx context.Context, key string) ([]string, error) {
+	return c.Client.SMembers(ctx, key).Result()
+}
+
+func (c *client) SRem(ctx context.Context, key string, members ...string) error {
+	args := make([]interface{}, 0, len(members))
+	for _, member := range members {
+		args = append(args, member)
+	}
+	return c.Client.SRem(ctx, key, args...).Err()
+}
+
 var _ Client = (*clusterClient)(nil)
 
 type clusterClient struct {
@@ -80,3 +107,24 @@ func (c *clusterClient) Lock(key string) sessions.Lock {
 func (c *clusterClient) Ping(ctx context.Context) error {
 	return c.ClusterClient.Ping(ctx).Err()
 }
+
+func (c *clusterClient) SAdd(ctx context.Context, key string, member string, expiration time.Duration) error {
+	_, err := c.ClusterClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, key, member)
+		pipe.Expire(ctx, key, expiration)
+		return nil
+	})
+	return err
+}
+
+func (c *clusterClient) SMembers(ctx context.Context, key string) ([]string, error) {
+	return c.ClusterClient.SMembers(ctx, key).Result()
+}
+
+func (c *clusterClient) SRem(ctx context.Context, key string, members ...string) error {
+	args := make([]interface{}, 0, len(members))
+	for _, member := range members {
+		args = append(args, member)
+	}
+	return c.ClusterClient.SRem(ctx, key, args...).Err()
+}
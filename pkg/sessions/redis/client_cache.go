@@ -0,0 +1,219 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+// invalidationChannel is the channel redis publishes the invalidations of
+// tracked keys to, for clients using RESP2
+const invalidationChannel = "__redis__:invalidate"
+
+// enableTracking enables server assisted client side caching on the
+// connection that receives the invalidations.
+// Tracking uses broadcasting mode, so that any change to a key with the
+// prefix is published regardless of which connection read the key, and
+// redirects the invalidations to the connection itself.
+var enableTracking = func(ctx context.Context, cn *redis.Conn, prefix string) error {
+	id, err := cn.ClientID(ctx).Result()
+	if err != nil {
+		return err
+	}
+	args := []interface{}{"CLIENT", "TRACKING", "ON", "REDIRECT", id, "BCAST"}
+	if prefix != "" {
+		args = append(args, "PREFIX", prefix)
+	}
+	cmd := redis.NewStatusCmd(ctx, args...)
+	_ = cn.Process(ctx, cmd)
+	return cmd.Err()
+}
+
+// clientCache caches the values read from redis in memory.
+// Redis tracks the keys of the store and publishes a message when they
+// change, which removes them from the cache. Values also expire after the
+// TTL of the cache, which bounds how long a value may be stale if an
+// invalidation is lost.
+type clientCache struct {
+	// Clock is used to expire cached values
+	Clock clock.Clock
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	size    int
+	ttl     time.Duration
+
+	// generation changes with every invalidation, a value read from redis is
+	// only cached if no invalidation arrived while it was read
+	generation uint64
+	// tracking is true while invalidations are received, values are neither
+	// served from nor added to the cache otherwise
+	tracking bool
+
+	pubsub *redis.PubSub
+}
+
+type cacheEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func newClientCache(size int, ttl time.Duration) *clientCache {
+	return &clientCache{
+		entries: map[string]cacheEntry{},
+		size:    size,
+		ttl:     ttl,
+	}
+}
+
+// get returns the cached value of the key
+func (c *clientCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.tracking {
+		return nil, false
+	}
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.Clock.Now().Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// currentGeneration returns the generation to pass to set for a value that
+// is about to be read from redis
+func (c *clientCache) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// set caches the value of the key, unless the cache was invalidated since
+// the generation was taken.
+// When the cache is full an arbitrary entry is removed.
+func (c *clientCache) set(key string, value []byte, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.tracking || generation != c.generation {
+		return
+	}
+	if _, ok := c.entries[key]; !ok && c.size > 0 && len(c.entries) >= c.size {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = cacheEntry{value: value, expiresAt: c.Clock.Now().Add(c.ttl)}
+}
+
+// invalidate removes the keys from the cache
+func (c *clientCache) invalidate(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+}
+
+// flush removes all keys from the cache and sets whether invalidations are
+// received
+func (c *clientCache) flush(tracking bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.entries = map[string]cacheEntry{}
+	c.tracking = tracking
+}
+
+// onConnect enables tracking on a new connection of the invalidation
+// subscriber. Invalidations may have been lost while there was no
+// connection, so the cache starts out empty.
+func (c *clientCache) onConnect(prefix string) func(context.Context, *redis.Conn) error {
+	return func(ctx context.Context, cn *redis.Conn) error {
+		if err := enableTracking(ctx, cn, prefix); err != nil {
+			return err
+		}
+		c.flush(true)
+		return nil
+	}
+}
+
+// subscribe subscribes to the invalidations of the tracked keys. It returns
+// once the subscription is confirmed, invalidations are then received in
+// the background until the subscriber is closed.
+func (c *clientCache) subscribe(ctx context.Context, subscriber *redis.Client) error {
+	pubsub := subscriber.Subscribe(ctx, invalidationChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+
+	c.pubsub = pubsub
+	go c.receiveInvalidations(pubsub)
+	return nil
+}
+
+// close stops receiving invalidations
+func (c *clientCache) close() error {
+	c.flush(false)
+	if c.pubsub == nil {
+		return nil
+	}
+	return c.pubsub.Close()
+}
+
+func (c *clientCache) receiveInvalidations(pubsub *redis.PubSub) {
+	ctx := context.Background()
+	for {
+		msg, err := pubsub.ReceiveMessage(ctx)
+		if errors.Is(err, redis.ErrClosed) {
+			return
+		}
+		if err != nil {
+			logger.Errorf("error receiving redis cache invalidations, the cache is disabled until reconnected: %v", err)
+			c.flush(false)
+			c.reconnect(ctx, pubsub)
+			continue
+		}
+
+		switch {
+		case len(msg.PayloadSlice) > 0:
+			c.invalidate(msg.PayloadSlice...)
+		case msg.Payload != "":
+			c.invalidate(msg.Payload)
+		default:
+			// A message without keys is sent when the database is flushed
+			c.flush(true)
+		}
+	}
+}
+
+// reconnect waits until the subscriber has reconnected, which re-enables
+// tracking and the cache
+func (c *clientCache) reconnect(ctx context.Context, pubsub *redis.PubSub) {
+	for {
+		err := pubsub.Ping(ctx)
+		if errors.Is(err, redis.ErrClosed) {
+			return
+		}
+		if err == nil {
+			c.flush(true)
+			return
+		}
+		time.Sleep(time.Second)
+	}
+}
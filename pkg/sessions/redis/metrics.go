@@ -0,0 +1,109 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// pipelineCommand is the command label of pipelines, which are timed as a
+// whole
+const pipelineCommand = "pipeline"
+
+// metricsHook is a redis.Hook that records the latency and errors of the
+// commands sent to redis
+type metricsHook struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+func newMetricsHook(registerer prometheus.Registerer) *metricsHook {
+	return &metricsHook{
+		duration: registerCommandDurationHistogram(registerer),
+		errors:   registerCommandErrorsCounter(registerer),
+	}
+}
+
+func (h *metricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *metricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.observe(cmd.Name(), start, err)
+		return err
+	}
+}
+
+func (h *metricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.observe(pipelineCommand, start, err)
+		return err
+	}
+}
+
+// observe records the duration of a command, and counts it as an error
+// unless it failed because a key does not exist
+func (h *metricsHook) observe(command string, start time.Time, err error) {
+	h.duration.WithLabelValues(command).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, redis.Nil) {
+		h.errors.WithLabelValues(command).Inc()
+	}
+}
+
+// registerCommandDurationHistogram registers the
+// 'oauth2_proxy_redis_command_duration_seconds' metric
+// This tracks the latency of the commands sent to redis bucketed by command
+func registerCommandDurationHistogram(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	histogram := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "oauth2_proxy_redis_command_duration_seconds",
+			Help:    "A histogram of the latency of redis session store commands.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		},
+		[]string{"command"},
+	)
+
+	if err := registerer.Register(histogram); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			histogram = are.ExistingCollector.(*prometheus.HistogramVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return histogram
+}
+
+// registerCommandErrorsCounter registers the
+// 'oauth2_proxy_redis_command_errors_total' metric
+// This keeps a tally of the commands sent to redis that failed bucketed by
+// command
+func registerCommandErrorsCounter(registerer prometheus.Registerer) *prometheus.CounterVec {
+	counter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "oauth2_proxy_redis_command_errors_total",
+			Help: "Total number of failed redis session store commands.",
+		},
+		[]string{"command"},
+	)
+
+	if err := registerer.Register(counter); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			counter = are.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			panic(err)
+		}
+	}
+
+	return counter
+}
+
+var _ redis.Hook = (*metricsHook)(nil)
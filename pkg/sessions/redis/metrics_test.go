@@ -0,0 +1,67 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Redis Metrics Tests", func() {
+	var mr *miniredis.Miniredis
+	var client Client
+	var hook *metricsHook
+	ctx := context.Background()
+
+	BeforeEach(func() {
+		var err error
+		mr, err = miniredis.Run()
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(mr.Close)
+
+		client, err = NewRedisClient(options.RedisStoreOptions{
+			ConnectionURL: "redis://" + mr.Addr(),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(client.Close)
+
+		hook = newMetricsHook(prometheus.NewRegistry())
+		client.AddHook(hook)
+	})
+
+	It("records the latency of commands", func() {
+		Expect(client.Set(ctx, "key", []byte("value"), 0)).To(Succeed())
+		_, err := client.Get(ctx, "key")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(testutil.CollectAndCount(hook.duration, "oauth2_proxy_redis_command_duration_seconds")).To(Equal(2))
+		Expect(testutil.CollectAndCount(hook.errors)).To(Equal(0))
+	})
+
+	It("records pipelines as a whole", func() {
+		pipe := client.Pipeline()
+		pipe.Set(ctx, "key", "value", 0)
+		pipe.SAdd(ctx, "index", "key")
+		_, err := pipe.Exec(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(testutil.CollectAndCount(hook.duration)).To(Equal(1))
+		histogram := hook.duration.WithLabelValues(pipelineCommand).(prometheus.Histogram)
+		Expect(testutil.CollectAndCount(histogram)).To(Equal(1))
+	})
+
+	It("counts failed commands but not missing keys", func() {
+		_, err := client.Get(ctx, "missing")
+		Expect(err).To(HaveOccurred())
+		Expect(testutil.ToFloat64(hook.errors.WithLabelValues("get"))).To(Equal(float64(0)))
+
+		mr.SetError("unavailable")
+		_, err = client.Get(ctx, "key")
+		Expect(err).To(MatchError("unavailable"))
+		Expect(testutil.ToFloat64(hook.errors.WithLabelValues("get"))).To(Equal(float64(1)))
+	})
+})
@@ -12,6 +12,7 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -19,6 +20,14 @@ import (
 // interface that stores sessions in redis
 type SessionStore struct {
 	Client Client
+
+	// Prefix is prepended to all keys written by the store
+	Prefix string
+
+	// cache caches loaded sessions in memory when client side caching is
+	// enabled, subscriber receives its invalidations
+	cache      *clientCache
+	subscriber *redis.Client
 }
 
 // NewRedisSessionStore initialises a new instance of the SessionStore and wraps
@@ -28,17 +37,57 @@ func NewRedisSessionStore(opts *options.SessionOptions, cookieOpts *options.Cook
 	if err != nil {
 		return nil, fmt.Errorf("error constructing redis client: %v", err)
 	}
+	client.AddHook(newMetricsHook(prometheus.DefaultRegisterer))
 
-	rs := &SessionStore{
-		Client: client,
+	rs := NewStore(client, opts.Redis.KeyPrefix)
+	if opts.Redis.ClientCache {
+		if err := rs.enableClientCache(opts.Redis); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("error enabling redis client side caching: %v", err)
+		}
 	}
+
 	return persistence.NewManager(rs, cookieOpts), nil
 }
 
+// NewStore creates a SessionStore that writes its keys with the prefix
+func NewStore(client Client, prefix string) *SessionStore {
+	return &SessionStore{
+		Client: client,
+		Prefix: prefix,
+	}
+}
+
+// enableClientCache connects the subscriber that receives the invalidations
+// of the keys of the store and enables the cache once subscribed.
+// Invalidations are redirected to a RESP2 pubsub connection, as go-redis
+// does not deliver RESP3 push messages to the caller.
+func (store *SessionStore) enableClientCache(opts options.RedisStoreOptions) error {
+	if opts.UseCluster {
+		return fmt.Errorf("client side caching is not supported with redis cluster")
+	}
+
+	cache := newClientCache(opts.ClientCacheSize, opts.ClientCacheTTL)
+	subscriber, err := NewRedisSubscriber(opts, cache.onConnect(store.Prefix))
+	if err != nil {
+		return err
+	}
+	if err := cache.subscribe(context.Background(), subscriber); err != nil {
+		subscriber.Close()
+		return err
+	}
+
+	store.cache = cache
+	store.subscriber = subscriber
+	return nil
+}
+
 // Save takes a sessions.SessionState and stores the information from it
 // to redis, and adds a new persistence cookie on the HTTP response writer
 func (store *SessionStore) Save(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	key = store.Prefix + key
 	err := store.Client.Set(ctx, key, value, exp)
+	store.invalidate(key)
 	if err != nil {
 		return fmt.Errorf("error saving redis session: %v", err)
 	}
@@ -46,19 +95,36 @@ func (store *SessionStore) Save(ctx context.Context, key string, value []byte, e
 }
 
 // Load reads sessions.SessionState information from a persistence
-// cookie within the HTTP request object
+// cookie within the HTTP request object.
+// A cached session is returned without reading it again.
 func (store *SessionStore) Load(ctx context.Context, key string) ([]byte, error) {
+	key = store.Prefix + key
+	if store.cache == nil {
+		value, err := store.Client.Get(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("error loading redis session: %v", err)
+		}
+		return value, nil
+	}
+
+	if value, ok := store.cache.get(key); ok {
+		return value, nil
+	}
+	generation := store.cache.currentGeneration()
 	value, err := store.Client.Get(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("error loading redis session: %v", err)
 	}
+	store.cache.set(key, value, generation)
 	return value, nil
 }
 
 // Clear clears any saved session information for a given persistence cookie
 // from redis, and then clears the session
 func (store *SessionStore) Clear(ctx context.Context, key string) error {
+	key = store.Prefix + key
 	err := store.Client.Del(ctx, key)
+	store.invalidate(key)
 	if err != nil {
 		return fmt.Errorf("error clearing the session from redis: %v", err)
 	}
@@ -67,7 +133,7 @@ func (store *SessionStore) Clear(ctx context.Context, key string) error {
 
 // Lock creates a lock object for sessions.SessionState
 func (store *SessionStore) Lock(key string) sessions.Lock {
-	return store.Client.Lock(key)
+	return store.Client.Lock(store.Prefix + key)
 }
 
 // VerifyConnection verifies the redis connection is valid and the
@@ -76,6 +142,88 @@ func (store *SessionStore) VerifyConnection(ctx context.Context) error {
 	return store.Client.Ping(ctx)
 }
 
+// AddToIndex adds a session ticket to a secondary index stored as a redis set
+func (store *SessionStore) AddToIndex(ctx context.Context, index string, member string, exp time.Duration) error {
+	err := store.Client.SAdd(ctx, store.Prefix+index, member, exp)
+	if err != nil {
+		return fmt.Errorf("error adding to redis session index: %v", err)
+	}
+	return nil
+}
+
+// LoadIndex reads the session tickets of a secondary index from redis
+func (store *SessionStore) LoadIndex(ctx context.Context, index string) ([]string, error) {
+	members, err := store.Client.SMembers(ctx, store.Prefix+index)
+	if err != nil {
+		return nil, fmt.Errorf("error loading redis session index: %v", err)
+	}
+	return members, nil
+}
+
+// RemoveFromIndex removes session tickets from a secondary index in redis
+func (store *SessionStore) RemoveFromIndex(ctx context.Context, index string, members ...string) error {
+	err := store.Client.SRem(ctx, store.Prefix+index, members...)
+	if err != nil {
+		return fmt.Errorf("error removing from redis session index: %v", err)
+	}
+	return nil
+}
+
+// Pipeline returns a pipeline that sends the writes of a saved session to
+// redis in a single round trip
+func (store *SessionStore) Pipeline() persistence.Pipeline {
+	return &pipeline{
+		store: store,
+		pipe:  store.Client.Pipeline(),
+	}
+}
+
+// Close stops receiving cache invalidations and closes the connections to
+// redis
+func (store *SessionStore) Close() error {
+	if store.cache != nil {
+		store.cache.close()
+		store.subscriber.Close()
+	}
+	return store.Client.Close()
+}
+
+// invalidate removes keys written by this store from the cache, without
+// waiting for redis to publish the change
+func (store *SessionStore) invalidate(keys ...string) {
+	if store.cache != nil {
+		store.cache.invalidate(keys...)
+	}
+}
+
+// pipeline queues the writes of a session in a redis pipeline
+type pipeline struct {
+	store *SessionStore
+	pipe  redis.Pipeliner
+	keys  []string
+}
+
+func (p *pipeline) Save(ctx context.Context, key string, value []byte, exp time.Duration) {
+	key = p.store.Prefix + key
+	p.pipe.Set(ctx, key, value, exp)
+	p.keys = append(p.keys, key)
+}
+
+func (p *pipeline) AddToIndex(ctx context.Context, index string, member string, exp time.Duration) {
+	index = p.store.Prefix + index
+	p.pipe.SAdd(ctx, index, member)
+	p.pipe.Expire(ctx, index, exp)
+}
+
+func (p *pipeline) Exec(ctx context.Context) error {
+	_, err := p.pipe.Exec(ctx)
+	p.store.invalidate(p.keys...)
+	if err != nil {
+		return fmt.Errorf("error saving redis session: %v", err)
+	}
+	return nil
+}
+
 // NewRedisClient makes a redis.Client (either standalone, sentinel aware, or
 // redis cluster)
 func NewRedisClient(opts options.RedisStoreOptions) (Client, error) {
@@ -95,6 +243,16 @@ func NewRedisClient(opts options.RedisStoreOptions) (Client, error) {
 // buildSentinelClient makes a redis.Client that connects to Redis Sentinel
 // for Primary/Replica Redis node coordination
 func buildSentinelClient(opts options.RedisStoreOptions) (Client, error) {
+	failoverOpts, err := sentinelOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(redis.NewFailoverClient(failoverOpts)), nil
+}
+
+// sentinelOptions makes the options of a redis.Client that connects to
+// Redis Sentinel
+func sentinelOptions(opts options.RedisStoreOptions) (*redis.FailoverOptions, error) {
 	addrs, opt, err := parseRedisURLs(opts.SentinelConnectionURLs)
 	if err != nil {
 		return nil, fmt.Errorf("could not parse redis urls: %v", err)
@@ -111,7 +269,7 @@ func buildSentinelClient(opts options.RedisStoreOptions) (Client, error) {
 		return nil, err
 	}
 
-	client := redis.NewFailoverClient(&redis.FailoverOptions{
+	return &redis.FailoverOptions{
 		MasterName:       opts.SentinelMasterName,
 		SentinelAddrs:    addrs,
 		SentinelPassword: opts.SentinelPassword,
@@ -119,8 +277,7 @@ func buildSentinelClient(opts options.RedisStoreOptions) (Client, error) {
 		Password:         opts.Password,
 		TLSConfig:        opt.TLSConfig,
 		ConnMaxIdleTime:  time.Duration(opts.IdleTimeout) * time.Second,
-	})
-	return newClient(client), nil
+	}, nil
 }
 
 // buildClusterClient makes a redis.Client that is Redis Cluster aware
@@ -154,6 +311,16 @@ func buildClusterClient(opts options.RedisStoreOptions) (Client, error) {
 // buildStandaloneClient makes a redis.Client that connects to a simple
 // Redis node
 func buildStandaloneClient(opts options.RedisStoreOptions) (Client, error) {
+	opt, err := standaloneOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(redis.NewClient(opt)), nil
+}
+
+// standaloneOptions makes the options of a redis.Client that connects to a
+// simple Redis node
+func standaloneOptions(opts options.RedisStoreOptions) (*redis.Options, error) {
 	opt, err := redis.ParseURL(opts.ConnectionURL)
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse redis url: %s", err)
@@ -171,9 +338,33 @@ func buildStandaloneClient(opts options.RedisStoreOptions) (Client, error) {
 	}
 
 	opt.ConnMaxIdleTime = time.Duration(opts.IdleTimeout) * time.Second
+	return opt, nil
+}
 
-	client := redis.NewClient(opt)
-	return newClient(client), nil
+// NewRedisSubscriber makes a redis.Client (either standalone or sentinel
+// aware) for pubsub that uses RESP2 and calls onConnect on every new
+// connection
+func NewRedisSubscriber(opts options.RedisStoreOptions, onConnect func(context.Context, *redis.Conn) error) (*redis.Client, error) {
+	if opts.UseCluster {
+		return nil, fmt.Errorf("redis subscribers are not supported with redis cluster")
+	}
+	if opts.UseSentinel {
+		failoverOpts, err := sentinelOptions(opts)
+		if err != nil {
+			return nil, err
+		}
+		failoverOpts.Protocol = 2
+		failoverOpts.OnConnect = onConnect
+		return redis.NewFailoverClient(failoverOpts), nil
+	}
+
+	opt, err := standaloneOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	opt.Protocol = 2
+	opt.OnConnect = onConnect
+	return redis.NewClient(opt), nil
 }
 
 // setupTLSConfig sets the TLSConfig if the TLS option is given in redis.Options
@@ -232,3 +423,4 @@ func parseRedisURLs(urls []string) ([]string, *redis.Options, error) {
 }
 
 var _ persistence.Store = (*SessionStore)(nil)
+var _ persistence.Pipeliner = (*SessionStore)(nil)
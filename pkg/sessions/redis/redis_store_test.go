@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"time"
 
 	"github.com/Bose/minisentinel"
@@ -11,6 +12,7 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
 )
 
 const (
@@ -29,6 +31,8 @@ var _ = Describe("Redis SessionStore Tests", func() {
 	var ss sessionsapi.SessionStore
 
 	BeforeEach(func() {
+		ss = nil
+
 		var err error
 		mr, err = miniredis.Run()
 		Expect(err).ToNot(HaveOccurred())
@@ -42,7 +46,7 @@ var _ = Describe("Redis SessionStore Tests", func() {
 		// Release any connections immediately after the test ends
 		if redisManager, ok := ss.(*persistence.Manager); ok {
 			if redisManager.Store.(*SessionStore).Client != nil {
-				Expect(redisManager.Store.(*SessionStore).Client.(closer).Close()).To(Succeed())
+				Expect(redisManager.Store.(closer).Close()).To(Succeed())
 			}
 		}
 	})
@@ -242,4 +246,156 @@ var _ = Describe("Redis SessionStore Tests", func() {
 			)
 		})
 	})
+
+	Context("with a key prefix", func() {
+		tests.RunSessionStoreTests(
+			func(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessionsapi.SessionStore, error) {
+				opts.Type = options.RedisSessionStoreType
+				opts.Redis.ConnectionURL = redisProtocol + mr.Addr()
+				opts.Redis.KeyPrefix = "proxy:"
+
+				// Capture the session store so that we can close the client
+				var err error
+				ss, err = NewRedisSessionStore(opts, cookieOpts)
+				return ss, err
+			},
+			func(d time.Duration) error {
+				mr.FastForward(d)
+				return nil
+			},
+		)
+	})
+
+	Context("with client side caching", func() {
+		var stores []*SessionStore
+
+		BeforeEach(func() {
+			stores = nil
+
+			// miniredis does not support tracking, invalidations are published
+			// by the tests instead
+			trackingEnabler := enableTracking
+			enableTracking = func(context.Context, *redis.Conn, string) error { return nil }
+			DeferCleanup(func() { enableTracking = trackingEnabler })
+		})
+
+		tests.RunSessionStoreTests(
+			func(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessionsapi.SessionStore, error) {
+				opts.Type = options.RedisSessionStoreType
+				opts.Redis.ConnectionURL = redisProtocol + mr.Addr()
+				opts.Redis.ClientCache = true
+
+				// Capture the session store so that we can close the client
+				var err error
+				ss, err = NewRedisSessionStore(opts, cookieOpts)
+				if err != nil {
+					return nil, err
+				}
+				stores = append(stores, ss.(*persistence.Manager).Store.(*SessionStore))
+				return ss, nil
+			},
+			func(d time.Duration) error {
+				mr.FastForward(d)
+				// Redis publishes the invalidation of expired keys, which
+				// miniredis does not
+				for _, store := range stores {
+					store.cache.flush(true)
+				}
+				return nil
+			},
+		)
+	})
+
+	Context("with a store", func() {
+		const prefix = "proxy:"
+		var store *SessionStore
+		ctx := context.Background()
+
+		BeforeEach(func() {
+			client, err := NewRedisClient(options.RedisStoreOptions{
+				ConnectionURL: redisProtocol + mr.Addr(),
+			})
+			Expect(err).ToNot(HaveOccurred())
+			store = NewStore(client, prefix)
+		})
+
+		AfterEach(func() {
+			Expect(store.Close()).To(Succeed())
+		})
+
+		It("writes its keys below the prefix", func() {
+			Expect(store.Save(ctx, "key", []byte("value"), time.Minute)).To(Succeed())
+			Expect(store.AddToIndex(ctx, "index", "key", time.Minute)).To(Succeed())
+
+			Expect(mr.Get(prefix + "key")).To(Equal("value"))
+			Expect(mr.SMembers(prefix + "index")).To(ConsistOf("key"))
+			Expect(mr.Exists("key")).To(BeFalse())
+		})
+
+		It("locks sessions below the prefix", func() {
+			lock := store.Lock("key")
+			Expect(lock.Obtain(ctx, time.Minute)).To(Succeed())
+			Expect(mr.Exists(prefix + "key.lock")).To(BeTrue())
+
+			Expect(store.Lock("key").Obtain(ctx, time.Minute)).To(MatchError(sessionsapi.ErrLockNotObtained))
+			Expect(lock.Release(ctx)).To(Succeed())
+			Expect(mr.Exists(prefix + "key.lock")).To(BeFalse())
+		})
+
+		It("sends pipelined writes when executed", func() {
+			pipe := store.Pipeline()
+			pipe.Save(ctx, "key", []byte("value"), time.Minute)
+			pipe.AddToIndex(ctx, "index", "key", time.Hour)
+			Expect(mr.Exists(prefix + "key")).To(BeFalse())
+
+			Expect(pipe.Exec(ctx)).To(Succeed())
+			Expect(mr.Get(prefix + "key")).To(Equal("value"))
+			Expect(mr.TTL(prefix + "key")).To(Equal(time.Minute))
+			Expect(mr.SMembers(prefix + "index")).To(ConsistOf("key"))
+			Expect(mr.TTL(prefix + "index")).To(Equal(time.Hour))
+		})
+
+		Context("with client side caching", func() {
+			BeforeEach(func() {
+				trackingEnabler := enableTracking
+				enableTracking = func(context.Context, *redis.Conn, string) error { return nil }
+				DeferCleanup(func() { enableTracking = trackingEnabler })
+
+				Expect(store.enableClientCache(options.RedisStoreOptions{
+					ConnectionURL:   redisProtocol + mr.Addr(),
+					ClientCacheTTL:  time.Minute,
+					ClientCacheSize: 10,
+				})).To(Succeed())
+				Expect(store.Save(ctx, "key", []byte("value"), time.Hour)).To(Succeed())
+				_, err := store.Load(ctx, "key")
+				Expect(err).ToNot(HaveOccurred())
+
+				// Change the session without the store seeing it
+				Expect(mr.Set(prefix+"key", "changed")).To(Succeed())
+			})
+
+			It("loads cached sessions", func() {
+				value, err := store.Load(ctx, "key")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(value).To(Equal([]byte("value")))
+			})
+
+			It("loads sessions again once invalidated", func() {
+				mr.Publish(invalidationChannel, prefix+"key")
+
+				Eventually(func() ([]byte, error) {
+					return store.Load(ctx, "key")
+				}).Should(Equal([]byte("changed")))
+			})
+
+			It("loads sessions again once the cache TTL has passed", func() {
+				store.cache.Clock.Set(time.Now().Add(2 * time.Minute))
+				DeferCleanup(func() { store.cache.Clock.Reset() })
+
+				value, err := store.Load(ctx, "key")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(value).To(Equal([]byte("changed")))
+			})
+		})
+	})
 })
@@ -0,0 +1,88 @@
+package sessions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+)
+
+// RevocationList records sessions revoked by the provider that cannot be
+// removed from the session store directly, such as cookie sessions.
+// Sessions issued before they were revoked are reported as revoked until
+// the entry expires. Entries are only held in memory and are not shared
+// between replicas.
+type RevocationList struct {
+	ttl   time.Duration
+	clock clock.Clock
+
+	mu      sync.Mutex
+	entries map[revocationKey]time.Time
+}
+
+// revocationKey identifies a revoked provider session or subject
+type revocationKey struct {
+	providerID string
+	subject    string
+	sessionID  string
+}
+
+// NewRevocationList creates a RevocationList that holds revocations for the
+// given duration. This should match the lifetime of the session cookie.
+func NewRevocationList(ttl time.Duration) *RevocationList {
+	return &RevocationList{
+		ttl:     ttl,
+		entries: make(map[revocationKey]time.Time),
+	}
+}
+
+// RevokeSessions records the revocation of all sessions issued by the
+// provider with the given provider session ID, or of all sessions of the
+// subject when sessionID is empty.
+// It implements the sessions.SessionRevoker interface.
+func (l *RevocationList) RevokeSessions(_ context.Context, providerID, subject, sessionID string) error {
+	key := revocationKey{providerID: providerID, subject: subject}
+	if sessionID != "" {
+		key = revocationKey{providerID: providerID, sessionID: sessionID}
+	}
+
+	now := l.clock.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[key] = now
+	for k, revokedAt := range l.entries {
+		if now.Sub(revokedAt) > l.ttl {
+			delete(l.entries, k)
+		}
+	}
+	return nil
+}
+
+// IsRevoked reports whether the session was issued before a matching
+// revocation was recorded. The issue time is used as refreshes reset the
+// creation time of the session.
+func (l *RevocationList) IsRevoked(s *sessions.SessionState) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range []revocationKey{
+		{providerID: s.ProviderID, subject: s.Subject},
+		{providerID: s.ProviderID, sessionID: s.SessionID},
+	} {
+		if key.subject == "" && key.sessionID == "" {
+			continue
+		}
+		revokedAt, ok := l.entries[key]
+		if !ok || l.clock.Now().Sub(revokedAt) > l.ttl {
+			continue
+		}
+		if issuedAt := s.IssueTime(); issuedAt == nil || !issuedAt.After(revokedAt) {
+			return true
+		}
+	}
+	return false
+}
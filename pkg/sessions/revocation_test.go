@@ -0,0 +1,79 @@
+package sessions_test
+
+import (
+	"context"
+	"time"
+
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RevocationList", func() {
+	var list *sessions.RevocationList
+	var session *sessionsapi.SessionState
+	now := time.Now()
+
+	BeforeEach(func() {
+		clock.Set(now)
+		list = sessions.NewRevocationList(time.Hour)
+
+		created := now.Add(-time.Minute)
+		session = &sessionsapi.SessionState{
+			ProviderID: "provider",
+			Subject:    "subject",
+			SessionID:  "session",
+			CreatedAt:  &created,
+		}
+	})
+
+	AfterEach(func() {
+		clock.Reset()
+	})
+
+	It("does not revoke sessions without a revocation", func() {
+		Expect(list.IsRevoked(session)).To(BeFalse())
+	})
+
+	It("revokes sessions with a matching session ID", func() {
+		Expect(list.RevokeSessions(context.Background(), "provider", "other", "session")).To(Succeed())
+		Expect(list.IsRevoked(session)).To(BeTrue())
+	})
+
+	It("revokes sessions with a matching subject", func() {
+		Expect(list.RevokeSessions(context.Background(), "provider", "subject", "")).To(Succeed())
+		Expect(list.IsRevoked(session)).To(BeTrue())
+	})
+
+	It("does not revoke sessions from another provider", func() {
+		Expect(list.RevokeSessions(context.Background(), "another", "subject", "")).To(Succeed())
+		Expect(list.IsRevoked(session)).To(BeFalse())
+	})
+
+	It("does not revoke sessions created after the revocation", func() {
+		Expect(list.RevokeSessions(context.Background(), "provider", "subject", "")).To(Succeed())
+
+		created := now.Add(time.Minute)
+		session.CreatedAt = &created
+		Expect(list.IsRevoked(session)).To(BeFalse())
+	})
+
+	It("revokes sessions that are refreshed after the revocation", func() {
+		Expect(list.RevokeSessions(context.Background(), "provider", "subject", "")).To(Succeed())
+
+		issued := now.Add(-time.Minute)
+		session.IssuedAt = &issued
+		Expect(clock.Add(time.Minute)).To(Succeed())
+		session.CreatedAtNow()
+		Expect(list.IsRevoked(session)).To(BeTrue())
+	})
+
+	It("forgets revocations once they expire", func() {
+		Expect(list.RevokeSessions(context.Background(), "provider", "subject", "")).To(Succeed())
+
+		Expect(clock.Add(2 * time.Hour)).To(Succeed())
+		Expect(list.IsRevoked(session)).To(BeFalse())
+	})
+})
@@ -6,16 +6,41 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/cookie"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/etcd"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/memory"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/redis"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/sql"
 )
 
 // NewSessionStore creates a SessionStore from the provided configuration
 func NewSessionStore(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessions.SessionStore, error) {
+	store, err := newSessionStore(opts, cookieOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The concurrent session limit is enforced by the persistent session stores
+	if manager, ok := store.(*persistence.Manager); ok {
+		manager.MaxConcurrent = opts.MaxConcurrent
+		manager.RejectOverLimit = opts.LimitPolicy == options.RejectSessionLimitPolicy
+	}
+	return store, nil
+}
+
+// newSessionStore creates the SessionStore of the configured type
+func newSessionStore(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessions.SessionStore, error) {
 	switch opts.Type {
 	case options.CookieSessionStoreType:
 		return cookie.NewCookieSessionStore(opts, cookieOpts)
 	case options.RedisSessionStoreType:
 		return redis.NewRedisSessionStore(opts, cookieOpts)
+	case options.SQLSessionStoreType:
+		return sql.NewSQLSessionStore(opts, cookieOpts)
+	case options.MemorySessionStoreType:
+		return memory.NewMemorySessionStore(opts, cookieOpts)
+	case options.EtcdSessionStoreType:
+		return etcd.NewEtcdSessionStore(opts, cookieOpts)
 	default:
 		return nil, fmt.Errorf("unknown session store type '%s'", opts.Type)
 	}
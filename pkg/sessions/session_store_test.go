@@ -3,6 +3,7 @@ package sessions_test
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -10,8 +11,11 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions"
 	sessionscookie "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/cookie"
+	sessionsetcd "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/etcd"
+	sessionsmemory "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/memory"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/redis"
+	sessionssql "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/sql"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -76,6 +80,70 @@ var _ = Describe("NewSessionStore", func() {
 		})
 	})
 
+	Context("with type 'sql'", func() {
+		BeforeEach(func() {
+			opts.Type = options.SQLSessionStoreType
+			opts.SQL.Driver = options.SQLiteDriver
+			opts.SQL.ConnectionURL = "file:" + filepath.Join(GinkgoT().TempDir(), "sessions.db")
+		})
+
+		It("creates a persistence.Manager that wraps a sql.SessionStore", func() {
+			ss, err := sessions.NewSessionStore(opts, cookieOpts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss).To(BeAssignableToTypeOf(&persistence.Manager{}))
+
+			store, ok := ss.(*persistence.Manager).Store.(*sessionssql.SessionStore)
+			Expect(ok).To(BeTrue())
+			Expect(store.Close()).To(Succeed())
+		})
+	})
+
+	Context("with type 'etcd'", func() {
+		BeforeEach(func() {
+			opts.Type = options.EtcdSessionStoreType
+			opts.Etcd.Endpoints = []string{"http://127.0.0.1:2379"}
+		})
+
+		It("creates a persistence.Manager that wraps an etcd.SessionStore", func() {
+			ss, err := sessions.NewSessionStore(opts, cookieOpts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss).To(BeAssignableToTypeOf(&persistence.Manager{}))
+
+			store, ok := ss.(*persistence.Manager).Store.(*sessionsetcd.SessionStore)
+			Expect(ok).To(BeTrue())
+			Expect(store.Close()).To(Succeed())
+		})
+	})
+
+	Context("with type 'memory'", func() {
+		BeforeEach(func() {
+			opts.Type = options.MemorySessionStoreType
+		})
+
+		It("creates a persistence.Manager that wraps a memory.SessionStore", func() {
+			ss, err := sessions.NewSessionStore(opts, cookieOpts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss).To(BeAssignableToTypeOf(&persistence.Manager{}))
+
+			store, ok := ss.(*persistence.Manager).Store.(*sessionsmemory.SessionStore)
+			Expect(ok).To(BeTrue())
+			Expect(store.Close()).To(Succeed())
+		})
+
+		It("applies the concurrent session limit", func() {
+			opts.MaxConcurrent = 3
+			opts.LimitPolicy = options.RejectSessionLimitPolicy
+
+			ss, err := sessions.NewSessionStore(opts, cookieOpts)
+			Expect(err).NotTo(HaveOccurred())
+
+			manager := ss.(*persistence.Manager)
+			Expect(manager.MaxConcurrent).To(Equal(3))
+			Expect(manager.RejectOverLimit).To(BeTrue())
+			Expect(manager.Store.(*sessionsmemory.SessionStore).Close()).To(Succeed())
+		})
+	})
+
 	Context("with an invalid type", func() {
 		BeforeEach(func() {
 			opts.Type = "invalid-type"
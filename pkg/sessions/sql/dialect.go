@@ -0,0 +1,134 @@
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+)
+
+// dialect captures the differences between the supported databases
+type dialect struct {
+	// driverName is the name the database/sql driver is registered with
+	driverName string
+
+	// keyType and blobType are the column types of keys and session data
+	keyType  string
+	blobType string
+
+	// numberedPlaceholders is set for databases that use $1, $2, ...
+	// rather than ? as query placeholders
+	numberedPlaceholders bool
+
+	// upsertFormat inserts a row or updates the given columns of an existing
+	// row. It is formatted with the table, the columns, the placeholders,
+	// the conflicting key columns and the assignments of the updated columns.
+	upsertFormat string
+	// updateFormat is the assignment of a column in upsertFormat
+	updateFormat string
+
+	// insertIgnoreFormat inserts a row unless a row with the same key exists.
+	// It is formatted with the table, the columns and the placeholders.
+	insertIgnoreFormat string
+
+	// lockMigrationsFormat and unlockMigrationsFormat take and release a
+	// session level lock that serialises the schema migrations of several
+	// instances. They are formatted with the migrations table and are empty
+	// for databases that do not need them.
+	lockMigrationsFormat   string
+	unlockMigrationsFormat string
+
+	// maxOpenConns limits the connection pool, 0 is unlimited
+	maxOpenConns int
+}
+
+var dialects = map[string]*dialect{
+	options.PostgresSQLDriver: {
+		driverName:             "pgx",
+		keyType:                "VARCHAR(512)",
+		blobType:               "BYTEA",
+		numberedPlaceholders:   true,
+		upsertFormat:           "INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
+		updateFormat:           "%[1]s = EXCLUDED.%[1]s",
+		insertIgnoreFormat:     "INSERT INTO %s (%s) VALUES (%s) ON CONFLICT DO NOTHING",
+		lockMigrationsFormat:   "SELECT pg_advisory_lock(hashtext('%s'))",
+		unlockMigrationsFormat: "SELECT pg_advisory_unlock(hashtext('%s'))",
+	},
+	options.MySQLDriver: {
+		driverName: "mysql",
+		// Keys are ASCII, which keeps the composite primary key of the index
+		// table within the InnoDB key length limit.
+		keyType:                "VARCHAR(512) CHARACTER SET ascii COLLATE ascii_bin",
+		blobType:               "MEDIUMBLOB",
+		upsertFormat:           "INSERT INTO %s (%s) VALUES (%s) ON DUPLICATE KEY UPDATE %[5]s",
+		updateFormat:           "%[1]s = VALUES(%[1]s)",
+		insertIgnoreFormat:     "INSERT IGNORE INTO %s (%s) VALUES (%s)",
+		lockMigrationsFormat:   "SELECT GET_LOCK('%s', -1)",
+		unlockMigrationsFormat: "SELECT RELEASE_LOCK('%s')",
+	},
+	options.SQLiteDriver: {
+		driverName:         "sqlite",
+		keyType:            "TEXT",
+		blobType:           "BLOB",
+		upsertFormat:       "INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
+		updateFormat:       "%[1]s = excluded.%[1]s",
+		insertIgnoreFormat: "INSERT INTO %s (%s) VALUES (%s) ON CONFLICT DO NOTHING",
+		// SQLite allows a single writer, serialise access through one
+		// connection rather than failing with busy errors. The database is
+		// local to the instance, so migrations need no further lock.
+		maxOpenConns: 1,
+	},
+}
+
+// getDialect returns the dialect of the configured database driver
+func getDialect(driver string) (*dialect, error) {
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sql driver %q", driver)
+	}
+	return d, nil
+}
+
+// rebind replaces the ? placeholders of a query with the placeholders of
+// the database
+func (d *dialect) rebind(query string) string {
+	if !d.numberedPlaceholders {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// upsert builds a query that inserts a row or, when a row with the same key
+// exists, updates the remaining columns
+func (d *dialect) upsert(table string, keys []string, columns []string) string {
+	updates := make([]string, 0, len(columns))
+	for _, column := range columns {
+		updates = append(updates, fmt.Sprintf(d.updateFormat, column))
+	}
+	all := append(append([]string{}, keys...), columns...)
+	return d.rebind(fmt.Sprintf(d.upsertFormat,
+		table, strings.Join(all, ", "), placeholders(len(all)), strings.Join(keys, ", "), strings.Join(updates, ", ")))
+}
+
+// insertIgnore builds a query that inserts a row unless a row with the same
+// key exists
+func (d *dialect) insertIgnore(table string, columns []string) string {
+	return d.rebind(fmt.Sprintf(d.insertIgnoreFormat,
+		table, strings.Join(columns, ", "), placeholders(len(columns))))
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
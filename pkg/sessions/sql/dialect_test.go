@@ -0,0 +1,59 @@
+package sql
+
+import (
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SQL dialects", func() {
+	type upsertTableInput struct {
+		driver   string
+		expected string
+	}
+
+	DescribeTable("upsert",
+		func(in upsertTableInput) {
+			d, err := getDialect(in.driver)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.upsert("sessions", []string{"session_key"}, []string{"value", "expires_at"})).To(Equal(in.expected))
+		},
+		Entry("with postgres", upsertTableInput{
+			driver:   options.PostgresSQLDriver,
+			expected: "INSERT INTO sessions (session_key, value, expires_at) VALUES ($1, $2, $3) ON CONFLICT (session_key) DO UPDATE SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at",
+		}),
+		Entry("with mysql", upsertTableInput{
+			driver:   options.MySQLDriver,
+			expected: "INSERT INTO sessions (session_key, value, expires_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE value = VALUES(value), expires_at = VALUES(expires_at)",
+		}),
+		Entry("with sqlite", upsertTableInput{
+			driver:   options.SQLiteDriver,
+			expected: "INSERT INTO sessions (session_key, value, expires_at) VALUES (?, ?, ?) ON CONFLICT (session_key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at",
+		}),
+	)
+
+	type insertIgnoreTableInput struct {
+		driver   string
+		expected string
+	}
+
+	DescribeTable("insertIgnore",
+		func(in insertIgnoreTableInput) {
+			d, err := getDialect(in.driver)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.insertIgnore("locks", []string{"lock_key", "token"})).To(Equal(in.expected))
+		},
+		Entry("with postgres", insertIgnoreTableInput{
+			driver:   options.PostgresSQLDriver,
+			expected: "INSERT INTO locks (lock_key, token) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		}),
+		Entry("with mysql", insertIgnoreTableInput{
+			driver:   options.MySQLDriver,
+			expected: "INSERT IGNORE INTO locks (lock_key, token) VALUES (?, ?)",
+		}),
+		Entry("with sqlite", insertIgnoreTableInput{
+			driver:   options.SQLiteDriver,
+			expected: "INSERT INTO locks (lock_key, token) VALUES (?, ?) ON CONFLICT DO NOTHING",
+		}),
+	)
+})
@@ -0,0 +1,86 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+)
+
+// The locks of the sessions are held in the rows of the locks table, which
+// record the token of the holder and expire so that a crashed holder does not
+// keep the session locked.
+var _ persistence.LockStore = (*SessionStore)(nil)
+
+// InsertLock deletes an expired lock on the key and inserts the lock of the
+// token in one transaction. The insert is ignored when another holder's row
+// remains.
+func (store *SessionStore) InsertLock(ctx context.Context, key, token string, expiration time.Duration) (bool, error) {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("error obtaining sql session lock: %v", err)
+	}
+	defer func() {
+		// Rollback is a no-op once the transaction has been committed
+		_ = tx.Rollback()
+	}()
+
+	// An expired lock no longer prevents others from obtaining the lock
+	_, err = tx.ExecContext(ctx,
+		store.dialect.rebind(fmt.Sprintf("DELETE FROM %s WHERE lock_key = ? AND expires_at <= ?", store.tables.locks)),
+		key, store.now())
+	if err != nil {
+		return false, fmt.Errorf("error obtaining sql session lock: %v", err)
+	}
+
+	result, err := tx.ExecContext(ctx,
+		store.dialect.insertIgnore(store.tables.locks, []string{"lock_key", "token", "expires_at"}),
+		key, token, store.Clock.Now().Add(expiration).UnixMilli())
+	if err != nil {
+		return false, fmt.Errorf("error obtaining sql session lock: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error obtaining sql session lock: %v", err)
+	}
+
+	n, err := result.RowsAffected()
+	return err == nil && n == 1, nil
+}
+
+// ExtendLock updates the expiry of the row of the token, if it has not
+// expired.
+func (store *SessionStore) ExtendLock(ctx context.Context, key, token string, expiration time.Duration) (bool, error) {
+	result, err := store.DB.ExecContext(ctx,
+		store.dialect.rebind(fmt.Sprintf("UPDATE %s SET expires_at = ? WHERE lock_key = ? AND token = ? AND expires_at > ?", store.tables.locks)),
+		store.Clock.Now().Add(expiration).UnixMilli(), key, token, store.now())
+	if err != nil {
+		return false, fmt.Errorf("error refreshing sql session lock: %v", err)
+	}
+	n, err := result.RowsAffected()
+	return err == nil && n == 1, nil
+}
+
+// IsLocked counts the rows of the key that have not expired.
+func (store *SessionStore) IsLocked(ctx context.Context, key string) (bool, error) {
+	var count int
+	err := store.DB.QueryRowContext(ctx,
+		store.dialect.rebind(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE lock_key = ? AND expires_at > ?", store.tables.locks)),
+		key, store.now()).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("error reading sql session lock: %v", err)
+	}
+	return count > 0, nil
+}
+
+// DeleteLock deletes the row of the token.
+func (store *SessionStore) DeleteLock(ctx context.Context, key, token string) (bool, error) {
+	result, err := store.DB.ExecContext(ctx,
+		store.dialect.rebind(fmt.Sprintf("DELETE FROM %s WHERE lock_key = ? AND token = ?", store.tables.locks)),
+		key, token)
+	if err != nil {
+		return false, fmt.Errorf("error releasing sql session lock: %v", err)
+	}
+	n, err := result.RowsAffected()
+	return err == nil && n == 1, nil
+}
@@ -0,0 +1,91 @@
+package sql
+
+import (
+	"context"
+	gosql "database/sql"
+	"fmt"
+)
+
+// migration returns the statements that upgrade the schema by one version
+type migration func(t tables, d *dialect) []string
+
+// migrations upgrade the schema of the session store.
+// Migrations must never be changed once released, changes to the schema are
+// made by appending a new migration.
+var migrations = []migration{
+	// 1: sessions, secondary indexes and locks
+	func(t tables, d *dialect) []string {
+		return []string{
+			fmt.Sprintf("CREATE TABLE %s (session_key %s NOT NULL PRIMARY KEY, value %s NOT NULL, expires_at BIGINT NOT NULL)",
+				t.sessions, d.keyType, d.blobType),
+			fmt.Sprintf("CREATE INDEX %[1]s_expires_at ON %[1]s (expires_at)", t.sessions),
+			fmt.Sprintf("CREATE TABLE %s (index_key %s NOT NULL, member %s NOT NULL, expires_at BIGINT NOT NULL, PRIMARY KEY (index_key, member))",
+				t.indexes, d.keyType, d.keyType),
+			fmt.Sprintf("CREATE INDEX %[1]s_expires_at ON %[1]s (expires_at)", t.indexes),
+			fmt.Sprintf("CREATE TABLE %s (lock_key %s NOT NULL PRIMARY KEY, token VARCHAR(64) NOT NULL, expires_at BIGINT NOT NULL)",
+				t.locks, d.keyType),
+		}
+	},
+}
+
+// migrate applies the migrations that have not been applied to the database
+// yet. Each migration is applied in a transaction along with the update of
+// the schema version. Instances starting together are serialised by a
+// database lock held on a single connection, so that a migration is never
+// applied twice.
+func migrate(ctx context.Context, db *gosql.DB, t tables, d *dialect) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error connecting to migrate the schema: %v", err)
+	}
+	defer conn.Close()
+
+	if d.lockMigrationsFormat != "" {
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf(d.lockMigrationsFormat, t.migrations)); err != nil {
+			return fmt.Errorf("error locking schema migrations: %v", err)
+		}
+		defer func() {
+			// The outcome of the migrations has been returned by then
+			_, _ = conn.ExecContext(context.Background(), fmt.Sprintf(d.unlockMigrationsFormat, t.migrations))
+		}()
+	}
+
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version INTEGER NOT NULL)", t.migrations))
+	if err != nil {
+		return fmt.Errorf("error creating schema migrations table: %v", err)
+	}
+
+	var version int
+	err = conn.QueryRowContext(ctx, fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s", t.migrations)).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("error reading schema version: %v", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if err := applyMigration(ctx, conn, t, d, i+1); err != nil {
+			return fmt.Errorf("error applying schema migration %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *gosql.Conn, t tables, d *dialect, version int) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Rollback is a no-op once the transaction has been committed
+		_ = tx.Rollback()
+	}()
+
+	for _, statement := range migrations[version-1](t, d) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, d.rebind(fmt.Sprintf("INSERT INTO %s (version) VALUES (?)", t.migrations)), version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,262 @@
+package sql
+
+import (
+	"context"
+	gosql "database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	// Database drivers supported by the SessionStore
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+)
+
+// tablePrefixPattern restricts the table prefix, which is interpolated into
+// queries, to characters that are valid in unquoted identifiers
+var tablePrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_]*$`)
+
+// tables holds the names of the tables of the session store
+type tables struct {
+	sessions   string
+	indexes    string
+	locks      string
+	migrations string
+}
+
+func newTables(prefix string) tables {
+	return tables{
+		sessions:   prefix + "sessions",
+		indexes:    prefix + "session_indexes",
+		locks:      prefix + "session_locks",
+		migrations: prefix + "schema_migrations",
+	}
+}
+
+// SessionStore is an implementation of the persistence.Store
+// interface that stores sessions in a SQL database
+type SessionStore struct {
+	DB *gosql.DB
+
+	// Clock is used to expire sessions, indexes and locks
+	Clock clock.Clock
+
+	dialect *dialect
+	tables  tables
+	reaper  persistence.Reaper
+}
+
+// NewSQLSessionStore initialises a new instance of the SessionStore, starts
+// removing expired sessions in the background and wraps it in a
+// persistence.Manager
+func NewSQLSessionStore(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessions.SessionStore, error) {
+	store, err := NewStore(context.Background(), opts.SQL)
+	if err != nil {
+		return nil, err
+	}
+	store.StartReaper(opts.SQL.ReapInterval)
+
+	return persistence.NewManager(store, cookieOpts), nil
+}
+
+// NewStore connects to the database and migrates its schema to the latest
+// version
+func NewStore(ctx context.Context, opts options.SQLStoreOptions) (*SessionStore, error) {
+	d, err := getDialect(opts.Driver)
+	if err != nil {
+		return nil, err
+	}
+	if !tablePrefixPattern.MatchString(opts.TablePrefix) {
+		return nil, fmt.Errorf("invalid sql table prefix %q: only letters, digits and underscores are allowed", opts.TablePrefix)
+	}
+
+	db, err := gosql.Open(d.driverName, opts.ConnectionURL)
+	if err != nil {
+		return nil, fmt.Errorf("error opening sql database: %v", err)
+	}
+	if d.maxOpenConns > 0 {
+		db.SetMaxOpenConns(d.maxOpenConns)
+	}
+
+	store := &SessionStore{
+		DB:      db,
+		dialect: d,
+		tables:  newTables(opts.TablePrefix),
+	}
+	if err := migrate(ctx, db, store.tables, d); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// Save upserts the row of the key with the value and its expiry
+func (store *SessionStore) Save(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	_, err := store.DB.ExecContext(ctx,
+		store.dialect.upsert(store.tables.sessions, []string{"session_key"}, []string{"value", "expires_at"}),
+		key, value, store.expiresAt(exp))
+	if err != nil {
+		return fmt.Errorf("error saving sql session: %v", err)
+	}
+	return nil
+}
+
+// Load selects the value of the row of the key. Rows that expired but have
+// not been reaped yet are not selected.
+func (store *SessionStore) Load(ctx context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := store.DB.QueryRowContext(ctx,
+		store.dialect.rebind(fmt.Sprintf("SELECT value FROM %s WHERE session_key = ? AND (expires_at = 0 OR expires_at > ?)", store.tables.sessions)),
+		key, store.now()).Scan(&value)
+	if errors.Is(err, gosql.ErrNoRows) {
+		return nil, errors.New("error loading sql session: session not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading sql session: %v", err)
+	}
+	return value, nil
+}
+
+// Clear deletes the row of the key
+func (store *SessionStore) Clear(ctx context.Context, key string) error {
+	_, err := store.DB.ExecContext(ctx,
+		store.dialect.rebind(fmt.Sprintf("DELETE FROM %s WHERE session_key = ?", store.tables.sessions)), key)
+	if err != nil {
+		return fmt.Errorf("error clearing the session from sql: %v", err)
+	}
+	return nil
+}
+
+// Lock creates a lock on the session held in the locks table
+func (store *SessionStore) Lock(key string) sessions.Lock {
+	return persistence.NewTokenLock(store, key)
+}
+
+// VerifyConnection verifies the database connection is valid and the
+// server is responsive
+func (store *SessionStore) VerifyConnection(ctx context.Context) error {
+	return store.DB.PingContext(ctx)
+}
+
+// AddToIndex upserts the row of the member of the index and, in the same
+// transaction, extends the expiry of the other members to at least the
+// given duration
+func (store *SessionStore) AddToIndex(ctx context.Context, index string, member string, exp time.Duration) error {
+	expiresAt := store.expiresAt(exp)
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error adding to sql session index: %v", err)
+	}
+	defer func() {
+		// Rollback is a no-op once the transaction has been committed
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx,
+		store.dialect.upsert(store.tables.indexes, []string{"index_key", "member"}, []string{"expires_at"}),
+		index, member, expiresAt)
+	if err != nil {
+		return fmt.Errorf("error adding to sql session index: %v", err)
+	}
+	if expiresAt == 0 {
+		_, err = tx.ExecContext(ctx,
+			store.dialect.rebind(fmt.Sprintf("UPDATE %s SET expires_at = 0 WHERE index_key = ? AND expires_at <> 0", store.tables.indexes)),
+			index)
+	} else {
+		_, err = tx.ExecContext(ctx,
+			store.dialect.rebind(fmt.Sprintf("UPDATE %s SET expires_at = ? WHERE index_key = ? AND expires_at <> 0 AND expires_at < ?", store.tables.indexes)),
+			expiresAt, index, expiresAt)
+	}
+	if err != nil {
+		return fmt.Errorf("error extending sql session index: %v", err)
+	}
+	return tx.Commit()
+}
+
+// LoadIndex selects the members of the index that have not expired
+func (store *SessionStore) LoadIndex(ctx context.Context, index string) ([]string, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		store.dialect.rebind(fmt.Sprintf("SELECT member FROM %s WHERE index_key = ? AND (expires_at = 0 OR expires_at > ?)", store.tables.indexes)),
+		index, store.now())
+	if err != nil {
+		return nil, fmt.Errorf("error loading sql session index: %v", err)
+	}
+	defer rows.Close()
+
+	members := []string{}
+	for rows.Next() {
+		var member string
+		if err := rows.Scan(&member); err != nil {
+			return nil, fmt.Errorf("error loading sql session index: %v", err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error loading sql session index: %v", err)
+	}
+	return members, nil
+}
+
+// RemoveFromIndex deletes the rows of the members of the index
+func (store *SessionStore) RemoveFromIndex(ctx context.Context, index string, members ...string) error {
+	query := store.dialect.rebind(fmt.Sprintf("DELETE FROM %s WHERE index_key = ? AND member = ?", store.tables.indexes))
+	for _, member := range members {
+		if _, err := store.DB.ExecContext(ctx, query, index, member); err != nil {
+			return fmt.Errorf("error removing from sql session index: %v", err)
+		}
+	}
+	return nil
+}
+
+// StartReaper removes expired sessions, indexes and locks from the database
+// every interval until the store is closed.
+// Expired rows are never returned by the store, the reaper only reclaims
+// their space.
+func (store *SessionStore) StartReaper(interval time.Duration) {
+	store.reaper.Start(interval, func() {
+		if err := store.Reap(context.Background()); err != nil {
+			logger.Errorf("error removing expired sql sessions: %v", err)
+		}
+	})
+}
+
+// Reap removes expired sessions, indexes and locks from the database
+func (store *SessionStore) Reap(ctx context.Context) error {
+	now := store.now()
+	for _, table := range []string{store.tables.sessions, store.tables.indexes, store.tables.locks} {
+		_, err := store.DB.ExecContext(ctx,
+			store.dialect.rebind(fmt.Sprintf("DELETE FROM %s WHERE expires_at <> 0 AND expires_at <= ?", table)), now)
+		if err != nil {
+			return fmt.Errorf("error removing expired rows from %s: %v", table, err)
+		}
+	}
+	return nil
+}
+
+// Close stops the reaper and closes the database connections
+func (store *SessionStore) Close() error {
+	store.reaper.Stop()
+	return store.DB.Close()
+}
+
+// now returns the current time as stored in the expires_at columns
+func (store *SessionStore) now() int64 {
+	return store.Clock.Now().UnixMilli()
+}
+
+// expiresAt returns the expires_at column value of a row that expires after
+// the duration. A row that never expires has an expires_at of 0.
+func (store *SessionStore) expiresAt(exp time.Duration) int64 {
+	if exp <= 0 {
+		return 0
+	}
+	return store.Clock.Now().Add(exp).UnixMilli()
+}
@@ -0,0 +1,205 @@
+package sql
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	sessionsapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/persistence"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/tests"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SQL SessionStore Tests", func() {
+	var connectionURL string
+	var stores []*SessionStore
+	var now time.Time
+
+	BeforeEach(func() {
+		connectionURL = "file:" + filepath.Join(GinkgoT().TempDir(), "sessions.db")
+		stores = nil
+		now = time.Now()
+	})
+
+	JustAfterEach(func() {
+		for _, store := range stores {
+			Expect(store.Close()).To(Succeed())
+		}
+	})
+
+	tests.RunSessionStoreTests(
+		func(opts *options.SessionOptions, cookieOpts *options.Cookie) (sessionsapi.SessionStore, error) {
+			opts.Type = options.SQLSessionStoreType
+			opts.SQL = options.SQLStoreOptions{
+				Driver:        options.SQLiteDriver,
+				ConnectionURL: connectionURL,
+				TablePrefix:   "oauth2_proxy_",
+				ReapInterval:  time.Minute,
+			}
+
+			ss, err := NewSQLSessionStore(opts, cookieOpts)
+			if err != nil {
+				return nil, err
+			}
+			// Capture the store so that we can control its clock and close it
+			store := ss.(*persistence.Manager).Store.(*SessionStore)
+			store.Clock.Set(now)
+			stores = append(stores, store)
+			return ss, nil
+		},
+		func(d time.Duration) error {
+			now = now.Add(d)
+			for _, store := range stores {
+				if err := store.Clock.Add(d); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+
+	Context("with a store", func() {
+		var store *SessionStore
+		ctx := context.Background()
+
+		BeforeEach(func() {
+			var err error
+			store, err = NewStore(ctx, options.SQLStoreOptions{
+				Driver:        options.SQLiteDriver,
+				ConnectionURL: connectionURL,
+				TablePrefix:   "test_",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			store.Clock.Set(now)
+			stores = append(stores, store)
+		})
+
+		It("does not migrate the schema again", func() {
+			again, err := NewStore(ctx, options.SQLStoreOptions{
+				Driver:        options.SQLiteDriver,
+				ConnectionURL: connectionURL,
+				TablePrefix:   "test_",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			stores = append(stores, again)
+
+			var versions int
+			Expect(store.DB.QueryRow("SELECT COUNT(*) FROM test_schema_migrations").Scan(&versions)).To(Succeed())
+			Expect(versions).To(Equal(len(migrations)))
+		})
+
+		It("does not load expired sessions", func() {
+			Expect(store.Save(ctx, "key", []byte("value"), time.Minute)).To(Succeed())
+			value, err := store.Load(ctx, "key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal([]byte("value")))
+
+			Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+			_, err = store.Load(ctx, "key")
+			Expect(err).To(MatchError("error loading sql session: session not found"))
+		})
+
+		It("keeps sessions without an expiration", func() {
+			Expect(store.Save(ctx, "key", []byte("value"), 0)).To(Succeed())
+			Expect(store.Clock.Add(24 * time.Hour)).To(Succeed())
+			Expect(store.Reap(ctx)).To(Succeed())
+
+			value, err := store.Load(ctx, "key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal([]byte("value")))
+		})
+
+		It("reaps expired sessions, indexes and locks", func() {
+			Expect(store.Save(ctx, "expired", []byte("value"), time.Minute)).To(Succeed())
+			Expect(store.Save(ctx, "current", []byte("value"), time.Hour)).To(Succeed())
+			Expect(store.AddToIndex(ctx, "index", "expired", time.Minute)).To(Succeed())
+			Expect(store.Lock("expired").Obtain(ctx, time.Minute)).To(Succeed())
+
+			Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+			Expect(store.Reap(ctx)).To(Succeed())
+
+			for table, expected := range map[string]int{"test_sessions": 1, "test_session_indexes": 0, "test_session_locks": 0} {
+				var count int
+				Expect(store.DB.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count)).To(Succeed())
+				Expect(count).To(Equal(expected), table)
+			}
+		})
+
+		It("extends the expiration of all members of an index", func() {
+			Expect(store.AddToIndex(ctx, "index", "first", time.Minute)).To(Succeed())
+			Expect(store.AddToIndex(ctx, "index", "second", time.Hour)).To(Succeed())
+
+			Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+			members, err := store.LoadIndex(ctx, "index")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(members).To(ConsistOf("first", "second"))
+		})
+
+		It("removes the expiration of all members of an index", func() {
+			Expect(store.AddToIndex(ctx, "index", "first", time.Minute)).To(Succeed())
+			Expect(store.AddToIndex(ctx, "index", "second", 0)).To(Succeed())
+
+			Expect(store.Clock.Add(24 * time.Hour)).To(Succeed())
+			members, err := store.LoadIndex(ctx, "index")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(members).To(ConsistOf("first", "second"))
+		})
+
+		It("rejects an invalid table prefix", func() {
+			_, err := NewStore(ctx, options.SQLStoreOptions{
+				Driver:        options.SQLiteDriver,
+				ConnectionURL: connectionURL,
+				TablePrefix:   "sessions; DROP TABLE users; --",
+			})
+			Expect(err).To(MatchError(ContainSubstring("invalid sql table prefix")))
+		})
+
+		It("rejects an unsupported driver", func() {
+			_, err := NewStore(ctx, options.SQLStoreOptions{
+				Driver:        "oracle",
+				ConnectionURL: connectionURL,
+			})
+			Expect(err).To(MatchError("unsupported sql driver \"oracle\""))
+		})
+
+		Context("with a lock", func() {
+			var lock, other sessionsapi.Lock
+
+			BeforeEach(func() {
+				lock = store.Lock("ticket")
+				other = store.Lock("ticket")
+				Expect(lock.Obtain(ctx, time.Minute)).To(Succeed())
+			})
+
+			It("cannot be obtained by another holder", func() {
+				Expect(other.Obtain(ctx, time.Minute)).To(MatchError(sessionsapi.ErrLockNotObtained))
+				Expect(other.Release(ctx)).To(MatchError(sessionsapi.ErrNotLocked))
+				Expect(other.Refresh(ctx, time.Minute)).To(MatchError(sessionsapi.ErrNotLocked))
+			})
+
+			It("can be obtained by another holder once released", func() {
+				Expect(lock.Release(ctx)).To(Succeed())
+				Expect(other.Obtain(ctx, time.Minute)).To(Succeed())
+			})
+
+			It("can be obtained by another holder once expired", func() {
+				Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+				Expect(other.Obtain(ctx, time.Minute)).To(Succeed())
+				Expect(lock.Release(ctx)).To(MatchError(sessionsapi.ErrNotLocked))
+			})
+
+			It("is extended when refreshed", func() {
+				Expect(lock.Refresh(ctx, 5*time.Minute)).To(Succeed())
+				Expect(store.Clock.Add(2 * time.Minute)).To(Succeed())
+
+				locked, err := other.Peek(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(locked).To(BeTrue())
+				Expect(other.Obtain(ctx, time.Minute)).To(MatchError(sessionsapi.ErrLockNotObtained))
+			})
+		})
+	})
+})
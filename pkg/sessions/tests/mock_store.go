@@ -14,20 +14,28 @@ type entry struct {
 	expiration time.Duration
 }
 
+// indexEntry is a MockStore index entry with an expiration
+type indexEntry struct {
+	members    map[string]struct{}
+	expiration time.Duration
+}
+
 // MockStore is a generic in-memory implementation of persistence.Store
 // for mocking in tests
 type MockStore struct {
-	cache     map[string]entry
-	lockCache map[string]*MockLock
-	elapsed   time.Duration
+	cache      map[string]entry
+	indexCache map[string]indexEntry
+	lockCache  map[string]*MockLock
+	elapsed    time.Duration
 }
 
 // NewMockStore creates a MockStore
 func NewMockStore() *MockStore {
 	return &MockStore{
-		cache:     map[string]entry{},
-		lockCache: map[string]*MockLock{},
-		elapsed:   0 * time.Second,
+		cache:      map[string]entry{},
+		indexCache: map[string]indexEntry{},
+		lockCache:  map[string]*MockLock{},
+		elapsed:    0 * time.Second,
 	}
 }
 
@@ -69,6 +77,49 @@ func (s *MockStore) VerifyConnection(_ context.Context) error {
 	return nil
 }
 
+// AddToIndex adds a member to an index in the memory cache
+func (s *MockStore) AddToIndex(_ context.Context, index string, member string, exp time.Duration) error {
+	idx, ok := s.indexCache[index]
+	if !ok || idx.expiration <= s.elapsed {
+		idx = indexEntry{members: map[string]struct{}{}}
+	}
+	idx.members[member] = struct{}{}
+	if s.elapsed+exp > idx.expiration {
+		idx.expiration = s.elapsed + exp
+	}
+	s.indexCache[index] = idx
+	return nil
+}
+
+// LoadIndex gets the members of an index from the memory cache
+func (s *MockStore) LoadIndex(_ context.Context, index string) ([]string, error) {
+	idx, ok := s.indexCache[index]
+	if !ok || idx.expiration <= s.elapsed {
+		delete(s.indexCache, index)
+		return nil, nil
+	}
+	members := make([]string, 0, len(idx.members))
+	for member := range idx.members {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// RemoveFromIndex deletes members of an index from the memory cache
+func (s *MockStore) RemoveFromIndex(_ context.Context, index string, members ...string) error {
+	idx, ok := s.indexCache[index]
+	if !ok {
+		return nil
+	}
+	for _, member := range members {
+		delete(idx.members, member)
+	}
+	if len(idx.members) == 0 {
+		delete(s.indexCache, index)
+	}
+	return nil
+}
+
 // FastForward simulates the flow of time to test expirations
 func (s *MockStore) FastForward(duration time.Duration) {
 	for _, mockLock := range s.lockCache {
@@ -133,6 +133,63 @@ func RunSessionStoreTests(newSS NewSessionStoreFunc, persistentFastForward Persi
 				PersistentSessionStoreInterfaceTests(&input)
 			}
 		})
+
+		Context("with a rotated cookie secret", func() {
+			var previousSecret string
+
+			BeforeEach(func() {
+				// Save the session with the previous secret
+				var err error
+				ss, err = newSS(opts, input.cookieOpts)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ss.Save(input.response, input.request, input.session)).To(Succeed())
+				for _, cookie := range input.response.Result().Cookies() {
+					input.request.AddCookie(cookie)
+				}
+
+				secret := make([]byte, 32)
+				_, err = rand.Read(secret)
+				Expect(err).ToNot(HaveOccurred())
+
+				previousSecret = input.cookieOpts.Secret
+				rotated := *input.cookieOpts
+				rotated.Secret = string(secret)
+				rotated.PreviousSecrets = []string{previousSecret}
+				input.cookieOpts = &rotated
+
+				ss, err = newSS(opts, input.cookieOpts)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("loads sessions saved with the previous secret", func() {
+				loadedSession, err := ss.Load(input.request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(loadedSession.AccessToken).To(Equal(input.session.AccessToken))
+				Expect(loadedSession.Email).To(Equal(input.session.Email))
+			})
+
+			It("signs sessions with the current secret when they are saved again", func() {
+				loadedSession, err := ss.Load(input.request)
+				Expect(err).ToNot(HaveOccurred())
+
+				resp := httptest.NewRecorder()
+				Expect(ss.Save(resp, input.request, loadedSession)).To(Succeed())
+
+				cookies := resp.Result().Cookies()
+				Expect(cookies).ToNot(BeEmpty())
+				_, _, ok := encryption.Validate(cookies[0], input.cookieOpts.Secret, input.cookieOpts.Expire)
+				Expect(ok).To(BeTrue())
+				_, _, ok = encryption.Validate(cookies[0], previousSecret, input.cookieOpts.Expire)
+				Expect(ok).To(BeFalse())
+			})
+
+			It("does not load sessions once the previous secret is removed", func() {
+				input.cookieOpts.PreviousSecrets = nil
+
+				_, err := ss.Load(input.request)
+				Expect(err).To(HaveOccurred())
+			})
+		})
 	})
 }
 
@@ -358,6 +415,194 @@ func PersistentSessionStoreInterfaceTests(in *testInput) {
 			})
 		})
 	})
+
+	Context("when RevokeSessions is called on a persistent store", func() {
+		var firstReq, secondReq, otherReq *http.Request
+
+		saveSession := func(subject, sessionID string) *http.Request {
+			session := *in.session
+			session.ProviderID = "provider"
+			session.Subject = subject
+			session.SessionID = sessionID
+
+			resp := httptest.NewRecorder()
+			err := in.ss().Save(resp, httptest.NewRequest("GET", "http://example.com/", nil), &session)
+			Expect(err).ToNot(HaveOccurred())
+
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			for _, cookie := range resp.Result().Cookies() {
+				req.AddCookie(cookie)
+			}
+			return req
+		}
+
+		expectLoaded := func(req *http.Request, loaded bool) {
+			session, err := in.ss().Load(req)
+			if loaded {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(session).ToNot(BeNil())
+			} else {
+				Expect(err).To(HaveOccurred())
+				Expect(session).To(BeNil())
+			}
+		}
+
+		BeforeEach(func() {
+			firstReq = saveSession("subject", "sid-1")
+			secondReq = saveSession("subject", "sid-2")
+			otherReq = saveSession("other", "sid-3")
+		})
+
+		It("implements the SessionRevoker interface", func() {
+			_, ok := in.ss().(sessionsapi.SessionRevoker)
+			Expect(ok).To(BeTrue())
+		})
+
+		It("clears only the session with the matching session ID", func() {
+			revoker := in.ss().(sessionsapi.SessionRevoker)
+			Expect(revoker.RevokeSessions(in.request.Context(), "provider", "subject", "sid-1")).To(Succeed())
+
+			expectLoaded(firstReq, false)
+			expectLoaded(secondReq, true)
+			expectLoaded(otherReq, true)
+		})
+
+		It("clears all sessions of the subject without a session ID", func() {
+			revoker := in.ss().(sessionsapi.SessionRevoker)
+			Expect(revoker.RevokeSessions(in.request.Context(), "provider", "subject", "")).To(Succeed())
+
+			expectLoaded(firstReq, false)
+			expectLoaded(secondReq, false)
+			expectLoaded(otherReq, true)
+		})
+
+		It("does not clear sessions issued by another provider", func() {
+			revoker := in.ss().(sessionsapi.SessionRevoker)
+			Expect(revoker.RevokeSessions(in.request.Context(), "another", "subject", "")).To(Succeed())
+
+			expectLoaded(firstReq, true)
+			expectLoaded(secondReq, true)
+		})
+	})
+
+	Context("when sessions are administered on a persistent store", func() {
+		var aliceReq, aliceOtherReq, bobReq, aliceOtherProviderReq *http.Request
+		var admin sessionsapi.SessionAdministrator
+
+		saveSession := func(providerID, user, email, userAgent string) *http.Request {
+			session := *in.session
+			session.ProviderID = providerID
+			session.User = user
+			session.Email = email
+
+			saveReq := httptest.NewRequest("GET", "http://example.com/", nil)
+			saveReq.Header.Set("User-Agent", userAgent)
+			resp := httptest.NewRecorder()
+			Expect(in.ss().Save(resp, saveReq, &session)).To(Succeed())
+
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			for _, cookie := range resp.Result().Cookies() {
+				req.AddCookie(cookie)
+			}
+			return req
+		}
+
+		expectLoaded := func(req *http.Request, loaded bool) {
+			_, err := in.ss().Load(req)
+			if loaded {
+				Expect(err).ToNot(HaveOccurred())
+			} else {
+				Expect(err).To(HaveOccurred())
+			}
+		}
+
+		BeforeEach(func() {
+			aliceReq = saveSession("corp", "alice", "alice@example.com", "browser-1")
+			aliceOtherReq = saveSession("corp", "alice", "alice@example.com", "browser-2")
+			bobReq = saveSession("corp", "bob", "bob@example.com", "browser-3")
+			// The same user name at another provider is another user
+			aliceOtherProviderReq = saveSession("other", "alice", "alice@example.com", "browser-4")
+
+			var ok bool
+			admin, ok = in.ss().(sessionsapi.SessionAdministrator)
+			Expect(ok).To(BeTrue())
+		})
+
+		It("lists the sessions of a user by user name", func() {
+			infos, err := admin.ListSessions(in.request.Context(), "corp", "alice")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(infos).To(HaveLen(2))
+
+			userAgents := []string{}
+			for _, info := range infos {
+				Expect(info.ID).ToNot(BeEmpty())
+				Expect(info.User).To(Equal("alice"))
+				Expect(info.Email).To(Equal("alice@example.com"))
+				Expect(info.CreatedAt).ToNot(BeNil())
+				Expect(info.ExpiresAt).ToNot(BeNil())
+				userAgents = append(userAgents, info.UserAgent)
+			}
+			Expect(userAgents).To(ConsistOf("browser-1", "browser-2"))
+		})
+
+		It("lists the sessions of a user of each provider separately", func() {
+			infos, err := admin.ListSessions(in.request.Context(), "other", "alice")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(infos).To(HaveLen(1))
+			Expect(infos[0].ProviderID).To(Equal("other"))
+			Expect(infos[0].UserAgent).To(Equal("browser-4"))
+		})
+
+		It("lists the sessions of a user by email address", func() {
+			infos, err := admin.ListSessions(in.request.Context(), "corp", "bob@example.com")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(infos).To(HaveLen(1))
+			Expect(infos[0].UserAgent).To(Equal("browser-3"))
+		})
+
+		It("does not list sessions that have been cleared", func() {
+			resp := httptest.NewRecorder()
+			Expect(in.ss().Clear(resp, aliceReq)).To(Succeed())
+
+			infos, err := admin.ListSessions(in.request.Context(), "corp", "alice")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(infos).To(HaveLen(1))
+			Expect(infos[0].UserAgent).To(Equal("browser-2"))
+		})
+
+		It("revokes a single session by ID", func() {
+			infos, err := admin.ListSessions(in.request.Context(), "corp", "alice")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(infos).To(HaveLen(2))
+
+			revoked := infos[0]
+			Expect(admin.RevokeSession(in.request.Context(), revoked.ID)).To(Succeed())
+
+			infos, err = admin.ListSessions(in.request.Context(), "corp", "alice")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(infos).To(HaveLen(1))
+			Expect(infos[0].ID).ToNot(Equal(revoked.ID))
+			expectLoaded(bobReq, true)
+		})
+
+		It("returns ErrSessionNotFound for an unknown session ID", func() {
+			Expect(admin.RevokeSession(in.request.Context(), "unknown")).To(MatchError(sessionsapi.ErrSessionNotFound))
+			Expect(admin.RevokeSession(in.request.Context(), in.cookieOpts.Name+"-00000000000000000000000000000000")).To(MatchError(sessionsapi.ErrSessionNotFound))
+		})
+
+		It("revokes all sessions of a user", func() {
+			Expect(admin.RevokeUserSessions(in.request.Context(), "corp", "alice@example.com")).To(Succeed())
+
+			expectLoaded(aliceReq, false)
+			expectLoaded(aliceOtherReq, false)
+			expectLoaded(bobReq, true)
+			expectLoaded(aliceOtherProviderReq, true)
+
+			infos, err := admin.ListSessions(in.request.Context(), "corp", "alice")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(infos).To(BeEmpty())
+		})
+	})
 }
 
 func SessionStoreInterfaceTests(in *testInput) {
@@ -11,6 +11,7 @@ import (
 	"github.com/mbland/hmacauth"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/httpsig"
 )
 
 const (
@@ -41,7 +42,8 @@ var SignatureHeaders = []string{
 
 // newHTTPUpstreamProxy creates a new httpUpstreamProxy that can serve requests
 // to a single upstream host.
-func newHTTPUpstreamProxy(upstream options.Upstream, u *url.URL, sigData *options.SignatureData, errorHandler ProxyErrorHandler) http.Handler {
+// Requests are signed with an HTTP Message Signature when a signer is given.
+func newHTTPUpstreamProxy(upstream options.Upstream, u *url.URL, sigData *options.SignatureData, signer *httpsig.Signer, errorHandler ProxyErrorHandler) http.Handler {
 	// Set path to empty so that request paths start at the server root
 	// Unix scheme need the path to find the socket
 	if u.Scheme != "unix" {
@@ -49,12 +51,12 @@ func newHTTPUpstreamProxy(upstream options.Upstream, u *url.URL, sigData *option
 	}
 
 	// Create a ReverseProxy
-	proxy := newReverseProxy(u, upstream, errorHandler)
+	proxy := newReverseProxy(u, upstream, signer, errorHandler)
 
 	// Set up a WebSocket proxy if required
 	var wsProxy http.Handler
 	if upstream.ProxyWebSockets == nil || *upstream.ProxyWebSockets {
-		wsProxy = newWebSocketReverseProxy(u, upstream.InsecureSkipTLSVerify)
+		wsProxy = newWebSocketReverseProxy(u, upstream.InsecureSkipTLSVerify, signer)
 	}
 
 	var auth hmacauth.HmacAuth
@@ -87,6 +89,8 @@ func (h *httpUpstreamProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request)
 	scope.Upstream = h.upstream
 
 	// TODO (@NickMeves) - Deprecate GAP-Signature & remove GAP-Auth
+	// GAP-Signature is replaced by the HTTP Message Signatures configured with
+	// the MessageSignature of the upstream.
 	if h.auth != nil {
 		req.Header.Set("GAP-Auth", rw.Header().Get("GAP-Auth"))
 		h.auth.SignRequest(req)
@@ -121,7 +125,7 @@ func (t *unixRoundTripper) RoundTrip(req *http.Request) (*http.Response, error)
 // servers based on the upstream configuration provided.
 // The proxy should render an error page if there are failures connecting to the
 // upstream server.
-func newReverseProxy(target *url.URL, upstream options.Upstream, errorHandler ProxyErrorHandler) http.Handler {
+func newReverseProxy(target *url.URL, upstream options.Upstream, signer *httpsig.Signer, errorHandler ProxyErrorHandler) http.Handler {
 	proxy := httputil.NewSingleHostReverseProxy(target)
 
 	// Inherit default transport options from Go's stdlib
@@ -167,7 +171,7 @@ func newReverseProxy(target *url.URL, upstream options.Upstream, errorHandler Pr
 	}
 
 	// Apply the customized transport to our proxy before returning it
-	proxy.Transport = transport
+	proxy.Transport = newSigningTransport(transport, signer)
 
 	return proxy
 }
@@ -196,7 +200,7 @@ func setProxyDirector(proxy *httputil.ReverseProxy) {
 }
 
 // newWebSocketReverseProxy creates a new reverse proxy for proxying websocket connections.
-func newWebSocketReverseProxy(u *url.URL, skipTLSVerify bool) http.Handler {
+func newWebSocketReverseProxy(u *url.URL, skipTLSVerify bool, signer *httpsig.Signer) http.Handler {
 	wsProxy := httputil.NewSingleHostReverseProxy(u)
 
 	// Inherit default transport options from Go's stdlib
@@ -208,7 +212,7 @@ func newWebSocketReverseProxy(u *url.URL, skipTLSVerify bool) http.Handler {
 	}
 
 	// Apply the customized transport to our proxy before returning it
-	wsProxy.Transport = transport
+	wsProxy.Transport = newSigningTransport(transport, signer)
 
 	return wsProxy
 }
@@ -74,7 +74,7 @@ var _ = Describe("HTTP Upstream Suite", func() {
 			u, err := url.Parse(*in.serverAddr)
 			Expect(err).ToNot(HaveOccurred())
 
-			handler := newHTTPUpstreamProxy(upstream, u, in.signatureData, in.errorHandler)
+			handler := newHTTPUpstreamProxy(upstream, u, in.signatureData, nil, in.errorHandler)
 			handler.ServeHTTP(rw, req)
 
 			Expect(rw.Code).To(Equal(in.expectedResponse.code))
@@ -353,7 +353,7 @@ var _ = Describe("HTTP Upstream Suite", func() {
 		u, err := url.Parse(serverAddr)
 		Expect(err).ToNot(HaveOccurred())
 
-		handler := newHTTPUpstreamProxy(upstream, u, nil, nil)
+		handler := newHTTPUpstreamProxy(upstream, u, nil, nil, nil)
 		httpUpstream, ok := handler.(*httpUpstreamProxy)
 		Expect(ok).To(BeTrue())
 
@@ -393,7 +393,7 @@ var _ = Describe("HTTP Upstream Suite", func() {
 				Timeout:               &in.timeout,
 			}
 
-			handler := newHTTPUpstreamProxy(upstream, u, in.sigData, in.errorHandler)
+			handler := newHTTPUpstreamProxy(upstream, u, in.sigData, nil, in.errorHandler)
 			upstreamProxy, ok := handler.(*httpUpstreamProxy)
 			Expect(ok).To(BeTrue())
 
@@ -483,7 +483,7 @@ var _ = Describe("HTTP Upstream Suite", func() {
 			u, err := url.Parse(serverAddr)
 			Expect(err).ToNot(HaveOccurred())
 
-			handler := newHTTPUpstreamProxy(upstream, u, nil, nil)
+			handler := newHTTPUpstreamProxy(upstream, u, nil, nil, nil)
 
 			proxyServer = httptest.NewServer(middleware.NewScope(false, "X-Request-Id")(handler))
 		})
@@ -0,0 +1,99 @@
+package upstream
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options/util"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/httpsig"
+)
+
+// defaultMessageSignatureComponents are the components covered by the HTTP
+// Message Signatures of an upstream when none are configured: the request
+// line and the identity headers.
+// The body is not covered by default, as its Content-Digest requires reading
+// the whole body into memory before the request is sent.
+var defaultMessageSignatureComponents = []string{
+	httpsig.ComponentMethod,
+	httpsig.ComponentTargetURI,
+	"authorization",
+	"x-forwarded-user",
+	"x-forwarded-email",
+	"x-forwarded-preferred-username",
+	"x-forwarded-groups",
+}
+
+// newMessageSigner creates the signer of the HTTP Message Signatures of an
+// upstream, or nil when the upstream requests are not signed.
+func newMessageSigner(opts *options.MessageSignature) (*httpsig.Signer, error) {
+	if opts == nil {
+		return nil, nil
+	}
+	if opts.Key == nil {
+		return nil, errors.New("no key configured")
+	}
+
+	data, err := util.GetSecretValue(opts.Key)
+	if err != nil {
+		return nil, fmt.Errorf("could not load key: %v", err)
+	}
+
+	var key httpsig.Key
+	switch opts.Algorithm {
+	case "", httpsig.AlgorithmHMACSHA256:
+		key = httpsig.NewHMACSHA256Key(opts.KeyID, data)
+	case httpsig.AlgorithmEd25519:
+		private, err := httpsig.ParseEd25519PrivateKey(data)
+		if err != nil {
+			return nil, err
+		}
+		key = httpsig.NewEd25519PrivateKey(opts.KeyID, private)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", opts.Algorithm)
+	}
+
+	components := opts.Components
+	if len(components) == 0 {
+		components = defaultMessageSignatureComponents
+	}
+
+	return httpsig.NewSigner(httpsig.SignerOptions{
+		Key:        key,
+		Components: components,
+		Label:      opts.Label,
+		Tag:        opts.Tag,
+		Lifetime:   opts.Lifetime.Duration(),
+	})
+}
+
+// newSigningTransport signs the requests sent by the transport, when a signer
+// is given.
+// Requests are signed by the transport, after the reverse proxy has set the
+// headers it adds, so that the signature covers the request as it is sent.
+func newSigningTransport(transport http.RoundTripper, signer *httpsig.Signer) http.RoundTripper {
+	if signer == nil {
+		return transport
+	}
+	return &signingTransport{signer: signer, next: transport}
+}
+
+// signingTransport signs requests with an HTTP Message Signature
+type signingTransport struct {
+	signer *httpsig.Signer
+	next   http.RoundTripper
+}
+
+// RoundTrip signs a copy of the request and sends it with the next transport
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it is given
+	req = req.Clone(req.Context())
+	if err := t.signer.SignRequest(req); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("could not sign request: %v", err)
+	}
+	return t.next.RoundTrip(req)
+}
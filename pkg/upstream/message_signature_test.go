@@ -0,0 +1,148 @@
+package upstream
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	middlewareapi "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/httpsig"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Message Signature Suite", func() {
+	var public ed25519.PublicKey
+	var private []byte
+
+	BeforeEach(func() {
+		var key ed25519.PrivateKey
+		var err error
+		public, key, err = ed25519.GenerateKey(rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		Expect(err).ToNot(HaveOccurred())
+		private = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	})
+
+	Context("proxying to an upstream that verifies signatures", func() {
+		var signedUpstream *httptest.Server
+		var contentDigest string
+
+		BeforeEach(func() {
+			contentDigest = ""
+			verifier, err := httpsig.NewVerifier(httpsig.VerifierOptions{
+				Keys: []httpsig.Key{
+					httpsig.NewHMACSHA256Key("shared", []byte("secret")),
+					httpsig.NewEd25519PublicKey("proxy", public),
+				},
+				RequiredComponents: []string{httpsig.ComponentMethod, httpsig.ComponentTargetURI, "x-forwarded-user"},
+				MaxAge:             time.Minute,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			signedUpstream = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				contentDigest = req.Header.Get(httpsig.ContentDigestHeader)
+				verifier.Middleware(&testHTTPUpstream{}).ServeHTTP(rw, req)
+			}))
+			DeferCleanup(signedUpstream.Close)
+		})
+
+		proxy := func(signature *options.MessageSignature) int {
+			signer, err := newMessageSigner(signature)
+			Expect(err).ToNot(HaveOccurred())
+
+			u, err := url.Parse(signedUpstream.URL)
+			Expect(err).ToNot(HaveOccurred())
+			handler := newHTTPUpstreamProxy(options.Upstream{ID: "signed"}, u, nil, signer, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "http://example.localhost/items?page=2", strings.NewReader(`{"name": "item"}`))
+			req.Header.Set("X-Forwarded-User", "user-123")
+			req = middlewareapi.AddRequestScope(req, &middlewareapi.RequestScope{})
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+			return rw.Code
+		}
+
+		It("signs requests with a shared secret", func() {
+			Expect(proxy(&options.MessageSignature{
+				KeyID: "shared",
+				Key:   &options.SecretSource{Value: []byte("secret")},
+			})).To(Equal(http.StatusOK))
+		})
+
+		It("signs requests with an Ed25519 key", func() {
+			Expect(proxy(&options.MessageSignature{
+				KeyID:     "proxy",
+				Algorithm: "ed25519",
+				Key:       &options.SecretSource{Value: private},
+				Lifetime:  durationPtr(time.Minute),
+			})).To(Equal(http.StatusOK))
+		})
+
+		It("does not cover the body by default", func() {
+			Expect(proxy(&options.MessageSignature{
+				KeyID: "shared",
+				Key:   &options.SecretSource{Value: []byte("secret")},
+			})).To(Equal(http.StatusOK))
+			Expect(contentDigest).To(BeEmpty())
+		})
+
+		It("covers the body with its content digest when configured", func() {
+			Expect(proxy(&options.MessageSignature{
+				KeyID:      "shared",
+				Key:        &options.SecretSource{Value: []byte("secret")},
+				Components: []string{"@method", "@target-uri", "content-digest", "x-forwarded-user"},
+			})).To(Equal(http.StatusOK))
+			Expect(contentDigest).To(HavePrefix("sha-256=:"))
+		})
+
+		It("does not sign requests without a message signature", func() {
+			Expect(proxy(nil)).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("does not cover components missing from the configuration", func() {
+			Expect(proxy(&options.MessageSignature{
+				KeyID:      "shared",
+				Key:        &options.SecretSource{Value: []byte("secret")},
+				Components: []string{"@method", "@target-uri"},
+			})).To(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	DescribeTable("newMessageSigner errors",
+		func(signature *options.MessageSignature, expectedErr string) {
+			signer, err := newMessageSigner(signature)
+			Expect(err).To(MatchError(expectedErr))
+			Expect(signer).To(BeNil())
+		},
+		Entry("without a key", &options.MessageSignature{KeyID: "proxy"}, "no key configured"),
+		Entry("with an unsupported algorithm", &options.MessageSignature{
+			KeyID:     "proxy",
+			Algorithm: "rsa-pss-sha512",
+			Key:       &options.SecretSource{Value: []byte("secret")},
+		}, `unsupported algorithm "rsa-pss-sha512"`),
+		Entry("with an invalid Ed25519 key", &options.MessageSignature{
+			KeyID:     "proxy",
+			Algorithm: "ed25519",
+			Key:       &options.SecretSource{Value: []byte("secret")},
+		}, "key is not PEM encoded"),
+		Entry("with an invalid component", &options.MessageSignature{
+			KeyID:      "proxy",
+			Key:        &options.SecretSource{Value: []byte("secret")},
+			Components: []string{"@status"},
+		}, `unsupported derived component "@status"`),
+	)
+})
+
+func durationPtr(d time.Duration) *options.Duration {
+	duration := options.Duration(d)
+	return &duration
+}
@@ -20,9 +20,18 @@ import (
 // HTTP proxies fail to connect to upstream servers.
 type ProxyErrorHandler func(http.ResponseWriter, *http.Request, error)
 
+// Proxy is an http.Handler that serves requests directed to multiple upstreams.
+type Proxy interface {
+	http.Handler
+
+	// MatchUpstream returns the ID of the upstream that would serve the request.
+	// If no upstream matches the request, false is returned.
+	MatchUpstream(req *http.Request) (string, bool)
+}
+
 // NewProxy creates a new multiUpstreamProxy that can serve requests directed to
 // multiple upstreams.
-func NewProxy(upstreams options.UpstreamConfig, sigData *options.SignatureData, writer pagewriter.Writer) (http.Handler, error) {
+func NewProxy(upstreams options.UpstreamConfig, sigData *options.SignatureData, writer pagewriter.Writer) (Proxy, error) {
 	m := &multiUpstreamProxy{
 		serveMux: mux.NewRouter(),
 	}
@@ -72,6 +81,18 @@ func (m *multiUpstreamProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request
 	m.serveMux.ServeHTTP(rw, req)
 }
 
+// MatchUpstream returns the ID of the upstream registered for the request.
+// Each upstream route is named after the upstream ID when it is registered.
+func (m *multiUpstreamProxy) MatchUpstream(req *http.Request) (string, bool) {
+	var match mux.RouteMatch
+	if !m.serveMux.Match(req, &match) || match.Route == nil {
+		return "", false
+	}
+
+	id := match.Route.GetName()
+	return id, id != ""
+}
+
 // registerStaticResponseHandler registers a static response handler with at the given path.
 func (m *multiUpstreamProxy) registerStaticResponseHandler(upstream options.Upstream, writer pagewriter.Writer) error {
 	logger.Printf("mapping path %q => static response %d", upstream.Path, derefStaticCode(upstream.StaticCode))
@@ -86,14 +107,19 @@ func (m *multiUpstreamProxy) registerFileServer(upstream options.Upstream, u *ur
 
 // registerHTTPUpstreamProxy registers a new httpUpstreamProxy based on the configuration given.
 func (m *multiUpstreamProxy) registerHTTPUpstreamProxy(upstream options.Upstream, u *url.URL, sigData *options.SignatureData, writer pagewriter.Writer) error {
+	signer, err := newMessageSigner(upstream.MessageSignature)
+	if err != nil {
+		return fmt.Errorf("could not create message signer: %v", err)
+	}
+
 	logger.Printf("mapping path %q => upstream %q", upstream.Path, upstream.URI)
-	return m.registerHandler(upstream, newHTTPUpstreamProxy(upstream, u, sigData, writer.ProxyErrorHandler), writer)
+	return m.registerHandler(upstream, newHTTPUpstreamProxy(upstream, u, sigData, signer, writer.ProxyErrorHandler), writer)
 }
 
 // registerHandler ensures the given handler is regiestered with the serveMux.
 func (m *multiUpstreamProxy) registerHandler(upstream options.Upstream, handler http.Handler, writer pagewriter.Writer) error {
 	if upstream.RewriteTarget == "" {
-		m.registerSimpleHandler(upstream.Path, handler)
+		m.registerSimpleHandler(upstream.ID, upstream.Path, handler)
 		return nil
 	}
 
@@ -102,11 +128,11 @@ func (m *multiUpstreamProxy) registerHandler(upstream options.Upstream, handler
 
 // registerSimpleHandler maintains the behaviour of the go standard serveMux
 // by ensuring any path with a trailing `/` matches all paths under that prefix.
-func (m *multiUpstreamProxy) registerSimpleHandler(path string, handler http.Handler) {
+func (m *multiUpstreamProxy) registerSimpleHandler(id, path string, handler http.Handler) {
 	if strings.HasSuffix(path, "/") {
-		m.serveMux.PathPrefix(path).Handler(handler)
+		m.serveMux.PathPrefix(path).Handler(handler).Name(id)
 	} else {
-		m.serveMux.Path(path).Handler(handler)
+		m.serveMux.Path(path).Handler(handler).Name(id)
 	}
 }
 
@@ -124,7 +150,7 @@ func (m *multiUpstreamProxy) registerRewriteHandler(upstream options.Upstream, h
 	h := alice.New(rewrite).Then(handler)
 	m.serveMux.MatcherFunc(func(req *http.Request, _ *mux.RouteMatch) bool {
 		return rewriteRegExp.MatchString(req.URL.Path)
-	}).Handler(h)
+	}).Handler(h).Name(upstream.ID)
 
 	return nil
 }
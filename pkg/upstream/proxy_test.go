@@ -122,10 +122,13 @@ var _ = Describe("Proxy Suite", func() {
 				// Don't mock the remote Address
 				req.RemoteAddr = ""
 
+				matchedUpstream, _ := upstreamServer.MatchUpstream(req)
+
 				upstreamServer.ServeHTTP(rw, req)
 
 				scope := middlewareapi.GetRequestScope(req)
 				Expect(scope.Upstream).To(Equal(in.upstream))
+				Expect(matchedUpstream).To(Equal(in.upstream))
 
 				Expect(rw.Code).To(Equal(in.response.code))
 
@@ -62,9 +62,19 @@ func validateTrustedIPs(o *options.Options) []string {
 	return msgs
 }
 
-// validateAPIRoutes validates regex paths passed with options.ApiRoutes
+// validateAPIRoutes validates regex paths passed with options.ApiRoutes,
+// and the paths and token requirements of options.APIRouteConfig
 func validateAPIRoutes(o *options.Options) []string {
-	return validateRegexes(o.APIRoutes)
+	msgs := validateRegexes(o.APIRoutes)
+	for _, route := range o.APIRouteConfig {
+		if route.Path == "" {
+			msgs = append(msgs, "api route has empty path: paths are required for all api routes")
+			continue
+		}
+		msgs = append(msgs, validateRegexes([]string{route.Path})...)
+		msgs = append(msgs, validateTokenRequirements(fmt.Sprintf("api route %q", route.Path), route.TokenRequirements)...)
+	}
+	return msgs
 }
 
 // validateRegexes validates all regexes and returns a list of messages in case of error
@@ -87,6 +87,30 @@ var _ = Describe("Allowlist", func() {
 		}),
 	)
 
+	DescribeTable("validateAPIRoutes",
+		func(apiRoutes []string, apiRouteConfig []options.APIRoute, errStrings []string) {
+			opts := &options.Options{
+				APIRoutes:      apiRoutes,
+				APIRouteConfig: apiRouteConfig,
+			}
+			Expect(validateAPIRoutes(opts)).To(ConsistOf(errStrings))
+		},
+		Entry("Valid api routes", []string{"^/api"}, []options.APIRoute{
+			{Path: "^/orders/", TokenRequirements: &options.TokenRequirements{Scopes: []string{"orders:read"}}},
+			{Path: "^/health$"},
+		}, []string{}),
+		Entry("Invalid api routes", []string{"/(api"}, []options.APIRoute{
+			{Path: ""},
+			{Path: "/(orders"},
+			{Path: "^/orders/", TokenRequirements: &options.TokenRequirements{Scopes: []string{"orders read"}}},
+		}, []string{
+			"error compiling regex //(api/: error parsing regexp: missing closing ): `/(api`",
+			"api route has empty path: paths are required for all api routes",
+			"error compiling regex //(orders/: error parsing regexp: missing closing ): `/(orders`",
+			"api route \"^/orders/\" requires invalid scope \"orders read\": scopes must not be empty or contain spaces, quotes or backslashes",
+		}),
+	)
+
 	DescribeTable("validateTrustedIPs",
 		func(t *validateTrustedIPsTableInput) {
 			opts := &options.Options{
@@ -0,0 +1,101 @@
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/authorization"
+)
+
+// validateAuthorization checks that each required claim names a claim and
+// lists the values that are accepted for it, and that each policy compiles.
+// The owner is used to prefix messages, eg: `upstream "foo"`.
+func validateAuthorization(owner string, auth *options.Authorization) []string {
+	msgs := []string{}
+	if auth == nil {
+		return msgs
+	}
+
+	for _, claim := range auth.RequiredClaims {
+		if claim.Claim == "" {
+			msgs = append(msgs, fmt.Sprintf("%s has a required claim with an empty claim name", owner))
+			continue
+		}
+		if len(claim.Values) == 0 {
+			msgs = append(msgs, fmt.Sprintf("%s has required claim %q with no values", owner, claim.Claim))
+		}
+	}
+
+	for _, policy := range auth.Policies {
+		name := policy.Name
+		if name == "" {
+			name = policy.Expression
+		}
+		if err := authorization.ValidatePolicy(policy.Expression); err != nil {
+			msgs = append(msgs, fmt.Sprintf("%s has invalid policy %q: %v", owner, name, err))
+		}
+	}
+
+	return msgs
+}
+
+// validateTokenRequirements checks that the required scopes are valid scope
+// tokens (RFC 6749 section 3.3) and that the audiences are not empty.
+// The owner is used to prefix messages, eg: `api route "^/api"`.
+func validateTokenRequirements(owner string, requirements *options.TokenRequirements) []string {
+	msgs := []string{}
+	if requirements == nil {
+		return msgs
+	}
+
+	for _, scope := range requirements.Scopes {
+		if !isScopeToken(scope) {
+			msgs = append(msgs, fmt.Sprintf("%s requires invalid scope %q: scopes must not be empty or contain spaces, quotes or backslashes", owner, scope))
+		}
+	}
+	for _, audience := range requirements.Audiences {
+		if audience == "" || strings.ContainsAny(audience, "\"\\") {
+			msgs = append(msgs, fmt.Sprintf("%s requires invalid audience %q: audiences must not be empty or contain quotes or backslashes", owner, audience))
+		}
+	}
+
+	return msgs
+}
+
+// isScopeToken checks that the scope only contains the printable ASCII
+// characters, other than quotes and backslashes, that RFC 6749 allows.
+func isScopeToken(scope string) bool {
+	if scope == "" {
+		return false
+	}
+	for _, c := range scope {
+		if c <= ' ' || c > '~' || c == '"' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// validateAuthorizationWebhook checks that the webhook has a valid HTTP(S) URL.
+func validateAuthorizationWebhook(webhook *options.AuthorizationWebhook) []string {
+	msgs := []string{}
+	if webhook == nil {
+		return msgs
+	}
+
+	if webhook.URL == "" {
+		return append(msgs, "authorization webhook has empty url: a url is required")
+	}
+
+	u, err := url.Parse(webhook.URL)
+	if err != nil {
+		return append(msgs, fmt.Sprintf("authorization webhook has invalid url: %v", err))
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		msgs = append(msgs, fmt.Sprintf("authorization webhook url %q must be an absolute http or https url", webhook.URL))
+	}
+
+	return msgs
+}
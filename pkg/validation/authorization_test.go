@@ -0,0 +1,91 @@
+package validation
+
+import (
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Authorization", func() {
+	type validateAuthorizationTableInput struct {
+		authorization *options.Authorization
+		errStrings    []string
+	}
+
+	DescribeTable("validateAuthorization",
+		func(o *validateAuthorizationTableInput) {
+			Expect(validateAuthorization("authorization", o.authorization)).To(ConsistOf(o.errStrings))
+		},
+		Entry("with no authorization", &validateAuthorizationTableInput{
+			authorization: nil,
+			errStrings:    []string{},
+		}),
+		Entry("with valid policies", &validateAuthorizationTableInput{
+			authorization: &options.Authorization{
+				Policies: []options.Policy{
+					{Name: "sre", Expression: `"sre" in session.groups && request.method != "DELETE"`},
+					{Expression: `session.email.endsWith("@corp.com")`},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with an empty policy", &validateAuthorizationTableInput{
+			authorization: &options.Authorization{
+				Policies: []options.Policy{{Name: "empty"}},
+			},
+			errStrings: []string{"authorization has invalid policy \"empty\": expression is empty"},
+		}),
+		Entry("with a policy that does not return a bool", &validateAuthorizationTableInput{
+			authorization: &options.Authorization{
+				Policies: []options.Policy{{Expression: `session.claim("email")`}},
+			},
+			errStrings: []string{"authorization has invalid policy \"session.claim(\\\"email\\\")\": expression must evaluate to a bool, not list(string)"},
+		}),
+		Entry("with an invalid required claim", &validateAuthorizationTableInput{
+			authorization: &options.Authorization{
+				RequiredClaims: []options.RequiredClaim{{Claim: "groups"}},
+			},
+			errStrings: []string{"authorization has required claim \"groups\" with no values"},
+		}),
+	)
+
+	DescribeTable("validateAuthorizationWebhook",
+		func(webhook *options.AuthorizationWebhook, errStrings []string) {
+			Expect(validateAuthorizationWebhook(webhook)).To(ConsistOf(errStrings))
+		},
+		Entry("with no webhook", nil, []string{}),
+		Entry("with a valid url", &options.AuthorizationWebhook{URL: "https://authz.internal/check"}, []string{}),
+		Entry("with an empty url", &options.AuthorizationWebhook{}, []string{
+			"authorization webhook has empty url: a url is required",
+		}),
+		Entry("with a relative url", &options.AuthorizationWebhook{URL: "/check"}, []string{
+			"authorization webhook url \"/check\" must be an absolute http or https url",
+		}),
+		Entry("with an unsupported scheme", &options.AuthorizationWebhook{URL: "ftp://authz.internal"}, []string{
+			"authorization webhook url \"ftp://authz.internal\" must be an absolute http or https url",
+		}),
+	)
+	DescribeTable("validateTokenRequirements",
+		func(requirements *options.TokenRequirements, errStrings []string) {
+			Expect(validateTokenRequirements("upstream \"api\"", requirements)).To(ConsistOf(errStrings))
+		},
+		Entry("with no requirements", nil, []string{}),
+		Entry("with valid requirements", &options.TokenRequirements{
+			Scopes:    []string{"openid", "orders:read", "https://api.example.com/orders.write"},
+			Audiences: []string{"https://api.example.com", "orders-api"},
+		}, []string{}),
+		Entry("with invalid scopes", &options.TokenRequirements{
+			Scopes: []string{"", "orders:read orders:write", `say"hi"`},
+		}, []string{
+			"upstream \"api\" requires invalid scope \"\": scopes must not be empty or contain spaces, quotes or backslashes",
+			"upstream \"api\" requires invalid scope \"orders:read orders:write\": scopes must not be empty or contain spaces, quotes or backslashes",
+			"upstream \"api\" requires invalid scope \"say\\\"hi\\\"\": scopes must not be empty or contain spaces, quotes or backslashes",
+		}),
+		Entry("with invalid audiences", &options.TokenRequirements{
+			Audiences: []string{"", `domain\api`},
+		}, []string{
+			"upstream \"api\" requires invalid audience \"\": audiences must not be empty or contain quotes or backslashes",
+			"upstream \"api\" requires invalid audience \"domain\\\\api\": audiences must not be empty or contain quotes or backslashes",
+		}),
+	)
+})
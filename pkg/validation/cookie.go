@@ -12,6 +12,11 @@ import (
 
 func validateCookie(o options.Cookie) []string {
 	msgs := validateCookieSecret(o.Secret)
+	for i, secret := range o.PreviousSecrets {
+		for _, msg := range validateCookieSecret(secret) {
+			msgs = append(msgs, fmt.Sprintf("cookie_previous_secrets[%d]: %s", i, msg))
+		}
+	}
 
 	if o.Expire != time.Duration(0) && o.Refresh >= o.Expire {
 		msgs = append(msgs, fmt.Sprintf(
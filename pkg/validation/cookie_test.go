@@ -123,6 +123,41 @@ func TestValidateCookie(t *testing.T) {
 				invalidBase64SecretMsg,
 			},
 		},
+		{
+			name: "with valid previous secrets",
+			cookie: options.Cookie{
+				Name:            validName,
+				Secret:          validSecret,
+				PreviousSecrets: []string{validBase64Secret, validSecret},
+				Domains:         emptyDomains,
+				Path:            "",
+				Expire:          time.Hour,
+				Refresh:         15 * time.Minute,
+				Secure:          true,
+				HTTPOnly:        false,
+				SameSite:        "",
+			},
+			errStrings: []string{},
+		},
+		{
+			name: "with invalid previous secrets",
+			cookie: options.Cookie{
+				Name:            validName,
+				Secret:          validSecret,
+				PreviousSecrets: []string{validSecret, invalidSecret, ""},
+				Domains:         emptyDomains,
+				Path:            "",
+				Expire:          time.Hour,
+				Refresh:         15 * time.Minute,
+				Secure:          true,
+				HTTPOnly:        false,
+				SameSite:        "",
+			},
+			errStrings: []string{
+				"cookie_previous_secrets[1]: " + invalidSecretMsg,
+				"cookie_previous_secrets[2]: " + missingSecretMsg,
+			},
+		},
 		{
 			name: "with an invalid name",
 			cookie: options.Cookie{
@@ -0,0 +1,110 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/header"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
+)
+
+// identityTokenSecretClaims are the session claims that must not be copied
+// into identity tokens, as upstreams could use them with the provider
+var identityTokenSecretClaims = map[string]struct{}{
+	"access_token":  {},
+	"id_token":      {},
+	"refresh_token": {},
+}
+
+// identityTokenRegisteredClaims are the claims set by the proxy in every
+// identity token, which session claims must not replace
+var identityTokenRegisteredClaims = map[string]struct{}{
+	"iss": {},
+	"sub": {},
+	"aud": {},
+	"exp": {},
+	"nbf": {},
+	"iat": {},
+	"jti": {},
+}
+
+func validateIdentityToken(o *options.Options) []string {
+	msgs := []string{}
+
+	if o.IdentityToken == nil {
+		if usesIdentityTokenClaim(o.InjectRequestHeaders) || usesIdentityTokenClaim(o.InjectResponseHeaders) {
+			msgs = append(msgs, fmt.Sprintf("headers with the claim %q require an identity token to be configured", header.IdentityTokenClaim))
+		}
+		return msgs
+	}
+
+	token := o.IdentityToken
+	if token.Issuer == "" {
+		msgs = append(msgs, "identity token has empty issuer: an issuer is required")
+	}
+
+	durations := []struct {
+		name     string
+		duration *options.Duration
+	}{
+		{"lifetime", token.Lifetime},
+		{"keyRotationInterval", token.KeyRotationInterval},
+		{"keyGracePeriod", token.KeyGracePeriod},
+	}
+	for _, d := range durations {
+		if d.duration.Duration() < 0 {
+			msgs = append(msgs, fmt.Sprintf("identity token %s must not be negative", d.name))
+		}
+	}
+
+	for _, claim := range token.Claims {
+		if claim == "" {
+			msgs = append(msgs, "identity token has empty claim: claim names are required")
+		}
+		if _, ok := identityTokenSecretClaims[claim]; ok {
+			msgs = append(msgs, fmt.Sprintf("identity token claim %q must not be included in identity tokens", claim))
+		}
+		if _, ok := identityTokenRegisteredClaims[claim]; ok {
+			msgs = append(msgs, fmt.Sprintf("identity token claim %q is set by the proxy and cannot be included from the session", claim))
+		}
+	}
+
+	for i, key := range token.SigningKeys {
+		if key.Key == nil {
+			msgs = append(msgs, fmt.Sprintf("identity token signing key %d has no key", i))
+			continue
+		}
+		msgs = append(msgs, prefixValues(fmt.Sprintf("identity token signing key %d is invalid: ", i), validateSecretSource(*key.Key))...)
+	}
+
+	if len(token.SigningKeys) == 0 && sharesSessions(o.Session) {
+		logger.Print("WARNING: identity tokens are signed with keys generated by each instance, but the session store is shared between instances: " +
+			"upstreams that verify the tokens with the JWKS of another instance reject them. Configure the identity token signingKeys instead")
+	}
+
+	return msgs
+}
+
+// sharesSessions returns true if the session store is shared between
+// instances, which means more than one instance is likely to run
+func sharesSessions(o options.SessionOptions) bool {
+	switch o.Type {
+	case options.RedisSessionStoreType, options.EtcdSessionStoreType:
+		return true
+	case options.SQLSessionStoreType:
+		return o.SQL.Driver != options.SQLiteDriver
+	default:
+		return false
+	}
+}
+
+func usesIdentityTokenClaim(headers []options.Header) bool {
+	for _, h := range headers {
+		for _, value := range h.Values {
+			if value.ClaimSource != nil && value.ClaimSource.Claim == header.IdentityTokenClaim {
+				return true
+			}
+		}
+	}
+	return false
+}
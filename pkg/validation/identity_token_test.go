@@ -0,0 +1,92 @@
+package validation
+
+import (
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Identity Token", func() {
+	identityTokenHeader := []options.Header{
+		{
+			Name: "Authorization",
+			Values: []options.HeaderValue{
+				{ClaimSource: &options.ClaimSource{Claim: "identity_token", Prefix: "Bearer "}},
+			},
+		},
+	}
+	negative := options.Duration(-time.Minute)
+
+	DescribeTable("validateIdentityToken",
+		func(o *options.Options, errStrings []string) {
+			Expect(validateIdentityToken(o)).To(ConsistOf(errStrings))
+		},
+		Entry("with no identity token", &options.Options{}, []string{}),
+		Entry("with a valid identity token", &options.Options{
+			InjectRequestHeaders: identityTokenHeader,
+			IdentityToken: &options.IdentityToken{
+				Issuer: "https://proxy.example.com",
+				Claims: []string{"email", "groups"},
+				SigningKeys: []options.SigningKey{
+					{Key: &options.SecretSource{Value: []byte("key")}},
+				},
+			},
+		}, []string{}),
+		Entry("with an identity token header and no identity token", &options.Options{
+			InjectResponseHeaders: identityTokenHeader,
+		}, []string{
+			"headers with the claim \"identity_token\" require an identity token to be configured",
+		}),
+		Entry("with an empty issuer", &options.Options{
+			IdentityToken: &options.IdentityToken{},
+		}, []string{
+			"identity token has empty issuer: an issuer is required",
+		}),
+		Entry("with negative durations", &options.Options{
+			IdentityToken: &options.IdentityToken{
+				Issuer:              "https://proxy.example.com",
+				Lifetime:            &negative,
+				KeyRotationInterval: &negative,
+				KeyGracePeriod:      &negative,
+			},
+		}, []string{
+			"identity token lifetime must not be negative",
+			"identity token keyRotationInterval must not be negative",
+			"identity token keyGracePeriod must not be negative",
+		}),
+		Entry("with token claims", &options.Options{
+			IdentityToken: &options.IdentityToken{
+				Issuer: "https://proxy.example.com",
+				Claims: []string{"", "access_token", "refresh_token"},
+			},
+		}, []string{
+			"identity token has empty claim: claim names are required",
+			"identity token claim \"access_token\" must not be included in identity tokens",
+			"identity token claim \"refresh_token\" must not be included in identity tokens",
+		}),
+		Entry("with registered claims", &options.Options{
+			IdentityToken: &options.IdentityToken{
+				Issuer: "https://proxy.example.com",
+				Claims: []string{"email", "sub", "exp", "aud"},
+			},
+		}, []string{
+			"identity token claim \"sub\" is set by the proxy and cannot be included from the session",
+			"identity token claim \"exp\" is set by the proxy and cannot be included from the session",
+			"identity token claim \"aud\" is set by the proxy and cannot be included from the session",
+		}),
+		Entry("with invalid signing keys", &options.Options{
+			IdentityToken: &options.IdentityToken{
+				Issuer: "https://proxy.example.com",
+				SigningKeys: []options.SigningKey{
+					{ID: "missing"},
+					{Key: &options.SecretSource{Value: []byte("key"), FromEnv: "KEY"}},
+				},
+			},
+		}, []string{
+			"identity token signing key 0 has no key",
+			"identity token signing key 1 is invalid: " + multipleValuesForSecretSource,
+		}),
+	)
+})
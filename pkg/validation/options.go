@@ -23,8 +23,18 @@ func Validate(o *options.Options) error {
 	msgs := validateCookie(o.Cookie)
 	msgs = append(msgs, validateSessionCookieMinimal(o)...)
 	msgs = append(msgs, validateRedisSessionStore(o)...)
+	msgs = append(msgs, validateRedisClientCache(o)...)
+	msgs = append(msgs, validateSQLSessionStore(o)...)
+	msgs = append(msgs, validateMemorySessionStore(o)...)
+	msgs = append(msgs, validateEtcdSessionStore(o)...)
+	msgs = append(msgs, validateSessionAdmin(o)...)
+	msgs = append(msgs, validateDeviceAuthorization(o)...)
+	msgs = append(msgs, validateSessionLimit(o)...)
+	msgs = append(msgs, validateSessionLifetime(o)...)
+	msgs = append(msgs, validateSessionRefresh(o)...)
 	msgs = append(msgs, prefixValues("injectRequestHeaders: ", validateHeaders(o.InjectRequestHeaders)...)...)
 	msgs = append(msgs, prefixValues("injectResponseHeaders: ", validateHeaders(o.InjectResponseHeaders)...)...)
+	msgs = append(msgs, validateIdentityToken(o)...)
 	msgs = append(msgs, validateProviders(o)...)
 	msgs = append(msgs, validateAPIRoutes(o)...)
 	msgs = configureLogger(o.Logging, msgs)
@@ -78,6 +88,8 @@ func Validate(o *options.Options) error {
 	}
 
 	msgs = append(msgs, validateUpstreams(o.UpstreamServers)...)
+	msgs = append(msgs, validateAuthorization("authorization", &o.Authorization)...)
+	msgs = append(msgs, validateAuthorizationWebhook(o.AuthorizationWebhook)...)
 
 	if o.ReverseProxy {
 		parser, err := ip.GetRealClientIPParser(o.RealClientIPHeader)
@@ -107,7 +119,7 @@ func parseSignatureKey(o *options.Options, msgs []string) []string {
 		return msgs
 	}
 
-	logger.Print("WARNING: `--signature-key` is deprecated. It will be removed in a future release")
+	logger.Print("WARNING: `--signature-key` is deprecated. It will be removed in a future release. Use the messageSignature of upstreams instead")
 
 	components := strings.Split(o.SignatureKey, ":")
 	if len(components) != 2 {
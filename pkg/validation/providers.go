@@ -24,6 +24,8 @@ func validateProviders(o *options.Options) []string {
 
 	for _, provider := range o.Providers {
 		msgs = append(msgs, validateProvider(provider, providerIDs)...)
+		msgs = append(msgs, validateIntrospection(provider, o.SkipJwtBearerTokens)...)
+		msgs = append(msgs, validatePushedAuthorizationRequests(provider)...)
 	}
 
 	return msgs
@@ -58,6 +60,72 @@ func validateProvider(provider options.Provider, providerIDs map[string]struct{}
 		msgs = append(msgs, validateEntraConfig(provider)...)
 	}
 
+	if provider.OIDCConfig.RPInitiatedLogout && provider.OIDCConfig.SkipDiscovery && provider.OIDCConfig.EndSessionURL == "" {
+		msgs = append(msgs, "provider missing setting: oidc-end-session-url is required for RP-initiated logout when OIDC discovery is skipped")
+	}
+
+	msgs = append(msgs, validateExtraClaims(provider.OIDCConfig.ExtraClaims)...)
+
+	return msgs
+}
+
+// sessionClaims are the claims that SessionState.GetClaim reads from the
+// fields of the session, which extra claims cannot replace
+var sessionClaims = map[string]struct{}{
+	"access_token":       {},
+	"id_token":           {},
+	"created_at":         {},
+	"expires_on":         {},
+	"refresh_token":      {},
+	"email":              {},
+	"user":               {},
+	"groups":             {},
+	"preferred_username": {},
+}
+
+func validateExtraClaims(claims []string) []string {
+	msgs := []string{}
+
+	for _, claim := range claims {
+		if claim == "" {
+			msgs = append(msgs, "invalid setting: oidc-extra-claim must not be empty")
+			continue
+		}
+		if _, ok := sessionClaims[claim]; ok {
+			msgs = append(msgs, fmt.Sprintf("invalid setting: oidc-extra-claim %q is already stored in the session", claim))
+		}
+	}
+
+	return msgs
+}
+
+func validateIntrospection(provider options.Provider, skipJwtBearerTokens bool) []string {
+	msgs := []string{}
+
+	if provider.OIDCConfig.IntrospectionCacheTTL.Duration() < 0 {
+		msgs = append(msgs, "invalid setting: oidc-introspection-cache-ttl must not be negative")
+	}
+	if !provider.OIDCConfig.IntrospectBearerTokens {
+		return msgs
+	}
+
+	if !skipJwtBearerTokens {
+		msgs = append(msgs, "invalid setting: oidc-introspect-bearer-tokens requires skip-jwt-bearer-tokens")
+	}
+	if provider.OIDCConfig.SkipDiscovery && provider.OIDCConfig.IntrospectionURL == "" {
+		msgs = append(msgs, "provider missing setting: oidc-introspection-url is required for token introspection when OIDC discovery is skipped")
+	}
+
+	return msgs
+}
+
+func validatePushedAuthorizationRequests(provider options.Provider) []string {
+	msgs := []string{}
+
+	if provider.OIDCConfig.PushedAuthorizationRequests && provider.OIDCConfig.SkipDiscovery && provider.OIDCConfig.PushedAuthorizationRequestURL == "" {
+		msgs = append(msgs, "provider missing setting: oidc-pushed-authorization-request-url is required for pushed authorization requests when OIDC discovery is skipped")
+	}
+
 	return msgs
 }
 
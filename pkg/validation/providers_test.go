@@ -1,6 +1,8 @@
 package validation
 
 import (
+	"time"
+
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -30,6 +32,69 @@ var _ = Describe("Providers", func() {
 		ClientSecret: "ClientSecret",
 	}
 
+	missingEndSessionURLProvider := options.Provider{
+		ID:           "ProviderIDMissingEndSessionURL",
+		ClientID:     "ClientID",
+		ClientSecret: "ClientSecret",
+		OIDCConfig: options.OIDCOptions{
+			SkipDiscovery:     true,
+			RPInitiatedLogout: true,
+		},
+	}
+
+	extraClaimsProvider := options.Provider{
+		ID:           "ProviderIDExtraClaims",
+		ClientID:     "ClientID",
+		ClientSecret: "ClientSecret",
+		OIDCConfig: options.OIDCOptions{
+			ExtraClaims: []string{"department", "org.tenant", "", "email"},
+		},
+	}
+
+	introspectionProvider := options.Provider{
+		ID:           "ProviderIDIntrospection",
+		ClientID:     "ClientID",
+		ClientSecret: "ClientSecret",
+		OIDCConfig: options.OIDCOptions{
+			SkipDiscovery:          true,
+			IntrospectBearerTokens: true,
+			IntrospectionURL:       "https://idp.example.com/introspect",
+		},
+	}
+
+	negativeTTL := options.Duration(-time.Minute)
+	invalidIntrospectionProvider := options.Provider{
+		ID:           "ProviderIDInvalidIntrospection",
+		ClientID:     "ClientID",
+		ClientSecret: "ClientSecret",
+		OIDCConfig: options.OIDCOptions{
+			SkipDiscovery:          true,
+			IntrospectBearerTokens: true,
+			IntrospectionCacheTTL:  &negativeTTL,
+		},
+	}
+
+	parProvider := options.Provider{
+		ID:           "ProviderIDPushedAuthorizationRequests",
+		ClientID:     "ClientID",
+		ClientSecret: "ClientSecret",
+		OIDCConfig: options.OIDCOptions{
+			SkipDiscovery:                 true,
+			PushedAuthorizationRequests:   true,
+			PushedAuthorizationRequestURL: "https://idp.example.com/par",
+		},
+	}
+
+	missingPARURLProvider := options.Provider{
+		ID:           "ProviderIDMissingPushedAuthorizationRequestURL",
+		ClientID:     "ClientID",
+		ClientSecret: "ClientSecret",
+		OIDCConfig: options.OIDCOptions{
+			SkipDiscovery:               true,
+			PushedAuthorizationRequests: true,
+		},
+	}
+
 	missingProvider := "at least one provider has to be defined"
 	emptyIDMsg := "provider has empty id: ids are required for all providers"
 	duplicateProviderIDMsg := "multiple providers found with id ProviderID: provider ids must be unique"
@@ -52,6 +117,64 @@ var _ = Describe("Providers", func() {
 			},
 			errStrings: []string{},
 		}),
+		Entry("with RP-initiated logout and no end session URL", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					missingEndSessionURLProvider,
+				},
+			},
+			errStrings: []string{"provider missing setting: oidc-end-session-url is required for RP-initiated logout when OIDC discovery is skipped"},
+		}),
+		Entry("with invalid extra claims", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					extraClaimsProvider,
+				},
+			},
+			errStrings: []string{
+				"invalid setting: oidc-extra-claim must not be empty",
+				"invalid setting: oidc-extra-claim \"email\" is already stored in the session",
+			},
+		}),
+		Entry("with token introspection", &validateProvidersTableInput{
+			options: &options.Options{
+				SkipJwtBearerTokens: true,
+				Providers: options.Providers{
+					introspectionProvider,
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with invalid token introspection", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					invalidIntrospectionProvider,
+				},
+			},
+			errStrings: []string{
+				"invalid setting: oidc-introspection-cache-ttl must not be negative",
+				"invalid setting: oidc-introspect-bearer-tokens requires skip-jwt-bearer-tokens",
+				"provider missing setting: oidc-introspection-url is required for token introspection when OIDC discovery is skipped",
+			},
+		}),
+		Entry("with pushed authorization requests", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					parProvider,
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with pushed authorization requests without an endpoint", &validateProvidersTableInput{
+			options: &options.Options{
+				Providers: options.Providers{
+					missingPARURLProvider,
+				},
+			},
+			errStrings: []string{
+				"provider missing setting: oidc-pushed-authorization-request-url is required for pushed authorization requests when OIDC discovery is skipped",
+			},
+		}),
 		Entry("with an empty providerID", &validateProvidersTableInput{
 			options: &options.Options{
 				Providers: options.Providers{
@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/etcd"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/redis"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/sessions/sql"
 )
 
 func validateSessionCookieMinimal(o *options.Options) []string {
@@ -57,7 +61,7 @@ func validateRedisSessionStore(o *options.Options) []string {
 	}
 	nonce := base64.RawURLEncoding.EncodeToString(n)
 
-	key := fmt.Sprintf("%s-healthcheck-%s", o.Cookie.Name, nonce)
+	key := fmt.Sprintf("%s%s-healthcheck-%s", o.Session.Redis.KeyPrefix, o.Cookie.Name, nonce)
 	return sendRedisConnectionTest(client, key, nonce)
 }
 
@@ -86,3 +90,236 @@ func sendRedisConnectionTest(client redis.Client, key string, val string) []stri
 	}
 	return msgs
 }
+
+// validateRedisClientCache checks that client side caching of the redis
+// session store is used with a standalone or sentinel redis and a positive TTL
+func validateRedisClientCache(o *options.Options) []string {
+	if o.Session.Type != options.RedisSessionStoreType || !o.Session.Redis.ClientCache {
+		return []string{}
+	}
+
+	msgs := []string{}
+	if o.Session.Redis.UseCluster {
+		msgs = append(msgs, "redis_client_cache is not supported with redis_use_cluster")
+	}
+	if o.Session.Redis.ClientCacheTTL <= 0 {
+		msgs = append(msgs, "redis_client_cache_ttl must be positive")
+	}
+	if o.Session.Redis.ClientCacheSize < 0 {
+		msgs = append(msgs, "redis_client_cache_size cannot be negative")
+	}
+	return msgs
+}
+
+// validateSQLSessionStore connects to the database of the SQL session store,
+// migrates its schema and attempts to Save, Load and Clear a random health
+// check key
+func validateSQLSessionStore(o *options.Options) []string {
+	if o.Session.Type != options.SQLSessionStoreType {
+		return []string{}
+	}
+	if o.Session.SQL.ConnectionURL == "" {
+		return []string{"missing setting: sql-connection-url"}
+	}
+
+	ctx := context.Background()
+	store, err := sql.NewStore(ctx, o.Session.SQL)
+	if err != nil {
+		return []string{fmt.Sprintf("unable to initialize the sql session store: %v", err)}
+	}
+	defer store.Close()
+
+	n, err := encryption.Nonce(32)
+	if err != nil {
+		return []string{fmt.Sprintf("unable to generate a sql initialization test key: %v", err)}
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(n)
+	key := fmt.Sprintf("%s-healthcheck-%s", o.Cookie.Name, nonce)
+
+	if err := store.Save(ctx, key, []byte(nonce), time.Minute); err != nil {
+		return []string{fmt.Sprintf("unable to set a sql initialization key: %v", err)}
+	}
+
+	msgs := []string{}
+	value, err := store.Load(ctx, key)
+	if err != nil {
+		msgs = append(msgs, fmt.Sprintf("unable to retrieve sql initialization key: %v", err))
+	} else if string(value) != nonce {
+		msgs = append(msgs, "the retrieved sql initialization key did not match the value we set")
+	}
+	if err := store.Clear(ctx, key); err != nil {
+		msgs = append(msgs, fmt.Sprintf("unable to delete the sql initialization key: %v", err))
+	}
+	return msgs
+}
+
+// validateEtcdSessionStore connects to etcd and attempts to Save, Load and
+// Clear a random health check key
+func validateEtcdSessionStore(o *options.Options) []string {
+	if o.Session.Type != options.EtcdSessionStoreType {
+		return []string{}
+	}
+	if len(o.Session.Etcd.Endpoints) == 0 {
+		return []string{"missing setting: etcd-endpoints"}
+	}
+
+	client, err := etcd.NewEtcdClient(o.Session.Etcd)
+	if err != nil {
+		return []string{fmt.Sprintf("unable to initialize an etcd client: %v", err)}
+	}
+	store := etcd.NewStore(client, o.Session.Etcd.KeyPrefix)
+	defer store.Close()
+
+	timeout := o.Session.Etcd.DialTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	n, err := encryption.Nonce(32)
+	if err != nil {
+		return []string{fmt.Sprintf("unable to generate an etcd initialization test key: %v", err)}
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(n)
+	key := fmt.Sprintf("%s-healthcheck-%s", o.Cookie.Name, nonce)
+
+	if err := store.Save(ctx, key, []byte(nonce), time.Minute); err != nil {
+		return []string{fmt.Sprintf("unable to set an etcd initialization key: %v", err)}
+	}
+
+	msgs := []string{}
+	value, err := store.Load(ctx, key)
+	if err != nil {
+		msgs = append(msgs, fmt.Sprintf("unable to retrieve etcd initialization key: %v", err))
+	} else if string(value) != nonce {
+		msgs = append(msgs, "the retrieved etcd initialization key did not match the value we set")
+	}
+	if err := store.Clear(ctx, key); err != nil {
+		msgs = append(msgs, fmt.Sprintf("unable to delete the etcd initialization key: %v", err))
+	}
+	return msgs
+}
+
+// validateMemorySessionStore checks the capacity of the memory session store
+// and that its snapshot can be written
+func validateMemorySessionStore(o *options.Options) []string {
+	if o.Session.Type != options.MemorySessionStoreType {
+		return []string{}
+	}
+
+	msgs := []string{}
+	if o.Session.Memory.Capacity < 0 {
+		msgs = append(msgs, "memory_store_capacity cannot be negative")
+	}
+	if o.Session.Memory.SnapshotPath != "" {
+		dir := filepath.Dir(o.Session.Memory.SnapshotPath)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			msgs = append(msgs, fmt.Sprintf("memory_store_snapshot_path: directory %q does not exist", dir))
+		}
+	}
+	return msgs
+}
+
+// validateSessionAdmin checks that the session admin API is protected by a
+// token and that the session store can find the sessions of a user.
+func validateSessionAdmin(o *options.Options) []string {
+	if o.SessionAdmin == nil {
+		return []string{}
+	}
+
+	msgs := []string{}
+	if o.SessionAdmin.Token == nil {
+		msgs = append(msgs, "sessionAdmin: token is required")
+	} else if msg := validateSecretSource(*o.SessionAdmin.Token); msg != "" {
+		msgs = append(msgs, "sessionAdmin: token: "+msg)
+	}
+	if o.Session.Type == options.CookieSessionStoreType {
+		msgs = append(msgs, "sessionAdmin: requires a persistent session store, session_store_type cannot be cookie")
+	}
+	return msgs
+}
+
+// validateDeviceAuthorization checks that the device authorization grant is
+// used with a session store that can keep pending codes and issue tokens.
+func validateDeviceAuthorization(o *options.Options) []string {
+	if o.DeviceAuthorization == nil {
+		return []string{}
+	}
+
+	msgs := []string{}
+	if len(o.DeviceAuthorization.ClientIDs) == 0 {
+		msgs = append(msgs, "deviceAuthorization: clientIDs must list the clients allowed to request device codes")
+	}
+	if o.DeviceAuthorization.MaxPendingRequests < 0 {
+		msgs = append(msgs, "deviceAuthorization: maxPendingRequests cannot be negative")
+	}
+	if lifetime := o.DeviceAuthorization.CodeLifetime; lifetime != nil && lifetime.Duration() <= 0 {
+		msgs = append(msgs, "deviceAuthorization: codeLifetime must be positive")
+	}
+	if o.DeviceAuthorization.PollInterval.Duration() < 0 {
+		msgs = append(msgs, "deviceAuthorization: pollInterval cannot be negative")
+	}
+	if o.Session.Type == options.CookieSessionStoreType {
+		msgs = append(msgs, "deviceAuthorization: requires a persistent session store, session_store_type cannot be cookie")
+	}
+	return msgs
+}
+
+// validateSessionLimit checks that the concurrent session limit is used with
+// a session store that can find the sessions of a user.
+func validateSessionLimit(o *options.Options) []string {
+	msgs := []string{}
+	if o.Session.MaxConcurrent < 0 {
+		msgs = append(msgs, "session_max_concurrent cannot be negative")
+	}
+	if o.Session.MaxConcurrent > 0 && o.Session.Type == options.CookieSessionStoreType {
+		msgs = append(msgs, "session_max_concurrent requires a persistent session store, session_store_type cannot be cookie")
+	}
+	switch o.Session.LimitPolicy {
+	case "", options.EvictOldestSessionLimitPolicy, options.RejectSessionLimitPolicy:
+	default:
+		msgs = append(msgs, fmt.Sprintf("invalid session_limit_policy %q, must be %q or %q",
+			o.Session.LimitPolicy, options.EvictOldestSessionLimitPolicy, options.RejectSessionLimitPolicy))
+	}
+	return msgs
+}
+
+// validateSessionLifetime checks the idle timeout and maximum lifetime of
+// sessions.
+func validateSessionLifetime(o *options.Options) []string {
+	msgs := []string{}
+	if o.Session.IdleTimeout < 0 {
+		msgs = append(msgs, "session_idle_timeout cannot be negative")
+	}
+	if o.Session.MaxLifetime < 0 {
+		msgs = append(msgs, "session_max_lifetime cannot be negative")
+	}
+	if o.Session.IdleTimeout > 0 && o.Session.ActivityInterval >= o.Session.IdleTimeout {
+		msgs = append(msgs, fmt.Sprintf(
+			"session_activity_interval (%s) must be less than session_idle_timeout (%s)",
+			o.Session.ActivityInterval, o.Session.IdleTimeout))
+	}
+	return msgs
+}
+
+// validateSessionRefresh checks the refresh skew and that background
+// refreshes are used with a persistent session store and a refresh condition.
+func validateSessionRefresh(o *options.Options) []string {
+	msgs := []string{}
+	if o.Session.RefreshSkew < 0 {
+		msgs = append(msgs, "session_refresh_skew cannot be negative")
+	}
+	if o.Session.BackgroundRefreshInterval < 0 {
+		msgs = append(msgs, "session_background_refresh_interval cannot be negative")
+	}
+	if o.Session.BackgroundRefreshInterval > 0 {
+		if o.Session.Type == options.CookieSessionStoreType {
+			msgs = append(msgs, "session_background_refresh_interval requires a persistent session store")
+		}
+		if o.Cookie.Refresh <= 0 && o.Session.RefreshSkew <= 0 {
+			msgs = append(msgs, "session_background_refresh_interval requires cookie_refresh or session_refresh_skew to be set")
+		}
+	}
+	return msgs
+}
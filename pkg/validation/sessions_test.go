@@ -1,6 +1,8 @@
 package validation
 
 import (
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/Bose/minisentinel"
@@ -404,4 +406,461 @@ var _ = Describe("Sessions", func() {
 			errStrings: []string{clusterAndSentinelMsg},
 		}),
 	)
+
+	type sessionAdminTableInput struct {
+		opts       *options.Options
+		errStrings []string
+	}
+
+	DescribeTable("validateSessionAdmin",
+		func(o *sessionAdminTableInput) {
+			Expect(validateSessionAdmin(o.opts)).To(ConsistOf(o.errStrings))
+		},
+		Entry("No session admin API", &sessionAdminTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.CookieSessionStoreType,
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("Session admin API with a redis session store", &sessionAdminTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.RedisSessionStoreType,
+				},
+				SessionAdmin: &options.SessionAdmin{
+					Token: &options.SecretSource{Value: []byte("token")},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("Session admin API without a token", &sessionAdminTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.RedisSessionStoreType,
+				},
+				SessionAdmin: &options.SessionAdmin{},
+			},
+			errStrings: []string{"sessionAdmin: token is required"},
+		}),
+		Entry("Session admin API with a cookie session store", &sessionAdminTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.CookieSessionStoreType,
+				},
+				SessionAdmin: &options.SessionAdmin{
+					Token: &options.SecretSource{Value: []byte("token")},
+				},
+			},
+			errStrings: []string{"sessionAdmin: requires a persistent session store, session_store_type cannot be cookie"},
+		}),
+	)
+
+	type deviceAuthorizationTableInput struct {
+		opts       *options.Options
+		errStrings []string
+	}
+
+	negative := options.Duration(-time.Second)
+	zero := options.Duration(0)
+
+	DescribeTable("validateDeviceAuthorization",
+		func(o *deviceAuthorizationTableInput) {
+			Expect(validateDeviceAuthorization(o.opts)).To(ConsistOf(o.errStrings))
+		},
+		Entry("No device authorization", &deviceAuthorizationTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.CookieSessionStoreType,
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("Device authorization with a redis session store", &deviceAuthorizationTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.RedisSessionStoreType,
+				},
+				DeviceAuthorization: &options.DeviceAuthorization{
+					ClientIDs: []string{"cli"},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("Device authorization with a cookie session store", &deviceAuthorizationTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.CookieSessionStoreType,
+				},
+				DeviceAuthorization: &options.DeviceAuthorization{
+					ClientIDs: []string{"cli"},
+				},
+			},
+			errStrings: []string{"deviceAuthorization: requires a persistent session store, session_store_type cannot be cookie"},
+		}),
+		Entry("Device authorization with invalid durations", &deviceAuthorizationTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.RedisSessionStoreType,
+				},
+				DeviceAuthorization: &options.DeviceAuthorization{
+					ClientIDs:    []string{"cli"},
+					CodeLifetime: &zero,
+					PollInterval: &negative,
+				},
+			},
+			errStrings: []string{
+				"deviceAuthorization: codeLifetime must be positive",
+				"deviceAuthorization: pollInterval cannot be negative",
+			},
+		}),
+		Entry("Device authorization without client IDs", &deviceAuthorizationTableInput{
+			opts: &options.Options{
+				Session: options.SessionOptions{
+					Type: options.RedisSessionStoreType,
+				},
+				DeviceAuthorization: &options.DeviceAuthorization{
+					MaxPendingRequests: -1,
+				},
+			},
+			errStrings: []string{
+				"deviceAuthorization: clientIDs must list the clients allowed to request device codes",
+				"deviceAuthorization: maxPendingRequests cannot be negative",
+			},
+		}),
+	)
+
+	type sessionLimitTableInput struct {
+		session    options.SessionOptions
+		errStrings []string
+	}
+
+	DescribeTable("validateSessionLimit",
+		func(o *sessionLimitTableInput) {
+			Expect(validateSessionLimit(&options.Options{Session: o.session})).To(ConsistOf(o.errStrings))
+		},
+		Entry("No session limit", &sessionLimitTableInput{
+			session: options.SessionOptions{
+				Type:        options.CookieSessionStoreType,
+				LimitPolicy: options.EvictOldestSessionLimitPolicy,
+			},
+			errStrings: []string{},
+		}),
+		Entry("Session limit with a redis session store", &sessionLimitTableInput{
+			session: options.SessionOptions{
+				Type:          options.RedisSessionStoreType,
+				MaxConcurrent: 3,
+				LimitPolicy:   options.RejectSessionLimitPolicy,
+			},
+			errStrings: []string{},
+		}),
+		Entry("Session limit with a cookie session store", &sessionLimitTableInput{
+			session: options.SessionOptions{
+				Type:          options.CookieSessionStoreType,
+				MaxConcurrent: 3,
+			},
+			errStrings: []string{"session_max_concurrent requires a persistent session store, session_store_type cannot be cookie"},
+		}),
+		Entry("Negative session limit", &sessionLimitTableInput{
+			session: options.SessionOptions{
+				Type:          options.RedisSessionStoreType,
+				MaxConcurrent: -1,
+			},
+			errStrings: []string{"session_max_concurrent cannot be negative"},
+		}),
+		Entry("Invalid session limit policy", &sessionLimitTableInput{
+			session: options.SessionOptions{
+				Type:          options.RedisSessionStoreType,
+				MaxConcurrent: 3,
+				LimitPolicy:   "newest",
+			},
+			errStrings: []string{"invalid session_limit_policy \"newest\", must be \"evict-oldest\" or \"reject\""},
+		}),
+	)
+
+	type sessionLifetimeTableInput struct {
+		session    options.SessionOptions
+		errStrings []string
+	}
+
+	DescribeTable("validateSessionLifetime",
+		func(o *sessionLifetimeTableInput) {
+			Expect(validateSessionLifetime(&options.Options{Session: o.session})).To(ConsistOf(o.errStrings))
+		},
+		Entry("No idle timeout or maximum lifetime", &sessionLifetimeTableInput{
+			session: options.SessionOptions{
+				ActivityInterval: time.Minute,
+			},
+			errStrings: []string{},
+		}),
+		Entry("Idle timeout and maximum lifetime", &sessionLifetimeTableInput{
+			session: options.SessionOptions{
+				IdleTimeout:      30 * time.Minute,
+				MaxLifetime:      12 * time.Hour,
+				ActivityInterval: time.Minute,
+			},
+			errStrings: []string{},
+		}),
+		Entry("Negative idle timeout and maximum lifetime", &sessionLifetimeTableInput{
+			session: options.SessionOptions{
+				IdleTimeout: -time.Minute,
+				MaxLifetime: -time.Minute,
+			},
+			errStrings: []string{
+				"session_idle_timeout cannot be negative",
+				"session_max_lifetime cannot be negative",
+			},
+		}),
+		Entry("Activity interval longer than the idle timeout", &sessionLifetimeTableInput{
+			session: options.SessionOptions{
+				IdleTimeout:      time.Minute,
+				ActivityInterval: 5 * time.Minute,
+			},
+			errStrings: []string{"session_activity_interval (5m0s) must be less than session_idle_timeout (1m0s)"},
+		}),
+	)
+
+	type sqlStoreTableInput struct {
+		session    options.SessionOptions
+		errStrings []string
+	}
+
+	DescribeTable("validateSQLSessionStore",
+		func(o *sqlStoreTableInput) {
+			if o.session.SQL.ConnectionURL == "tempfile" {
+				o.session.SQL.ConnectionURL = "file:" + filepath.Join(GinkgoT().TempDir(), "sessions.db")
+			}
+			Expect(validateSQLSessionStore(&options.Options{Session: o.session})).To(ConsistOf(o.errStrings))
+		},
+		Entry("cookie sessions are skipped", &sqlStoreTableInput{
+			session: options.SessionOptions{
+				Type: options.CookieSessionStoreType,
+			},
+			errStrings: []string{},
+		}),
+		Entry("connect successfully to sqlite", &sqlStoreTableInput{
+			session: options.SessionOptions{
+				Type: options.SQLSessionStoreType,
+				SQL: options.SQLStoreOptions{
+					Driver:        options.SQLiteDriver,
+					ConnectionURL: "tempfile",
+					TablePrefix:   "oauth2_proxy_",
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("missing connection URL", &sqlStoreTableInput{
+			session: options.SessionOptions{
+				Type: options.SQLSessionStoreType,
+				SQL: options.SQLStoreOptions{
+					Driver: options.SQLiteDriver,
+				},
+			},
+			errStrings: []string{"missing setting: sql-connection-url"},
+		}),
+		Entry("unsupported driver", &sqlStoreTableInput{
+			session: options.SessionOptions{
+				Type: options.SQLSessionStoreType,
+				SQL: options.SQLStoreOptions{
+					Driver:        "oracle",
+					ConnectionURL: "tempfile",
+				},
+			},
+			errStrings: []string{"unable to initialize the sql session store: unsupported sql driver \"oracle\""},
+		}),
+	)
+
+	type memoryStoreTableInput struct {
+		session    options.SessionOptions
+		errStrings []string
+	}
+
+	DescribeTable("validateMemorySessionStore",
+		func(o *memoryStoreTableInput) {
+			Expect(validateMemorySessionStore(&options.Options{Session: o.session})).To(ConsistOf(o.errStrings))
+		},
+		Entry("cookie sessions are skipped", &memoryStoreTableInput{
+			session: options.SessionOptions{
+				Type: options.CookieSessionStoreType,
+				Memory: options.MemoryStoreOptions{
+					Capacity: -1,
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("default options", &memoryStoreTableInput{
+			session: options.SessionOptions{
+				Type: options.MemorySessionStoreType,
+				Memory: options.MemoryStoreOptions{
+					Capacity: 100000,
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("snapshot in an existing directory", &memoryStoreTableInput{
+			session: options.SessionOptions{
+				Type: options.MemorySessionStoreType,
+				Memory: options.MemoryStoreOptions{
+					SnapshotPath: filepath.Join(os.TempDir(), "sessions.snapshot"),
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("negative capacity", &memoryStoreTableInput{
+			session: options.SessionOptions{
+				Type: options.MemorySessionStoreType,
+				Memory: options.MemoryStoreOptions{
+					Capacity: -1,
+				},
+			},
+			errStrings: []string{"memory_store_capacity cannot be negative"},
+		}),
+		Entry("snapshot in a missing directory", &memoryStoreTableInput{
+			session: options.SessionOptions{
+				Type: options.MemorySessionStoreType,
+				Memory: options.MemoryStoreOptions{
+					SnapshotPath: "/does/not/exist/sessions.snapshot",
+				},
+			},
+			errStrings: []string{"memory_store_snapshot_path: directory \"/does/not/exist\" does not exist"},
+		}),
+	)
+
+	type etcdStoreTableInput struct {
+		session    options.SessionOptions
+		errStrings []string
+	}
+
+	DescribeTable("validateEtcdSessionStore",
+		func(o *etcdStoreTableInput) {
+			Expect(validateEtcdSessionStore(&options.Options{Session: o.session})).To(ConsistOf(o.errStrings))
+		},
+		Entry("cookie sessions are skipped", &etcdStoreTableInput{
+			session: options.SessionOptions{
+				Type: options.CookieSessionStoreType,
+			},
+			errStrings: []string{},
+		}),
+		Entry("missing endpoints", &etcdStoreTableInput{
+			session: options.SessionOptions{
+				Type: options.EtcdSessionStoreType,
+			},
+			errStrings: []string{"missing setting: etcd-endpoints"},
+		}),
+		Entry("missing CA", &etcdStoreTableInput{
+			session: options.SessionOptions{
+				Type: options.EtcdSessionStoreType,
+				Etcd: options.EtcdStoreOptions{
+					Endpoints: []string{"https://127.0.0.1:2379"},
+					CAPath:    "/does/not/exist/ca.pem",
+				},
+			},
+			errStrings: []string{"unable to initialize an etcd client: failed to load \"/does/not/exist/ca.pem\", open /does/not/exist/ca.pem: no such file or directory"},
+		}),
+	)
+
+	type redisClientCacheTableInput struct {
+		redis      options.RedisStoreOptions
+		errStrings []string
+	}
+
+	DescribeTable("validateRedisClientCache",
+		func(o *redisClientCacheTableInput) {
+			opts := &options.Options{
+				Session: options.SessionOptions{
+					Type:  options.RedisSessionStoreType,
+					Redis: o.redis,
+				},
+			}
+			Expect(validateRedisClientCache(opts)).To(ConsistOf(o.errStrings))
+		},
+		Entry("client cache disabled", &redisClientCacheTableInput{
+			redis: options.RedisStoreOptions{
+				UseCluster: true,
+			},
+			errStrings: []string{},
+		}),
+		Entry("client cache with standalone redis", &redisClientCacheTableInput{
+			redis: options.RedisStoreOptions{
+				ClientCache:     true,
+				ClientCacheTTL:  time.Minute,
+				ClientCacheSize: 100,
+			},
+			errStrings: []string{},
+		}),
+		Entry("client cache with redis cluster", &redisClientCacheTableInput{
+			redis: options.RedisStoreOptions{
+				UseCluster:     true,
+				ClientCache:    true,
+				ClientCacheTTL: time.Minute,
+			},
+			errStrings: []string{"redis_client_cache is not supported with redis_use_cluster"},
+		}),
+		Entry("invalid client cache TTL and size", &redisClientCacheTableInput{
+			redis: options.RedisStoreOptions{
+				ClientCache:     true,
+				ClientCacheSize: -1,
+			},
+			errStrings: []string{
+				"redis_client_cache_ttl must be positive",
+				"redis_client_cache_size cannot be negative",
+			},
+		}),
+	)
+
+	type sessionRefreshTableInput struct {
+		cookieRefresh time.Duration
+		session       options.SessionOptions
+		errStrings    []string
+	}
+
+	DescribeTable("validateSessionRefresh",
+		func(o *sessionRefreshTableInput) {
+			opts := &options.Options{
+				Cookie:  options.Cookie{Refresh: o.cookieRefresh},
+				Session: o.session,
+			}
+			Expect(validateSessionRefresh(opts)).To(ConsistOf(o.errStrings))
+		},
+		Entry("No refresh skew or background refresh", &sessionRefreshTableInput{
+			session: options.SessionOptions{
+				Type: options.CookieSessionStoreType,
+			},
+			errStrings: []string{},
+		}),
+		Entry("Background refresh with a refresh skew", &sessionRefreshTableInput{
+			session: options.SessionOptions{
+				Type:                      options.RedisSessionStoreType,
+				RefreshSkew:               30 * time.Second,
+				BackgroundRefreshInterval: time.Minute,
+			},
+			errStrings: []string{},
+		}),
+		Entry("Negative refresh skew and background refresh interval", &sessionRefreshTableInput{
+			session: options.SessionOptions{
+				RefreshSkew:               -time.Second,
+				BackgroundRefreshInterval: -time.Minute,
+			},
+			errStrings: []string{
+				"session_refresh_skew cannot be negative",
+				"session_background_refresh_interval cannot be negative",
+			},
+		}),
+		Entry("Background refresh with the cookie store", &sessionRefreshTableInput{
+			cookieRefresh: time.Hour,
+			session: options.SessionOptions{
+				Type:                      options.CookieSessionStoreType,
+				BackgroundRefreshInterval: time.Minute,
+			},
+			errStrings: []string{"session_background_refresh_interval requires a persistent session store"},
+		}),
+		Entry("Background refresh without a refresh condition", &sessionRefreshTableInput{
+			session: options.SessionOptions{
+				Type:                      options.RedisSessionStoreType,
+				BackgroundRefreshInterval: time.Minute,
+			},
+			errStrings: []string{"session_background_refresh_interval requires cookie_refresh or session_refresh_skew to be set"},
+		}),
+	)
 })
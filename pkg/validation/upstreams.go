@@ -3,8 +3,10 @@ package validation
 import (
 	"fmt"
 	"net/url"
+	"strings"
 
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/httpsig"
 )
 
 func validateUpstreams(upstreams options.UpstreamConfig) []string {
@@ -45,6 +47,77 @@ func validateUpstream(upstream options.Upstream, ids, paths map[string]struct{})
 
 	msgs = append(msgs, validateUpstreamURI(upstream)...)
 	msgs = append(msgs, validateStaticUpstream(upstream)...)
+	msgs = append(msgs, validateAuthorization(fmt.Sprintf("upstream %q", upstream.ID), upstream.Authorization)...)
+	msgs = append(msgs, validateTokenRequirements(fmt.Sprintf("upstream %q", upstream.ID), upstream.TokenRequirements)...)
+	msgs = append(msgs, validateMessageSignature(upstream)...)
+	msgs = append(msgs, validateTokenExchange(upstream)...)
+	return msgs
+}
+
+// validateMessageSignature checks that the message signature of the upstream
+// has a key, and that the upstream proxies requests that can be signed.
+func validateMessageSignature(upstream options.Upstream) []string {
+	msgs := []string{}
+	signature := upstream.MessageSignature
+	if signature == nil {
+		return msgs
+	}
+
+	if upstream.Static || strings.HasPrefix(upstream.URI, "file:") {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has messageSignature, but does not proxy requests, this will have no effect.", upstream.ID))
+	}
+	if signature.KeyID == "" {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has messageSignature with empty keyID: a key ID is required", upstream.ID))
+	}
+	switch signature.Algorithm {
+	case "", httpsig.AlgorithmHMACSHA256, httpsig.AlgorithmEd25519:
+		// Valid, do nothing
+	default:
+		msgs = append(msgs, fmt.Sprintf("upstream %q has messageSignature with unsupported algorithm %q: use %q or %q", upstream.ID, signature.Algorithm, httpsig.AlgorithmHMACSHA256, httpsig.AlgorithmEd25519))
+	}
+	if signature.Key == nil {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has messageSignature with no key: a key is required", upstream.ID))
+	} else {
+		msgs = append(msgs, prefixValues(fmt.Sprintf("upstream %q has messageSignature with invalid key: ", upstream.ID), validateSecretSource(*signature.Key))...)
+	}
+	if signature.Lifetime.Duration() < 0 {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has messageSignature with negative lifetime", upstream.ID))
+	}
+
+	return msgs
+}
+
+// validateTokenExchange checks that the token exchange of the upstream
+// requests a valid token, and that the upstream proxies requests.
+func validateTokenExchange(upstream options.Upstream) []string {
+	msgs := []string{}
+	exchange := upstream.TokenExchange
+	if exchange == nil {
+		return msgs
+	}
+
+	if upstream.Static || strings.HasPrefix(upstream.URI, "file:") {
+		msgs = append(msgs, fmt.Sprintf("upstream %q has tokenExchange, but does not proxy requests, this will have no effect.", upstream.ID))
+	}
+	switch exchange.SubjectTokenType {
+	case "", "access_token", "id_token":
+		// Valid, do nothing
+	default:
+		msgs = append(msgs, fmt.Sprintf("upstream %q has tokenExchange with unsupported subjectTokenType %q: use \"access_token\" or \"id_token\"", upstream.ID, exchange.SubjectTokenType))
+	}
+	if exchange.Resource != "" {
+		// RFC 8707 resource indicators are absolute URIs without a fragment
+		u, err := url.Parse(exchange.Resource)
+		if err != nil || !u.IsAbs() || u.Fragment != "" {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has tokenExchange with invalid resource %q: resources must be absolute URIs without a fragment", upstream.ID, exchange.Resource))
+		}
+	}
+	for _, scope := range exchange.Scopes {
+		if !isScopeToken(scope) {
+			msgs = append(msgs, fmt.Sprintf("upstream %q has tokenExchange with invalid scope %q: scopes must not be empty or contain spaces, quotes or backslashes", upstream.ID, scope))
+		}
+	}
+
 	return msgs
 }
 
@@ -17,6 +17,7 @@ var _ = Describe("Upstreams", func() {
 	flushInterval := options.Duration(5 * time.Second)
 	staticCode200 := 200
 	truth := true
+	negativeDuration := options.Duration(-time.Minute)
 
 	validHTTPUpstream := options.Upstream{
 		ID:   "validHTTPUpstream",
@@ -47,6 +48,9 @@ var _ = Describe("Upstreams", func() {
 	multipleIDsMsg := "multiple upstreams found with id \"foo\": upstream ids must be unique"
 	multiplePathsMsg := "multiple upstreams found with path \"/foo\": upstream paths must be unique"
 	staticCodeMsg := "upstream \"foo\" has staticCode (200), but is not a static upstream, set 'static' for a static response"
+	emptyClaimNameMsg := "upstream \"foo\" has a required claim with an empty claim name"
+	emptyClaimValuesMsg := "upstream \"foo\" has required claim \"groups\" with no values"
+	messageSignatureNoEffectMsg := "upstream \"foo\" has messageSignature, but does not proxy requests, this will have no effect."
 
 	DescribeTable("validateUpstreams",
 		func(o *validateUpstreamTableInput) {
@@ -207,5 +211,139 @@ var _ = Describe("Upstreams", func() {
 			},
 			errStrings: []string{emptyURIMsg, staticCodeMsg},
 		}),
+		Entry("with valid authorization rules", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://foo",
+						Authorization: &options.Authorization{
+							AllowedGroups: []string{"admins"},
+							RequiredClaims: []options.RequiredClaim{
+								{Claim: "groups", Values: []string{"admins"}},
+							},
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with invalid required claims", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://foo",
+						Authorization: &options.Authorization{
+							RequiredClaims: []options.RequiredClaim{
+								{Claim: "", Values: []string{"admins"}},
+								{Claim: "groups"},
+							},
+						},
+					},
+				},
+			},
+			errStrings: []string{emptyClaimNameMsg, emptyClaimValuesMsg},
+		}),
+		Entry("with a valid message signature", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://foo",
+						MessageSignature: &options.MessageSignature{
+							KeyID:     "proxy",
+							Algorithm: "ed25519",
+							Key:       &options.SecretSource{Value: []byte("key")},
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with an invalid message signature", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:     "foo",
+						Path:   "/foo",
+						Static: true,
+						MessageSignature: &options.MessageSignature{
+							Algorithm: "hmac-sha512",
+							Lifetime:  &negativeDuration,
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				messageSignatureNoEffectMsg,
+				"upstream \"foo\" has messageSignature with empty keyID: a key ID is required",
+				"upstream \"foo\" has messageSignature with unsupported algorithm \"hmac-sha512\": use \"hmac-sha256\" or \"ed25519\"",
+				"upstream \"foo\" has messageSignature with no key: a key is required",
+				"upstream \"foo\" has messageSignature with negative lifetime",
+			},
+		}),
+		Entry("with a message signature on a file upstream", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "file://var/lib/foo",
+						MessageSignature: &options.MessageSignature{
+							KeyID: "proxy",
+							Key:   &options.SecretSource{Value: []byte("secret"), FromEnv: "SECRET"},
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				messageSignatureNoEffectMsg,
+				"upstream \"foo\" has messageSignature with invalid key: " + multipleValuesForSecretSource,
+			},
+		}),
+		Entry("with a valid token exchange", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:   "foo",
+						Path: "/foo",
+						URI:  "http://foo",
+						TokenExchange: &options.TokenExchange{
+							Audience:         "orders-api",
+							Resource:         "https://orders.example.com/api",
+							Scopes:           []string{"orders:read"},
+							SubjectTokenType: "id_token",
+						},
+					},
+				},
+			},
+			errStrings: []string{},
+		}),
+		Entry("with an invalid token exchange", &validateUpstreamTableInput{
+			upstreams: options.UpstreamConfig{
+				Upstreams: []options.Upstream{
+					{
+						ID:     "foo",
+						Path:   "/foo",
+						Static: true,
+						TokenExchange: &options.TokenExchange{
+							Resource:         "/api#orders",
+							Scopes:           []string{"orders read"},
+							SubjectTokenType: "refresh_token",
+						},
+					},
+				},
+			},
+			errStrings: []string{
+				"upstream \"foo\" has tokenExchange, but does not proxy requests, this will have no effect.",
+				"upstream \"foo\" has tokenExchange with unsupported subjectTokenType \"refresh_token\": use \"access_token\" or \"id_token\"",
+				"upstream \"foo\" has tokenExchange with invalid resource \"/api#orders\": resources must be absolute URIs without a fragment",
+				"upstream \"foo\" has tokenExchange with invalid scope \"orders read\": scopes must not be empty or contain spaces, quotes or backslashes",
+			},
+		}),
 	)
 })
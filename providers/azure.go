@@ -264,9 +264,9 @@ func (p *AzureProvider) extractClaimsIntoSession(ctx context.Context, session *s
 	// due to above issues, id_token may not be signed by AAD
 	// in that case, we will fallback to access token
 	var err error
-	s, err = p.buildSessionFromClaims(session.IDToken, session.AccessToken)
+	s, err = p.buildSessionFromClaims(ctx, session.IDToken, session.AccessToken)
 	if err != nil || s.Email == "" {
-		s, err = p.buildSessionFromClaims(session.AccessToken, session.AccessToken)
+		s, err = p.buildSessionFromClaims(ctx, session.AccessToken, session.AccessToken)
 	}
 	if err != nil {
 		return fmt.Errorf("unable to get claims from token: %v", err)
@@ -276,6 +276,9 @@ func (p *AzureProvider) extractClaimsIntoSession(ctx context.Context, session *s
 	if s.Groups != nil {
 		session.Groups = s.Groups
 	}
+	if s.Claims != nil {
+		session.Claims = s.Claims
+	}
 
 	return nil
 }
@@ -308,7 +311,7 @@ func (p *AzureProvider) RefreshSession(ctx context.Context, s *sessions.SessionS
 
 	err := p.redeemRefreshToken(ctx, s)
 	if err != nil {
-		return false, fmt.Errorf("unable to redeem refresh token: %v", err)
+		return false, fmt.Errorf("unable to redeem refresh token: %w", err)
 	}
 
 	return true, nil
@@ -333,14 +336,16 @@ func (p *AzureProvider) redeemRefreshToken(ctx context.Context, s *sessions.Sess
 		IDToken      string `json:"id_token"`
 	}
 
-	err = requests.New(p.RedeemURL.String()).
+	result := requests.New(p.RedeemURL.String()).
 		WithContext(ctx).
 		WithMethod("POST").
 		WithBody(bytes.NewBufferString(params.Encode())).
 		SetHeader("Content-Type", "application/x-www-form-urlencoded").
-		Do().
-		UnmarshalInto(&jsonResponse)
-	if err != nil {
+		Do()
+	if err := checkRefreshTokenResult(result); err != nil {
+		return err
+	}
+	if err := result.UnmarshalInto(&jsonResponse); err != nil {
 		return err
 	}
 
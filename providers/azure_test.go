@@ -430,3 +430,20 @@ func TestAzureProviderRefresh(t *testing.T) {
 	assert.Equal(t, email, session.Email)
 	assert.Equal(t, timestamp, session.ExpiresOn.UTC())
 }
+
+func TestAzureProviderRefreshRejected(t *testing.T) {
+	b := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("content-type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant","error_description":"AADSTS70000: The provided grant has expired"}`))
+	}))
+	defer b.Close()
+	bURL, _ := url.Parse(b.URL)
+	p := testAzureProvider(bURL.Host, options.AzureOptions{})
+
+	session := &sessions.SessionState{AccessToken: "some_access_token", RefreshToken: "some_refresh_token"}
+	refreshed, err := p.RefreshSession(context.Background(), session)
+	assert.ErrorIs(t, err, ErrRefreshTokenRejected)
+	assert.False(t, refreshed)
+	assert.Equal(t, "some_refresh_token", session.RefreshToken)
+}
@@ -369,24 +369,31 @@ func (p *GoogleProvider) redeemRefreshToken(ctx context.Context, s *sessions.Ses
 	params.Add("grant_type", "refresh_token")
 
 	var data struct {
-		AccessToken string `json:"access_token"`
-		ExpiresIn   int64  `json:"expires_in"`
-		IDToken     string `json:"id_token"`
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+		IDToken      string `json:"id_token"`
 	}
 
-	err = requests.New(p.RedeemURL.String()).
+	result := requests.New(p.RedeemURL.String()).
 		WithContext(ctx).
 		WithMethod("POST").
 		WithBody(bytes.NewBufferString(params.Encode())).
 		SetHeader("Content-Type", "application/x-www-form-urlencoded").
-		Do().
-		UnmarshalInto(&data)
-	if err != nil {
+		Do()
+	if err := checkRefreshTokenResult(result); err != nil {
+		return err
+	}
+	if err := result.UnmarshalInto(&data); err != nil {
 		return err
 	}
 
 	s.AccessToken = data.AccessToken
 	s.IDToken = data.IDToken
+	// Google only returns a refresh token when it rotates it
+	if data.RefreshToken != "" {
+		s.RefreshToken = data.RefreshToken
+	}
 
 	s.CreatedAtNow()
 	s.ExpiresIn(time.Duration(data.ExpiresIn) * time.Second)
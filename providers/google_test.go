@@ -288,3 +288,39 @@ func TestGoogleProvider_userInGroup(t *testing.T) {
 	result = userInGroup(service, "group@example.com", "non-member-out-of-domain@otherexample.com")
 	assert.False(t, result)
 }
+
+func TestGoogleProviderRefreshSessionRotatesRefreshToken(t *testing.T) {
+	p := newGoogleProvider(t)
+	body, err := json.Marshal(redeemResponse{
+		AccessToken:  "a1234",
+		ExpiresIn:    10,
+		RefreshToken: "rotated12345",
+	})
+	assert.NoError(t, err)
+	var server *httptest.Server
+	p.RedeemURL, server = newRedeemServer(body)
+	defer server.Close()
+
+	session := &sessions.SessionState{AccessToken: "changeit", RefreshToken: "refresh12345"}
+	refreshed, err := p.RefreshSession(context.Background(), session)
+	assert.NoError(t, err)
+	assert.True(t, refreshed)
+	assert.Equal(t, "a1234", session.AccessToken)
+	assert.Equal(t, "rotated12345", session.RefreshToken)
+}
+
+func TestGoogleProviderRefreshSessionRejected(t *testing.T) {
+	p := newGoogleProvider(t)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(`{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`))
+	}))
+	defer server.Close()
+	p.RedeemURL, _ = url.Parse(server.URL)
+
+	session := &sessions.SessionState{AccessToken: "changeit", RefreshToken: "refresh12345"}
+	refreshed, err := p.RefreshSession(context.Background(), session)
+	assert.ErrorIs(t, err, ErrRefreshTokenRejected)
+	assert.False(t, refreshed)
+	assert.Equal(t, "refresh12345", session.RefreshToken)
+}
@@ -0,0 +1,260 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/util"
+)
+
+const (
+	// defaultIntrospectionCacheTTL is used when no cache TTL is configured
+	defaultIntrospectionCacheTTL = time.Minute
+
+	// maxIntrospectionCacheEntries bounds the memory used by the cache
+	maxIntrospectionCacheEntries = 10000
+)
+
+// ErrInactiveToken is returned when the introspection endpoint reports that
+// a token is not active
+var ErrInactiveToken = errors.New("token is not active")
+
+// TokenIntrospector validates bearer tokens with the OAuth 2.0 Token
+// Introspection (RFC 7662) endpoint of a provider.
+// Both active and inactive results are cached, so that repeated requests
+// with the same token do not each call the provider. Active tokens are never
+// cached beyond their expiry.
+type TokenIntrospector struct {
+	// Clock is used to expire cached results
+	Clock clock.Clock
+
+	provider *ProviderData
+	ttl      time.Duration
+	cache    *ttlCache[introspectionResult]
+}
+
+// introspectionResult is a cached introspection response
+type introspectionResult struct {
+	active bool
+	claims []byte
+	// exp is the expiry of the token, when the provider returned it
+	exp    int64
+	scopes []string
+}
+
+// NewTokenIntrospector creates a TokenIntrospector for the provider
+func NewTokenIntrospector(p *ProviderData) *TokenIntrospector {
+	ttl := p.IntrospectionCacheTTL
+	if ttl <= 0 {
+		ttl = defaultIntrospectionCacheTTL
+	}
+	return &TokenIntrospector{
+		provider: p,
+		ttl:      ttl,
+		cache:    newTTLCache[introspectionResult](maxIntrospectionCacheEntries),
+	}
+}
+
+// CreateSessionFromToken introspects the token and converts the response into
+// a session attributed to the provider.
+func (t *TokenIntrospector) CreateSessionFromToken(ctx context.Context, token string) (*sessions.SessionState, error) {
+	// The token is hashed so that the cache does not hold the tokens
+	// themselves
+	key := encryption.HashKey(token)
+
+	result, ok := t.cache.lookup(key, t.Clock.Now())
+	if !ok {
+		var expires time.Time
+		var err error
+		result, expires, err = t.introspect(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		t.cache.store(key, result, expires, t.Clock.Now())
+	}
+
+	if !result.active {
+		return nil, ErrInactiveToken
+	}
+	return t.buildSession(token, result)
+}
+
+// introspect calls the introspection endpoint of the provider. It returns the
+// result along with when the result expires from the cache.
+func (t *TokenIntrospector) introspect(ctx context.Context, token string) (introspectionResult, time.Time, error) {
+	if t.provider.IntrospectionURL == nil || t.provider.IntrospectionURL.String() == "" {
+		return introspectionResult{}, time.Time{}, errors.New("provider has no introspection endpoint")
+	}
+
+	params := url.Values{}
+	params.Set("token", token)
+	params.Set("token_type_hint", "access_token")
+
+	var response struct {
+		Active bool  `json:"active"`
+		Exp    int64 `json:"exp"`
+		middleware.ScopeClaims
+	}
+	body, err := t.provider.postClientForm(ctx, "token introspection", t.provider.IntrospectionURL.String(), params, &response)
+	if err != nil {
+		return introspectionResult{}, time.Time{}, err
+	}
+
+	now := t.Clock.Now()
+	expires := now.Add(t.ttl)
+	if response.Active && response.Exp > 0 {
+		exp := time.Unix(response.Exp, 0)
+		if !exp.After(now) {
+			// The provider should not report an expired token as active
+			return introspectionResult{}, expires, nil
+		}
+		if exp.Before(expires) {
+			expires = exp
+		}
+	}
+
+	return introspectionResult{
+		active: response.Active,
+		claims: body,
+		exp:    response.Exp,
+		scopes: response.Scopes(),
+	}, expires, nil
+}
+
+// buildSession maps the claims of an active token into a session
+func (t *TokenIntrospector) buildSession(token string, result introspectionResult) (*sessions.SessionState, error) {
+	extractor, err := util.NewJSONClaimExtractor(result.claims)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.checkTokenType(extractor); err != nil {
+		return nil, err
+	}
+	if err := t.checkAudience(extractor); err != nil {
+		return nil, err
+	}
+
+	ss := &sessions.SessionState{}
+	for _, c := range []struct {
+		claim string
+		dst   interface{}
+	}{
+		{"sub", &ss.User},
+		{"sub", &ss.Subject},
+		{"username", &ss.PreferredUsername},
+		{t.provider.EmailClaim, &ss.Email},
+		{t.provider.GroupsClaim, &ss.Groups},
+		{"aud", &ss.Audiences},
+	} {
+		if _, err := extractor.GetClaimInto(c.claim, c.dst); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, claim := range t.provider.ExtraClaims {
+		var values []string
+		exists, err := extractor.GetClaimInto(claim, &values)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		if ss.Claims == nil {
+			ss.Claims = map[string][]string{}
+		}
+		ss.Claims[claim] = values
+	}
+
+	// Tokens issued with the client credentials grant may have no subject
+	if ss.User == "" {
+		ss.User = ss.PreferredUsername
+	}
+	// Allow empty Email in Bearer case since we can't hit the ProfileURL
+	if ss.Email == "" {
+		ss.Email = ss.User
+	}
+
+	ss.Scopes = result.scopes
+	ss.AccessToken = token
+	ss.ProviderID = t.provider.ID
+
+	now := t.Clock.Now()
+	ss.CreatedAt = &now
+	if result.exp > 0 {
+		ss.SetExpiresOn(time.Unix(result.exp, 0))
+	}
+
+	return ss, nil
+}
+
+// checkTokenType rejects tokens that are not access tokens, such as refresh
+// tokens, when the provider reports their type
+func (t *TokenIntrospector) checkTokenType(extractor util.ClaimExtractor) error {
+	var tokenType string
+	if _, err := extractor.GetClaimInto("token_type", &tokenType); err != nil {
+		return err
+	}
+	switch strings.ToLower(tokenType) {
+	case "", "access_token", "bearer":
+		return nil
+	default:
+		return fmt.Errorf("introspected token has unsupported token type %q", tokenType)
+	}
+}
+
+// checkAudience accepts the audiences the verifier of the provider accepts:
+// the client ID or one of the extra audiences, in one of the audience claims.
+// Only tokens without any audience claim are checked against the client they
+// were issued to, so that tokens issued to the client for another audience
+// are rejected.
+func (t *TokenIntrospector) checkAudience(extractor util.ClaimExtractor) error {
+	allowed := map[string]struct{}{t.provider.ClientID: {}}
+	for _, audience := range t.provider.ExtraAudiences {
+		allowed[audience] = struct{}{}
+	}
+
+	audienceClaims := t.provider.AudienceClaims
+	if len(audienceClaims) == 0 {
+		audienceClaims = []string{"aud"}
+	}
+	hasAudience := false
+	for _, claim := range audienceClaims {
+		found, exists, err := hasAllowedValue(extractor, claim, allowed)
+		if err != nil || found {
+			return err
+		}
+		hasAudience = hasAudience || exists
+	}
+	if !hasAudience {
+		found, _, err := hasAllowedValue(extractor, "client_id", allowed)
+		if err != nil || found {
+			return err
+		}
+	}
+	return errors.New("introspected token was not issued for any of the allowed audiences")
+}
+
+// hasAllowedValue reports whether one of the values of the claim is allowed,
+// and whether the claim exists
+func hasAllowedValue(extractor util.ClaimExtractor, claim string, allowed map[string]struct{}) (bool, bool, error) {
+	var values []string
+	exists, err := extractor.GetClaimInto(claim, &values)
+	if err != nil || !exists {
+		return false, exists, err
+	}
+	for _, value := range values {
+		if _, ok := allowed[value]; ok {
+			return true, true, nil
+		}
+	}
+	return false, true, nil
+}
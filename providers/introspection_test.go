@@ -0,0 +1,188 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	introspectionClientID     = "client/id"
+	introspectionClientSecret = "secret:value"
+)
+
+func newTestIntrospectionServer(t *testing.T, responses map[string]map[string]interface{}, calls *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		*calls++
+
+		if req.FormValue("client_id") != introspectionClientID || req.FormValue("client_secret") != introspectionClientSecret {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "access_token", req.FormValue("token_type_hint"))
+
+		response, ok := responses[req.FormValue("token")]
+		if !ok {
+			response = map[string]interface{}{"active": false}
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		assert.NoError(t, json.NewEncoder(rw).Encode(response))
+	}))
+}
+
+func newTestTokenIntrospector(serverURL string, now time.Time) *TokenIntrospector {
+	introspectionURL, _ := url.Parse(serverURL)
+	introspector := NewTokenIntrospector(&ProviderData{
+		ID:                    "keycloak",
+		ClientID:              introspectionClientID,
+		ClientSecret:          introspectionClientSecret,
+		EmailClaim:            "email",
+		GroupsClaim:           "groups",
+		ExtraClaims:           []string{"client_id"},
+		ExtraAudiences:        []string{"https://api.example.com"},
+		IntrospectionURL:      introspectionURL,
+		IntrospectionCacheTTL: 5 * time.Minute,
+	})
+	introspector.Clock.Set(now)
+	return introspector
+}
+
+func TestTokenIntrospectorCreateSessionFromToken(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	exp := now.Add(time.Hour)
+
+	calls := 0
+	server := newTestIntrospectionServer(t, map[string]map[string]interface{}{
+		"opaque-token": {
+			"active":    true,
+			"sub":       "subject",
+			"username":  "jdoe",
+			"email":     "jdoe@example.com",
+			"groups":    []string{"admins", "users"},
+			"scope":     "openid api:read api:write",
+			"aud":       "https://api.example.com",
+			"client_id": "api-client",
+			"exp":       exp.Unix(),
+		},
+		"service-token": {
+			"active":     true,
+			"username":   "service-account",
+			"client_id":  introspectionClientID,
+			"token_type": "Bearer",
+		},
+	}, &calls)
+	defer server.Close()
+
+	introspector := newTestTokenIntrospector(server.URL, now)
+
+	session, err := introspector.CreateSessionFromToken(context.Background(), "opaque-token")
+	require.NoError(t, err)
+	assert.Equal(t, "subject", session.User)
+	assert.Equal(t, "subject", session.Subject)
+	assert.Equal(t, "jdoe", session.PreferredUsername)
+	assert.Equal(t, "jdoe@example.com", session.Email)
+	assert.Equal(t, []string{"admins", "users"}, session.Groups)
+	assert.Equal(t, map[string][]string{"client_id": {"api-client"}}, session.Claims)
+	assert.Equal(t, []string{"openid", "api:read", "api:write"}, session.Scopes)
+	assert.Equal(t, []string{"https://api.example.com"}, session.Audiences)
+	assert.Equal(t, "opaque-token", session.AccessToken)
+	assert.Equal(t, "keycloak", session.ProviderID)
+	assert.Equal(t, now, *session.CreatedAt)
+	assert.Equal(t, exp, *session.ExpiresOn)
+
+	session, err = introspector.CreateSessionFromToken(context.Background(), "service-token")
+	require.NoError(t, err)
+	assert.Equal(t, "service-account", session.User)
+	assert.Equal(t, "service-account", session.Email)
+	assert.Nil(t, session.ExpiresOn)
+
+	_, err = introspector.CreateSessionFromToken(context.Background(), "unknown-token")
+	assert.Equal(t, ErrInactiveToken, err)
+}
+
+func TestTokenIntrospectorCache(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	calls := 0
+	server := newTestIntrospectionServer(t, map[string]map[string]interface{}{
+		"short-lived-token": {"active": true, "sub": "subject", "aud": introspectionClientID, "exp": now.Add(time.Minute).Unix()},
+		"long-lived-token":  {"active": true, "sub": "subject", "aud": introspectionClientID, "exp": now.Add(time.Hour).Unix()},
+		"expired-token":     {"active": true, "sub": "subject", "aud": introspectionClientID, "exp": now.Add(-time.Minute).Unix()},
+	}, &calls)
+	defer server.Close()
+
+	introspector := newTestTokenIntrospector(server.URL, now)
+	introspect := func(token string) error {
+		_, err := introspector.CreateSessionFromToken(context.Background(), token)
+		return err
+	}
+
+	// Results are cached
+	for _, token := range []string{"short-lived-token", "long-lived-token"} {
+		assert.NoError(t, introspect(token))
+		assert.NoError(t, introspect(token))
+	}
+	assert.Equal(t, ErrInactiveToken, introspect("unknown-token"))
+	assert.Equal(t, ErrInactiveToken, introspect("unknown-token"))
+	assert.Equal(t, ErrInactiveToken, introspect("expired-token"))
+	assert.Equal(t, ErrInactiveToken, introspect("expired-token"))
+	assert.Equal(t, 4, calls)
+
+	// Active results are not cached beyond the expiry of the token
+	introspector.Clock.Set(now.Add(2 * time.Minute))
+	assert.Equal(t, ErrInactiveToken, introspect("short-lived-token"))
+	assert.NoError(t, introspect("long-lived-token"))
+	assert.Equal(t, ErrInactiveToken, introspect("unknown-token"))
+	assert.Equal(t, 5, calls)
+
+	// Other results are cached for the cache TTL
+	introspector.Clock.Set(now.Add(6 * time.Minute))
+	assert.NoError(t, introspect("long-lived-token"))
+	assert.Equal(t, ErrInactiveToken, introspect("unknown-token"))
+	assert.Equal(t, 7, calls)
+}
+
+func TestTokenIntrospectorRejectsTokens(t *testing.T) {
+	calls := 0
+	server := newTestIntrospectionServer(t, map[string]map[string]interface{}{
+		"other-audience-token": {"active": true, "sub": "subject", "aud": "other-api", "client_id": "other-client"},
+		"other-client-token":   {"active": true, "sub": "subject", "client_id": "other-client"},
+		"own-client-token":     {"active": true, "sub": "subject", "aud": "other-api", "client_id": introspectionClientID},
+		"refresh-token":        {"active": true, "sub": "subject", "client_id": introspectionClientID, "token_type": "refresh_token"},
+	}, &calls)
+	defer server.Close()
+
+	introspector := newTestTokenIntrospector(server.URL, time.Now())
+	for token, expected := range map[string]string{
+		"other-audience-token": "introspected token was not issued for any of the allowed audiences",
+		"other-client-token":   "introspected token was not issued for any of the allowed audiences",
+		"own-client-token":     "introspected token was not issued for any of the allowed audiences",
+		"refresh-token":        "introspected token has unsupported token type \"refresh_token\"",
+	} {
+		_, err := introspector.CreateSessionFromToken(context.Background(), token)
+		assert.EqualError(t, err, expected, token)
+	}
+}
+
+func TestTokenIntrospectorErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	introspector := newTestTokenIntrospector(server.URL, time.Now())
+	_, err := introspector.CreateSessionFromToken(context.Background(), "opaque-token")
+	assert.ErrorContains(t, err, "unexpected status 401")
+
+	introspector = NewTokenIntrospector(&ProviderData{})
+	_, err = introspector.CreateSessionFromToken(context.Background(), "opaque-token")
+	assert.EqualError(t, err, "provider has no introspection endpoint")
+}
@@ -98,9 +98,22 @@ func NewLoginGovProvider(p *ProviderData, opts options.LoginGovOptions) (*LoginG
 	if err := provider.configure(opts); err != nil {
 		return nil, fmt.Errorf("could not configure login.gov provider: %v", err)
 	}
+	p.clientAssertionFunc = provider.clientAssertion
 	return provider, nil
 }
 
+// clientAssertion signs the JWT login.gov authenticates the client with
+func (p *LoginGovProvider) clientAssertion(endpoint string) (string, error) {
+	claims := &jwt.RegisteredClaims{
+		Issuer:    p.ClientID,
+		Subject:   p.ClientID,
+		Audience:  jwt.ClaimStrings{endpoint},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod("RS256"), claims)
+	return token.SignedString(p.JWTKey)
+}
+
 func (p *LoginGovProvider) configure(opts options.LoginGovOptions) error {
 	pubJWKURL, err := url.Parse(opts.PubJWKURL)
 	if err != nil {
@@ -207,14 +220,7 @@ func (p *LoginGovProvider) Redeem(ctx context.Context, _, code, codeVerifier str
 		return nil, ErrMissingCode
 	}
 
-	claims := &jwt.RegisteredClaims{
-		Issuer:    p.ClientID,
-		Subject:   p.ClientID,
-		Audience:  jwt.ClaimStrings{p.RedeemURL.String()},
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
-	}
-	token := jwt.NewWithClaims(jwt.GetSigningMethod("RS256"), claims)
-	ss, err := token.SignedString(p.JWTKey)
+	ss, err := p.clientAssertion(p.RedeemURL.String())
 	if err != nil {
 		return nil, err
 	}
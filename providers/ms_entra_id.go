@@ -44,6 +44,9 @@ func NewMicrosoftEntraIDProvider(p *ProviderData, opts options.Provider) *Micros
 	p.setProviderDefaults(providerDefaults{
 		name: microsoftEntraIDProviderName,
 	})
+	if opts.MicrosoftEntraIDConfig.FederatedTokenAuth {
+		p.clientAssertionFunc = readFederatedToken
+	}
 
 	return &MicrosoftEntraIDProvider{
 		OIDCProvider: NewOIDCProvider(p, opts.OIDCConfig),
@@ -60,7 +63,7 @@ func (p *MicrosoftEntraIDProvider) EnrichSession(ctx context.Context, session *s
 		return fmt.Errorf("unable to enrich session: %v", err)
 	}
 
-	hasGroupOverage, err := p.checkGroupOverage(session)
+	hasGroupOverage, err := p.checkGroupOverage(ctx, session)
 	if err != nil {
 		return fmt.Errorf("unable to check token: %v", err)
 	}
@@ -77,7 +80,7 @@ func (p *MicrosoftEntraIDProvider) EnrichSession(ctx context.Context, session *s
 
 // ValidateSession checks for allowed tenants (e.g. for multi-tenant apps) and passes through to generic ValidateSession
 func (p *MicrosoftEntraIDProvider) ValidateSession(ctx context.Context, session *sessions.SessionState) bool {
-	tenant, err := p.getTenantFromToken(session)
+	tenant, err := p.getTenantFromToken(ctx, session)
 	if err != nil {
 		logger.Errorf("unable to retrieve entra tenant from token: %v", err)
 		return false
@@ -106,10 +109,9 @@ func (p *MicrosoftEntraIDProvider) Redeem(ctx context.Context, redirectURL, code
 
 // redeemWithFederatedToken performs custom token exchange with federated token instead of client secret
 func (p *MicrosoftEntraIDProvider) redeemWithFederatedToken(ctx context.Context, redirectURL, code, codeVerifier string) (*sessions.SessionState, error) {
-	federatedTokenPath := os.Getenv("AZURE_FEDERATED_TOKEN_FILE")
-	federatedToken, err := os.ReadFile(federatedTokenPath)
+	federatedToken, err := readFederatedToken(p.RedeemURL.String())
 	if err != nil {
-		return nil, fmt.Errorf("error reading federated token file %s: %s", federatedTokenPath, err)
+		return nil, err
 	}
 
 	params := url.Values{}
@@ -120,7 +122,7 @@ func (p *MicrosoftEntraIDProvider) redeemWithFederatedToken(ctx context.Context,
 	}
 	params.Add("redirect_uri", redirectURL)
 	params.Add("client_id", p.ClientID)
-	params.Add("client_assertion", string(federatedToken))
+	params.Add("client_assertion", federatedToken)
 	params.Add("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
 	params.Add("code", code)
 	params.Add("grant_type", "authorization_code")
@@ -150,9 +152,20 @@ func (p *MicrosoftEntraIDProvider) redeemWithFederatedToken(ctx context.Context,
 	return p.OIDCProvider.createSession(ctx, token.WithExtra(rawResponse), false)
 }
 
+// readFederatedToken reads the federated token used as the client assertion
+// of the workload identity
+func readFederatedToken(_ string) (string, error) {
+	federatedTokenPath := os.Getenv("AZURE_FEDERATED_TOKEN_FILE")
+	federatedToken, err := os.ReadFile(federatedTokenPath)
+	if err != nil {
+		return "", fmt.Errorf("error reading federated token file %s: %s", federatedTokenPath, err)
+	}
+	return string(federatedToken), nil
+}
+
 // checkGroupOverage checks ID token's group membership claims for the group overage
-func (p *MicrosoftEntraIDProvider) checkGroupOverage(session *sessions.SessionState) (bool, error) {
-	extractor, err := p.getClaimExtractor(session.IDToken, session.AccessToken)
+func (p *MicrosoftEntraIDProvider) checkGroupOverage(ctx context.Context, session *sessions.SessionState) (bool, error) {
+	extractor, err := p.getClaimExtractor(ctx, session.IDToken, session.AccessToken)
 	if err != nil {
 		return false, fmt.Errorf("unable to get claim extractor: %v", err)
 	}
@@ -213,8 +226,8 @@ func (p *MicrosoftEntraIDProvider) addGraphGroupsToSession(ctx context.Context,
 	return nil
 }
 
-func (p *MicrosoftEntraIDProvider) getTenantFromToken(session *sessions.SessionState) (string, error) {
-	extractor, err := p.getClaimExtractor(session.IDToken, session.AccessToken)
+func (p *MicrosoftEntraIDProvider) getTenantFromToken(ctx context.Context, session *sessions.SessionState) (string, error) {
+	extractor, err := p.getClaimExtractor(ctx, session.IDToken, session.AccessToken)
 	if err != nil {
 		return "", fmt.Errorf("unable to get claim extractor: %v", err)
 	}
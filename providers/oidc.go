@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/middleware"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
@@ -117,7 +118,7 @@ func (p *OIDCProvider) ValidateSession(ctx context.Context, s *sessions.SessionS
 	if p.SkipNonce {
 		return true
 	}
-	err = p.checkNonce(s)
+	err = p.checkNonce(ctx, s)
 	if err != nil {
 		logger.Errorf("nonce verification failed: %v", err)
 		return false
@@ -135,7 +136,7 @@ func (p *OIDCProvider) RefreshSession(ctx context.Context, s *sessions.SessionSt
 	ctx = oidc.ClientContext(ctx, requests.DefaultHTTPClient)
 	err := p.redeemRefreshToken(ctx, s)
 	if err != nil {
-		return false, fmt.Errorf("unable to redeem refresh token: %v", err)
+		return false, fmt.Errorf("unable to redeem refresh token: %w", err)
 	}
 
 	return true, nil
@@ -143,6 +144,8 @@ func (p *OIDCProvider) RefreshSession(ctx context.Context, s *sessions.SessionSt
 
 // redeemRefreshToken uses a RefreshToken with the RedeemURL to refresh the
 // Access Token and (probably) the ID Token.
+// Providers rotating refresh tokens return a new refresh token, which
+// replaces the used one in the session.
 func (p *OIDCProvider) redeemRefreshToken(ctx context.Context, s *sessions.SessionState) error {
 	clientSecret, err := p.GetClientSecret()
 	if err != nil {
@@ -162,7 +165,7 @@ func (p *OIDCProvider) redeemRefreshToken(ctx context.Context, s *sessions.Sessi
 	}
 	token, err := c.TokenSource(ctx, t).Token()
 	if err != nil {
-		return fmt.Errorf("failed to get token: %v", err)
+		return fmt.Errorf("failed to get token: %w", checkRefreshTokenError(err))
 	}
 
 	newSession, err := p.createSession(ctx, token, true)
@@ -179,6 +182,7 @@ func (p *OIDCProvider) redeemRefreshToken(ctx context.Context, s *sessions.Sessi
 		s.User = newSession.User
 		s.Groups = newSession.Groups
 		s.PreferredUsername = newSession.PreferredUsername
+		s.Claims = newSession.Claims
 	}
 
 	s.AccessToken = newSession.AccessToken
@@ -197,7 +201,7 @@ func (p *OIDCProvider) CreateSessionFromToken(ctx context.Context, token string)
 		return nil, err
 	}
 
-	ss, err := p.buildSessionFromClaims(token, "")
+	ss, err := p.buildSessionFromClaims(ctx, token, "")
 	if err != nil {
 		return nil, err
 	}
@@ -207,6 +211,13 @@ func (p *OIDCProvider) CreateSessionFromToken(ctx context.Context, token string)
 		ss.Email = ss.User
 	}
 
+	var scopeClaims middleware.ScopeClaims
+	if err := idToken.Claims(&scopeClaims); err != nil {
+		return nil, fmt.Errorf("failed to parse bearer token claims: %v", err)
+	}
+	ss.Scopes = scopeClaims.Scopes()
+	ss.Audiences = idToken.Audience
+
 	ss.AccessToken = token
 	ss.IDToken = token
 	ss.RefreshToken = ""
@@ -234,7 +245,7 @@ func (p *OIDCProvider) createSession(ctx context.Context, token *oauth2.Token, r
 	}
 
 	rawIDToken := getIDToken(token)
-	ss, err := p.buildSessionFromClaims(rawIDToken, token.AccessToken)
+	ss, err := p.buildSessionFromClaims(ctx, rawIDToken, token.AccessToken)
 	if err != nil {
 		return nil, err
 	}
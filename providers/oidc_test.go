@@ -213,6 +213,72 @@ func TestOIDCProviderRefreshSessionIfNeededWithIdToken(t *testing.T) {
 	assert.Equal(t, refreshToken, existingSession.RefreshToken)
 }
 
+func TestOIDCProviderRefreshSessionUpdatesExtraClaims(t *testing.T) {
+	idToken, _ := newSignedTestIDToken(defaultIDToken)
+	body, _ := json.Marshal(redeemTokenResponse{
+		AccessToken:  accessToken,
+		ExpiresIn:    10,
+		TokenType:    "Bearer",
+		RefreshToken: refreshToken,
+		IDToken:      idToken,
+	})
+
+	server, provider := newTestOIDCSetup(body)
+	provider.ExtraClaims = []string{"phone_number"}
+	defer server.Close()
+
+	existingSession := &sessions.SessionState{
+		AccessToken:  "changeit",
+		RefreshToken: refreshToken,
+		Claims:       map[string][]string{"phone_number": {"changeit"}},
+	}
+	refreshed, err := provider.RefreshSession(context.Background(), existingSession)
+	assert.NoError(t, err)
+	assert.True(t, refreshed)
+	assert.Equal(t, map[string][]string{"phone_number": {defaultIDToken.Phone}}, existingSession.Claims)
+}
+
+func TestOIDCProviderRefreshSessionRotatesRefreshToken(t *testing.T) {
+	body, _ := json.Marshal(redeemTokenResponse{
+		AccessToken:  accessToken,
+		ExpiresIn:    10,
+		TokenType:    "Bearer",
+		RefreshToken: "rotated_refresh_token",
+	})
+
+	server, provider := newTestOIDCSetup(body)
+	defer server.Close()
+
+	existingSession := &sessions.SessionState{
+		AccessToken:  "changeit",
+		RefreshToken: refreshToken,
+	}
+	refreshed, err := provider.RefreshSession(context.Background(), existingSession)
+	assert.NoError(t, err)
+	assert.True(t, refreshed)
+	assert.Equal(t, "rotated_refresh_token", existingSession.RefreshToken)
+}
+
+func TestOIDCProviderRefreshSessionRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Add("content-type", "application/json")
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = rw.Write([]byte(`{"error":"invalid_grant","error_description":"refresh token reused"}`))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	provider := newOIDCProvider(serverURL, false)
+
+	existingSession := &sessions.SessionState{
+		AccessToken:  "changeit",
+		RefreshToken: refreshToken,
+	}
+	refreshed, err := provider.RefreshSession(context.Background(), existingSession)
+	assert.ErrorIs(t, err, ErrRefreshTokenRejected)
+	assert.False(t, refreshed)
+	assert.Equal(t, refreshToken, existingSession.RefreshToken)
+}
+
 func TestOIDCProviderCreateSessionFromToken(t *testing.T) {
 	testCases := map[string]struct {
 		IDToken        idTokenClaims
@@ -1,14 +1,18 @@
 package providers
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"os"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
@@ -16,6 +20,7 @@ import (
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/logger"
 	internaloidc "github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/oidc"
 	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/providers/util"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
 	"golang.org/x/oauth2"
 )
 
@@ -27,6 +32,10 @@ const (
 // ProviderData contains information required to configure all implementations
 // of OAuth2 providers
 type ProviderData struct {
+	// ID is the unique identifier of the provider as configured.
+	// It is used to select the provider at login and to route sessions back
+	// to the provider that issued them.
+	ID                string
 	ProviderName      string
 	LoginURL          *url.URL
 	RedeemURL         *url.URL
@@ -47,18 +56,45 @@ type ProviderData struct {
 	UserClaim                string
 	EmailClaim               string
 	GroupsClaim              string
+	ExtraClaims              []string
 	Verifier                 internaloidc.IDTokenVerifier
 	SkipClaimsFromProfileURL bool
+	// AudienceClaims and ExtraAudiences are the audiences the Verifier
+	// accepts, which introspected tokens are checked against too
+	AudienceClaims []string
+	ExtraAudiences []string
 
 	// Universal Group authorization data structure
 	// any provider can set to consume
 	AllowedGroups map[string]struct{}
 
 	getAuthorizationHeaderFunc func(string) http.Header
+	// clientAssertionFunc creates a JWT client assertion for the endpoint,
+	// for providers that authenticate with assertions rather than the
+	// client secret
+	clientAssertionFunc        func(endpoint string) (string, error)
 	loginURLParameterDefaults  url.Values
 	loginURLParameterOverrides map[string]*regexp.Regexp
 
 	BackendLogoutURL string
+
+	// EndSessionURL is the OIDC end session endpoint users are redirected to
+	// on sign out when RPInitiatedLogout is enabled.
+	EndSessionURL     *url.URL
+	RPInitiatedLogout bool
+
+	// IntrospectionURL is the token introspection endpoint opaque bearer
+	// tokens are validated with when IntrospectBearerTokens is enabled.
+	// Results are cached for up to IntrospectionCacheTTL.
+	IntrospectionURL       *url.URL
+	IntrospectBearerTokens bool
+	IntrospectionCacheTTL  time.Duration
+
+	// PushedAuthorizationRequestURL is the endpoint the parameters of
+	// authorization requests are pushed to when PushedAuthorizationRequests
+	// is enabled.
+	PushedAuthorizationRequestURL *url.URL
+	PushedAuthorizationRequests   bool
 }
 
 // Data returns the ProviderData
@@ -238,14 +274,14 @@ func (p *ProviderData) verifyIDToken(ctx context.Context, token *oauth2.Token) (
 
 // buildSessionFromClaims uses IDToken claims to populate a fresh SessionState
 // with non-Token related fields.
-func (p *ProviderData) buildSessionFromClaims(rawIDToken, accessToken string) (*sessions.SessionState, error) {
+func (p *ProviderData) buildSessionFromClaims(ctx context.Context, rawIDToken, accessToken string) (*sessions.SessionState, error) {
 	ss := &sessions.SessionState{}
 
 	if rawIDToken == "" {
 		return ss, nil
 	}
 
-	extractor, err := p.getClaimExtractor(rawIDToken, accessToken)
+	extractor, err := p.getClaimExtractor(ctx, rawIDToken, accessToken)
 	if err != nil {
 		return nil, err
 	}
@@ -266,6 +302,29 @@ func (p *ProviderData) buildSessionFromClaims(rawIDToken, accessToken string) (*
 		}
 	}
 
+	for _, claim := range p.ExtraClaims {
+		var values []string
+		exists, err := extractor.GetClaimInto(claim, &values)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		if ss.Claims == nil {
+			ss.Claims = map[string][]string{}
+		}
+		ss.Claims[claim] = values
+	}
+
+	// The subject and session ID identify the session to the provider. They
+	// are only read from the ID Token itself and never from the profile URL.
+	for claim, dst := range map[string]*string{"sub": &ss.Subject, "sid": &ss.SessionID} {
+		if _, err := extractor.GetTokenClaimInto(claim, dst); err != nil {
+			return nil, err
+		}
+	}
+
 	// `email_verified` must be present and explicitly set to `false` to be
 	// considered unverified.
 	verifyEmail := (p.EmailClaim == options.OIDCEmailClaim) && !p.AllowUnverifiedEmail
@@ -285,13 +344,13 @@ func (p *ProviderData) buildSessionFromClaims(rawIDToken, accessToken string) (*
 	return ss, nil
 }
 
-func (p *ProviderData) getClaimExtractor(rawIDToken, accessToken string) (util.ClaimExtractor, error) {
+func (p *ProviderData) getClaimExtractor(ctx context.Context, rawIDToken, accessToken string) (util.ClaimExtractor, error) {
 	profileURL := p.ProfileURL
 	if p.SkipClaimsFromProfileURL {
 		profileURL = &url.URL{}
 	}
 
-	extractor, err := util.NewClaimExtractor(context.TODO(), rawIDToken, profileURL, p.getAuthorizationHeader(accessToken))
+	extractor, err := util.NewClaimExtractor(ctx, rawIDToken, profileURL, p.getAuthorizationHeader(accessToken))
 	if err != nil {
 		return nil, fmt.Errorf("could not initialise claim extractor: %v", err)
 	}
@@ -300,8 +359,8 @@ func (p *ProviderData) getClaimExtractor(rawIDToken, accessToken string) (util.C
 }
 
 // checkNonce compares the session's nonce with the IDToken's nonce claim
-func (p *ProviderData) checkNonce(s *sessions.SessionState) error {
-	extractor, err := p.getClaimExtractor(s.IDToken, "")
+func (p *ProviderData) checkNonce(ctx context.Context, s *sessions.SessionState) error {
+	extractor, err := p.getClaimExtractor(ctx, s.IDToken, "")
 	if err != nil {
 		return fmt.Errorf("id_token claims extraction failed: %v", err)
 	}
@@ -316,6 +375,64 @@ func (p *ProviderData) checkNonce(s *sessions.SessionState) error {
 	return nil
 }
 
+// postClientForm posts the form parameters to one of the endpoints of the
+// provider, authenticating with the client authentication of the provider,
+// and decodes the JSON response into v. It returns the body of the response.
+// Responses with a status other than OK or one of the accepted statuses are
+// returned as errors, which are prefixed with the operation.
+func (p *ProviderData) postClientForm(ctx context.Context, operation, endpoint string, params url.Values, v interface{}, accepted ...int) ([]byte, error) {
+	if err := p.addClientAuthentication(params, endpoint); err != nil {
+		return nil, err
+	}
+
+	result := requests.New(endpoint).
+		WithContext(ctx).
+		WithMethod("POST").
+		WithBody(bytes.NewBufferString(params.Encode())).
+		SetHeader("Content-Type", "application/x-www-form-urlencoded").
+		SetHeader("Accept", "application/json").
+		Do()
+	if result.Error() != nil {
+		return nil, fmt.Errorf("%s failed: %v", operation, result.Error())
+	}
+	if result.StatusCode() != http.StatusOK && !slices.Contains(accepted, result.StatusCode()) {
+		return nil, fmt.Errorf("%s failed: unexpected status %d: %s", operation, result.StatusCode(), result.Body())
+	}
+
+	if err := json.Unmarshal(result.Body(), v); err != nil {
+		return nil, fmt.Errorf("could not parse %s response: %v", operation, err)
+	}
+	return result.Body(), nil
+}
+
+// addClientAuthentication adds the client authentication of the provider to
+// the form parameters of a request to one of its endpoints: a client
+// assertion for providers that use them, or the client secret.
+// Public clients only identify themselves with the client_id parameter.
+// The token endpoint requests of Redeem are built by each provider instead.
+func (p *ProviderData) addClientAuthentication(params url.Values, endpoint string) error {
+	params.Set("client_id", p.ClientID)
+
+	if p.clientAssertionFunc != nil {
+		assertion, err := p.clientAssertionFunc(endpoint)
+		if err != nil {
+			return err
+		}
+		params.Set("client_assertion", assertion)
+		params.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+		return nil
+	}
+
+	clientSecret, err := p.GetClientSecret()
+	if err != nil {
+		return err
+	}
+	if clientSecret != "" {
+		params.Set("client_secret", clientSecret)
+	}
+	return nil
+}
+
 func (p *ProviderData) getAuthorizationHeader(accessToken string) http.Header {
 	if p.getAuthorizationHeaderFunc != nil && accessToken != "" {
 		return p.getAuthorizationHeaderFunc(accessToken)
@@ -109,6 +109,23 @@ var (
 	minimalIDToken = idTokenClaims{
 		RegisteredClaims: registeredClaims,
 	}
+
+	sessionIDToken = idTokenClaims{
+		Email:            "janed@me.com",
+		Sid:              "08a5019c-17e1-4977-8f42-65a12843ea02",
+		RegisteredClaims: registeredClaims,
+	}
+
+	extraClaimsIDToken = idTokenClaims{
+		Email: "janed@me.com",
+		Phone: "+4798765432",
+		Roles: []string{"test:simple", "test:roles"},
+		Org: map[string]interface{}{
+			"department": "Engineering",
+			"tenant":     map[string]interface{}{"id": 42},
+		},
+		RegisteredClaims: registeredClaims,
+	}
 )
 
 type idTokenClaims struct {
@@ -120,6 +137,8 @@ type idTokenClaims struct {
 	Roles    interface{} `json:"roles,omitempty"`
 	Verified *bool       `json:"email_verified,omitempty"`
 	Nonce    string      `json:"nonce,omitempty"`
+	Sid      string      `json:"sid,omitempty"`
+	Org      interface{} `json:"org,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -232,6 +251,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 		UserClaim                string
 		EmailClaim               string
 		GroupsClaim              string
+		ExtraClaims              []string
 		SkipClaimsFromProfileURL bool
 		SetProfileURL            bool
 		ExpectedError            error
@@ -249,6 +269,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 				Email:             "janed@me.com",
 				Groups:            []string{"test:a", "test:b"},
 				PreferredUsername: "Jane Dobbs",
+				Subject:           "123456789",
 			},
 		},
 		"Unverified Denied": {
@@ -269,6 +290,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 				Email:             "unverified@email.com",
 				Groups:            []string{"test:a", "test:b"},
 				PreferredUsername: "Mystery Man",
+				Subject:           "123456789",
 			},
 		},
 		"Complex Groups": {
@@ -286,6 +308,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 					"Just::A::String",
 				},
 				PreferredUsername: "Complex Claim",
+				Subject:           "123456789",
 			},
 		},
 		"User Claim Switched": {
@@ -299,6 +322,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 				Email:             "janed@me.com",
 				Groups:            []string{"test:a", "test:b"},
 				PreferredUsername: "Jane Dobbs",
+				Subject:           "123456789",
 			},
 		},
 		"User Claim switched to non string": {
@@ -312,6 +336,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 				Email:             "janed@me.com",
 				Groups:            []string{"test:a", "test:b"},
 				PreferredUsername: "Jane Dobbs",
+				Subject:           "123456789",
 			},
 		},
 		"Email Claim Switched": {
@@ -325,6 +350,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 				Email:             "+4025205729",
 				Groups:            []string{"test:a", "test:b"},
 				PreferredUsername: "Mystery Man",
+				Subject:           "123456789",
 			},
 		},
 		"Email Claim Switched to Non String": {
@@ -338,6 +364,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 				Email:             "[\"test:c\",\"test:d\"]",
 				Groups:            []string{"test:a", "test:b"},
 				PreferredUsername: "Mystery Man",
+				Subject:           "123456789",
 			},
 		},
 		"Email Claim Non Existent": {
@@ -351,6 +378,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 				Email:             "",
 				Groups:            []string{"test:a", "test:b"},
 				PreferredUsername: "Mystery Man",
+				Subject:           "123456789",
 			},
 		},
 		"Groups Claim Switched": {
@@ -364,6 +392,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 				Email:             "janed@me.com",
 				Groups:            []string{"test:c", "test:d"},
 				PreferredUsername: "Jane Dobbs",
+				Subject:           "123456789",
 			},
 		},
 		"Groups Claim Non Existent": {
@@ -377,6 +406,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 				Email:             "janed@me.com",
 				Groups:            nil,
 				PreferredUsername: "Jane Dobbs",
+				Subject:           "123456789",
 			},
 		},
 		"Groups Claim Numeric values": {
@@ -390,6 +420,7 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 				Email:             "janed@me.com",
 				Groups:            []string{"1", "2", "3"},
 				PreferredUsername: "Jane Dobbs",
+				Subject:           "123456789",
 			},
 		},
 		"Groups Claim string values": {
@@ -403,19 +434,50 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 				Email:             "janed@me.com",
 				Groups:            []string{"janed@me.com"},
 				PreferredUsername: "Jane Dobbs",
+				Subject:           "123456789",
+			},
+		},
+		"Session ID": {
+			IDToken:         sessionIDToken,
+			AllowUnverified: true,
+			EmailClaim:      "email",
+			UserClaim:       "sub",
+			ExpectedSession: &sessions.SessionState{
+				User:      "123456789",
+				Email:     "janed@me.com",
+				Subject:   "123456789",
+				SessionID: "08a5019c-17e1-4977-8f42-65a12843ea02",
+			},
+		},
+		"Extra claims": {
+			IDToken:         extraClaimsIDToken,
+			AllowUnverified: true,
+			EmailClaim:      "email",
+			UserClaim:       "sub",
+			ExtraClaims:     []string{"phone_number", "roles", "org.department", "org.tenant", "missing"},
+			ExpectedSession: &sessions.SessionState{
+				User:    "123456789",
+				Email:   "janed@me.com",
+				Subject: "123456789",
+				Claims: map[string][]string{
+					"phone_number":   {"+4798765432"},
+					"roles":          {"test:simple", "test:roles"},
+					"org.department": {"Engineering"},
+					"org.tenant":     {`{"id":42}`},
+				},
 			},
 		},
 		"Request claims from ProfileURL": {
 			IDToken:                minimalIDToken,
 			SetProfileURL:          true,
 			ExpectProfileURLCalled: true,
-			ExpectedSession:        &sessions.SessionState{},
+			ExpectedSession:        &sessions.SessionState{Subject: "123456789"},
 		},
 		"Skip claims request to ProfileURL": {
 			IDToken:                  minimalIDToken,
 			SetProfileURL:            true,
 			SkipClaimsFromProfileURL: true,
-			ExpectedSession:          &sessions.SessionState{},
+			ExpectedSession:          &sessions.SessionState{Subject: "123456789"},
 		},
 	}
 	for testName, tc := range testCases {
@@ -452,12 +514,13 @@ func TestProviderData_buildSessionFromClaims(t *testing.T) {
 			provider.UserClaim = tc.UserClaim
 			provider.EmailClaim = tc.EmailClaim
 			provider.GroupsClaim = tc.GroupsClaim
+			provider.ExtraClaims = tc.ExtraClaims
 			provider.SkipClaimsFromProfileURL = tc.SkipClaimsFromProfileURL
 
 			rawIDToken, err := newSignedTestIDToken(tc.IDToken)
 			g.Expect(err).ToNot(HaveOccurred())
 
-			ss, err := provider.buildSessionFromClaims(rawIDToken, "testtoken")
+			ss, err := provider.buildSessionFromClaims(context.Background(), rawIDToken, "testtoken")
 			if err != nil {
 				g.Expect(err).To(Equal(tc.ExpectedError))
 			}
@@ -520,7 +583,7 @@ func TestProviderData_checkNonce(t *testing.T) {
 				), verificationOptions),
 			}
 
-			if err := provider.checkNonce(tc.Session); err != nil {
+			if err := provider.checkNonce(context.Background(), tc.Session); err != nil {
 				g.Expect(err).To(Equal(tc.ExpectedError))
 			} else {
 				g.Expect(err).ToNot(HaveOccurred())
@@ -29,6 +29,11 @@ var (
 	// but an attempt to call `Verifier.Verify` was about to be made.
 	ErrMissingOIDCVerifier = errors.New("oidc verifier is not configured")
 
+	// ErrRefreshTokenRejected is returned when the token endpoint rejects a
+	// refresh token with an `invalid_grant` error. With refresh token
+	// rotation, this is how providers report the reuse of a rotated token.
+	ErrRefreshTokenRejected = errors.New("refresh token was rejected")
+
 	_ Provider = (*ProviderData)(nil)
 )
 
@@ -74,6 +74,7 @@ func NewProvider(providerConfig options.Provider) (Provider, error) {
 
 func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData, error) {
 	p := &ProviderData{
+		ID:               providerConfig.ID,
 		Scope:            providerConfig.Scope,
 		ClientID:         providerConfig.ClientID,
 		ClientSecret:     providerConfig.ClientSecret,
@@ -109,6 +110,15 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 			providerConfig.RedeemURL = endpoints.TokenURL
 			providerConfig.ProfileURL = endpoints.UserInfoURL
 			providerConfig.OIDCConfig.JwksURL = endpoints.JWKsURL
+			if endpoints.EndSessionURL != "" {
+				providerConfig.OIDCConfig.EndSessionURL = endpoints.EndSessionURL
+			}
+			if endpoints.IntrospectionURL != "" {
+				providerConfig.OIDCConfig.IntrospectionURL = endpoints.IntrospectionURL
+			}
+			if endpoints.PushedAuthorizationRequestURL != "" {
+				providerConfig.OIDCConfig.PushedAuthorizationRequestURL = endpoints.PushedAuthorizationRequestURL
+			}
 			p.SupportedCodeChallengeMethods = pkce.CodeChallengeAlgs
 		}
 	}
@@ -118,11 +128,14 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 		dst **url.URL
 		raw string
 	}{
-		"login":    {dst: &p.LoginURL, raw: providerConfig.LoginURL},
-		"redeem":   {dst: &p.RedeemURL, raw: providerConfig.RedeemURL},
-		"profile":  {dst: &p.ProfileURL, raw: providerConfig.ProfileURL},
-		"validate": {dst: &p.ValidateURL, raw: providerConfig.ValidateURL},
-		"resource": {dst: &p.ProtectedResource, raw: providerConfig.ProtectedResource},
+		"login":                        {dst: &p.LoginURL, raw: providerConfig.LoginURL},
+		"redeem":                       {dst: &p.RedeemURL, raw: providerConfig.RedeemURL},
+		"profile":                      {dst: &p.ProfileURL, raw: providerConfig.ProfileURL},
+		"validate":                     {dst: &p.ValidateURL, raw: providerConfig.ValidateURL},
+		"resource":                     {dst: &p.ProtectedResource, raw: providerConfig.ProtectedResource},
+		"end session":                  {dst: &p.EndSessionURL, raw: providerConfig.OIDCConfig.EndSessionURL},
+		"introspection":                {dst: &p.IntrospectionURL, raw: providerConfig.OIDCConfig.IntrospectionURL},
+		"pushed authorization request": {dst: &p.PushedAuthorizationRequestURL, raw: providerConfig.OIDCConfig.PushedAuthorizationRequestURL},
 	} {
 		var err error
 		*u.dst, err = url.Parse(u.raw)
@@ -141,6 +154,9 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 	p.AllowUnverifiedEmail = providerConfig.OIDCConfig.InsecureAllowUnverifiedEmail
 	p.EmailClaim = providerConfig.OIDCConfig.EmailClaim
 	p.GroupsClaim = providerConfig.OIDCConfig.GroupsClaim
+	p.ExtraClaims = providerConfig.OIDCConfig.ExtraClaims
+	p.AudienceClaims = providerConfig.OIDCConfig.AudienceClaims
+	p.ExtraAudiences = providerConfig.OIDCConfig.ExtraAudiences
 	p.SkipClaimsFromProfileURL = providerConfig.SkipClaimsFromProfileURL
 
 	// Set PKCE enabled or disabled based on discovery and force options
@@ -163,6 +179,13 @@ func newProviderDataFromConfig(providerConfig options.Provider) (*ProviderData,
 	p.setAllowedGroups(providerConfig.AllowedGroups)
 
 	p.BackendLogoutURL = providerConfig.BackendLogoutURL
+	p.RPInitiatedLogout = providerConfig.OIDCConfig.RPInitiatedLogout
+	p.IntrospectBearerTokens = providerConfig.OIDCConfig.IntrospectBearerTokens
+	p.IntrospectionCacheTTL = providerConfig.OIDCConfig.IntrospectionCacheTTL.Duration()
+	p.PushedAuthorizationRequests = providerConfig.OIDCConfig.PushedAuthorizationRequests
+	if p.PushedAuthorizationRequests && p.PushedAuthorizationRequestURL.String() == "" {
+		logger.Printf("Warning: provider %q has no pushed authorization request endpoint, logins use the full login URL", providerConfig.ID)
+	}
 
 	return p, nil
 }
@@ -0,0 +1,41 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// PushAuthorizationRequest sends the parameters of the login URL to the
+// pushed authorization request endpoint (RFC 9126). It returns the login URL
+// with only the `client_id` and the `request_uri` that references the pushed
+// parameters.
+// The login URL is returned unchanged when pushed authorization requests are
+// disabled or the provider has no such endpoint.
+func (p *ProviderData) PushAuthorizationRequest(ctx context.Context, loginURL string) (string, error) {
+	if !p.PushedAuthorizationRequests || p.PushedAuthorizationRequestURL == nil || p.PushedAuthorizationRequestURL.String() == "" {
+		return loginURL, nil
+	}
+
+	u, err := url.Parse(loginURL)
+	if err != nil {
+		return "", fmt.Errorf("could not parse login URL: %v", err)
+	}
+	var response struct {
+		RequestURI string `json:"request_uri"`
+	}
+	if _, err := p.postClientForm(ctx, "pushed authorization request", p.PushedAuthorizationRequestURL.String(), u.Query(), &response, http.StatusCreated); err != nil {
+		return "", err
+	}
+	if response.RequestURI == "" {
+		return "", errors.New("pushed authorization response has no request_uri")
+	}
+
+	u.RawQuery = url.Values{
+		"client_id":   {p.ClientID},
+		"request_uri": {response.RequestURI},
+	}.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,145 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// The client ID is escaped in the login URL
+	parClientID     = "par/client"
+	parClientSecret = "par:secret"
+)
+
+func newTestPushedAuthorizationProvider(parURL string) *ProviderData {
+	loginURL, _ := url.Parse("https://idp.example.com/authorize")
+	pushedAuthorizationRequestURL, _ := url.Parse(parURL)
+	return &ProviderData{
+		ClientID:                      parClientID,
+		ClientSecret:                  parClientSecret,
+		Scope:                         "openid email",
+		LoginURL:                      loginURL,
+		PushedAuthorizationRequestURL: pushedAuthorizationRequestURL,
+		PushedAuthorizationRequests:   true,
+	}
+}
+
+func TestPushAuthorizationRequest(t *testing.T) {
+	var pushed url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.FormValue("client_id") != parClientID || req.FormValue("client_secret") != parClientSecret {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.NoError(t, req.ParseForm())
+		pushed = req.PostForm
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusCreated)
+		_, _ = rw.Write([]byte(`{"request_uri":"urn:ietf:params:oauth:request_uri:abc123","expires_in":60}`))
+	}))
+	defer server.Close()
+
+	provider := newTestPushedAuthorizationProvider(server.URL)
+	loginURL := provider.GetLoginURL("https://proxy.example.com/oauth2/callback", "state", "", url.Values{"code_challenge": {"challenge"}})
+
+	pushedURL, err := provider.PushAuthorizationRequest(context.Background(), loginURL)
+	require.NoError(t, err)
+	assert.Equal(t, "https://idp.example.com/authorize?client_id="+url.QueryEscape(parClientID)+
+		"&request_uri="+url.QueryEscape("urn:ietf:params:oauth:request_uri:abc123"), pushedURL)
+
+	assert.Equal(t, url.Values{
+		"client_id":      {parClientID},
+		"client_secret":  {parClientSecret},
+		"redirect_uri":   {"https://proxy.example.com/oauth2/callback"},
+		"response_type":  {"code"},
+		"scope":          {"openid email"},
+		"state":          {"state"},
+		"code_challenge": {"challenge"},
+	}, pushed)
+}
+
+func TestPushAuthorizationRequestPublicClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "public-client", req.FormValue("client_id"))
+		_, hasSecret := req.PostForm["client_secret"]
+		assert.False(t, hasSecret)
+		rw.WriteHeader(http.StatusCreated)
+		_, _ = rw.Write([]byte(`{"request_uri":"urn:example:public"}`))
+	}))
+	defer server.Close()
+
+	provider := newTestPushedAuthorizationProvider(server.URL)
+	provider.ClientID = "public-client"
+	provider.ClientSecret = ""
+
+	pushedURL, err := provider.PushAuthorizationRequest(context.Background(), provider.GetLoginURL("https://proxy.example.com/oauth2/callback", "state", "", url.Values{}))
+	require.NoError(t, err)
+	assert.Equal(t, "https://idp.example.com/authorize?client_id=public-client&request_uri=urn%3Aexample%3Apublic", pushedURL)
+}
+
+func TestPushAuthorizationRequestClientAssertion(t *testing.T) {
+	var pushed url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.NoError(t, req.ParseForm())
+		pushed = req.PostForm
+		rw.WriteHeader(http.StatusCreated)
+		_, _ = rw.Write([]byte(`{"request_uri":"urn:example:assertion"}`))
+	}))
+	defer server.Close()
+
+	provider := newTestPushedAuthorizationProvider(server.URL)
+	provider.clientAssertionFunc = func(endpoint string) (string, error) {
+		return "assertion-for:" + endpoint, nil
+	}
+
+	_, err := provider.PushAuthorizationRequest(context.Background(), provider.GetLoginURL("https://proxy.example.com/oauth2/callback", "state", "", url.Values{}))
+	require.NoError(t, err)
+	assert.Equal(t, "assertion-for:"+server.URL, pushed.Get("client_assertion"))
+	assert.Equal(t, "urn:ietf:params:oauth:client-assertion-type:jwt-bearer", pushed.Get("client_assertion_type"))
+	assert.NotContains(t, pushed, "client_secret")
+}
+
+func TestPushAuthorizationRequestErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.FormValue("state") == "empty" {
+			rw.WriteHeader(http.StatusCreated)
+			_, _ = rw.Write([]byte(`{}`))
+			return
+		}
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = rw.Write([]byte(`{"error":"invalid_request"}`))
+	}))
+	defer server.Close()
+
+	provider := newTestPushedAuthorizationProvider(server.URL)
+
+	_, err := provider.PushAuthorizationRequest(context.Background(), provider.GetLoginURL("https://proxy.example.com/oauth2/callback", "state", "", url.Values{}))
+	assert.EqualError(t, err, `pushed authorization request failed: unexpected status 400: {"error":"invalid_request"}`)
+
+	_, err = provider.PushAuthorizationRequest(context.Background(), provider.GetLoginURL("https://proxy.example.com/oauth2/callback", "empty", "", url.Values{}))
+	assert.EqualError(t, err, "pushed authorization response has no request_uri")
+}
+
+func TestPushAuthorizationRequestFallback(t *testing.T) {
+	loginURL := "https://idp.example.com/authorize?client_id=client&state=state"
+
+	// Without an endpoint logins use the full login URL
+	provider := newTestPushedAuthorizationProvider("")
+	pushedURL, err := provider.PushAuthorizationRequest(context.Background(), loginURL)
+	require.NoError(t, err)
+	assert.Equal(t, loginURL, pushedURL)
+
+	provider = newTestPushedAuthorizationProvider("https://idp.example.com/par")
+	provider.PushedAuthorizationRequests = false
+	pushedURL, err = provider.PushAuthorizationRequest(context.Background(), loginURL)
+	require.NoError(t, err)
+	assert.Equal(t, loginURL, pushedURL)
+}
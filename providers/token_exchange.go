@@ -0,0 +1,122 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/clock"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/encryption"
+)
+
+const (
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	tokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+	tokenTypeIDToken       = "urn:ietf:params:oauth:token-type:id_token"
+
+	// tokenExchangeExpiryDelta is how long before their expiry exchanged
+	// tokens are exchanged again, so that upstreams do not receive tokens
+	// that expire in transit
+	tokenExchangeExpiryDelta = 30 * time.Second
+
+	// defaultTokenExchangeCacheTTL is used for exchanged tokens the provider
+	// does not give a lifetime for
+	defaultTokenExchangeCacheTTL = time.Minute
+
+	// maxTokenExchangeCacheEntries bounds the memory used by the cache
+	maxTokenExchangeCacheEntries = 10000
+)
+
+// TokenExchanger exchanges the tokens of sessions for tokens issued for
+// upstreams, with OAuth 2.0 Token Exchange (RFC 8693).
+// Exchanged tokens are cached in memory until shortly before they expire.
+type TokenExchanger struct {
+	// Clock is used to expire cached tokens
+	Clock clock.Clock
+
+	cache *ttlCache[string]
+}
+
+// NewTokenExchanger creates a TokenExchanger with an empty cache
+func NewTokenExchanger() *TokenExchanger {
+	return &TokenExchanger{
+		cache: newTTLCache[string](maxTokenExchangeCacheEntries),
+	}
+}
+
+// ExchangeToken exchanges the token of the session for a token issued for the
+// target, at the token endpoint of the provider that issued the session.
+func (t *TokenExchanger) ExchangeToken(ctx context.Context, p *ProviderData, s *sessions.SessionState, target *options.TokenExchange) (string, error) {
+	subjectToken, subjectTokenType := s.AccessToken, tokenTypeAccessToken
+	if target.SubjectTokenType == "id_token" {
+		subjectToken, subjectTokenType = s.IDToken, tokenTypeIDToken
+	}
+	if subjectToken == "" {
+		return "", fmt.Errorf("session has no %s to exchange", subjectTokenType)
+	}
+
+	params := url.Values{}
+	params.Set("grant_type", tokenExchangeGrantType)
+	params.Set("subject_token", subjectToken)
+	params.Set("subject_token_type", subjectTokenType)
+	params.Set("requested_token_type", tokenTypeAccessToken)
+	if target.Audience != "" {
+		params.Set("audience", target.Audience)
+	}
+	if target.Resource != "" {
+		params.Set("resource", target.Resource)
+	}
+	if len(target.Scopes) > 0 {
+		params.Set("scope", strings.Join(target.Scopes, " "))
+	}
+
+	// The request parameters include the subject token, so they are hashed
+	// for the cache not to hold the tokens themselves
+	key := encryption.HashKey(p.ID, params.Encode())
+	if token, ok := t.cache.lookup(key, t.Clock.Now()); ok {
+		return token, nil
+	}
+
+	token, expires, err := t.exchange(ctx, p, params)
+	if err != nil {
+		return "", err
+	}
+	t.cache.store(key, token, expires, t.Clock.Now())
+	return token, nil
+}
+
+// exchange calls the token endpoint of the provider. It returns the exchanged
+// token along with when it expires from the cache.
+func (t *TokenExchanger) exchange(ctx context.Context, p *ProviderData, params url.Values) (string, time.Time, error) {
+	if p.RedeemURL == nil || p.RedeemURL.String() == "" {
+		return "", time.Time{}, errors.New("provider has no token endpoint")
+	}
+
+	var response struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if _, err := p.postClientForm(ctx, "token exchange", p.RedeemURL.String(), params, &response); err != nil {
+		return "", time.Time{}, err
+	}
+	if response.AccessToken == "" {
+		return "", time.Time{}, errors.New("token exchange response has no access_token")
+	}
+	// Tokens that are not access tokens have the token type "N_A"
+	if response.TokenType != "" && !strings.EqualFold(response.TokenType, "Bearer") {
+		return "", time.Time{}, fmt.Errorf("token exchange issued an unsupported token type %q", response.TokenType)
+	}
+
+	now := t.Clock.Now()
+	expires := now.Add(defaultTokenExchangeCacheTTL)
+	if response.ExpiresIn > 0 {
+		expires = now.Add(time.Duration(response.ExpiresIn)*time.Second - tokenExchangeExpiryDelta)
+	}
+	return response.AccessToken, expires, nil
+}
@@ -0,0 +1,143 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/options"
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/apis/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	tokenExchangeClientID     = "exchange-client"
+	tokenExchangeClientSecret = "exchange-secret"
+)
+
+func newTestTokenExchangeServer(t *testing.T, calls *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		*calls++
+
+		if req.FormValue("client_id") != tokenExchangeClientID || req.FormValue("client_secret") != tokenExchangeClientSecret {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, tokenExchangeGrantType, req.FormValue("grant_type"))
+		assert.Equal(t, tokenTypeAccessToken, req.FormValue("requested_token_type"))
+
+		if req.FormValue("subject_token") == "revoked-token" {
+			rw.WriteHeader(http.StatusBadRequest)
+			_, _ = rw.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		assert.NoError(t, json.NewEncoder(rw).Encode(map[string]interface{}{
+			"access_token": req.FormValue("audience") + ":" + req.FormValue("resource") + ":" + req.FormValue("scope") +
+				":" + req.FormValue("subject_token_type") + ":" + req.FormValue("subject_token"),
+			"issued_token_type": tokenTypeAccessToken,
+			"token_type":        "Bearer",
+			"expires_in":        300,
+		}))
+	}))
+}
+
+func newTestTokenExchanger(now time.Time) *TokenExchanger {
+	exchanger := NewTokenExchanger()
+	exchanger.Clock.Set(now)
+	return exchanger
+}
+
+func newTestTokenExchangeProvider(serverURL string) *ProviderData {
+	redeemURL, _ := url.Parse(serverURL)
+	return &ProviderData{
+		ID:           "keycloak",
+		ClientID:     tokenExchangeClientID,
+		ClientSecret: tokenExchangeClientSecret,
+		RedeemURL:    redeemURL,
+	}
+}
+
+func TestTokenExchangerExchangeToken(t *testing.T) {
+	calls := 0
+	server := newTestTokenExchangeServer(t, &calls)
+	defer server.Close()
+
+	provider := newTestTokenExchangeProvider(server.URL)
+	exchanger := newTestTokenExchanger(time.Now())
+	session := &sessions.SessionState{AccessToken: "access-token", IDToken: "id-token"}
+
+	token, err := exchanger.ExchangeToken(context.Background(), provider, session, &options.TokenExchange{
+		Audience: "orders-api",
+		Resource: "https://orders.example.com",
+		Scopes:   []string{"orders:read", "orders:write"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "orders-api:https://orders.example.com:orders:read orders:write:"+tokenTypeAccessToken+":access-token", token)
+
+	token, err = exchanger.ExchangeToken(context.Background(), provider, session, &options.TokenExchange{
+		Audience:         "billing-api",
+		SubjectTokenType: "id_token",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "billing-api:::"+tokenTypeIDToken+":id-token", token)
+
+	_, err = exchanger.ExchangeToken(context.Background(), provider, &sessions.SessionState{AccessToken: "revoked-token"}, &options.TokenExchange{})
+	assert.ErrorContains(t, err, "token exchange failed: unexpected status 400")
+
+	_, err = exchanger.ExchangeToken(context.Background(), provider, &sessions.SessionState{AccessToken: "access-token"}, &options.TokenExchange{SubjectTokenType: "id_token"})
+	assert.EqualError(t, err, "session has no "+tokenTypeIDToken+" to exchange")
+	assert.Equal(t, 3, calls)
+
+	_, err = exchanger.ExchangeToken(context.Background(), &ProviderData{}, session, &options.TokenExchange{})
+	assert.EqualError(t, err, "provider has no token endpoint")
+}
+
+func TestTokenExchangerCache(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	calls := 0
+	server := newTestTokenExchangeServer(t, &calls)
+	defer server.Close()
+
+	provider := newTestTokenExchangeProvider(server.URL)
+	exchanger := newTestTokenExchanger(now)
+	exchange := func(subjectToken, audience string) {
+		_, err := exchanger.ExchangeToken(context.Background(), provider, &sessions.SessionState{AccessToken: subjectToken}, &options.TokenExchange{Audience: audience})
+		assert.NoError(t, err)
+	}
+
+	// Tokens are cached by subject token and target
+	exchange("access-token", "orders-api")
+	exchange("access-token", "orders-api")
+	exchange("access-token", "billing-api")
+	exchange("other-token", "orders-api")
+	assert.Equal(t, 3, calls)
+
+	// Tokens are exchanged again shortly before they expire
+	exchanger.Clock.Set(now.Add(4 * time.Minute))
+	exchange("access-token", "orders-api")
+	assert.Equal(t, 3, calls)
+	exchanger.Clock.Set(now.Add(5*time.Minute - tokenExchangeExpiryDelta))
+	exchange("access-token", "orders-api")
+	assert.Equal(t, 4, calls)
+}
+
+func TestTokenExchangerUnsupportedTokenType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`{"access_token":"saml-assertion","issued_token_type":"urn:ietf:params:oauth:token-type:saml2","token_type":"N_A"}`))
+	}))
+	defer server.Close()
+
+	exchanger := newTestTokenExchanger(time.Now())
+	_, err := exchanger.ExchangeToken(context.Background(), newTestTokenExchangeProvider(server.URL), &sessions.SessionState{AccessToken: "access-token"}, &options.TokenExchange{})
+	assert.EqualError(t, err, `token exchange issued an unsupported token type "N_A"`)
+}
@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlCache is an in memory cache of values that each expire at their own
+// time. The number of entries is bounded: when the cache is full, expired
+// entries are removed, and if that is not enough the cache is cleared.
+type ttlCache[V any] struct {
+	maxEntries int
+
+	mutex   sync.Mutex
+	entries map[string]ttlCacheEntry[V]
+}
+
+// ttlCacheEntry is a cached value along with when it expires
+type ttlCacheEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// newTTLCache creates an empty cache that holds up to maxEntries values
+func newTTLCache[V any](maxEntries int) *ttlCache[V] {
+	return &ttlCache[V]{
+		maxEntries: maxEntries,
+		entries:    map[string]ttlCacheEntry[V]{},
+	}
+}
+
+// lookup returns the value cached for the key, if it has not expired by now
+func (c *ttlCache[V]) lookup(key string, now time.Time) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if !now.Before(entry.expires) {
+		delete(c.entries, key)
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// store caches the value for the key until it expires
+func (c *ttlCache[V]) store(key string, value V, expires time.Time, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.entries) >= c.maxEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			c.entries = map[string]ttlCacheEntry[V]{}
+		}
+	}
+	c.entries[key] = ttlCacheEntry[V]{value: value, expires: expires}
+}
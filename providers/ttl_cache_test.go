@@ -0,0 +1,53 @@
+package providers
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTLCache(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cache := newTTLCache[string](3)
+
+	cache.store("short", "a", now.Add(time.Minute), now)
+	cache.store("long", "b", now.Add(time.Hour), now)
+
+	value, ok := cache.lookup("short", now)
+	assert.True(t, ok)
+	assert.Equal(t, "a", value)
+
+	// Expired values are not returned
+	_, ok = cache.lookup("short", now.Add(time.Minute))
+	assert.False(t, ok)
+	_, ok = cache.lookup("missing", now)
+	assert.False(t, ok)
+
+	// Expired values are removed when the cache is full
+	cache.store("expiring", "c", now.Add(time.Minute), now)
+	cache.store("other", "d", now.Add(time.Hour), now)
+	cache.store("full", "e", now.Add(time.Hour), now.Add(2*time.Minute))
+	for _, key := range []string{"long", "other", "full"} {
+		_, ok := cache.lookup(key, now.Add(2*time.Minute))
+		assert.True(t, ok, key)
+	}
+
+	// The cache is cleared when no value has expired
+	cache.store("new", "f", now.Add(time.Hour), now.Add(2*time.Minute))
+	_, ok = cache.lookup("long", now.Add(2*time.Minute))
+	assert.False(t, ok)
+	value, ok = cache.lookup("new", now.Add(2*time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, "f", value)
+}
+
+func TestTTLCacheBounded(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cache := newTTLCache[int](10)
+	for i := 0; i < 25; i++ {
+		cache.store(strconv.Itoa(i), i, now.Add(time.Hour), now)
+		assert.LessOrEqual(t, len(cache.entries), 10)
+	}
+}
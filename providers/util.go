@@ -2,10 +2,12 @@ package providers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 
+	"github.com/oauth2-proxy/oauth2-proxy/v7/pkg/requests"
 	"golang.org/x/oauth2"
 )
 
@@ -17,6 +19,37 @@ const (
 	acceptApplicationJSON = "application/json"
 )
 
+// invalidGrantErrorCode is the error code of a token response rejecting the
+// grant, such as an expired, revoked or already used refresh token
+const invalidGrantErrorCode = "invalid_grant"
+
+// checkRefreshTokenResult returns ErrRefreshTokenRejected when the response
+// of a refresh token request rejected the refresh token
+func checkRefreshTokenResult(result requests.Result) error {
+	if result.Error() != nil || result.StatusCode() != http.StatusBadRequest {
+		return nil
+	}
+
+	var data struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(result.Body(), &data); err != nil || data.Error != invalidGrantErrorCode {
+		return nil
+	}
+	return fmt.Errorf("%w: %s %s", ErrRefreshTokenRejected, data.Error, data.ErrorDescription)
+}
+
+// checkRefreshTokenError wraps the error of an oauth2 refresh token request
+// in ErrRefreshTokenRejected when it rejected the refresh token
+func checkRefreshTokenError(err error) error {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == invalidGrantErrorCode {
+		return fmt.Errorf("%w: %v", ErrRefreshTokenRejected, err)
+	}
+	return err
+}
+
 func makeAuthorizationHeader(prefix, token string, extraHeaders map[string]string) http.Header {
 	header := make(http.Header)
 	for key, value := range extraHeaders {